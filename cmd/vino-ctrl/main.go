@@ -0,0 +1,134 @@
+//go:build windows
+
+// vino-ctrl raises a console control event in Windows processes. vino runs
+// it under wine to deliver the CTRL_BREAK_EVENT and CTRL_CLOSE_EVENT that
+// SIGTERM and SIGHUP stand for, which wine has no way to raise from outside
+// the process.
+//
+//	vino-ctrl IMAGE EVENT
+//
+// raises EVENT, one of CTRL_C_EVENT, CTRL_BREAK_EVENT or CTRL_CLOSE_EVENT, in
+// every process running IMAGE.
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+var events = map[string]uint32{
+	"CTRL_C_EVENT":     windows.CTRL_C_EVENT,
+	"CTRL_BREAK_EVENT": windows.CTRL_BREAK_EVENT,
+	"CTRL_CLOSE_EVENT": windows.CTRL_CLOSE_EVENT,
+}
+
+// handlerTimeout bounds how long a process' control handlers may take
+// before vino-ctrl stops waiting for them.
+const handlerTimeout = 5 * time.Second
+
+var (
+	kernel32               = windows.NewLazySystemDLL("kernel32.dll")
+	procCtrlRoutine        = kernel32.NewProc("CtrlRoutine")
+	procCreateRemoteThread = kernel32.NewProc("CreateRemoteThread")
+)
+
+func main() {
+	if len(os.Args) != 3 {
+		fmt.Fprintln(os.Stderr, "usage: vino-ctrl IMAGE EVENT")
+		os.Exit(2)
+	}
+	event, ok := events[os.Args[2]]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "vino-ctrl: unknown event %q\n", os.Args[2])
+		os.Exit(2)
+	}
+	if err := raiseImage(os.Args[1], event); err != nil {
+		fmt.Fprintf(os.Stderr, "vino-ctrl: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// raiseImage raises event in every process running image.
+func raiseImage(image string, event uint32) error {
+	pids, err := processes(image)
+	if err != nil {
+		return err
+	}
+	if len(pids) == 0 {
+		return fmt.Errorf("no process runs %s", image)
+	}
+	var errs []error
+	for _, pid := range pids {
+		if err := raise(pid, event); err != nil {
+			errs = append(errs, fmt.Errorf("process %d: %w", pid, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// processes returns the IDs of the processes running image, compared
+// case-insensitively like Windows does.
+func processes(image string) ([]uint32, error) {
+	snapshot, err := windows.CreateToolhelp32Snapshot(windows.TH32CS_SNAPPROCESS, 0)
+	if err != nil {
+		return nil, fmt.Errorf("list processes: %w", err)
+	}
+	defer windows.CloseHandle(snapshot)
+
+	var (
+		pids  []uint32
+		entry = windows.ProcessEntry32{Size: uint32(unsafe.Sizeof(windows.ProcessEntry32{}))}
+	)
+	for err = windows.Process32First(snapshot, &entry); err == nil; err = windows.Process32Next(snapshot, &entry) {
+		if entry.ProcessID != windows.GetCurrentProcessId() && strings.EqualFold(windows.UTF16ToString(entry.ExeFile[:]), image) {
+			pids = append(pids, entry.ProcessID)
+		}
+	}
+	if !errors.Is(err, windows.ERROR_NO_MORE_FILES) {
+		return nil, fmt.Errorf("list processes: %w", err)
+	}
+	return pids, nil
+}
+
+// raise runs the control handlers of process pid for event, the way the
+// console does: on a new thread of the process starting at kernel32's
+// CtrlRoutine, which is mapped at the same address in every process of a
+// session. Wine raises CTRL_C_EVENT on SIGINT the same way.
+func raise(pid, event uint32) error {
+	if err := procCtrlRoutine.Find(); err != nil {
+		return err
+	}
+	process, err := windows.OpenProcess(windows.PROCESS_CREATE_THREAD|windows.PROCESS_QUERY_INFORMATION|windows.PROCESS_VM_OPERATION|windows.PROCESS_VM_WRITE|windows.PROCESS_VM_READ, false, pid)
+	if err != nil {
+		return fmt.Errorf("open process: %w", err)
+	}
+	defer windows.CloseHandle(process)
+
+	// CtrlRoutine lives elsewhere in processes of another bitness.
+	var self, target bool
+	if err := windows.IsWow64Process(windows.CurrentProcess(), &self); err != nil {
+		return err
+	}
+	if err := windows.IsWow64Process(process, &target); err != nil {
+		return err
+	}
+	if self != target {
+		return errors.New("process has another bitness than vino-ctrl")
+	}
+
+	thread, _, err := procCreateRemoteThread.Call(uintptr(process), 0, 0, procCtrlRoutine.Addr(), uintptr(event), 0, 0)
+	if thread == 0 {
+		return fmt.Errorf("create thread: %w", err)
+	}
+	defer windows.CloseHandle(windows.Handle(thread))
+	if _, err := windows.WaitForSingleObject(windows.Handle(thread), uint32(handlerTimeout.Milliseconds())); err != nil {
+		return fmt.Errorf("wait for handlers: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,82 @@
+//go:build windows
+
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"os/exec"
+	"testing"
+	"time"
+
+	"golang.org/x/sys/windows"
+)
+
+// Run under wine with: GOOS=windows go test -exec wine ./cmd/vino-ctrl
+
+const childEnv = "VINO_CTRL_TEST_CHILD"
+
+// TestMain turns the test binary into a console process reporting the
+// control events its handlers receive, when started by TestRaise.
+func TestMain(m *testing.M) {
+	if os.Getenv(childEnv) == "" {
+		os.Exit(m.Run())
+	}
+	names := map[uint32]string{}
+	for name, event := range events {
+		names[event] = name
+	}
+	handler := windows.NewCallback(func(event uint32) uintptr {
+		fmt.Println(names[event])
+		return 1
+	})
+	if r, _, err := kernel32.NewProc("SetConsoleCtrlHandler").Call(handler, 1); r == 0 {
+		fmt.Fprintln(os.Stderr, "SetConsoleCtrlHandler:", err)
+		os.Exit(1)
+	}
+	fmt.Println("ready")
+	time.Sleep(time.Minute)
+	os.Exit(1)
+}
+
+func TestRaise(t *testing.T) {
+	for name, event := range events {
+		t.Run(name, func(t *testing.T) {
+			child := exec.Command(os.Args[0])
+			child.Env = append(os.Environ(), childEnv+"=1")
+			child.Stderr = os.Stderr
+			stdout, err := child.StdoutPipe()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := child.Start(); err != nil {
+				t.Fatal(err)
+			}
+			t.Cleanup(func() {
+				child.Process.Kill()
+				child.Wait()
+			})
+			lines := bufio.NewScanner(stdout)
+			if !lines.Scan() || lines.Text() != "ready" {
+				t.Fatalf("child didn't start: %q, %v", lines.Text(), lines.Err())
+			}
+
+			if err := raise(uint32(child.Process.Pid), event); err != nil {
+				t.Fatalf("raise: %v", err)
+			}
+			if !lines.Scan() {
+				t.Fatalf("child received nothing: %v", lines.Err())
+			}
+			if got := lines.Text(); got != name {
+				t.Errorf("child received %s, want %s", got, name)
+			}
+		})
+	}
+}
+
+func TestRaiseImageWithoutProcess(t *testing.T) {
+	if err := raiseImage("no-such-image.exe", windows.CTRL_BREAK_EVENT); err == nil {
+		t.Errorf("raiseImage succeeded without a process running the image")
+	}
+}
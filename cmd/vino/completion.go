@@ -0,0 +1,46 @@
+package main
+
+import (
+	"os"
+
+	"github.com/TheGrizzlyDev/vino/internal/pkg/cli"
+	"github.com/TheGrizzlyDev/vino/internal/pkg/runc"
+)
+
+type CompletionCommand struct {
+	Shell string `cli_argument:"shell" cli_help:"Shell to complete vino in: bash, zsh or fish."`
+}
+
+func (CompletionCommand) Description() string {
+	return "print the script completing vino command lines in a shell"
+}
+
+func (CompletionCommand) Slots() cli.Slot {
+	return cli.Group{
+		Ordered: []cli.Slot{
+			cli.Subcommand{Value: "completion"},
+			cli.Argument{Name: "shell"},
+		},
+	}
+}
+
+// CompletionMain writes the completion script of the shell to stdout.
+func CompletionMain(cmd CompletionCommand) error {
+	return cli.WriteCompletion(os.Stdout, cmd.Shell, "vino", completionTree())
+}
+
+// completionTree models the vino command line: its commands and the unions
+// those dispatching their arguments hand them to.
+func completionTree() cli.Tree {
+	nested := map[string][]cli.Command{
+		"runc":             cli.Members[runc.RuncCommands](),
+		"oci-runtime-hook": cli.Members[HookCommands](),
+		"metrics":          cli.Members[MetricsCommands](),
+		"display":          cli.Members[DisplayCommands](),
+	}
+	root := cli.Tree{Command: CommonCommand{}}
+	for _, c := range cli.Members[VinocCommands]() {
+		root.Children = append(root.Children, cli.Tree{Command: c, Children: cli.Leaves(nested[cli.SubcommandOf(c)]...)})
+	}
+	return root
+}
@@ -0,0 +1,237 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image/png"
+	"io"
+	"log/slog"
+	"net"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"golang.org/x/sys/unix"
+
+	"github.com/TheGrizzlyDev/vino/internal/pkg/cli"
+	"github.com/TheGrizzlyDev/vino/internal/pkg/runc"
+	"github.com/TheGrizzlyDev/vino/internal/pkg/vino/launcher"
+	"github.com/TheGrizzlyDev/vino/internal/pkg/vino/rootfs"
+	"github.com/TheGrizzlyDev/vino/internal/pkg/xwd"
+)
+
+type DisplayCommand struct {
+	DisplayArgs []string `cli_argument:"args"`
+}
+
+func (DisplayCommand) Description() string {
+	return "look at and use the virtual display of a container"
+}
+
+func (DisplayCommand) Slots() cli.Slot {
+	return cli.Group{
+		Ordered: []cli.Slot{
+			cli.Subcommand{Value: "display"},
+			cli.Arguments{Name: "args"},
+		},
+	}
+}
+
+type DisplayScreenshotCommand struct {
+	DelegatePath string `cli_flag:"--delegate_path" cli_group:"vinoc" cli_env:"VINO_DELEGATE_PATH" cli_help:"Path of the OCI runtime vino delegates to."`
+	Root         string `cli_flag:"--root" cli_group:"vinoc" cli_env:"VINO_ROOT" cli_help:"Root directory of the runtime's container state."`
+	Output       string `cli_flag:"--output" cli_flag_alternatives:"-o" cli_group:"screenshot" cli_default:"-" cli_help:"PNG file the screenshot is written to, - for stdout."`
+	ContainerID  string `cli_argument:"container_id"`
+}
+
+func (DisplayScreenshotCommand) Description() string {
+	return "write a PNG screenshot of a container's display"
+}
+
+func (DisplayScreenshotCommand) Slots() cli.Slot {
+	return cli.Group{
+		Unordered: []cli.Slot{
+			cli.FlagGroup{Name: "vinoc"},
+			cli.FlagGroup{Name: "screenshot"},
+		},
+		Ordered: []cli.Slot{
+			cli.Subcommand{Value: "screenshot"},
+			cli.Argument{Name: "container_id"},
+		},
+	}
+}
+
+type DisplayVNCCommand struct {
+	DelegatePath string `cli_flag:"--delegate_path" cli_group:"vinoc" cli_env:"VINO_DELEGATE_PATH" cli_help:"Path of the OCI runtime vino delegates to."`
+	Root         string `cli_flag:"--root" cli_group:"vinoc" cli_env:"VINO_ROOT" cli_help:"Root directory of the runtime's container state."`
+	// Listen is a host:port, or a unix socket when it is an absolute path.
+	Listen      string `cli_flag:"--listen" cli_group:"vnc" cli_default:"127.0.0.1:5900" cli_help:"host:port, or absolute path of a unix socket, VNC clients connect to."`
+	ContainerID string `cli_argument:"container_id"`
+}
+
+func (DisplayVNCCommand) Description() string {
+	return "serve a container's display to VNC clients"
+}
+
+func (DisplayVNCCommand) Slots() cli.Slot {
+	return cli.Group{
+		Unordered: []cli.Slot{
+			cli.FlagGroup{Name: "vinoc"},
+			cli.FlagGroup{Name: "vnc"},
+		},
+		Ordered: []cli.Slot{
+			cli.Subcommand{Value: "vnc"},
+			cli.Argument{Name: "container_id"},
+		},
+	}
+}
+
+type DisplayCommands struct {
+	Screenshot *DisplayScreenshotCommand
+	VNC        *DisplayVNCCommand
+}
+
+func DisplayMain(cmd DisplayCommand, config []cli.Source) error {
+	var cmds DisplayCommands
+	if err := cli.ParseAny(&cmds, cmd.DisplayArgs, config...); err != nil {
+		return cli.Nest("display", err)
+	}
+	switch {
+	case cmds.Screenshot != nil:
+		return DisplayScreenshotMain(*cmds.Screenshot)
+	case cmds.VNC != nil:
+		return DisplayVNCMain(*cmds.VNC)
+	}
+	return fmt.Errorf("display subcommand not supported: %v", cmd.DisplayArgs)
+}
+
+// maxFramebuffer bounds the size of a framebuffer file read: the pixels of
+// the largest screen xwd decodes, plus room for its header and colormap.
+const maxFramebuffer = xwd.MaxDimension*xwd.MaxDimension*4 + 1<<20
+
+// containerRoot returns the host path of the root of the running container
+// id. The container controls what is under it: resolve paths there with
+// the rootfs helpers.
+func containerRoot(ctx context.Context, delegatePath, root, id string) (string, error) {
+	delegate, err := runc.NewDelegatingCliClient(delegatePath)
+	if err != nil {
+		return "", fmt.Errorf("failed to create delegating client: %w", err)
+	}
+	state, err := runningContainer(ctx, delegate, runc.Global{Root: root}, id)
+	if err != nil {
+		return "", err
+	}
+	if state.Pid <= 0 {
+		return "", fmt.Errorf("container %s has no init process", id)
+	}
+	return filepath.Join("/proc", strconv.Itoa(state.Pid), "root"), nil
+}
+
+// DisplayScreenshotMain writes the screen of a container's virtual display
+// as a PNG image.
+func DisplayScreenshotMain(cmd DisplayScreenshotCommand) error {
+	root, err := containerRoot(context.Background(), cmd.DelegatePath, cmd.Root, cmd.ContainerID)
+	if err != nil {
+		return err
+	}
+	data, err := rootfs.ReadFile(root, filepath.Join(launcher.DisplayStateDir, launcher.FramebufferFile), maxFramebuffer)
+	if errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("container %s has no virtual display", cmd.ContainerID)
+	}
+	if err != nil {
+		return err
+	}
+	img, err := xwd.Decode(bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("read framebuffer: %w", err)
+	}
+
+	if cmd.Output == "" || cmd.Output == "-" {
+		return png.Encode(os.Stdout, img)
+	}
+	out, err := os.Create(cmd.Output)
+	if err != nil {
+		return err
+	}
+	if err := png.Encode(out, img); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// DisplayVNCMain forwards connections on a host address to the VNC server
+// of a container's virtual display until interrupted.
+func DisplayVNCMain(cmd DisplayVNCCommand) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, unix.SIGTERM)
+	defer stop()
+
+	root, err := containerRoot(ctx, cmd.DelegatePath, cmd.Root, cmd.ContainerID)
+	if err != nil {
+		return err
+	}
+	socket := filepath.Join(launcher.DisplayStateDir, launcher.VNCSocket)
+	if conn, err := rootfs.Dial(root, socket); err != nil {
+		return fmt.Errorf("container %s does not serve its display over VNC: %w", cmd.ContainerID, err)
+	} else {
+		conn.Close()
+	}
+
+	listen := cmd.Listen
+	network := "tcp"
+	if strings.HasPrefix(listen, "/") {
+		network = "unix"
+		os.Remove(listen)
+		defer os.Remove(listen)
+	}
+	ln, err := net.Listen(network, listen)
+	if err != nil {
+		return err
+	}
+	go func() {
+		<-ctx.Done()
+		ln.Close()
+	}()
+	slog.Info("forwarding VNC", "container", cmd.ContainerID, "listen", listen)
+
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+		go forwardVNC(conn, root, socket)
+	}
+}
+
+// forwardVNC copies between a client and the VNC socket, resolved inside
+// root, until either side closes.
+func forwardVNC(client net.Conn, root, socket string) {
+	defer client.Close()
+	server, err := rootfs.Dial(root, socket)
+	if err != nil {
+		slog.Warn("connect to VNC server", "socket", socket, "error", err)
+		return
+	}
+	defer server.Close()
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	pipe := func(dst, src net.Conn) {
+		defer wg.Done()
+		io.Copy(dst, src)
+		if cw, ok := dst.(interface{ CloseWrite() error }); ok {
+			cw.CloseWrite()
+		}
+	}
+	go pipe(server, client)
+	go pipe(client, server)
+	wg.Wait()
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"golang.org/x/sys/unix"
+
+	"github.com/TheGrizzlyDev/vino/internal/pkg/cli"
+	"github.com/TheGrizzlyDev/vino/internal/pkg/logging"
+	"github.com/TheGrizzlyDev/vino/internal/pkg/runc"
+	"github.com/TheGrizzlyDev/vino/internal/pkg/vino"
+	"github.com/TheGrizzlyDev/vino/internal/pkg/vino/events"
+	"github.com/TheGrizzlyDev/vino/internal/pkg/vino/hook"
+	"github.com/TheGrizzlyDev/vino/internal/pkg/vino/labels"
+	"github.com/TheGrizzlyDev/vino/internal/pkg/vino/usb"
+)
+
+type HotplugCommand struct {
+	DelegatePath string `cli_flag:"--delegate_path" cli_group:"vinoc" cli_env:"VINO_DELEGATE_PATH" cli_help:"Path of the OCI runtime vino delegates to."`
+	Root         string `cli_flag:"--root" cli_group:"vinoc" cli_env:"VINO_ROOT" cli_help:"Root directory of the runtime's container state."`
+	ContainerID  string `cli_argument:"container_id"`
+}
+
+func (HotplugCommand) Description() string {
+	return "pass USB devices through to a running container as they are plugged in"
+}
+
+func (HotplugCommand) Slots() cli.Slot {
+	return cli.Group{
+		Unordered: []cli.Slot{
+			cli.FlagGroup{Name: "vinoc"},
+		},
+		Ordered: []cli.Slot{
+			cli.Subcommand{Value: "hotplug"},
+			cli.Argument{Name: "container_id"},
+		},
+	}
+}
+
+// HotplugMain passes the USB devices a container asks for through to it as
+// they are plugged in and out, until the container exits or the command is
+// interrupted.
+func HotplugMain(cmd HotplugCommand) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, unix.SIGTERM)
+	defer stop()
+
+	delegate, err := runc.NewDelegatingCliClient(cmd.DelegatePath)
+	if err != nil {
+		return fmt.Errorf("failed to create delegating client: %w", err)
+	}
+	state, err := runningContainer(ctx, delegate, runc.Global{Root: cmd.Root}, cmd.ContainerID)
+	if err != nil {
+		return err
+	}
+	devices, _, err := labels.Parse(state.Annotations)
+	if err != nil {
+		return fmt.Errorf("parse annotations: %w", err)
+	}
+	var bindings []usb.Binding
+	for _, d := range devices {
+		if d.Class != labels.ClassUSB {
+			continue
+		}
+		id, err := usb.ParseID(d.ID)
+		if err != nil {
+			return err
+		}
+		access := vino.DeviceAccess(d)
+		bindings = append(bindings, usb.Binding{ID: id, Label: d.Label, Access: access.Cgroup, FileMode: access.FileMode})
+	}
+	if len(bindings) == 0 {
+		return fmt.Errorf("container %s has no usb devices", cmd.ContainerID)
+	}
+
+	logger := slog.Default().With(logging.KeyContainerID, cmd.ContainerID)
+	root := filepath.Join("/proc", strconv.Itoa(state.Pid), "root")
+	w := &usb.Watcher{
+		Bindings: bindings,
+		Container: &usb.Container{
+			Root:          root,
+			Prefix:        containerPrefix(state.Bundle),
+			DevicesCgroup: usb.DevicesCgroup("/proc", "/sys/fs/cgroup", state.Pid),
+		},
+		Logger: logger,
+		// Report the changes in the container's journal, for `runc events`.
+		Changed: func(action string, b usb.Binding, n usb.Node) {
+			d := events.Device{Action: action, Class: labels.ClassUSB, ID: b.ID.String(), Node: n.Path, Label: b.Label}
+			if err := events.Append(root, events.JournalPath, events.TypeDevice, d); err != nil {
+				logger.Warn("record device event", "error", err)
+			}
+		},
+	}
+
+	// Listen before syncing so that no device plugged in meanwhile is missed.
+	mon, err := usb.Listen()
+	if err != nil {
+		return err
+	}
+	if err := w.Sync(); err != nil {
+		mon.Close()
+		return err
+	}
+	done := make(chan error, 1)
+	go func() { done <- w.Run(mon) }()
+	logger.Info("watching usb devices", "devices", len(bindings))
+
+	t := time.NewTicker(time.Second)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			mon.Close()
+			<-done
+			return nil
+		case err := <-done:
+			mon.Close()
+			return fmt.Errorf("watch usb devices: %w", err)
+		case <-t.C:
+			if err := unix.Kill(state.Pid, 0); errors.Is(err, unix.ESRCH) {
+				logger.Info("container exited, stopping")
+				mon.Close()
+				<-done
+				return nil
+			}
+		}
+	}
+}
+
+// containerPrefix returns the WINEPREFIX of the container whose bundle is
+// bundleDir, or "" when it can't be told.
+func containerPrefix(bundleDir string) string {
+	c, err := hook.FromBundle(bundleDir)
+	if err != nil {
+		return ""
+	}
+	return c.WinePrefix
+}
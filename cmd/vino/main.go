@@ -5,23 +5,54 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"log"
+	"io"
+	"log/slog"
+	"net/http"
 	"os"
 	"os/exec"
+	"os/signal"
+	"path/filepath"
 	"strings"
+	"sync"
+	"syscall"
+	"time"
 
 	"github.com/TheGrizzlyDev/vino/internal/pkg/cli"
+	"github.com/TheGrizzlyDev/vino/internal/pkg/logging"
+	"github.com/TheGrizzlyDev/vino/internal/pkg/metrics"
 	"github.com/TheGrizzlyDev/vino/internal/pkg/runc"
+	"github.com/TheGrizzlyDev/vino/internal/pkg/tracing"
 	"github.com/TheGrizzlyDev/vino/internal/pkg/vino"
+	"github.com/TheGrizzlyDev/vino/internal/pkg/vino/events"
 	"github.com/TheGrizzlyDev/vino/internal/pkg/vino/hook"
 	"github.com/TheGrizzlyDev/vino/internal/pkg/vino/labels"
+	"github.com/TheGrizzlyDev/vino/internal/pkg/vino/launcher"
+	"github.com/TheGrizzlyDev/vino/internal/pkg/vino/rootfs"
+	"github.com/TheGrizzlyDev/vino/internal/pkg/vino/winexit"
 	"github.com/opencontainers/runtime-spec/specs-go"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/sys/unix"
 )
 
 const (
 	VINO_AFTER_PIVOT_PATH = "/run/vino"
+	// VINO_CTRL_AFTER_PIVOT_PATH is where vino-ctrl.exe, installed next to
+	// vino, is bound inside containers.
+	VINO_CTRL_AFTER_PIVOT_PATH = "/run/vino-ctrl.exe"
 )
 
+const tracerName = "github.com/TheGrizzlyDev/vino/cmd/vino"
+
+// metricsSocketInContainer is where the metrics socket is bound inside
+// containers, so that launchers can report too.
+const metricsSocketInContainer = "/run/vino-metrics.sock"
+
+// maxRelayedLog bounds what is relayed of the log of a container's
+// launchers: its end.
+const maxRelayedLog = 1 << 20
+
 var (
 	vinoHookCreateLogPath = "/var/log/vino-hook-create.log"
 	vinoHookStartLogPath  = "/var/log/vino-hook-start.log"
@@ -29,8 +60,12 @@ var (
 )
 
 type CommonCommand struct {
-	VinocLogPath *string  `cli_flag:"--vinoc_log_path" cli_group:"common"`
-	VinoArgs     []string `cli_argument:"args"`
+	Config         *string  `cli_flag:"--vinoc_config" cli_group:"common" cli_env:"VINO_CONFIG" cli_help:"File of key = value lines setting flags left off the command line, keyed by flag name without dashes."`
+	VinocLogPath   *string  `cli_flag:"--vinoc_log_path" cli_group:"common" cli_env:"VINO_LOG_PATH" cli_help:"File vino logs to, instead of the runc --log file or stderr."`
+	VinocLogFormat *string  `cli_flag:"--vinoc_log_format" cli_group:"common" cli_enum:"text|json" cli_env:"VINO_LOG_FORMAT" cli_help:"Format of the log records."`
+	OTLPEndpoint   *string  `cli_flag:"--vinoc_otlp_endpoint" cli_group:"common" cli_env:"VINO_OTLP_ENDPOINT" cli_help:"OTLP endpoint traces are exported to."`
+	MetricsSocket  *string  `cli_flag:"--vinoc_metrics_socket" cli_group:"common" cli_env:"VINO_METRICS_SOCKET" cli_help:"Socket of the vino metrics server samples are sent to."`
+	VinoArgs       []string `cli_argument:"args"`
 }
 
 func (CommonCommand) Slots() cli.Slot {
@@ -43,10 +78,14 @@ func (CommonCommand) Slots() cli.Slot {
 }
 
 type RuncCommand struct {
-	DelegatePath string   `cli_flag:"--delegate_path" cli_group:"vinoc"`
+	DelegatePath string   `cli_flag:"--delegate_path" cli_group:"vinoc" cli_env:"VINO_DELEGATE_PATH" cli_help:"Path of the OCI runtime vino delegates to."`
 	RuncArgs     []string `cli_argument:"args"`
 }
 
+func (RuncCommand) Description() string {
+	return "run an OCI runtime command, running containers under wine"
+}
+
 func (RuncCommand) Slots() cli.Slot {
 	return cli.Group{
 		Ordered: []cli.Slot{
@@ -58,12 +97,22 @@ func (RuncCommand) Slots() cli.Slot {
 }
 
 type HookCommand struct {
+	// Trace is the trace context of the vino invocation that installed the
+	// hook, as TRACEPARENT=... and TRACESTATE=... assignments. It comes as
+	// arguments since a hook's env would replace its whole environment.
+	Trace    []string `cli_flag:"--trace" cli_group:"hook" cli_help:"Trace context to continue, as TRACEPARENT=VALUE or TRACESTATE=VALUE."`
 	HookArgs []string `cli_argument:"args"`
 }
 
+func (HookCommand) Description() string {
+	return "set up a container's wine prefix, as an OCI runtime hook"
+}
+
 func (HookCommand) Slots() cli.Slot {
 	return cli.Group{
-		Unordered: []cli.Slot{},
+		Unordered: []cli.Slot{
+			cli.FlagGroup{Name: "hook"},
+		},
 		Ordered: []cli.Slot{
 			cli.Subcommand{Value: "oci-runtime-hook"},
 			cli.Arguments{Name: "args"},
@@ -73,6 +122,10 @@ func (HookCommand) Slots() cli.Slot {
 
 type HookCreateCommand struct{}
 
+func (HookCreateCommand) Description() string {
+	return "run the createContainer hook"
+}
+
 func (HookCreateCommand) Slots() cli.Slot {
 	return cli.Group{
 		Ordered: []cli.Slot{
@@ -83,6 +136,10 @@ func (HookCreateCommand) Slots() cli.Slot {
 
 type HookStartCommand struct{}
 
+func (HookStartCommand) Description() string {
+	return "run the startContainer hook"
+}
+
 func (HookStartCommand) Slots() cli.Slot {
 	return cli.Group{
 		Ordered: []cli.Slot{
@@ -97,12 +154,42 @@ type HookCommands struct {
 }
 
 type WineLauncherCommand struct {
-	Args []string `cli_argument:"args"`
+	// StatusFile is where the launcher records how the Windows process
+	// ended. Only the container's main process sets it.
+	StatusFile string `cli_flag:"--status_file" cli_group:"launcher" cli_help:"File the exit status of the Windows process is recorded in."`
+	// Service runs Args as the Windows service of that name instead of as a
+	// plain process. Only the container's main process sets it, from the
+	// dev.vinoc.process.service annotation.
+	Service string `cli_flag:"--service" cli_group:"launcher" cli_help:"Run the program as the Windows service of this name."`
+	// OwnDisplay makes the launcher start the container's virtual display.
+	// Only the container's main process sets it.
+	OwnDisplay bool `cli_flag:"--own_display" cli_group:"launcher" cli_help:"Start the container's virtual display."`
+	// OwnAudio makes the launcher set up the container's audio: wine's
+	// audio driver and, without a host socket, the null sink. Only the
+	// container's main process sets it.
+	OwnAudio bool `cli_flag:"--own_audio" cli_group:"launcher" cli_help:"Set up the container's audio."`
+	// OwnPrinters makes the launcher spool the print jobs of LPT ports
+	// backed by CUPS. Only the container's main process sets it.
+	OwnPrinters bool `cli_flag:"--own_printers" cli_group:"launcher" cli_help:"Spool the print jobs of the LPT ports backed by CUPS."`
+	// EventsFile is the journal the launcher records the end of the
+	// Windows process in, for `runc events`.
+	EventsFile string `cli_flag:"--events_file" cli_group:"launcher" cli_help:"Journal the container's events are recorded in."`
+	// WatchWineserver makes the launcher record the wineserver of the
+	// prefix starting and crashing in EventsFile. Only the container's main
+	// process sets it.
+	WatchWineserver bool     `cli_flag:"--watch_wineserver" cli_group:"launcher" cli_help:"Record the wineserver starting and crashing."`
+	Args            []string `cli_argument:"args" cli_help:"Windows program and its arguments."`
+}
+
+func (WineLauncherCommand) Description() string {
+	return "run a Windows program under wine, inside a container"
 }
 
 func (WineLauncherCommand) Slots() cli.Slot {
 	return cli.Group{
-		Unordered: []cli.Slot{},
+		Unordered: []cli.Slot{
+			cli.FlagGroup{Name: "launcher"},
+		},
 		Ordered: []cli.Slot{
 			cli.Subcommand{Value: "wine-launcher"},
 			cli.Arguments{Name: "args"},
@@ -110,10 +197,91 @@ func (WineLauncherCommand) Slots() cli.Slot {
 	}
 }
 
+type ExecCommand struct {
+	DelegatePath string   `cli_flag:"--delegate_path" cli_group:"vinoc" cli_env:"VINO_DELEGATE_PATH" cli_help:"Path of the OCI runtime vino delegates to."`
+	Root         string   `cli_flag:"--root" cli_group:"vinoc" cli_env:"VINO_ROOT" cli_help:"Root directory of the runtime's container state."`
+	Cwd          string   `cli_flag:"--cwd" cli_group:"process" cli_help:"Working directory of the program."`
+	Env          []string `cli_flag:"--env" cli_flag_alternatives:"-e" cli_group:"process" cli_help:"KEY=VALUE set in the program's environment."`
+	Tty          bool     `cli_flag:"--tty" cli_flag_alternatives:"-t" cli_group:"process" cli_help:"Allocate a terminal."`
+	ContainerID  string   `cli_argument:"container_id" cli_help:"Running container to execute the program in."`
+	Command      string   `cli_argument:"command" cli_help:"Windows program to execute."`
+	Args         []string `cli_argument:"args"`
+}
+
+func (ExecCommand) Description() string {
+	return "run a Windows program in a running container"
+}
+
+func (ExecCommand) Slots() cli.Slot {
+	return cli.Group{
+		Unordered: []cli.Slot{
+			cli.FlagGroup{Name: "vinoc"},
+			cli.FlagGroup{Name: "process"},
+		},
+		Ordered: []cli.Slot{
+			cli.Subcommand{Value: "exec"},
+			cli.Argument{Name: "container_id"},
+			cli.Group{
+				Ordered: []cli.Slot{
+					cli.Literal{Value: "--"},
+					cli.Argument{Name: "command"},
+					cli.Arguments{Name: "args"},
+				},
+			},
+		},
+	}
+}
+
+type MetricsCommand struct {
+	MetricsArgs []string `cli_argument:"args"`
+}
+
+func (MetricsCommand) Description() string {
+	return "collect and expose the metrics of vino processes"
+}
+
+func (MetricsCommand) Slots() cli.Slot {
+	return cli.Group{
+		Ordered: []cli.Slot{
+			cli.Subcommand{Value: "metrics"},
+			cli.Arguments{Name: "args"},
+		},
+	}
+}
+
+type MetricsServeCommand struct {
+	Socket string `cli_flag:"--socket" cli_group:"serve" cli_default:"/run/vino-metrics/metrics.sock" cli_help:"Socket vino processes send their samples to."`
+	Listen string `cli_flag:"--listen" cli_group:"serve" cli_default:"127.0.0.1:9464" cli_help:"Address /metrics is served on."`
+}
+
+func (MetricsServeCommand) Description() string {
+	return "serve the samples of vino processes on /metrics"
+}
+
+func (MetricsServeCommand) Slots() cli.Slot {
+	return cli.Group{
+		Unordered: []cli.Slot{
+			cli.FlagGroup{Name: "serve"},
+		},
+		Ordered: []cli.Slot{
+			cli.Subcommand{Value: "serve"},
+		},
+	}
+}
+
+type MetricsCommands struct {
+	Serve *MetricsServeCommand
+}
+
 type VinocCommands struct {
-	Runc     *RuncCommand
-	Hook     *HookCommand
-	Launcher *WineLauncherCommand
+	Runc       *RuncCommand
+	Hook       *HookCommand
+	Launcher   *WineLauncherCommand
+	Exec       *ExecCommand
+	Metrics    *MetricsCommand
+	Display    *DisplayCommand
+	Hotplug    *HotplugCommand
+	Completion *CompletionCommand
 }
 
 func main() {
@@ -122,9 +290,18 @@ func main() {
 		os.Exit(0)
 	}
 
-	log.Println(err)
+	var he *cli.HelpError
+	if errors.As(cli.Nest("vino", err), &he) {
+		fmt.Print(he.Usage())
+		os.Exit(0)
+	}
+
 	fmt.Println(err)
 
+	var we *winexit.ExitError
+	if errors.As(err, &we) {
+		os.Exit(we.ExitCode())
+	}
 	var ee *exec.ExitError
 	if errors.As(err, &ee) {
 		if status, ok := ee.ProcessState.Sys().(interface{ ExitStatus() int }); ok {
@@ -134,83 +311,327 @@ func main() {
 	os.Exit(1)
 }
 
-func run(args []string) error {
-	var common CommonCommand
-	if err := cli.Parse(&common, os.Args[1:]); err != nil {
+func run(args []string) (err error) {
+	common, config, err := parseCommon(args)
+	if err != nil {
 		return err
 	}
-	if common.VinocLogPath != nil {
-		f, err := os.OpenFile(*common.VinocLogPath, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0666)
-		if err != nil {
-			return err
+
+	var vinocCommands VinocCommands
+	parseErr := cli.ParseAny(&vinocCommands, common.VinoArgs, config...)
+
+	target := logTargetFor(common, vinocCommands)
+	logger, closer, err := logging.Open(target.Path, logging.Options{Format: target.Format, Debug: target.Debug})
+	if err != nil {
+		return err
+	}
+	defer closer.Close()
+	slog.SetDefault(logger)
+	defer func() {
+		if err != nil && !errors.Is(err, cli.ErrHelp) {
+			slog.Error("vino failed", "error", err)
 		}
-		defer f.Close()
-		log.SetOutput(f)
+	}()
+
+	shutdownTracing, err := tracing.Setup(otlpEndpoint(common), "vino")
+	if err != nil {
+		return err
 	}
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := shutdownTracing(ctx); err != nil {
+			slog.Warn("export traces", "error", err)
+		}
+	}()
 
-	var vinocCommands VinocCommands
-	if err := cli.ParseAny(&vinocCommands, common.VinoArgs); err != nil {
-		return fmt.Errorf("cannot parse vino subcommand: %v", err)
+	if common.MetricsSocket != nil {
+		metrics.SetDefault(&metrics.Client{Socket: *common.MetricsSocket})
+	}
+
+	if parseErr != nil {
+		return fmt.Errorf("cannot parse vino subcommand: %w", parseErr)
 	}
 
 	switch {
 	case vinocCommands.Hook != nil:
 		return HookMain(*vinocCommands.Hook)
 	case vinocCommands.Runc != nil:
-		return RuncMain(*vinocCommands.Runc)
+		return RuncMain(*vinocCommands.Runc, childConfigFor(common))
 	case vinocCommands.Launcher != nil:
 		return RunWine(*vinocCommands.Launcher)
+	case vinocCommands.Exec != nil:
+		return ExecMain(*vinocCommands.Exec, childConfigFor(common))
+	case vinocCommands.Metrics != nil:
+		return MetricsMain(*vinocCommands.Metrics, config)
+	case vinocCommands.Display != nil:
+		return DisplayMain(*vinocCommands.Display, config)
+	case vinocCommands.Hotplug != nil:
+		return HotplugMain(*vinocCommands.Hotplug)
+	case vinocCommands.Completion != nil:
+		return CompletionMain(*vinocCommands.Completion)
 	}
 
 	return fmt.Errorf("subcommand not supported: %v", args)
 }
 
-func RuncMain(cmd RuncCommand) error {
-	delegate, err := runc.NewDelegatingCliClient(cmd.DelegatePath, runc.InheritStdin)
+// parseCommon parses the flags common to all vino commands, along with the
+// --vinoc_config file, if any, which sets the flags of vino commands left
+// off the command line and out of the environment.
+func parseCommon(args []string) (CommonCommand, []cli.Source, error) {
+	var common CommonCommand
+	if err := cli.Parse(&common, args); err != nil || common.Config == nil {
+		return common, nil, err
+	}
+	config, err := cli.ReadConfigFile(*common.Config)
 	if err != nil {
-		return fmt.Errorf("failed to create delegating client: %w", err)
+		return common, nil, fmt.Errorf("read --vinoc_config: %w", err)
+	}
+	// The file may set common flags too.
+	common = CommonCommand{}
+	if err := cli.Parse(&common, args, config); err != nil {
+		return common, nil, err
 	}
+	return common, []cli.Source{config}, nil
+}
 
-	executablePath, err := os.Executable()
+// otlpEndpoint returns where spans are exported: --vinoc_otlp_endpoint, or
+// the standard OTLP environment variables.
+func otlpEndpoint(common CommonCommand) string {
+	if common.OTLPEndpoint != nil {
+		return *common.OTLPEndpoint
+	}
+	if e := os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT"); e != "" {
+		return e
+	}
+	return os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT")
+}
 
+// logTarget describes where a vino process writes its log records.
+type logTarget struct {
+	Path   string
+	Format string
+	Debug  bool
+}
+
+// logTargetFor resolves the log destination. An explicit --vinoc_log_path
+// wins; otherwise runc subcommands honor the --log, --log-format and --debug
+// globals so that records land next to the delegate's in the file
+// containerd or Docker reads runtime logs from.
+func logTargetFor(common CommonCommand, cmds VinocCommands) logTarget {
+	var target logTarget
+	if common.VinocLogPath != nil {
+		target.Path = *common.VinocLogPath
+	}
+	if common.VinocLogFormat != nil {
+		target.Format = *common.VinocLogFormat
+	}
+	if cmds.Runc == nil {
+		return target
+	}
+
+	var runcCmds runc.RuncCommands
+	if err := cli.ParseAny(&runcCmds, cmds.Runc.RuncArgs); err != nil {
+		return target
+	}
+	global := runc.GlobalOf(runcCmds.Command())
+	target.Debug = global.Debug
+	if common.VinocLogPath == nil && global.Log != "" {
+		target.Path = global.Log
+		if common.VinocLogFormat == nil {
+			target.Format = string(global.LogFormat)
+		}
+	}
+	return target
+}
+
+func RuncMain(cmd RuncCommand, children childConfig) (err error) {
+	var cmds runc.RuncCommands
+	if err := cli.ParseAny(&cmds, cmd.RuncArgs); err != nil {
+		return cli.Nest("runc", err)
+	}
+	active := cmds.Command()
+	global := runc.GlobalOf(active)
+
+	logger := slog.Default().With(logging.KeySubcommand, cli.SubcommandOf(active))
+	if id := runc.ContainerIDOf(active); id != "" {
+		logger = logger.With(logging.KeyContainerID, id)
+	}
+	if bundle := cmds.Bundle(); bundle != "" {
+		logger = logger.With(logging.KeyBundle, bundle)
+	}
+	slog.SetDefault(logger)
+
+	ctx, span := otel.Tracer(tracerName).Start(context.Background(), "vino "+cli.SubcommandOf(active), trace.WithAttributes(
+		attribute.String(logging.KeyContainerID, runc.ContainerIDOf(active)),
+		attribute.String(logging.KeyBundle, cmds.Bundle()),
+	))
+	defer tracing.End(span, &err)
+
+	if cmds.Create != nil || cmds.Run != nil {
+		defer func() {
+			result := "ok"
+			if err != nil {
+				result = "error"
+			}
+			metrics.Inc(metrics.ContainerCreations, result)
+		}()
+	}
+
+	// Hooks run before pivot_root, so they can still reach the runtime log
+	// handed to us by containerd or Docker.
+	if global.Log != "" {
+		children.Log = &logTarget{Path: global.Log, Format: string(global.LogFormat)}
+	}
+	// Hooks continue the trace, given its context as arguments: they keep
+	// the environment they inherit from the runtime.
+	children.Trace = tracing.Environ(ctx)
+
+	w, err := newWrapper(cmd.DelegatePath, children)
 	if err != nil {
 		return err
 	}
+	w.Logger = logger
+
+	if cmds.Delete != nil {
+		relayLauncherLog(ctx, logger, w.Delegate, global, cmds.Delete.ContainerID)
+	}
+
+	if err = w.RunContext(ctx, cmds); err != nil {
+		var re *runc.RewriteError
+		if errors.As(err, &re) {
+			metrics.Inc(metrics.RewriteFailures, re.Reason)
+		}
+		var ee *exec.ExitError
+		if errors.As(err, &ee) {
+			return err
+		}
+		return fmt.Errorf("command run failed: %w", err)
+	}
+
+	return nil
+}
+
+// relayLauncherLog logs the records the launchers of the container id
+// logged inside it, before the container and their log go away.
+func relayLauncherLog(ctx context.Context, logger *slog.Logger, delegate runc.Cli, global runc.Global, id string) {
+	state, err := runc.NewClient(delegate, global).State(ctx, id)
+	if err != nil {
+		logger.Debug("relay launcher log", "error", err)
+		return
+	}
+	c, err := hook.FromBundle(state.Bundle)
+	if err != nil {
+		logger.Debug("relay launcher log", "error", err)
+		return
+	}
+	f, err := rootfs.Open(c.Root, launcher.LogPath, unix.O_RDONLY|unix.O_NONBLOCK|unix.O_NOCTTY, 0)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			logger.Warn("relay launcher log", "error", err)
+		}
+		return
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil || !fi.Mode().IsRegular() {
+		logger.Warn("relay launcher log: not a regular file", "path", launcher.LogPath)
+		return
+	}
+	if fi.Size() > maxRelayedLog {
+		if _, err := f.Seek(fi.Size()-maxRelayedLog, io.SeekStart); err != nil {
+			logger.Warn("relay launcher log", "error", err)
+			return
+		}
+	}
+	if err := logging.Relay(logger.With("source", "launcher"), f); err != nil {
+		logger.Warn("relay launcher log", "error", err)
+	}
+}
+
+// childConfig carries the settings vino forwards to the hooks and launchers
+// it installs.
+type childConfig struct {
+	// Log is where hooks write their records, or their own files under
+	// /var/log when nil.
+	Log          *logTarget
+	OTLPEndpoint string
+	// MetricsSocket is the host path of the metrics socket.
+	MetricsSocket string
+	// Trace is the trace context hooks continue, as tracing.Environ
+	// returns it.
+	Trace []string
+}
+
+func childConfigFor(common CommonCommand) childConfig {
+	c := childConfig{OTLPEndpoint: otlpEndpoint(common)}
+	if common.MetricsSocket != nil {
+		c.MetricsSocket = *common.MetricsSocket
+	}
+	return c
+}
+
+// commonCommand wraps args so the spawned hook logs, traces and reports
+// metrics according to c, logging to defaultLogPath unless c says otherwise.
+func (c childConfig) commonCommand(defaultLogPath string, args []string) CommonCommand {
+	common := CommonCommand{VinocLogPath: &defaultLogPath, VinoArgs: args}
+	if c.Log != nil {
+		common.VinocLogPath = &c.Log.Path
+		if c.Log.Format != "" {
+			common.VinocLogFormat = &c.Log.Format
+		}
+	}
+	if c.OTLPEndpoint != "" {
+		common.OTLPEndpoint = &c.OTLPEndpoint
+	}
+	if c.MetricsSocket != "" {
+		common.MetricsSocket = &c.MetricsSocket
+	}
+	return common
+}
+
+// newWrapper builds a runc wrapper that delegates to delegatePath and
+// rewrites bundles and processes so they are launched through vino.
+func newWrapper(delegatePath string, hooks childConfig) (*runc.Wrapper, error) {
+	delegate, err := runc.NewDelegatingCliClient(delegatePath, runc.InheritStdin)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create delegating client: %w", err)
+	}
+
+	executablePath, err := os.Executable()
+
+	if err != nil {
+		return nil, err
+	}
 
 	hookStartArgs, err := cli.ConvertToCmdline(HookStartCommand{})
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	hookStartArgs, err = cli.ConvertToCmdline(HookCommand{HookArgs: hookStartArgs})
+	hookStartArgs, err = cli.ConvertToCmdline(HookCommand{Trace: hooks.Trace, HookArgs: hookStartArgs})
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	hookStartArgs, err = cli.ConvertToCmdline(CommonCommand{
-		VinocLogPath: &vinoHookStartLogPath,
-		VinoArgs:     hookStartArgs,
-	})
+	hookStartArgs, err = cli.ConvertToCmdline(hooks.commonCommand(vinoHookStartLogPath, hookStartArgs))
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	hookCreateArgs, err := cli.ConvertToCmdline(HookCreateCommand{})
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	hookCreateArgs, err = cli.ConvertToCmdline(HookCommand{HookArgs: hookCreateArgs})
+	hookCreateArgs, err = cli.ConvertToCmdline(HookCommand{Trace: hooks.Trace, HookArgs: hookCreateArgs})
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	hookCreateArgs, err = cli.ConvertToCmdline(CommonCommand{
-		VinocLogPath: &vinoHookCreateLogPath,
-		VinoArgs:     hookCreateArgs,
-	})
+	hookCreateArgs, err = cli.ConvertToCmdline(hooks.commonCommand(vinoHookCreateLogPath, hookCreateArgs))
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	bundleRewriter := &vino.BundleRewriter{
@@ -222,44 +643,168 @@ func RuncMain(cmd RuncCommand) error {
 			executablePath: VINO_AFTER_PIVOT_PATH,
 		},
 	}
+	// Launchers raise console events through vino-ctrl, when installed.
+	ctrlHelper := filepath.Join(filepath.Dir(executablePath), "vino-ctrl.exe")
+	if fi, err := os.Stat(ctrlHelper); err == nil && fi.Mode().IsRegular() {
+		bundleRewriter.RebindPaths[ctrlHelper] = VINO_CTRL_AFTER_PIVOT_PATH
+	}
+
+	launcherCommon := CommonCommand{VinocLogPath: &wineLauncherLogPath}
+	// Launchers run after pivot_root, where the runtime log isn't: they log
+	// inside the container, and vino relays their records to it when the
+	// container is deleted.
+	if hooks.Log != nil {
+		logPath, logFormat := launcher.LogPath, logging.FormatJSON
+		launcherCommon.VinocLogPath = &logPath
+		launcherCommon.VinocLogFormat = &logFormat
+	}
+	// Launchers run after pivot_root: they reach the metrics socket through
+	// a bind of it, provided the server created it already. Only the socket
+	// is bound, its directory may be shared, e.g. /run.
+	if hooks.MetricsSocket != "" {
+		if fi, err := os.Stat(hooks.MetricsSocket); err != nil {
+			slog.Warn("metrics socket unavailable, launchers won't report metrics", "error", err)
+		} else if fi.Mode().Type() != os.ModeSocket {
+			slog.Warn("metrics socket is not a socket, launchers won't report metrics", "path", hooks.MetricsSocket)
+		} else {
+			bundleRewriter.RebindPaths[hooks.MetricsSocket] = metricsSocketInContainer
+			sock := metricsSocketInContainer
+			launcherCommon.MetricsSocket = &sock
+		}
+	}
 
-	wineLauncherArgs, err := cli.ConvertToCmdline(WineLauncherCommand{})
+	// Only the main process records how it ended; exec'd processes would
+	// overwrite its status. All of them report their end as events.
+	processRewriter, err := launcherRewriter(launcherCommon, WineLauncherCommand{
+		StatusFile:      winexit.StatusPath,
+		EventsFile:      events.JournalPath,
+		WatchWineserver: true,
+		OwnDisplay:      true,
+		OwnAudio:        true,
+		OwnPrinters:     true,
+	})
+	if err != nil {
+		return nil, err
+	}
+	processRewriter.ServiceFlag = "--service"
+	bundleRewriter.MainProcess = processRewriter
+	execProcessRewriter, err := launcherRewriter(launcherCommon, WineLauncherCommand{EventsFile: events.JournalPath})
+	if err != nil {
+		return nil, err
+	}
+
+	return &runc.Wrapper{
+		BundleRewriter:      bundleRewriter,
+		ProcessRewriter:     processRewriter,
+		ExecProcessRewriter: execProcessRewriter,
+		StateRewriter:       vino.StateRewriter{},
+		EventRewriter:       vino.EventRewriter{},
+		Delegate:            delegate,
+	}, nil
+}
+
+// runningContainer returns the state of the running container id, as
+// reported by the delegate runtime.
+func runningContainer(ctx context.Context, delegate runc.Cli, global runc.Global, id string) (specs.State, error) {
+	state, err := runc.NewClient(delegate, global).State(ctx, id)
+	if err != nil {
+		return specs.State{}, err
+	}
+	slog.Debug("resolved container", logging.KeyBundle, state.Bundle, "status", state.Status)
+	if state.Status != specs.StateRunning {
+		return *state, fmt.Errorf("container %s is %s, not running", id, state.Status)
+	}
+	return *state, nil
+}
+
+// launcherRewriter returns a rewriter running processes through launcher,
+// with common as its common flags.
+func launcherRewriter(common CommonCommand, launcher WineLauncherCommand) (*vino.ProcessRewriter, error) {
+	args, err := cli.ConvertToCmdline(launcher)
+	if err != nil {
+		return nil, err
+	}
+	common.VinoArgs = args
+	args, err = cli.ConvertToCmdline(common)
+	if err != nil {
+		return nil, err
+	}
+	return &vino.ProcessRewriter{
+		WineLauncherPath: VINO_AFTER_PIVOT_PATH,
+		WineLauncherArgs: args,
+	}, nil
+}
+
+// ExecMain runs a Windows command inside a running vino container. The
+// process is derived from the container's bundle and then goes through the
+// same exec rewrite as `runc exec`.
+func ExecMain(cmd ExecCommand, children childConfig) error {
+	w, err := newWrapper(cmd.DelegatePath, children)
 	if err != nil {
 		return err
 	}
+	slog.SetDefault(slog.Default().With(
+		logging.KeySubcommand, cli.SubcommandOf(cmd),
+		logging.KeyContainerID, cmd.ContainerID,
+	))
 
-	wineLauncherArgs, err = cli.ConvertToCmdline(CommonCommand{
-		VinocLogPath: &wineLauncherLogPath,
-		VinoArgs:     wineLauncherArgs,
-	})
+	ctx := context.Background()
+	global := runc.Global{Root: cmd.Root}
+
+	state, err := runningContainer(ctx, w.Delegate, global, cmd.ContainerID)
 	if err != nil {
 		return err
 	}
 
-	processRewriter := &vino.ProcessRewriter{
-		WineLauncherPath: VINO_AFTER_PIVOT_PATH,
-		WineLauncherArgs: wineLauncherArgs,
+	data, err := os.ReadFile(filepath.Join(state.Bundle, "config.json"))
+	if err != nil {
+		return fmt.Errorf("read bundle: %w", err)
+	}
+	var spec specs.Spec
+	if err := json.Unmarshal(data, &spec); err != nil {
+		return fmt.Errorf("unmarshal bundle: %w", err)
 	}
 
-	w := runc.Wrapper{
-		BundleRewriter:  bundleRewriter,
-		ProcessRewriter: processRewriter,
-		Delegate:        delegate,
+	proc, err := vino.ExecProcess(&spec, vino.ExecOptions{
+		Args: append([]string{cmd.Command}, cmd.Args...),
+		Cwd:  cmd.Cwd,
+		Env:  cmd.Env,
+		Tty:  cmd.Tty,
+	})
+	if err != nil {
+		return err
 	}
 
-	if err := runc.RunWithArgs(&w, cmd.RuncArgs); err != nil {
-		var ee *exec.ExitError
-		if errors.As(err, &ee) {
-			return err
-		}
-		return fmt.Errorf("command run failed: %w", err)
+	f, err := os.CreateTemp("", "vino-exec-*.json")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	if err := json.NewEncoder(f).Encode(proc); err != nil {
+		f.Close()
+		return fmt.Errorf("write process: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return err
 	}
 
-	return nil
+	return w.Run(runc.RuncCommands{
+		Exec: &runc.Exec{
+			Global:      global,
+			ContainerID: cmd.ContainerID,
+			Process:     f.Name(),
+		},
+	})
 }
 
-func HookMain(cmd HookCommand) error {
-	ctx := context.Background() // TODO move outside to main
+func HookMain(cmd HookCommand) (err error) {
+	// Hooks continue the trace of the vino invocation that installed them.
+	ctx := tracing.FromEnviron(context.Background(), append(os.Environ(), cmd.Trace...))
+
+	var hookCommands HookCommands
+	if err := cli.ParseAny(&hookCommands, cmd.HookArgs); err != nil {
+		return cli.Nest("oci-runtime-hook", err)
+	}
 
 	var state specs.State
 	if err := json.NewDecoder(os.Stdin).Decode(&state); err != nil {
@@ -271,42 +816,127 @@ func HookMain(cmd HookCommand) error {
 		return fmt.Errorf("parse annotations: %w", err)
 	}
 
-	hookEnv, err := hook.FromEnvironment()
-	if err != nil {
-		return err
-	}
+	logger := slog.Default().With(
+		logging.KeyContainerID, state.ID,
+		logging.KeyBundle, state.Bundle,
+	)
 
-	var hookCommands HookCommands
-	if err := cli.ParseAny(&hookCommands, cmd.HookArgs); err != nil {
-		return err
-	}
+	tracer := otel.Tracer(tracerName)
+	attrs := trace.WithAttributes(
+		attribute.String(logging.KeyContainerID, state.ID),
+		attribute.String(logging.KeyBundle, state.Bundle),
+	)
 
 	switch {
+	case hookCommands.Create != nil:
+		logger = logger.With(logging.KeySubcommand, cli.SubcommandOf(hookCommands.Create))
+		_, span := tracer.Start(ctx, "hook create", attrs)
+		defer tracing.End(span, &err)
+		defer observeHook("create", "total", time.Now())
+		logger.Debug("create hook", "devices", len(devs), "mounts", len(mounts))
+
+		// The start hook never runs: what has to be in the container before
+		// its process starts is done here.
+		container, err := hook.FromBundle(state.Bundle)
+		if err != nil {
+			return err
+		}
+		start := time.Now()
+		if err = container.AttachPrinters(mounts); err != nil {
+			return err
+		}
+		if err = container.LinkUSB(devs); err != nil {
+			return err
+		}
+		observeHook("create", "devices", start)
+		if err = container.CreateLauncherLog(); err != nil {
+			return err
+		}
 	case hookCommands.Start != nil:
-		if err = hookEnv.ApplyDevices(devs); err != nil {
+		logger = logger.With(logging.KeySubcommand, cli.SubcommandOf(hookCommands.Start))
+		ctx, span := tracer.Start(ctx, "hook start", attrs)
+		defer tracing.End(span, &err)
+		defer observeHook("start", "total", time.Now())
+
+		hookEnv, err := hook.FromEnvironment()
+		if err != nil {
 			return err
 		}
-		if err = hookEnv.ApplyMounts(mounts); err != nil {
+		start := time.Now()
+		if err = setupPrefix(ctx, logger, hookEnv, devs, mounts); err != nil {
 			return err
 		}
+		observeHook("start", "prefix", start)
 
-		if err := exec.CommandContext(ctx, "wineserver").Wait(); err != nil {
+		logger.Info("starting wineserver", logging.KeyPhase, "wineserver", "prefix", hookEnv.WinePrefix)
+		start = time.Now()
+		if err = runTraced(ctx, "wineserver"); err != nil {
 			return err
 		}
+		observeHook("start", "wineserver", start)
 
-		if err := exec.CommandContext(ctx, "wineboot").Wait(); err != nil {
+		logger.Info("booting prefix", logging.KeyPhase, "wineboot", "prefix", hookEnv.WinePrefix)
+		start = time.Now()
+		if err = runTraced(ctx, "wineboot"); err != nil {
 			return err
 		}
+		observeHook("start", "wineboot", start)
 	}
 
 	return nil
 }
 
+// setupPrefix exposes the devices and mounts requested by the container
+// inside its wine prefix.
+func setupPrefix(ctx context.Context, logger *slog.Logger, hookEnv *hook.VinoContainer, devs []labels.Device, mounts []labels.Mount) (err error) {
+	_, span := otel.Tracer(tracerName).Start(ctx, "prefix setup", trace.WithAttributes(
+		attribute.Int("vino.devices", len(devs)),
+		attribute.Int("vino.mounts", len(mounts)),
+	))
+	defer tracing.End(span, &err)
+
+	logger.Info("applying devices", logging.KeyPhase, "devices", "count", len(devs))
+	if err = hookEnv.ApplyDevices(devs); err != nil {
+		return err
+	}
+	logger.Info("applying mounts", logging.KeyPhase, "mounts", "count", len(mounts))
+	return hookEnv.ApplyMounts(mounts)
+}
+
+// observeHook records the duration of a hook phase that began at start.
+func observeHook(hook, phase string, start time.Time) {
+	metrics.Observe(metrics.HookDuration, time.Since(start).Seconds(), hook, phase)
+}
+
+// runTraced runs name to completion within a span of the same name.
+func runTraced(ctx context.Context, name string, args ...string) (err error) {
+	ctx, span := otel.Tracer(tracerName).Start(ctx, name)
+	defer tracing.End(span, &err)
+	return exec.CommandContext(ctx, name, args...).Run()
+}
+
 func RunWine(launcherCmd WineLauncherCommand) error {
+	logger := slog.Default().With(
+		logging.KeySubcommand, cli.SubcommandOf(launcherCmd),
+		logging.KeyPhase, "launch",
+	)
+
+	if stop := setupDisplay(logger, launcherCmd.OwnDisplay); stop != nil {
+		defer stop()
+	}
+	audio := os.Getenv(launcher.EnvAudio)
+	if stop := setupAudio(logger, launcherCmd.OwnAudio, audio); stop != nil {
+		defer stop()
+	}
+	if stop := setupPrinters(logger, launcherCmd.OwnPrinters); stop != nil {
+		defer stop()
+	}
+
 	if strings.Index(launcherCmd.Args[0], "@") == 0 {
 		// TODO: this code can be simplified a bit and merge most
 		//       logic with the branch below
 		bin := strings.TrimPrefix(launcherCmd.Args[0], "@")
+		logger.Info("launching linux process", "bin", bin, "args", launcherCmd.Args[1:])
 		cmd := exec.Command(bin, launcherCmd.Args[1:]...)
 		cmd.Env = os.Environ()
 		cmd.Stdin = os.Stdin
@@ -338,15 +968,403 @@ func RunWine(launcherCmd WineLauncherCommand) error {
 		args = append([]string{"-a", wine}, args...)
 	}
 
+	if state, err := vino.ProbeWineserver(winePrefix()); err != nil {
+		logger.Debug("probe wineserver", "error", err)
+	} else if state == vino.WineserverGone {
+		logger.Info("wineserver gone, wine will restart it")
+		metrics.Inc(metrics.WineserverRestarts)
+	}
+
+	opts, err := launcher.FromEnvironment()
+	if err != nil {
+		logger.Warn("read launcher options, using defaults", "error", err)
+	}
+	// Stop signals are meant for the Windows process, not for the launcher:
+	// hold on to them from before wine starts so none is lost.
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, launcher.StopSignals...)
+	defer signal.Stop(sigs)
+
+	if launcherCmd.Service != "" {
+		opts.Kind = launcher.KindService
+		opts.Service = launcherCmd.Service
+	}
+	env := launcher.WineDebugEnv(os.Environ(), opts.DebugProfile, opts.DebugChannels)
+	diagnostics, err := launcher.OpenDiagnostics(opts.DebugLog)
+	if err != nil {
+		logger.Warn("open debug log, keeping wine diagnostics on stderr", "error", err)
+	} else if diagnostics != nil {
+		defer diagnostics.Close()
+	}
+	stderr := io.Writer(os.Stderr)
+	if len(opts.DebugChannels) > 0 || diagnostics != nil {
+		forwarder := &launcher.DebugForwarder{
+			Channels: opts.DebugChannels,
+			Handler:  slog.NewJSONHandler(os.Stderr, nil),
+			Out:      os.Stderr,
+		}
+		if diagnostics != nil {
+			forwarder.Diagnostics = diagnostics
+		}
+		defer forwarder.Flush()
+		stderr = forwarder
+	}
+	// prepare readies the prefix for the debug profile and the audio
+	// endpoint, with the stderr the first wine command of the container has
+	// to use.
+	prepare := func(ctx context.Context, stderr io.Writer) error {
+		if launcherCmd.OwnAudio && audio != "" {
+			if err := launcher.SetAudioDriver(ctx, wine, os.Environ(), stderr); err != nil {
+				logger.Warn("configure audio", "audio", audio, "error", err)
+			}
+		}
+		if opts.DebugProfile != launcher.ProfileRelay {
+			return nil
+		}
+		return launcher.SetRelayDLLs(ctx, wine, os.Environ(), stderr, opts.RelayDLLs)
+	}
+
+	if opts.Service != "" {
+		return runService(logger, launcherCmd, opts, wine, env, stderr, prepare, sigs)
+	}
+	if err := prepare(context.Background(), stderr); err != nil {
+		logger.Warn("prepare debug profile", "profile", opts.DebugProfile, "error", err)
+	}
+
+	logger.Info("launching windows process", "bin", bin, "args", args)
+	detector := &winexit.Detector{}
 	cmd := exec.Command(bin, args...)
-	cmd.Env = os.Environ()
+	cmd.Env = env
 	cmd.Stdin = os.Stdin
 	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
+	cmd.Stderr = io.MultiWriter(stderr, detector)
+	// wineserver inherits wine's stderr and may outlive it: don't wait for
+	// it to close the pipe once wine is gone.
+	cmd.WaitDelay = time.Second
+
+	stopper := &launcher.Stopper{
+		Kind:   opts.Kind,
+		Grace:  opts.StopTimeout,
+		Wine:   wine,
+		Image:  launcher.ImageName(launcherCmd.Args[0]),
+		Logger: logger,
+	}
+	if _, err := os.Stat(VINO_CTRL_AFTER_PIVOT_PATH); err == nil {
+		stopper.CtrlHelper = VINO_CTRL_AFTER_PIVOT_PATH
+	}
+
+	err = cmd.Start()
+	if err == nil {
+		done := make(chan struct{})
+		supervised := make(chan struct{})
+		go func() {
+			defer close(supervised)
+			stopper.Supervise(cmd.Process, sigs, done)
+		}()
+		stopWatch := watchWineserver(logger, launcherCmd)
+		err = cmd.Wait()
+		stopWatch()
+		close(done)
+		<-supervised
+	}
+	var status winexit.Status
+	if cmd.ProcessState == nil {
+		status = winexit.LaunchFailed(err)
+	} else {
+		var sig os.Signal
+		if ws, ok := cmd.ProcessState.Sys().(syscall.WaitStatus); ok && ws.Signaled() {
+			sig = ws.Signal()
+		}
+		status = winexit.Resolve(cmd.ProcessState.ExitCode(), sig, detector)
+	}
+	return reportExit(logger, launcherCmd, status)
+}
+
+// wineserverPoll is how often the main launcher probes the wineserver.
+const wineserverPoll = time.Second
+
+// watchWineserver records the wineserver of the prefix starting and, while
+// the Windows process runs, crashing, when launcherCmd asks for it. It
+// returns the function to call once the Windows process is gone, after which
+// the wineserver exiting is expected.
+func watchWineserver(logger *slog.Logger, launcherCmd WineLauncherCommand) func() {
+	if !launcherCmd.WatchWineserver || launcherCmd.EventsFile == "" {
+		return func() {}
+	}
+	prefix := winePrefix()
+	ctx, cancel := context.WithCancel(context.Background())
+	watched := make(chan struct{})
+	go func() {
+		defer close(watched)
+		running := false
+		vino.WatchWineserver(ctx, prefix, wineserverPoll, func(state vino.WineserverState) {
+			var typ string
+			switch {
+			case state == vino.WineserverRunning:
+				typ = events.TypeWineserverStarted
+			case state == vino.WineserverGone && running:
+				typ = events.TypeWineserverCrashed
+				logger.Warn("wineserver crashed", "prefix", prefix)
+			}
+			running = state == vino.WineserverRunning
+			if typ == "" {
+				return
+			}
+			if err := events.Append("/", launcherCmd.EventsFile, typ, events.Wineserver{Prefix: prefix}); err != nil {
+				logger.Warn("record wineserver event", "error", err)
+			}
+		})
+	}()
+	return func() {
+		cancel()
+		<-watched
+	}
+}
+
+// displayTimeout bounds how long launchers wait for the virtual display.
+const displayTimeout = 10 * time.Second
+
+// setupDisplay starts the container's virtual display when the launcher owns
+// it, or waits for it to be up otherwise. It returns the function stopping
+// the display, if it started one.
+func setupDisplay(logger *slog.Logger, own bool) func() {
+	screen, managed := os.LookupEnv(launcher.EnvDisplayScreen)
+	if !managed {
+		return nil
+	}
+	display := os.Getenv("DISPLAY")
+	if !own {
+		ctx, cancel := context.WithTimeout(context.Background(), displayTimeout)
+		defer cancel()
+		if err := launcher.WaitDisplay(ctx, launcher.X11SocketDir, display); err != nil {
+			logger.Warn("wait for display", "display", display, "error", err)
+		}
+		return nil
+	}
+	d := &launcher.Display{
+		Name:     display,
+		Screen:   screen,
+		StateDir: launcher.DisplayStateDir,
+		VNC:      os.Getenv(launcher.EnvDisplayVNC) == "1",
+		Logger:   logger,
+	}
+	stop, err := d.Start(context.Background(), displayTimeout)
+	if err != nil {
+		logger.Warn("start display, GUI programs will fail", "display", display, "error", err)
+		return nil
+	}
+	return stop
+}
+
+// audioTimeout bounds how long the launcher waits for the null sink.
+const audioTimeout = 5 * time.Second
+
+// setupAudio starts the null sink when the launcher owns the container's
+// audio and no host socket was bound in. It returns the function stopping
+// it, if it started one.
+func setupAudio(logger *slog.Logger, own bool, audio string) func() {
+	if !own || audio != launcher.AudioNull {
+		return nil
+	}
+	n := &launcher.NullSink{Logger: logger}
+	stop, err := n.Start(context.Background(), audioTimeout)
+	if err != nil {
+		logger.Warn("start null sink, programs needing an audio device may fail", "error", err)
+		return nil
+	}
+	return stop
+}
 
-	err := cmd.Run()
+// setupPrinters spools the print jobs of the LPT ports backed by CUPS when
+// the launcher owns the container's printers. It returns the function
+// stopping the spoolers, if it started any.
+func setupPrinters(logger *slog.Logger, own bool) func() {
+	ports := os.Getenv(launcher.EnvPrinterSpool)
+	if !own || ports == "" {
+		return nil
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	var wg sync.WaitGroup
+	for _, port := range strings.Split(ports, ",") {
+		s := &launcher.Spooler{
+			FIFO:   launcher.SpoolPath(launcher.PrinterSpoolDir, port),
+			Env:    os.Environ(),
+			Logger: logger,
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := s.Run(ctx); err != nil {
+				logger.Warn("spool print jobs, printing fails", "port", port, "error", err)
+			}
+		}()
+	}
+	return func() {
+		cancel()
+		wg.Wait()
+	}
+}
+
+// runService runs the launcher's arguments as a Windows service, for as long
+// as the service process lives.
+func runService(logger *slog.Logger, launcherCmd WineLauncherCommand, opts launcher.Options, wine string, env []string, stderr io.Writer, prepare func(context.Context, io.Writer) error, sigs <-chan os.Signal) error {
+	logger = logger.With("service", opts.Service)
+	logger.Info("launching windows service", "args", launcherCmd.Args)
+	detector := &winexit.Detector{}
+	svc := &launcher.Service{
+		Name:    opts.Service,
+		Wine:    wine,
+		Args:    launcherCmd.Args,
+		Env:     env,
+		Stdout:  os.Stdout,
+		Stderr:  io.MultiWriter(stderr, detector),
+		Logger:  logger,
+		Prepare: prepare,
+	}
+	ctx := context.Background()
+	proc, err := svc.Start(ctx)
+	if err != nil {
+		logger.Error("start service", "error", err)
+		return reportExit(logger, launcherCmd, winexit.LaunchFailed(err))
+	}
+
+	stopper := &launcher.Stopper{
+		Kind:    launcher.KindService,
+		Grace:   opts.StopTimeout,
+		Wine:    wine,
+		Service: opts.Service,
+		Logger:  logger,
+	}
+	done := make(chan struct{})
+	supervised := make(chan struct{})
+	go func() {
+		defer close(supervised)
+		stopper.Supervise(proc, sigs, done)
+	}()
+	stopWatch := watchWineserver(logger, launcherCmd)
+	err = proc.Wait(ctx)
+	stopWatch()
+	close(done)
+	<-supervised
 	if err != nil {
 		return err
 	}
-	return nil
+
+	// The exit code of a process started by services.exe isn't ours to see:
+	// a stopped service is a clean exit unless it was seen crashing.
+	exitCode := 0
+	if detector.Detection() != nil {
+		exitCode = 1
+	}
+	return reportExit(logger, launcherCmd, winexit.Resolve(exitCode, nil, detector))
+}
+
+// reportExit logs, records and returns how the Windows process of
+// launcherCmd ended, as an error carrying the container exit code unless it
+// exited cleanly.
+func reportExit(logger *slog.Logger, launcherCmd WineLauncherCommand, status winexit.Status) error {
+	metrics.Inc(metrics.WindowsProcessExits, status.CodeString())
+	if launcherCmd.StatusFile != "" {
+		if err := winexit.WriteFile(launcherCmd.StatusFile, status); err != nil {
+			logger.Warn("record exit status", "error", err)
+		}
+	}
+	if launcherCmd.EventsFile != "" {
+		image := launcher.ImageName(launcherCmd.Args[0])
+		if err := events.Append("/", launcherCmd.EventsFile, events.TypeWindowsExit, events.ExitOf(image, status)); err != nil {
+			logger.Warn("record exit event", "error", err)
+		}
+	}
+
+	attrs := []any{
+		"kind", status.Kind,
+		"code", status.CodeString(),
+		"exit_code", status.ExitCode,
+	}
+	if status.Name != "" {
+		attrs = append(attrs, "name", status.Name)
+	}
+	if status.Reason != "" {
+		attrs = append(attrs, "reason", status.Reason)
+	}
+	if status.Detail != "" {
+		attrs = append(attrs, "detail", status.Detail)
+	}
+	if status.Kind == winexit.KindExited {
+		logger.Info("windows process exited", attrs...)
+	} else {
+		logger.Error("windows process failed", attrs...)
+	}
+
+	if status.ExitCode == 0 {
+		return nil
+	}
+	return &winexit.ExitError{Status: status}
+}
+
+// winePrefix returns the prefix wine uses in this environment.
+func winePrefix() string {
+	if prefix := os.Getenv("WINEPREFIX"); prefix != "" {
+		return prefix
+	}
+	home, _ := os.UserHomeDir()
+	return filepath.Join(home, ".wine")
+}
+
+func MetricsMain(cmd MetricsCommand, config []cli.Source) error {
+	var cmds MetricsCommands
+	if err := cli.ParseAny(&cmds, cmd.MetricsArgs, config...); err != nil {
+		return cli.Nest("metrics", err)
+	}
+	switch {
+	case cmds.Serve != nil:
+		return MetricsServeMain(*cmds.Serve)
+	}
+	return fmt.Errorf("metrics subcommand not supported: %v", cmd.MetricsArgs)
+}
+
+// MetricsServeMain aggregates the samples vino processes send to the metrics
+// socket and exposes them on /metrics until interrupted.
+func MetricsServeMain(cmd MetricsServeCommand) error {
+	socket, listen := cmd.Socket, cmd.Listen
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, unix.SIGTERM)
+	defer stop()
+
+	if err := os.MkdirAll(filepath.Dir(socket), 0o755); err != nil {
+		return err
+	}
+	conn, err := metrics.Listen(socket)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(socket)
+
+	reg := metrics.NewRegistry()
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", reg)
+	srv := &http.Server{Addr: listen, Handler: mux}
+
+	errCh := make(chan error, 2)
+	go func() { errCh <- reg.Consume(ctx, conn) }()
+	go func() {
+		if err := srv.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+			errCh <- err
+		}
+	}()
+	slog.Info("serving metrics", "socket", socket, "listen", listen)
+
+	select {
+	case <-ctx.Done():
+	case err = <-errCh:
+	}
+	stop()
+	conn.Close()
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if serr := srv.Shutdown(shutdownCtx); err == nil {
+		err = serr
+	}
+	return err
 }
@@ -0,0 +1,329 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	specs "github.com/opencontainers/runtime-spec/specs-go"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"golang.org/x/sys/unix"
+
+	"github.com/TheGrizzlyDev/vino/internal/pkg/cli"
+	"github.com/TheGrizzlyDev/vino/internal/pkg/tracing"
+	"github.com/TheGrizzlyDev/vino/internal/pkg/vino/launcher"
+	"github.com/TheGrizzlyDev/vino/internal/pkg/vino/rootfs"
+)
+
+// TestTracedCreateHook installs the hooks of a traced create and runs the
+// createContainer one as the runtime would: with the environment of the
+// runtime, which it keeps, and the container's state on its stdin.
+func TestTracedCreateHook(t *testing.T) {
+	dir := t.TempDir()
+	traces := filepath.Join(dir, "traces.json")
+	p := sdktrace.NewTracerProvider()
+	ctx, span := p.Tracer("test").Start(context.Background(), "vino create")
+	defer span.End()
+
+	w, err := newWrapper("/bin/true", childConfig{
+		Log:          &logTarget{Path: filepath.Join(dir, "runtime.log"), Format: "json"},
+		OTLPEndpoint: traces,
+		Trace:        tracing.Environ(ctx),
+	})
+	if err != nil {
+		t.Fatalf("newWrapper: %v", err)
+	}
+	bundle := &specs.Spec{Process: &specs.Process{Args: []string{"app.exe"}}}
+	if err := w.BundleRewriter.RewriteBundle(bundle); err != nil {
+		t.Fatalf("RewriteBundle: %v", err)
+	}
+	if bundle.Hooks == nil || len(bundle.Hooks.CreateContainer) != 1 {
+		t.Fatalf("hooks = %+v, want a createContainer hook", bundle.Hooks)
+	}
+	hook := bundle.Hooks.CreateContainer[0]
+	if hook.Env != nil {
+		t.Fatalf("hook env = %q, want the runtime's inherited", hook.Env)
+	}
+
+	writeBundle(t, dir, bundle)
+	runHook(t, hook, dir, bundle.Annotations)
+
+	// The hook's span joins the trace.
+	f, err := os.Open(traces)
+	if err != nil {
+		t.Fatalf("no span exported: %v", err)
+	}
+	defer f.Close()
+	want := span.SpanContext().TraceID().String()
+	found := false
+	for sc := bufio.NewScanner(f); sc.Scan(); {
+		if strings.Contains(sc.Text(), `"name":"hook create"`) && strings.Contains(sc.Text(), `"traceId":"`+want+`"`) {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("no hook create span in trace %s", want)
+	}
+}
+
+// writeBundle writes spec as the bundle in dir, with its root filesystem
+// in dir/rootfs.
+func writeBundle(t *testing.T, dir string, spec *specs.Spec) {
+	t.Helper()
+	spec.Root = &specs.Root{Path: "rootfs"}
+	data, err := json.Marshal(spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(dir, "rootfs"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "config.json"), data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// runHook runs hook as the runtime would for the container of the bundle
+// in dir: with the runtime's environment and the container's state, its
+// annotations included, on its stdin.
+func runHook(t *testing.T, hook specs.Hook, dir string, annotations map[string]string) {
+	t.Helper()
+	state, err := json.Marshal(specs.State{ID: "c1", Bundle: dir, Annotations: annotations})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, wr, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	wr.Write(state)
+	wr.Close()
+	stdin := os.Stdin
+	os.Stdin = r
+	defer func() { os.Stdin = stdin }()
+	if err := run(hook.Args[1:]); err != nil {
+		t.Fatalf("hook %q: %v", hook.Args, err)
+	}
+}
+
+// TestPrintThroughCUPS rewrites a container printing to a CUPS socket and
+// runs its create hook: what a program writes to its LPT port reaches the
+// spooler of the launcher, which submits it.
+func TestPrintThroughCUPS(t *testing.T) {
+	dir := t.TempDir()
+	socket := filepath.Join(dir, "cups.sock")
+	l, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	w, err := newWrapper("/bin/true", childConfig{Log: &logTarget{Path: filepath.Join(dir, "runtime.log")}})
+	if err != nil {
+		t.Fatalf("newWrapper: %v", err)
+	}
+	bundle := &specs.Spec{
+		Annotations: map[string]string{
+			"dev.vinoc.mounts.labels": `{"type":"printer","source_path":"` + socket + `","destination_label":"LPT2"}`,
+		},
+		Process: &specs.Process{Args: []string{"app.exe"}, Env: []string{"WINEPREFIX=/prefix"}},
+	}
+	if err := w.BundleRewriter.RewriteBundle(bundle); err != nil {
+		t.Fatalf("RewriteBundle: %v", err)
+	}
+	if !slices.Contains(bundle.Process.Env, launcher.EnvPrinterSpool+"=lpt2") {
+		t.Fatalf("env = %q, want lpt2 spooled", bundle.Process.Env)
+	}
+	writeBundle(t, dir, bundle)
+	root := filepath.Join(dir, "rootfs")
+	if err := os.MkdirAll(filepath.Join(root, "prefix"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	runHook(t, bundle.Hooks.CreateContainer[0], dir, bundle.Annotations)
+
+	jobs := filepath.Join(dir, "jobs")
+	lp := filepath.Join(dir, "lp")
+	if err := os.WriteFile(lp, []byte("#!/bin/sh\ncat >> "+jobs+"\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	s := &launcher.Spooler{FIFO: filepath.Join(root, launcher.SpoolPath(launcher.PrinterSpoolDir, "lpt2")), Lp: lp}
+	done := make(chan error, 1)
+	go func() { done <- s.Run(ctx) }()
+
+	f, err := rootfs.Open(root, "/prefix/dosdevices/lpt2", unix.O_WRONLY, 0)
+	if err != nil {
+		t.Fatalf("open lpt2: %v", err)
+	}
+	f.WriteString("LABEL\n")
+	f.Close()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		data, _ := os.ReadFile(jobs)
+		if string(data) == "LABEL\n" {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("submitted jobs = %q, want the label", data)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	cancel()
+	if err := <-done; err != nil {
+		t.Errorf("spooler: %v", err)
+	}
+}
+
+// TestServiceOnlyForMainProcess rewrites a container running a Windows
+// service: its main process runs as the service, processes exec'd with the
+// environment of the main one don't.
+func TestServiceOnlyForMainProcess(t *testing.T) {
+	w, err := newWrapper("/bin/true", childConfig{})
+	if err != nil {
+		t.Fatalf("newWrapper: %v", err)
+	}
+	bundle := &specs.Spec{
+		Annotations: map[string]string{"dev.vinoc.process.service": "Spooler"},
+		Process:     &specs.Process{Args: []string{"spoolsv.exe"}},
+	}
+	if err := w.BundleRewriter.RewriteBundle(bundle); err != nil {
+		t.Fatalf("RewriteBundle: %v", err)
+	}
+	exec := specs.Process{Args: []string{"cmd", "/c", "dir"}, Env: slices.Clone(bundle.Process.Env)}
+	if err := w.ProcessRewriter.RewriteProcess(bundle.Process); err != nil {
+		t.Fatalf("RewriteProcess: %v", err)
+	}
+	if err := w.ExecProcessRewriter.RewriteProcess(&exec); err != nil {
+		t.Fatalf("RewriteProcess(exec): %v", err)
+	}
+	if i := slices.IndexFunc(bundle.Process.Env, func(kv string) bool { return strings.Contains(kv, "Spooler") }); i >= 0 {
+		t.Errorf("main process env has %q, want the service kept out of it", bundle.Process.Env[i])
+	}
+
+	launcherOf := func(args []string) WineLauncherCommand {
+		t.Helper()
+		var common CommonCommand
+		if err := cli.Parse(&common, args[1:]); err != nil {
+			t.Fatalf("parse %q: %v", args, err)
+		}
+		var cmds VinocCommands
+		if err := cli.ParseAny(&cmds, common.VinoArgs); err != nil || cmds.Launcher == nil {
+			t.Fatalf("parse %q: %v", args, err)
+		}
+		return *cmds.Launcher
+	}
+	if main := launcherOf(bundle.Process.Args); main.Service != "Spooler" || !slices.Equal(main.Args, []string{"spoolsv.exe"}) {
+		t.Errorf("main launcher = %+v, want spoolsv.exe run as Spooler", main)
+	}
+	if l := launcherOf(exec.Args); l.Service != "" || !slices.Equal(l.Args, []string{"cmd", "/c", "dir"}) {
+		t.Errorf("exec launcher = %+v, want cmd /c dir run as a plain process", l)
+	}
+}
+
+// TestMetricsSocketBoundAlone checks that containers get the metrics socket,
+// not the directory it is in.
+func TestMetricsSocketBoundAlone(t *testing.T) {
+	dir := t.TempDir()
+	socket := filepath.Join(dir, "metrics.sock")
+	l, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: socket, Net: "unixgram"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	w, err := newWrapper("/bin/true", childConfig{MetricsSocket: socket})
+	if err != nil {
+		t.Fatalf("newWrapper: %v", err)
+	}
+	bundle := &specs.Spec{Process: &specs.Process{Args: []string{"app.exe"}}}
+	if err := w.BundleRewriter.RewriteBundle(bundle); err != nil {
+		t.Fatalf("RewriteBundle: %v", err)
+	}
+	var sources []string
+	for _, m := range bundle.Mounts {
+		if strings.HasPrefix(m.Source, dir) {
+			sources = append(sources, m.Source+" -> "+m.Destination)
+		}
+	}
+	if want := []string{socket + " -> " + metricsSocketInContainer}; !slices.Equal(sources, want) {
+		t.Errorf("mounts = %q, want %q", sources, want)
+	}
+}
+
+// TestLauncherLogRelayed creates a container logging to the runtime log and
+// deletes it: the host log isn't bound into the container, what its
+// launchers logged inside it is relayed to the runtime log on delete.
+func TestLauncherLogRelayed(t *testing.T) {
+	dir := t.TempDir()
+	runtimeLog := filepath.Join(dir, "runtime.log")
+	w, err := newWrapper("/bin/true", childConfig{Log: &logTarget{Path: runtimeLog, Format: "json"}})
+	if err != nil {
+		t.Fatalf("newWrapper: %v", err)
+	}
+	bundle := &specs.Spec{Process: &specs.Process{Args: []string{"app.exe"}, Env: []string{"WINEPREFIX=/prefix"}}}
+	if err := w.BundleRewriter.RewriteBundle(bundle); err != nil {
+		t.Fatalf("RewriteBundle: %v", err)
+	}
+	for _, m := range bundle.Mounts {
+		if m.Source == runtimeLog {
+			t.Fatalf("runtime log bound into the container at %s", m.Destination)
+		}
+	}
+	if err := w.ProcessRewriter.RewriteProcess(bundle.Process); err != nil {
+		t.Fatalf("RewriteProcess: %v", err)
+	}
+	var common CommonCommand
+	if err := cli.Parse(&common, bundle.Process.Args[1:]); err != nil {
+		t.Fatal(err)
+	}
+	if common.VinocLogPath == nil || *common.VinocLogPath != launcher.LogPath || common.VinocLogFormat == nil || *common.VinocLogFormat != "json" {
+		t.Fatalf("launcher logs to %v as %v, want %s as json", common.VinocLogPath, common.VinocLogFormat, launcher.LogPath)
+	}
+
+	writeBundle(t, dir, bundle)
+	root := filepath.Join(dir, "rootfs")
+	if err := os.MkdirAll(filepath.Join(root, "prefix"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	runHook(t, bundle.Hooks.CreateContainer[0], dir, nil)
+	logFile := filepath.Join(root, launcher.LogPath)
+	if fi, err := os.Stat(logFile); err != nil || fi.Mode().Perm() != 0o666 {
+		t.Fatalf("launcher log = %v, %v; want it writable by anyone", fi, err)
+	}
+	// What the launcher logs from inside the container.
+	if err := os.WriteFile(logFile, []byte(`{"time":"2026-01-02T03:04:05Z","level":"warning","msg":"wineserver gone","prefix":"/prefix"}`+"\n"), 0); err != nil {
+		t.Fatal(err)
+	}
+
+	// The delegate reports the container's state, and deletes it.
+	delegate := filepath.Join(dir, "runc")
+	state := `{"ociVersion":"1.2.0","id":"c1","status":"stopped","pid":0,"bundle":"` + dir + `"}`
+	if err := os.WriteFile(delegate, []byte("#!/bin/sh\nfor a; do [ \"$a\" = state ] && echo '"+state+"'; done\nexit 0\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := run([]string{"runc", "--delegate_path", delegate, "--log", runtimeLog, "--log-format", "json", "delete", "c1"}); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	data, err := os.ReadFile(runtimeLog)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var relayed map[string]any
+	for line := range strings.Lines(string(data)) {
+		var rec map[string]any
+		if json.Unmarshal([]byte(line), &rec) == nil && rec["msg"] == "wineserver gone" {
+			relayed = rec
+		}
+	}
+	if relayed == nil || relayed["source"] != "launcher" || relayed["container_id"] != "c1" || relayed["level"] != "warning" || relayed["time"] != "2026-01-02T03:04:05Z" {
+		t.Errorf("relayed record = %v, want the launcher's warning for c1\nlog:\n%s", relayed, data)
+	}
+}
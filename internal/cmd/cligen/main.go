@@ -0,0 +1,135 @@
+// Command cligen generates the cli.Command structs of a container
+// runtime's command line, from its --help output or a JSON description of
+// it, for go generate. internal/pkg/runc generates the commands of runc
+// from runc.json with:
+//
+//	//go:generate go run github.com/TheGrizzlyDev/vino/internal/cmd/cligen --spec runc.json --package runcgen -o runcgen/commands_gen.go
+//
+// With --format json it writes the description read from --help instead,
+// to be reviewed and amended (repeated flags, value types, "--" literals)
+// and generated from with --spec, so that moving to another version of
+// the runtime is a diff of the description and of the generated code.
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/TheGrizzlyDev/vino/internal/pkg/cli"
+	"github.com/TheGrizzlyDev/vino/internal/pkg/cli/cligen"
+)
+
+type CligenCmd struct {
+	Runtime string `cli_flag:"--runtime" cli_group:"cligen" cli_help:"Runtime binary whose --help to read."`
+	Spec    string `cli_flag:"--spec" cli_group:"cligen" cli_help:"JSON description of the runtime to read instead."`
+	Package string `cli_flag:"--package" cli_group:"cligen" cli_env:"GOPACKAGE" cli_help:"Package of the generated code."`
+	Output  string `cli_flag:"--output" cli_flag_alternatives:"-o" cli_group:"cligen" cli_help:"File to write, standard output if unset."`
+	Format  string `cli_flag:"--format" cli_group:"cligen" cli_enum:"go|json" cli_default:"go" cli_help:"Whether to write Go code or the JSON description."`
+}
+
+func (CligenCmd) Slots() cli.Slot {
+	return cli.Group{
+		Unordered: []cli.Slot{cli.FlagGroup{Name: "cligen"}},
+	}
+}
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("cligen: ")
+	var cmd CligenCmd
+	if err := cli.Parse(&cmd, os.Args[1:]); err != nil {
+		var he *cli.HelpError
+		if errors.As(err, &he) {
+			he.Path = []string{"cligen"}
+			fmt.Print(he.Usage())
+			return
+		}
+		log.Fatal(err)
+	}
+	if err := run(cmd); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func run(cmd CligenCmd) error {
+	var (
+		rt     cligen.Runtime
+		source string
+		err    error
+	)
+	switch {
+	case cmd.Runtime != "" && cmd.Spec != "":
+		return errors.New("--runtime and --spec are exclusive")
+	case cmd.Runtime != "":
+		rt, err = cligen.FromHelp(filepath.Base(cmd.Runtime), helpOf(cmd.Runtime))
+		if err != nil {
+			return err
+		}
+		rt.Version = versionOf(cmd.Runtime)
+		source = rt.Name + " --help"
+	case cmd.Spec != "":
+		f, err := os.Open(cmd.Spec)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		if rt, err = cligen.ReadJSON(f); err != nil {
+			return fmt.Errorf("%s: %w", cmd.Spec, err)
+		}
+		source = filepath.Base(cmd.Spec)
+	default:
+		return errors.New("one of --runtime or --spec is required")
+	}
+	if rt.Version != "" {
+		source += " (" + rt.Version + ")"
+	}
+
+	var out bytes.Buffer
+	if cmd.Format == "json" {
+		err = cligen.WriteJSON(&out, rt)
+	} else {
+		if cmd.Package == "" {
+			return errors.New("--package is required outside of go generate")
+		}
+		var src []byte
+		src, err = cligen.Generate(rt, cligen.Options{Package: cmd.Package, Source: source})
+		out.Write(src)
+	}
+	if err != nil {
+		return err
+	}
+	if cmd.Output == "" {
+		_, err = os.Stdout.Write(out.Bytes())
+		return err
+	}
+	return os.WriteFile(cmd.Output, out.Bytes(), 0o644)
+}
+
+// helpOf returns the help of the runtime binary.
+func helpOf(runtime string) cligen.HelpFunc {
+	return func(args ...string) (string, error) {
+		out, err := exec.Command(runtime, append(args, "--help")...).Output()
+		// Some programs exit with an error after printing their help.
+		if err != nil && len(out) == 0 {
+			return "", err
+		}
+		return string(out), nil
+	}
+}
+
+// versionOf returns the first line of the --version output of the runtime
+// binary, or nothing.
+func versionOf(runtime string) string {
+	out, err := exec.Command(runtime, "--version").Output()
+	if err != nil {
+		return ""
+	}
+	line, _, _ := strings.Cut(string(out), "\n")
+	return strings.TrimSpace(line)
+}
@@ -4,12 +4,14 @@ import (
 	"bytes"
 	"errors"
 	"log"
+	"log/slog"
 	"os"
 	"os/exec"
 	"sync"
 	"time"
 
 	"github.com/TheGrizzlyDev/vino/internal/pkg/cli"
+	"github.com/TheGrizzlyDev/vino/internal/pkg/logging"
 
 	"github.com/TheGrizzlyDev/vino/internal/pkg/runc"
 )
@@ -107,9 +109,10 @@ func (lw *logWriter) Close() error {
 }
 
 type DelegatecCmd struct {
-	Arguments     []string `cli_argument:"args"`
-	DelegatecLogs string   `cli_flag:"--delegatec_logs" cli_group:"delegate"`
-	DelegatePath  string   `cli_flag:"--delegate_path" cli_group:"delegate"`
+	Arguments          []string `cli_argument:"args"`
+	DelegatecLogs      string   `cli_flag:"--delegatec_logs" cli_group:"delegate"`
+	DelegatecLogFormat string   `cli_flag:"--delegatec_log_format" cli_group:"delegate" cli_enum:"text|json"`
+	DelegatePath       string   `cli_flag:"--delegate_path" cli_group:"delegate"`
 }
 
 func (d DelegatecCmd) Slots() cli.Slot {
@@ -127,18 +130,14 @@ func main() {
 		panic(err)
 	}
 
-	f, err := os.OpenFile(delegatecCmd.DelegatecLogs, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0666)
+	logger, closer, err := logging.Open(delegatecCmd.DelegatecLogs, logging.Options{Format: delegatecCmd.DelegatecLogFormat})
 	if err != nil {
-		log.Fatalf("error opening file: %v", err)
+		log.Fatalf("error opening log: %v", err)
 	}
-	defer f.Close()
-	log.SetOutput(f)
+	defer closer.Close()
+	slog.SetDefault(logger)
 
-	log.Printf("delegatec called with args: %v\n", os.Args)
-	log.Printf("delegatec environment: %v\n", os.Environ())
-
-	log.Println(os.Args)
-	log.Println(delegatecCmd)
+	slog.Info("delegatec called", "args", delegatecCmd.Arguments, "delegate", delegatecCmd.DelegatePath)
 
 	cli, err := runc.NewDelegatingCliClient(delegatecCmd.DelegatePath, runc.InheritStdin)
 	if err != nil {
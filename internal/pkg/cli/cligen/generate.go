@@ -0,0 +1,234 @@
+package cligen
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Options tell Generate what to generate.
+type Options struct {
+	// Package is the name of the package of the generated file.
+	Package string
+	// Source tells where the description came from, for the header of the
+	// file: "runc --help" or "runc.json".
+	Source string
+}
+
+// Generate returns the Go source of the cli.Command structs of rt: a
+// Global struct holding its global flags, one struct per command
+// embedding it, and the union of the commands, Commands, for
+// cli.ParseAny.
+//
+// Flags of a command are unordered and fall in one group named after it
+// unless their Group says otherwise; global flags precede the subcommand.
+func Generate(rt Runtime, opts Options) ([]byte, error) {
+	if err := rt.Validate(); err != nil {
+		return nil, err
+	}
+	g := &generator{rt: rt}
+	g.header(opts)
+	types := g.typeNames()
+	if len(rt.Global) > 0 {
+		fmt.Fprintf(&g.b, "// Global holds the global flags of %s, given before the subcommand.\n", rt.Name)
+		g.structType("Global", "", rt.Global, nil, false)
+	}
+	for _, c := range rt.Commands {
+		name := types[c.Name]
+		if c.Doc != "" {
+			fmt.Fprintf(&g.b, "// %s is %q: %s.\n", name, rt.Name+" "+c.Name, strings.TrimSuffix(c.Doc, "."))
+		} else {
+			fmt.Fprintf(&g.b, "// %s is %q.\n", name, rt.Name+" "+c.Name)
+		}
+		g.structType(name, c.Name, c.Flags, c.Arguments, len(rt.Global) > 0)
+		g.slots(name, c)
+	}
+
+	fmt.Fprintf(&g.b, "// Commands holds one of the commands of %s, for cli.ParseAny.\ntype Commands struct {\n", rt.Name)
+	for _, c := range rt.Commands {
+		fmt.Fprintf(&g.b, "\t%s *%[1]s\n", types[c.Name])
+	}
+	g.b.WriteString("}\n")
+
+	src, err := format.Source(g.b.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("format generated code: %w", err)
+	}
+	return src, nil
+}
+
+type generator struct {
+	rt Runtime
+	b  bytes.Buffer
+}
+
+func (g *generator) header(opts Options) {
+	fmt.Fprintf(&g.b, "// Code generated by cligen from %s; DO NOT EDIT.\n\n", opts.Source)
+	fmt.Fprintf(&g.b, "package %s\n\nimport (\n", opts.Package)
+	uses := func(typ string) bool {
+		has := func(f Flag) bool { return f.goType() == typ }
+		if slices.ContainsFunc(g.rt.Global, has) {
+			return true
+		}
+		return slices.ContainsFunc(g.rt.Commands, func(c Command) bool { return slices.ContainsFunc(c.Flags, has) })
+	}
+	if uses("time.Duration") {
+		g.b.WriteString("\t\"time\"\n\n")
+	}
+	g.b.WriteString("\t\"github.com/TheGrizzlyDev/vino/internal/pkg/cli\"\n)\n\n")
+}
+
+// typeNames returns the Go type of each command, by name.
+func (g *generator) typeNames() map[string]string {
+	used := map[string]bool{"Global": true, "Commands": true}
+	names := map[string]string{}
+	for _, c := range g.rt.Commands {
+		names[c.Name] = unique(goName(c.Name), "Command", used)
+	}
+	return names
+}
+
+// structType writes the struct type name holding flags and args, the flags
+// and arguments of the command cmd, or global flags if cmd is empty.
+func (g *generator) structType(name, cmd string, flags []Flag, args []Argument, global bool) {
+	fmt.Fprintf(&g.b, "type %s struct {\n", name)
+	// Global names the embedded global flags, or the struct holding them.
+	used := map[string]bool{"Global": true}
+	if global {
+		g.b.WriteString("\tGlobal\n\n")
+	}
+	for _, f := range flags {
+		field := unique(goName(strings.TrimLeft(f.Name, "-")), "Flag", used)
+		tags := []string{"cli_flag", f.Name}
+		if len(f.Aliases) > 0 {
+			tags = append(tags, "cli_flag_alternatives", strings.Join(f.Aliases, "|"))
+		}
+		tags = append(tags, "cli_group", f.group(cmd))
+		if len(f.Enum) > 1 {
+			tags = append(tags, "cli_enum", strings.Join(f.Enum, "|"))
+		}
+		if f.Help != "" {
+			tags = append(tags, "cli_help", f.Help)
+		}
+		fmt.Fprintf(&g.b, "\t%s %s %s\n", field, f.goType(), structTag(tags...))
+	}
+	if len(flags) > 0 && len(args) > 0 {
+		g.b.WriteString("\n")
+	}
+	for _, a := range args {
+		if a.Literal != "" {
+			continue
+		}
+		field := unique(goName(a.Name), "Arg", used)
+		typ := "string"
+		if a.Variadic {
+			typ = "[]string"
+		}
+		fmt.Fprintf(&g.b, "\t%s %s %s\n", field, typ, structTag("cli_argument", a.Name))
+	}
+	g.b.WriteString("}\n\n")
+}
+
+// slots writes the Slots method of the command c, of type name.
+func (g *generator) slots(name string, c Command) {
+	fmt.Fprintf(&g.b, "func (%s) Slots() cli.Slot {\n\treturn cli.Group{\n", name)
+	if groups := flagGroups(c.Name, c.Flags); len(groups) > 0 {
+		g.b.WriteString("\t\tUnordered: []cli.Slot{\n")
+		for _, grp := range groups {
+			fmt.Fprintf(&g.b, "\t\t\tcli.FlagGroup{Name: %q},\n", grp)
+		}
+		g.b.WriteString("\t\t},\n")
+	}
+	g.b.WriteString("\t\tOrdered: []cli.Slot{\n")
+	for _, grp := range flagGroups("", g.rt.Global) {
+		fmt.Fprintf(&g.b, "\t\t\tcli.FlagGroup{Name: %q},\n", grp)
+	}
+	fmt.Fprintf(&g.b, "\t\t\tcli.Subcommand{Value: %q},\n", c.Name)
+	// A literal and the arguments after it are grouped, as in
+	// "<container_id> [-- <command> <args>...]".
+	closing := 0
+	for _, a := range c.Arguments {
+		switch {
+		case a.Literal != "":
+			g.b.WriteString("cli.Group{\nOrdered: []cli.Slot{\n")
+			closing++
+			fmt.Fprintf(&g.b, "cli.Literal{Value: %q},\n", a.Literal)
+		case a.Variadic:
+			fmt.Fprintf(&g.b, "cli.Arguments{Name: %q},\n", a.Name)
+		default:
+			fmt.Fprintf(&g.b, "cli.Argument{Name: %q},\n", a.Name)
+		}
+	}
+	g.b.WriteString(strings.Repeat("},\n},\n", closing))
+	g.b.WriteString("\t\t},\n\t}\n}\n\n")
+}
+
+// flagGroups returns the groups of flags, of the command cmd or global,
+// in order.
+func flagGroups(cmd string, flags []Flag) []string {
+	var groups []string
+	for _, f := range flags {
+		if grp := f.group(cmd); !slices.Contains(groups, grp) {
+			groups = append(groups, grp)
+		}
+	}
+	return groups
+}
+
+// structTag renders a struct tag of the keys and values in kv.
+func structTag(kv ...string) string {
+	var parts []string
+	for i := 0; i+1 < len(kv); i += 2 {
+		parts = append(parts, kv[i]+":"+strconv.Quote(kv[i+1]))
+	}
+	tag := strings.Join(parts, " ")
+	if strings.Contains(tag, "`") {
+		return strconv.Quote(tag)
+	}
+	return "`" + tag + "`"
+}
+
+// initialisms are spelled in capitals in Go names.
+var initialisms = map[string]string{
+	"api": "API", "cpu": "CPU", "fd": "FD", "fds": "FDs", "gid": "GID", "id": "ID", "io": "IO",
+	"ip": "IP", "json": "JSON", "lsm": "LSM", "oci": "OCI", "pid": "PID", "tcp": "TCP",
+	"tty": "TTY", "uid": "UID", "url": "URL",
+}
+
+// goName turns a command, flag or argument name into an exported Go name:
+// "console-socket" is ConsoleSocket, "container_id" ContainerID.
+func goName(s string) string {
+	var b strings.Builder
+	for _, w := range strings.FieldsFunc(s, func(r rune) bool { return !unicode.IsLetter(r) && !unicode.IsDigit(r) }) {
+		if i, ok := initialisms[strings.ToLower(w)]; ok {
+			b.WriteString(i)
+			continue
+		}
+		r := []rune(strings.ToLower(w))
+		r[0] = unicode.ToUpper(r[0])
+		b.WriteString(string(r))
+	}
+	name := b.String()
+	if name == "" || !unicode.IsLetter([]rune(name)[0]) {
+		name = "X" + name
+	}
+	return name
+}
+
+// unique returns name, or name followed by suffix and a number if needed,
+// so that it isn't in used; it adds it there.
+func unique(name, suffix string, used map[string]bool) string {
+	if used[name] {
+		base := name + suffix
+		name = base
+		for i := 2; used[name]; i++ {
+			name = base + strconv.Itoa(i)
+		}
+	}
+	used[name] = true
+	return name
+}
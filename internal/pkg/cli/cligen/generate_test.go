@@ -0,0 +1,134 @@
+package cligen
+
+import (
+	"bytes"
+	"go/ast"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestGenerate(t *testing.T) {
+	t.Parallel()
+	rt, err := FromHelp("runc", runcHelp.help)
+	if err != nil {
+		t.Fatalf("FromHelp: %v", err)
+	}
+	// Amended as a reviewer would: help output doesn't tell these.
+	exec := &rt.Commands[1]
+	exec.Flags[1].Type = "map[string]string"
+	exec.Flags[3].Type = "[]uint"
+	exec.Arguments = append(exec.Arguments[:1], Argument{Literal: "--"}, Argument{Name: "command"}, Argument{Name: "args", Variadic: true})
+	rt.Global = append(rt.Global, Flag{Name: "--timeout", Type: "time.Duration", Help: "give up after `timeout`"})
+
+	src, err := Generate(rt, Options{Package: "runcgen", Source: "runc --help"})
+	if err != nil {
+		t.Fatalf("Generate: %v", err)
+	}
+	for _, want := range []string{
+		"// Code generated by cligen from runc --help; DO NOT EDIT.\n",
+		"\t\"time\"\n",
+		"\tRootless string `cli_flag:\"--rootless\" cli_group:\"global\" cli_enum:\"true|false|auto\" cli_help:",
+		"\tTimeout time.Duration \"cli_flag:\\\"--timeout\\\" cli_group:\\\"global\\\" cli_help:\\\"give up after `timeout`\\\"\"\n",
+		"// Exec is \"runc exec\": execute new process inside the container.\ntype Exec struct {\n\tGlobal\n",
+		"\tEnv            map[string]string `cli_flag:\"--env\" cli_flag_alternatives:\"-e\" cli_group:\"exec\"",
+		"\tPreserveFDs    int ",
+		"\tContainerID string   `cli_argument:\"container_id\"`\n\tCommand     string   `cli_argument:\"command\"`\n\tArgs        []string `cli_argument:\"args\"`\n",
+		`			cli.Subcommand{Value: "exec"},
+			cli.Argument{Name: "container_id"},
+			cli.Group{
+				Ordered: []cli.Slot{
+					cli.Literal{Value: "--"},
+					cli.Argument{Name: "command"},
+					cli.Arguments{Name: "args"},
+				},
+			},
+		},`,
+		"type Commands struct {\n\tCheckpoint *Checkpoint\n\tExec       *Exec\n\tKill       *Kill\n}\n",
+	} {
+		if !containsCode(src, want) {
+			t.Errorf("generated code lacks\n%s\ngot:\n%s", want, src)
+		}
+	}
+
+	// The generated code builds against the cli package.
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "commands_gen.go", src, parser.ParseComments)
+	if err != nil {
+		t.Fatalf("parse generated code: %v", err)
+	}
+	conf := types.Config{Importer: importer.ForCompiler(fset, "source", nil)}
+	if _, err := conf.Check("runcgen", fset, []*ast.File{f}, nil); err != nil {
+		t.Fatalf("type-check generated code: %v\n%s", err, src)
+	}
+}
+
+// containsCode reports whether src contains want, but for the amount of
+// white space, which gofmt aligns.
+func containsCode(src []byte, want string) bool {
+	return strings.Contains(strings.Join(strings.Fields(string(src)), " "), strings.Join(strings.Fields(want), " "))
+}
+
+func TestGenerate_Names(t *testing.T) {
+	t.Parallel()
+	rt := Runtime{
+		Name:   "rt",
+		Global: []Flag{{Name: "--global", Type: "bool"}},
+		Commands: []Command{
+			{Name: "commands", Flags: []Flag{{Name: "--id", Type: "bool"}, {Name: "-i", Type: "bool", Group: "extra"}}, Arguments: []Argument{{Name: "id"}}},
+		},
+	}
+	src, err := Generate(rt, Options{Package: "p", Source: "rt.json"})
+	if err != nil {
+		t.Fatalf("Generate: %v", err)
+	}
+	for _, want := range []string{
+		"GlobalFlag bool `cli_flag:\"--global\"",
+		"type CommandsCommand struct {",
+		"ID bool `cli_flag:\"--id\" cli_group:\"commands\"`",
+		"I  bool `cli_flag:\"-i\" cli_group:\"extra\"`",
+		"IDArg string `cli_argument:\"id\"`",
+		"cli.FlagGroup{Name: \"commands\"},\n\t\t\tcli.FlagGroup{Name: \"extra\"},",
+	} {
+		if !containsCode(src, want) {
+			t.Errorf("generated code lacks %q:\n%s", want, src)
+		}
+	}
+}
+
+func TestJSON(t *testing.T) {
+	t.Parallel()
+	rt, err := FromHelp("runc", runcHelp.help)
+	if err != nil {
+		t.Fatalf("FromHelp: %v", err)
+	}
+	var b bytes.Buffer
+	if err := WriteJSON(&b, rt); err != nil {
+		t.Fatalf("WriteJSON: %v", err)
+	}
+	got, err := ReadJSON(&b)
+	if err != nil {
+		t.Fatalf("ReadJSON: %v", err)
+	}
+	if !reflect.DeepEqual(got, rt) {
+		t.Errorf("ReadJSON(WriteJSON(rt)) =\n  %+v\nwant\n  %+v", got, rt)
+	}
+
+	_, err = ReadJSON(strings.NewReader(`{"name": "rt", "commands": [
+		{"name": "run", "flags": [{"name": "--x", "type": "float"}, {"name": "-y", "enum": ["a"], "type": "int"}],
+		 "arguments": [{"name": "rest", "variadic": true}, {"literal": "--", "name": "sep"}]}]}`))
+	for _, want := range []string{
+		`run: flag "--x" has unsupported type "float"`,
+		`run: flag "-y" has an enum but type "int"`,
+		`run: variadic argument "rest" is not the last one`,
+		`run: literal "--" has a name or is variadic`,
+	} {
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("ReadJSON = %v, want an error with %q", err, want)
+		}
+	}
+}
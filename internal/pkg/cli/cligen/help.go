@@ -0,0 +1,323 @@
+package cligen
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// HelpFunc returns the --help output of a runtime, or of its subcommand
+// if args names one.
+type HelpFunc func(args ...string) (string, error)
+
+// FromHelp describes the runtime name from its help output: the global
+// flags and the commands its --help lists, and the flags and arguments of
+// each command from its own.
+//
+// It reads the help of urfave/cli (runc), argp (crun) and clap (youki)
+// programs: sections such as "OPTIONS:" or "Commands:", flags spelled
+// "--env value, -e value", "-d, --detach" or "--log <LOG>", enums listed
+// as "[possible values: a, b]", "('a', 'b' or 'c')" or "a|b", and
+// arguments in the usage line.
+func FromHelp(name string, help HelpFunc) (Runtime, error) {
+	text, err := help()
+	if err != nil {
+		return Runtime{}, fmt.Errorf("%s --help: %w", name, err)
+	}
+	top := parseHelp(text, "")
+	rt := Runtime{Name: name, Global: append(top.globals, top.flags...)}
+	for _, entry := range top.commands {
+		text, err := help(entry.name)
+		if err != nil {
+			return Runtime{}, fmt.Errorf("%s %s --help: %w", name, entry.name, err)
+		}
+		page := parseHelp(text, entry.name)
+		cmd := Command{Name: entry.name, Doc: page.doc, Flags: page.flags, Arguments: page.args}
+		if cmd.Doc == "" {
+			cmd.Doc = entry.doc
+		}
+		rt.Commands = append(rt.Commands, cmd)
+	}
+	return rt, rt.Validate()
+}
+
+// helpPage is what a help output tells.
+type helpPage struct {
+	doc   string
+	usage string
+	// flags are the flags of the command; globals those listed apart as
+	// global options.
+	flags, globals []Flag
+	args           []Argument
+	commands       []commandEntry
+}
+
+type commandEntry struct {
+	name, doc string
+}
+
+var (
+	// headerRe matches section headers such as "OPTIONS:" or
+	// "Usage: youki [OPTIONS]".
+	headerRe = regexp.MustCompile(`^([A-Za-z][A-Za-z ]*):(.*)$`)
+	// gapRe separates flag and command names from their description.
+	gapRe = regexp.MustCompile(`\t|\s{2,}`)
+	// spellingRe matches one spelling of a flag, with its value if any:
+	// "--env value", "--log <LOG>", "--pid-file=FILE" or "--opt[=VALUE]".
+	spellingRe = regexp.MustCompile(`^(--?[A-Za-z0-9?][A-Za-z0-9_?.-]*)(\[?=\S+\]?|\s+\S.*)?$`)
+	commandRe  = regexp.MustCompile(`^[a-z][a-z0-9-]*$`)
+
+	possibleRe = regexp.MustCompile(`\[possible values: ([^\]]+)\]`)
+	quotedRe   = regexp.MustCompile(`'([a-z0-9-]+)'`)
+	pipedRe    = regexp.MustCompile(`(?:^|[\s(:])([a-z0-9-]+(?:\|[a-z0-9-]+)+)(?:$|[\s),.])`)
+	defaultRe  = regexp.MustCompile(`[(\[]default: (\[\]|[^)\]]*)[)\]]`)
+)
+
+// skippedFlags are answered by the runtime itself rather than passed
+// through.
+var skippedFlags = []string{"--help", "--version", "--usage"}
+
+// parseHelp reads the help output text of the command cmd, or of the
+// runtime if cmd is empty.
+func parseHelp(text, cmd string) helpPage {
+	var page helpPage
+	section := ""
+	// last is the flag a continuation line adds to the help of.
+	var last *Flag
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimRight(line, " \t\r")
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" {
+			last = nil
+			continue
+		}
+		if m := headerRe.FindStringSubmatch(line); m != nil {
+			section = strings.ToLower(m[1])
+			last = nil
+			if rest := strings.TrimSpace(m[2]); section == "usage" && rest != "" && page.usage == "" {
+				page.usage = rest
+			}
+			continue
+		}
+		indented := trimmed != line
+		switch {
+		case strings.HasPrefix(trimmed, "-"):
+			f, ok := parseFlag(trimmed)
+			last = nil
+			if !ok || slices.Contains(skippedFlags, f.Name) {
+				continue
+			}
+			if strings.Contains(section, "global") {
+				page.globals = append(page.globals, f)
+				last = &page.globals[len(page.globals)-1]
+			} else {
+				page.flags = append(page.flags, f)
+				last = &page.flags[len(page.flags)-1]
+			}
+		case last != nil && indented:
+			last.Help = strings.TrimSpace(last.Help + " " + trimmed)
+		case strings.Contains(section, "command"):
+			if e, ok := parseCommand(trimmed); ok {
+				page.commands = append(page.commands, e)
+			}
+		case section == "usage" && page.usage == "":
+			page.usage = trimmed
+		case section == "name" && page.doc == "":
+			if _, doc, ok := strings.Cut(trimmed, " - "); ok {
+				page.doc = doc
+			}
+		case (section == "" && !indented || section == "description") && page.doc == "":
+			page.doc = trimmed
+		}
+	}
+	for _, flags := range [][]Flag{page.flags, page.globals} {
+		for i := range flags {
+			flags[i].infer()
+		}
+	}
+	if cmd != "" {
+		page.args = usageArgs(page.usage, cmd)
+	}
+	return page
+}
+
+// parseFlag reads a flag from its line of help, such as
+// "--env value, -e value  set environment variables".
+func parseFlag(line string) (Flag, bool) {
+	spec, help := line, ""
+	if loc := gapRe.FindStringIndex(line); loc != nil {
+		spec, help = line[:loc[0]], strings.TrimSpace(line[loc[1]:])
+	}
+	f := Flag{Type: "bool", Help: help}
+	for _, spelling := range strings.Split(spec, ",") {
+		m := spellingRe.FindStringSubmatch(strings.TrimSpace(spelling))
+		if m == nil {
+			return Flag{}, false
+		}
+		name, value := m[1], strings.TrimSpace(m[2])
+		if value != "" {
+			f.Type = "string"
+			if strings.HasSuffix(value, "...") {
+				f.Type = "[]string"
+			}
+		}
+		// The long spelling is the name of the flag.
+		switch {
+		case f.Name == "":
+			f.Name = name
+		case strings.HasPrefix(name, "--") && !strings.HasPrefix(f.Name, "--"):
+			f.Aliases = append(f.Aliases, f.Name)
+			f.Name = name
+		default:
+			f.Aliases = append(f.Aliases, name)
+		}
+	}
+	return f, f.Name != ""
+}
+
+// infer tells the enum and type of f from its help.
+func (f *Flag) infer() {
+	if f.Type == "bool" {
+		return
+	}
+	var enum []string
+	if m := possibleRe.FindStringSubmatch(f.Help); m != nil {
+		for _, v := range strings.Split(m[1], ",") {
+			enum = append(enum, strings.TrimSpace(v))
+		}
+	} else if ms := quotedRe.FindAllStringSubmatch(f.Help, -1); len(ms) > 1 {
+		for _, m := range ms {
+			enum = append(enum, m[1])
+		}
+	} else if m := pipedRe.FindStringSubmatch(f.Help); m != nil {
+		enum = strings.Split(m[1], "|")
+	}
+	if len(enum) > 1 && f.Type == "string" {
+		f.Enum = enum
+		return
+	}
+	if m := defaultRe.FindStringSubmatch(f.Help); m != nil && f.Type == "string" {
+		def := strings.Trim(m[1], `"'`)
+		if def == "[]" {
+			f.Type = "[]string"
+		} else if _, err := strconv.Atoi(def); err == nil {
+			f.Type = "int"
+		}
+	}
+}
+
+// parseCommand reads a command from its line in a list of commands, such
+// as "exec  execute new process" or "exec - exec a command".
+func parseCommand(line string) (commandEntry, bool) {
+	names, doc := line, ""
+	if loc := gapRe.FindStringIndex(line); loc != nil {
+		names, doc = line[:loc[0]], strings.TrimSpace(line[loc[1]:])
+	} else if n, d, ok := strings.Cut(line, " - "); ok {
+		names, doc = n, d
+	}
+	name, _, _ := strings.Cut(names, ",")
+	name = strings.TrimSpace(name)
+	doc = strings.TrimSpace(strings.TrimPrefix(doc, "- "))
+	if !commandRe.MatchString(name) || name == "help" {
+		return commandEntry{}, false
+	}
+	return commandEntry{name: name, doc: doc}, true
+}
+
+// usageArgs reads the arguments of the command cmd from its usage line:
+// "<container-id>", "CONTAINER", "[signal]" or "<ARGS>..." following cmd,
+// but for options and the values of flags.
+func usageArgs(usage, cmd string) []Argument {
+	toks := usageTokens(usage)
+	i := slices.Index(toks, cmd)
+	if i < 0 {
+		return nil
+	}
+	var args []Argument
+	afterFlag := false
+	for _, t := range toks[i+1:] {
+		if t == "|" || t == "||" || (len(args) > 0 && args[len(args)-1].Variadic) {
+			break
+		}
+		if t == "--" {
+			args = append(args, Argument{Literal: t})
+			continue
+		}
+		if strings.HasPrefix(t, "-") {
+			afterFlag = !strings.Contains(t, "=")
+			continue
+		}
+		value := afterFlag
+		afterFlag = false
+		if value && strings.HasPrefix(t, "<") {
+			continue
+		}
+		inner := t
+		variadic := strings.HasSuffix(inner, "...")
+		inner = strings.TrimSuffix(inner, "...")
+		optional := strings.HasPrefix(inner, "[")
+		inner = strings.TrimSuffix(strings.TrimPrefix(inner, "["), "]")
+		if strings.HasSuffix(inner, "...") {
+			variadic = true
+			inner = strings.TrimSuffix(inner, "...")
+		}
+		if strings.HasPrefix(inner, "-") || strings.Contains(strings.ToLower(inner), "option") {
+			continue
+		}
+		if strings.HasPrefix(inner, "<") && strings.HasSuffix(inner, ">") {
+			inner = inner[1 : len(inner)-1]
+		} else if !optional && inner != strings.ToUpper(inner) {
+			// Lower-case words are literals of the usage, not arguments.
+			continue
+		}
+		name := argName(inner)
+		if name == "" || slices.ContainsFunc(args, func(a Argument) bool { return a.Name == name }) {
+			continue
+		}
+		args = append(args, Argument{Name: name, Variadic: variadic})
+	}
+	return args
+}
+
+// usageTokens splits a usage line on spaces outside of brackets.
+func usageTokens(usage string) []string {
+	var toks []string
+	var cur strings.Builder
+	depth := 0
+	for _, r := range usage {
+		switch {
+		case r == '[' || r == '<':
+			depth++
+		case (r == ']' || r == '>') && depth > 0:
+			depth--
+		case r == ' ' && depth == 0:
+			if cur.Len() > 0 {
+				toks = append(toks, cur.String())
+				cur.Reset()
+			}
+			continue
+		}
+		cur.WriteRune(r)
+	}
+	if cur.Len() > 0 {
+		toks = append(toks, cur.String())
+	}
+	return toks
+}
+
+// argName turns the name of an argument in a usage line into the name of
+// its slot: "container-id" and "CONTAINER_ID" are "container_id".
+func argName(s string) string {
+	s = strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9':
+			return r
+		case r >= 'A' && r <= 'Z':
+			return r - 'A' + 'a'
+		}
+		return '_'
+	}, s)
+	return strings.Trim(s, "_")
+}
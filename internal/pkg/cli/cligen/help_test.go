@@ -0,0 +1,237 @@
+package cligen
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+// helps maps the arguments given before --help to the help output of a
+// runtime.
+type helps map[string]string
+
+func (h helps) help(args ...string) (string, error) {
+	key := fmt.Sprint(args)
+	text, ok := h[key]
+	if !ok {
+		return "", fmt.Errorf("no help for %s", key)
+	}
+	return text, nil
+}
+
+var runcHelp = helps{
+	"[]": `NAME:
+   runc - Open Container Initiative runtime
+
+USAGE:
+   runc [global options] command [command options] [arguments...]
+
+COMMANDS:
+     checkpoint  checkpoint a running container
+     exec        execute new process inside the container
+     kill        kill sends the specified signal (default: SIGTERM) to the container's init process
+     help, h     Shows a list of commands or help for one command
+
+GLOBAL OPTIONS:
+   --debug             enable debug logging
+   --log value         set the log file to write runc logs to (default is '/dev/stderr')
+   --log-format value  set the log format ('text' (default), or 'json') (default: "text")
+   --rootless value    ignore cgroup permission errors ('true', 'false', or 'auto') (default: "auto")
+   --help, -h          show help
+   --version, -v       print the version
+`,
+	"[checkpoint]": `NAME:
+   runc checkpoint - checkpoint a running container
+
+USAGE:
+   runc checkpoint [command options] <container-id>
+
+OPTIONS:
+   --image-path value           path for saving criu image files
+   --leave-running              leave the process running after checkpointing
+   --manage-cgroups-mode value  cgroups mode: soft|full|strict|ignore (default: "soft")
+   --empty-ns value             create a namespace, but don't restore its properties
+   --status-fd value            criu writes \0 to this FD once lazy-pages is ready (default: 0)
+`,
+	"[exec]": `NAME:
+   runc exec - execute new process inside the container
+
+USAGE:
+   runc exec [command options] <container-id> <command> [command options]  || -p process.json <container-id>
+
+OPTIONS:
+   --console-socket value             path to an AF_UNIX socket which will receive a file descriptor referencing the master end of the console's pseudoterminal
+   --env value, -e value              set environment variables (default: [])
+   --tty, -t                          allocate a pseudo-TTY
+   --additional-gids value, -g value  additional gids
+   --preserve-fds value               Pass N additional file descriptors to the container (stdio + $LISTEN_FDS + N in total)
+                                      (default: 0)
+`,
+	"[kill]": `NAME:
+   runc kill - kill sends the specified signal (default: SIGTERM) to the container's init process
+
+USAGE:
+   runc kill [command options] <container-id> [signal]
+
+OPTIONS:
+   --all, -a  send the specified signal to all processes inside the container
+`,
+}
+
+func TestFromHelp_Runc(t *testing.T) {
+	t.Parallel()
+	rt, err := FromHelp("runc", runcHelp.help)
+	if err != nil {
+		t.Fatalf("FromHelp: %v", err)
+	}
+	want := Runtime{
+		Name: "runc",
+		Global: []Flag{
+			{Name: "--debug", Type: "bool", Help: "enable debug logging"},
+			{Name: "--log", Type: "string", Help: "set the log file to write runc logs to (default is '/dev/stderr')"},
+			{Name: "--log-format", Type: "string", Enum: []string{"text", "json"}, Help: `set the log format ('text' (default), or 'json') (default: "text")`},
+			{Name: "--rootless", Type: "string", Enum: []string{"true", "false", "auto"}, Help: `ignore cgroup permission errors ('true', 'false', or 'auto') (default: "auto")`},
+		},
+		Commands: []Command{
+			{
+				Name: "checkpoint", Doc: "checkpoint a running container",
+				Flags: []Flag{
+					{Name: "--image-path", Type: "string", Help: "path for saving criu image files"},
+					{Name: "--leave-running", Type: "bool", Help: "leave the process running after checkpointing"},
+					{Name: "--manage-cgroups-mode", Type: "string", Enum: []string{"soft", "full", "strict", "ignore"}, Help: `cgroups mode: soft|full|strict|ignore (default: "soft")`},
+					{Name: "--empty-ns", Type: "string", Help: "create a namespace, but don't restore its properties"},
+					{Name: "--status-fd", Type: "int", Help: `criu writes \0 to this FD once lazy-pages is ready (default: 0)`},
+				},
+				Arguments: []Argument{{Name: "container_id"}},
+			},
+			{
+				Name: "exec", Doc: "execute new process inside the container",
+				Flags: []Flag{
+					{Name: "--console-socket", Type: "string", Help: "path to an AF_UNIX socket which will receive a file descriptor referencing the master end of the console's pseudoterminal"},
+					{Name: "--env", Aliases: []string{"-e"}, Type: "[]string", Help: "set environment variables (default: [])"},
+					{Name: "--tty", Aliases: []string{"-t"}, Type: "bool", Help: "allocate a pseudo-TTY"},
+					{Name: "--additional-gids", Aliases: []string{"-g"}, Type: "string", Help: "additional gids"},
+					{Name: "--preserve-fds", Type: "int", Help: "Pass N additional file descriptors to the container (stdio + $LISTEN_FDS + N in total) (default: 0)"},
+				},
+				Arguments: []Argument{{Name: "container_id"}, {Name: "command"}},
+			},
+			{
+				Name: "kill", Doc: "kill sends the specified signal (default: SIGTERM) to the container's init process",
+				Flags: []Flag{
+					{Name: "--all", Aliases: []string{"-a"}, Type: "bool", Help: "send the specified signal to all processes inside the container"},
+				},
+				Arguments: []Argument{{Name: "container_id"}, {Name: "signal"}},
+			},
+		},
+	}
+	if !reflect.DeepEqual(rt, want) {
+		t.Errorf("FromHelp =\n  %+v\nwant\n  %+v", rt, want)
+	}
+}
+
+func TestFromHelp_Crun(t *testing.T) {
+	t.Parallel()
+	crun := helps{
+		"[]": `Usage: crun [OPTION...] COMMAND [OPTION...]
+
+COMMANDS:
+	create      - create a container
+	exec        - exec a command in a running container
+
+      --cgroup-manager=MANAGER   cgroup manager
+      --debug                produce verbose output
+      --log=FILE             log destination
+  -?, --help                 Give this help list
+      --usage                Give a short usage message
+  -V, --version              Print program version
+`,
+		"[create]": `Usage: crun [OPTION...] create [OPTION...] CONTAINER
+OCI runtime
+
+  -b, --bundle=DIR           container bundle (default ".")
+      --console-socket=SOCKET   path to a socket that will receive the ptmx end
+                             of the tty
+  -?, --help                 Give this help list
+`,
+		"[exec]": `Usage: crun [OPTION...] exec CONTAINER CMD [ARGS]...
+OCI runtime
+
+  -d, --detach               detach the command in the background
+  -e, --env=ENV              add an environment variable
+`,
+	}
+	rt, err := FromHelp("crun", crun.help)
+	if err != nil {
+		t.Fatalf("FromHelp: %v", err)
+	}
+	if got := len(rt.Global); got != 3 || rt.Global[0].Name != "--cgroup-manager" || rt.Global[1].Type != "bool" {
+		t.Errorf("Global = %+v", rt.Global)
+	}
+	create := rt.Commands[0]
+	wantCreate := Command{
+		Name: "create", Doc: "create a container",
+		Flags: []Flag{
+			{Name: "--bundle", Aliases: []string{"-b"}, Type: "string", Help: `container bundle (default ".")`},
+			{Name: "--console-socket", Type: "string", Help: "path to a socket that will receive the ptmx end of the tty"},
+		},
+		Arguments: []Argument{{Name: "container"}},
+	}
+	if !reflect.DeepEqual(create, wantCreate) {
+		t.Errorf("create =\n  %+v\nwant\n  %+v", create, wantCreate)
+	}
+	if want := []Argument{{Name: "container"}, {Name: "cmd"}, {Name: "args", Variadic: true}}; !reflect.DeepEqual(rt.Commands[1].Arguments, want) {
+		t.Errorf("exec arguments = %+v, want %+v", rt.Commands[1].Arguments, want)
+	}
+}
+
+func TestFromHelp_Youki(t *testing.T) {
+	t.Parallel()
+	youki := helps{
+		"[]": `youki is a container runtime written in Rust
+
+Usage: youki [OPTIONS] <COMMAND>
+
+Commands:
+  create  Create a container
+  help    Print this message or the help of the given subcommand(s)
+
+Options:
+  -d, --debug                    change log level to debug, but the ` + "`log-level`" + ` flag takes precedence
+      --log-format <LOG_FORMAT>  set the log format [possible values: text, json]
+  -h, --help                     Print help
+`,
+		"[create]": `Create a container
+
+Usage: youki create [OPTIONS] --bundle <BUNDLE> <CONTAINER_ID>
+
+Arguments:
+  <CONTAINER_ID>  Name of the container instance to be started
+
+Options:
+  -b, --bundle <BUNDLE>
+          path to the bundle directory, containing config.json and root filesystem
+      --pid-file <PID_FILE>  File to write pid of the container created
+      --preserve-fds <PRESERVE_FDS>  Pass N additional file descriptors to the container [default: 0]
+`,
+	}
+	rt, err := FromHelp("youki", youki.help)
+	if err != nil {
+		t.Fatalf("FromHelp: %v", err)
+	}
+	if want := (Flag{Name: "--log-format", Type: "string", Enum: []string{"text", "json"}, Help: "set the log format [possible values: text, json]"}); !reflect.DeepEqual(rt.Global[1], want) {
+		t.Errorf("Global[1] = %+v, want %+v", rt.Global[1], want)
+	}
+	if len(rt.Commands) != 1 {
+		t.Fatalf("Commands = %+v, want create alone", rt.Commands)
+	}
+	create := rt.Commands[0]
+	if create.Doc != "Create a container" || !reflect.DeepEqual(create.Arguments, []Argument{{Name: "container_id"}}) {
+		t.Errorf("create = %+v", create)
+	}
+	if f := create.Flags[0]; f.Name != "--bundle" || f.Help != "path to the bundle directory, containing config.json and root filesystem" {
+		t.Errorf("--bundle = %+v", f)
+	}
+	if f := create.Flags[2]; f.Type != "int" {
+		t.Errorf("--preserve-fds = %+v, want an int", f)
+	}
+}
@@ -0,0 +1,161 @@
+// Package cligen describes the command line of a container runtime, as
+// read from its --help output or written down in JSON, and generates the
+// cli.Command structs parsing and rendering it.
+//
+// Descriptions read from --help are a starting point: help output doesn't
+// tell repeated flags or literals such as "--" apart, so the JSON
+// description is meant to be reviewed and amended, then generated from.
+package cligen
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+)
+
+// Runtime describes the command line of a runtime: its global flags,
+// given before the subcommand, and its commands.
+type Runtime struct {
+	Name     string    `json:"name"`
+	Version  string    `json:"version,omitempty"`
+	Global   []Flag    `json:"global,omitempty"`
+	Commands []Command `json:"commands"`
+}
+
+// Command is a subcommand of a runtime.
+type Command struct {
+	Name      string     `json:"name"`
+	Doc       string     `json:"doc,omitempty"`
+	Flags     []Flag     `json:"flags,omitempty"`
+	Arguments []Argument `json:"arguments,omitempty"`
+}
+
+// Flag is a flag of a command, or a global one.
+type Flag struct {
+	// Name is the main spelling of the flag, such as "--console-socket".
+	Name    string   `json:"name"`
+	Aliases []string `json:"aliases,omitempty"`
+	// Group is the flag group the flag belongs to: by default "global"
+	// for global flags, and the name of the command otherwise.
+	Group string `json:"group,omitempty"`
+	// Type is the Go type of the flag, one of Types, string if empty.
+	Type string   `json:"type,omitempty"`
+	Enum []string `json:"enum,omitempty"`
+	Help string   `json:"help,omitempty"`
+}
+
+// Argument is a positional argument of a command, or a literal token such
+// as "--" if Literal is set.
+type Argument struct {
+	Name     string `json:"name,omitempty"`
+	Variadic bool   `json:"variadic,omitempty"`
+	Literal  string `json:"literal,omitempty"`
+}
+
+// Types are the Go types flags may have.
+var Types = []string{"bool", "string", "int", "uint", "time.Duration", "[]string", "[]int", "[]uint", "map[string]string"}
+
+// ReadJSON reads the JSON description of a runtime.
+func ReadJSON(r io.Reader) (Runtime, error) {
+	var rt Runtime
+	dec := json.NewDecoder(r)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&rt); err != nil {
+		return Runtime{}, fmt.Errorf("read runtime description: %w", err)
+	}
+	return rt, rt.Validate()
+}
+
+// WriteJSON writes the JSON description of rt.
+func WriteJSON(w io.Writer, rt Runtime) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(rt)
+}
+
+// Validate checks that rt describes a command line Generate can render.
+func (rt Runtime) Validate() error {
+	var errs []string
+	if rt.Name == "" {
+		errs = append(errs, "runtime has no name")
+	}
+	checkFlags := func(where string, flags []Flag) {
+		seen := map[string]bool{}
+		for _, f := range flags {
+			for _, n := range append([]string{f.Name}, f.Aliases...) {
+				if !strings.HasPrefix(n, "-") {
+					errs = append(errs, fmt.Sprintf("%s: flag %q must start with '-'", where, n))
+				}
+				if seen[n] {
+					errs = append(errs, fmt.Sprintf("%s: flag %q is given twice", where, n))
+				}
+				seen[n] = true
+			}
+			if f.Type != "" && !slices.Contains(Types, f.Type) {
+				errs = append(errs, fmt.Sprintf("%s: flag %q has unsupported type %q", where, f.Name, f.Type))
+			}
+			if len(f.Enum) > 0 && f.Type != "" && f.Type != "string" {
+				errs = append(errs, fmt.Sprintf("%s: flag %q has an enum but type %q", where, f.Name, f.Type))
+			}
+			for _, e := range f.Enum {
+				if e == "" || strings.Contains(e, "|") {
+					errs = append(errs, fmt.Sprintf("%s: flag %q has invalid enum value %q", where, f.Name, e))
+				}
+			}
+		}
+	}
+	checkFlags("global", rt.Global)
+	names := map[string]bool{}
+	for _, c := range rt.Commands {
+		if c.Name == "" {
+			errs = append(errs, "command has no name")
+			continue
+		}
+		if names[c.Name] {
+			errs = append(errs, fmt.Sprintf("command %q is given twice", c.Name))
+		}
+		names[c.Name] = true
+		checkFlags(c.Name, c.Flags)
+		args := map[string]bool{}
+		for i, a := range c.Arguments {
+			switch {
+			case a.Literal != "" && (a.Name != "" || a.Variadic):
+				errs = append(errs, fmt.Sprintf("%s: literal %q has a name or is variadic", c.Name, a.Literal))
+			case a.Literal == "" && a.Name == "":
+				errs = append(errs, fmt.Sprintf("%s: argument %d has no name", c.Name, i))
+			case a.Variadic && i != len(c.Arguments)-1:
+				errs = append(errs, fmt.Sprintf("%s: variadic argument %q is not the last one", c.Name, a.Name))
+			}
+			if a.Name != "" && args[a.Name] {
+				errs = append(errs, fmt.Sprintf("%s: argument %q is given twice", c.Name, a.Name))
+			}
+			args[a.Name] = true
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("invalid runtime description:\n  - %s", strings.Join(errs, "\n  - "))
+	}
+	return nil
+}
+
+// group returns the flag group of f, a flag of the command cmd, or a
+// global flag if cmd is empty.
+func (f Flag) group(cmd string) string {
+	switch {
+	case f.Group != "":
+		return f.Group
+	case cmd == "":
+		return "global"
+	}
+	return strings.ReplaceAll(cmd, "-", "_")
+}
+
+// goType returns the Go type of f.
+func (f Flag) goType() string {
+	if f.Type == "" {
+		return "string"
+	}
+	return f.Type
+}
@@ -0,0 +1,436 @@
+// Package clitest checks that commands of the cli package survive being
+// parsed and rendered back: it generates random values of a command type
+// and random command lines spelling them, with flag alternatives,
+// --flag=value and unordered flags interleaved at any position Parse
+// accepts them.
+package clitest
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/TheGrizzlyDev/vino/internal/pkg/cli"
+)
+
+// Fuzz runs CheckRoundTrip for the command T on values generated from the
+// fuzzed seed.
+func Fuzz[T cli.Command](f *testing.F) {
+	for seed := range uint64(8) {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, seed uint64) {
+		CheckRoundTrip[T](t, rand.New(rand.NewPCG(seed, seed>>32)))
+	})
+}
+
+// union is the union of the sole command T, for ParseAny.
+type union[T any] struct{ Cmd *T }
+
+// CheckRoundTrip generates a value x of the command T and a command line
+// spelling it with r, and checks that:
+//
+//   - parsing the rendering of x with cli.ConvertToCmdline yields x;
+//   - parsing the generated command line yields x too, and renders as x
+//     does.
+func CheckRoundTrip[T cli.Command](t testing.TB, r *rand.Rand) {
+	t.Helper()
+	x, argv, err := Generate[T](r)
+	if err != nil {
+		t.Fatalf("Generate: %v", err)
+	}
+	rendered, err := cli.ConvertToCmdline(x)
+	if err != nil {
+		t.Fatalf("ConvertToCmdline(%+v): %v", x, err)
+	}
+	var fromRendered union[T]
+	if err := cli.ParseAny(&fromRendered, rendered); err != nil {
+		t.Fatalf("ParseAny(%q), rendered from %+v: %v", rendered, x, err)
+	}
+	if !reflect.DeepEqual(*fromRendered.Cmd, x) {
+		t.Fatalf("ParseAny(%q) =\n  %+v\nwant\n  %+v", rendered, *fromRendered.Cmd, x)
+	}
+
+	var fromArgv union[T]
+	if err := cli.ParseAny(&fromArgv, argv); err != nil {
+		t.Fatalf("ParseAny(%q), generated from %+v: %v", argv, x, err)
+	}
+	if !reflect.DeepEqual(*fromArgv.Cmd, x) {
+		t.Fatalf("ParseAny(%q) =\n  %+v\nwant\n  %+v", argv, *fromArgv.Cmd, x)
+	}
+	again, err := cli.ConvertToCmdline(*fromArgv.Cmd)
+	if err != nil {
+		t.Fatalf("ConvertToCmdline(%+v): %v", *fromArgv.Cmd, err)
+	}
+	if !slices.Equal(again, rendered) {
+		t.Fatalf("%q renders as %q, want %q", argv, again, rendered)
+	}
+}
+
+// Generate returns a random value of the command T, and a random command
+// line spelling it, subcommand included.
+//
+// Flags are set or left out at random, single arguments always set and
+// variadic ones given up to three values. Values are non-zero, so that
+// rendering doesn't leave them out, and drawn from an alphabet that doesn't
+// start a flag.
+func Generate[T cli.Command](r *rand.Rand) (T, []string, error) {
+	var x T
+	g := &generator{r: r, avoid: cli.SubcommandOf(x)}
+	if err := g.fill(reflect.ValueOf(&x).Elem()); err != nil {
+		return x, nil, err
+	}
+	argv := g.group(slotGroup(x.Slots()))
+	return x, argv, nil
+}
+
+// field is a tagged field of the command being generated.
+type field struct {
+	flag  string
+	alts  []string
+	group string
+	arg   string
+	// vals are the values given to the flag or argument, one per
+	// occurrence; a set bool flag has one empty value.
+	vals []string
+}
+
+type generator struct {
+	r      *rand.Rand
+	avoid  string
+	fields []*field
+}
+
+func slotGroup(s cli.Slot) cli.Group {
+	if g, ok := s.(cli.Group); ok {
+		return g
+	}
+	return cli.Group{Ordered: []cli.Slot{s}}
+}
+
+// fill sets the tagged fields of v, a command struct, at random.
+func (g *generator) fill(v reflect.Value) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if sf.PkgPath != "" {
+			continue
+		}
+		fv := v.Field(i)
+		_, embed := sf.Tag.Lookup("cli_embed")
+		if (sf.Anonymous || embed) && fv.Kind() == reflect.Struct {
+			if err := g.fill(fv); err != nil {
+				return err
+			}
+			continue
+		}
+		flag, hasFlag := sf.Tag.Lookup("cli_flag")
+		arg, hasArg := sf.Tag.Lookup("cli_argument")
+		if !hasFlag && !hasArg {
+			continue
+		}
+		f := &field{flag: flag, group: sf.Tag.Get("cli_group"), arg: arg}
+		for _, a := range strings.Split(sf.Tag.Get("cli_flag_alternatives"), "|") {
+			if a = strings.TrimSpace(a); a != "" {
+				f.alts = append(f.alts, a)
+			}
+		}
+		g.fields = append(g.fields, f)
+
+		_, hasDefault := sf.Tag.Lookup("cli_default")
+		// Flags left out would be set to their default, so they are always
+		// given.
+		if hasFlag && !hasDefault && g.r.IntN(2) == 0 {
+			continue
+		}
+		var enum []string
+		if e := sf.Tag.Get("cli_enum"); e != "" {
+			enum = strings.Split(e, "|")
+		}
+		vals, err := g.value(fv, enum, sf.Tag.Get("cli_sep"), hasArg)
+		if err != nil {
+			return fmt.Errorf("%s.%s: %w", t, sf.Name, err)
+		}
+		f.vals = vals
+	}
+	return nil
+}
+
+// value sets v at random and returns its values as command line tokens:
+// those of lists and maps joined by sep, if set, in groups at random.
+func (g *generator) value(v reflect.Value, enum []string, sep string, arg bool) ([]string, error) {
+	switch v.Kind() {
+	case reflect.Pointer:
+		v.Set(reflect.New(v.Type().Elem()))
+		return g.value(v.Elem(), enum, sep, arg)
+	case reflect.Slice, reflect.Map:
+		n := 1 + g.r.IntN(3)
+		if arg {
+			n = g.r.IntN(4)
+		}
+		var vals []string
+		for range n {
+			if v.Kind() == reflect.Map {
+				// Keys end at the first "=", values may hold more.
+				key := reflect.New(v.Type().Key()).Elem()
+				k, err := g.scalar(key, nil, "="+sep)
+				if err != nil {
+					return nil, err
+				}
+				elem := reflect.New(v.Type().Elem()).Elem()
+				e, err := g.scalar(elem, nil, sep)
+				if err != nil {
+					return nil, err
+				}
+				if v.IsNil() {
+					v.Set(reflect.MakeMap(v.Type()))
+				}
+				if v.MapIndex(key).IsValid() {
+					continue
+				}
+				v.SetMapIndex(key, elem)
+				vals = append(vals, k+"="+e)
+				continue
+			}
+			elem := reflect.New(v.Type().Elem()).Elem()
+			val, err := g.scalar(elem, enum, sep)
+			if err != nil {
+				return nil, err
+			}
+			v.Set(reflect.Append(v, elem))
+			vals = append(vals, val)
+		}
+		if sep == "" {
+			return vals, nil
+		}
+		var joined []string
+		for len(vals) > 0 {
+			k := 1 + g.r.IntN(len(vals))
+			joined = append(joined, strings.Join(vals[:k], sep))
+			vals = vals[k:]
+		}
+		return joined, nil
+	}
+	val, err := g.scalar(v, enum, "")
+	if err != nil {
+		return nil, err
+	}
+	return []string{val}, nil
+}
+
+var durationType = reflect.TypeFor[time.Duration]()
+
+// scalar sets v, of a kind a flag or argument holds once, to a random
+// non-zero value and returns it as a token, without any of the characters
+// in exclude.
+func (g *generator) scalar(v reflect.Value, enum []string, exclude string) (string, error) {
+	if v.Type() == durationType {
+		units := []time.Duration{time.Millisecond, time.Second, time.Minute, time.Hour}
+		d := time.Duration(1+g.r.IntN(100)) * units[g.r.IntN(len(units))]
+		v.SetInt(int64(d))
+		return d.String(), nil
+	}
+	switch v.Kind() {
+	case reflect.Bool:
+		v.SetBool(true)
+		return "", nil
+	case reflect.String:
+		s := g.token(exclude)
+		if len(enum) > 0 {
+			s = enum[g.r.IntN(len(enum))]
+		}
+		v.SetString(s)
+		return s, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n := int64(1 + g.r.IntN(100))
+		if g.r.IntN(4) == 0 {
+			n = -n
+		}
+		v.SetInt(n)
+		return strconv.FormatInt(n, 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		n := uint64(1 + g.r.IntN(200))
+		v.SetUint(n)
+		return strconv.FormatUint(n, 10), nil
+	}
+	return "", fmt.Errorf("unsupported kind %s", v.Kind())
+}
+
+const (
+	tokenStart = "abcdefghijklmnopqrstuvwxyz0123456789/"
+	tokenRest  = tokenStart + "._:,=-"
+)
+
+// token returns a random value that doesn't look like a flag, "--" or the
+// subcommand, and holds none of the characters in exclude.
+func (g *generator) token(exclude string) string {
+	without := func(alphabet string) string {
+		return strings.Map(func(r rune) rune {
+			if strings.ContainsRune(exclude, r) {
+				return -1
+			}
+			return r
+		}, alphabet)
+	}
+	start, rest := without(tokenStart), without(tokenRest)
+	for {
+		var b strings.Builder
+		b.WriteByte(start[g.r.IntN(len(start))])
+		for range g.r.IntN(8) {
+			b.WriteByte(rest[g.r.IntN(len(rest))])
+		}
+		if s := b.String(); s != g.avoid {
+			return s
+		}
+	}
+}
+
+// flagUnits returns the occurrences of the flags of group, each spelled
+// with a random name of the flag and, at random, as --flag=value, in random
+// order but for the occurrences of a repeated flag, which keep theirs.
+func (g *generator) flagUnits(group string) [][]string {
+	var units [][]string
+	var owners []int
+	for i, f := range g.fields {
+		if f.flag == "" || f.group != group {
+			continue
+		}
+		names := append([]string{f.flag}, f.alts...)
+		for _, val := range f.vals {
+			name := names[g.r.IntN(len(names))]
+			switch {
+			case val == "":
+				// A bool flag takes no value.
+				units = append(units, []string{name})
+			case g.r.IntN(2) == 0:
+				units = append(units, []string{name + "=" + val})
+			default:
+				units = append(units, []string{name, val})
+			}
+			owners = append(owners, i)
+		}
+	}
+	// Shuffle the owners of the occurrences, then hand each its own in
+	// order.
+	byOwner := map[int][][]string{}
+	for i, u := range units {
+		byOwner[owners[i]] = append(byOwner[owners[i]], u)
+	}
+	g.r.Shuffle(len(owners), func(i, j int) { owners[i], owners[j] = owners[j], owners[i] })
+	for i, o := range owners {
+		units[i] = byOwner[o][0]
+		byOwner[o] = byOwner[o][1:]
+	}
+	return units
+}
+
+// argTokens returns the values of the argument name.
+func (g *generator) argTokens(name string) []string {
+	var toks []string
+	for _, f := range g.fields {
+		if f.arg == name {
+			toks = append(toks, f.vals...)
+		}
+	}
+	return toks
+}
+
+// greedy reports whether s takes the rest of the command line, or makes
+// unordered flags unavailable after it.
+func greedy(s cli.Slot) bool {
+	switch v := s.(type) {
+	case cli.Arguments, cli.Literal:
+		return true
+	case cli.Group:
+		for _, o := range v.Ordered {
+			if greedy(o) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// group renders grp, with the unordered flags of grp at random positions
+// among the boundaries of its ordered slots Parse takes them at: after the
+// subcommand, if any, and up to the first slot taking the rest of the
+// command line.
+func (g *generator) group(grp cli.Group) []string {
+	var units [][]string
+	var unorderedArgs []string
+	for _, u := range grp.Unordered {
+		switch uv := u.(type) {
+		case cli.FlagGroup:
+			units = append(units, g.flagUnits(uv.Name)...)
+		case cli.Argument:
+			unorderedArgs = append(unorderedArgs, g.argTokens(uv.Name)...)
+		case cli.Arguments:
+			unorderedArgs = append(unorderedArgs, g.argTokens(uv.Name)...)
+		}
+	}
+
+	// Boundary b lies before ordered slot b, or at the end.
+	first, last := 0, len(grp.Ordered)
+	for i, o := range grp.Ordered {
+		if _, ok := o.(cli.Subcommand); ok {
+			first = i + 1
+		}
+	}
+	for i := first; i < len(grp.Ordered); i++ {
+		if greedy(grp.Ordered[i]) {
+			last = i
+			if _, ok := grp.Ordered[i].(cli.Literal); ok && i > first {
+				last = i - 1
+			}
+			break
+		}
+	}
+	// Units are spread over the boundaries in order, so that repeated
+	// flags keep theirs.
+	bounds := make([]int, len(units))
+	for i := range bounds {
+		bounds[i] = first
+		if last > first {
+			bounds[i] += g.r.IntN(last - first + 1)
+		}
+	}
+	slices.Sort(bounds)
+	at := make([][][]string, len(grp.Ordered)+1)
+	for i, u := range units {
+		at[bounds[i]] = append(at[bounds[i]], u)
+	}
+	// Unordered arguments take whatever follows the flags.
+	at[last] = append(at[last], unorderedArgs)
+
+	var argv []string
+	for i := 0; i <= len(grp.Ordered); i++ {
+		for _, u := range at[i] {
+			argv = append(argv, u...)
+		}
+		if i == len(grp.Ordered) {
+			break
+		}
+		switch ov := grp.Ordered[i].(type) {
+		case cli.FlagGroup:
+			for _, u := range g.flagUnits(ov.Name) {
+				argv = append(argv, u...)
+			}
+		case cli.Subcommand:
+			argv = append(argv, ov.Value)
+		case cli.Literal:
+			argv = append(argv, ov.Value)
+		case cli.Argument:
+			argv = append(argv, g.argTokens(ov.Name)...)
+		case cli.Arguments:
+			argv = append(argv, g.argTokens(ov.Name)...)
+		case cli.Group:
+			argv = append(argv, g.group(ov)...)
+		}
+	}
+	return argv
+}
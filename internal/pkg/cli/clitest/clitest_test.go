@@ -0,0 +1,61 @@
+package clitest
+
+import (
+	"math/rand/v2"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/TheGrizzlyDev/vino/internal/pkg/cli"
+)
+
+type wrapCmd struct {
+	Delegate string            `cli_flag:"--delegate_path" cli_group:"vinoc"`
+	Env      []string          `cli_flag:"--env" cli_flag_alternatives:"-e" cli_group:"process"`
+	Tty      bool              `cli_flag:"--tty" cli_flag_alternatives:"-t" cli_group:"process"`
+	Limit    *int16            `cli_flag:"--limit" cli_group:"process" cli_default:"5"`
+	Format   string            `cli_flag:"--format" cli_group:"process" cli_enum:"text|json"`
+	Timeout  *time.Duration    `cli_flag:"--timeout" cli_group:"process"`
+	Labels   map[string]string `cli_flag:"--label" cli_flag_alternatives:"-l" cli_group:"process"`
+	Caps     []string          `cli_flag:"--caps" cli_group:"process" cli_sep:","`
+	ID       string            `cli_argument:"id"`
+	Command  string            `cli_argument:"command"`
+	Args     []string          `cli_argument:"args"`
+}
+
+func (wrapCmd) Slots() cli.Slot {
+	return cli.Group{
+		Unordered: []cli.Slot{cli.FlagGroup{Name: "process"}},
+		Ordered: []cli.Slot{
+			cli.FlagGroup{Name: "vinoc"},
+			cli.Subcommand{Value: "wrap"},
+			cli.Argument{Name: "id"},
+			cli.Group{Ordered: []cli.Slot{
+				cli.Literal{Value: "--"},
+				cli.Argument{Name: "command"},
+				cli.Arguments{Name: "args"},
+			}},
+		},
+	}
+}
+
+func FuzzWrap(f *testing.F) { Fuzz[wrapCmd](f) }
+
+func TestGenerate(t *testing.T) {
+	t.Parallel()
+	r := rand.New(rand.NewPCG(1, 2))
+	for range 100 {
+		x, argv, err := Generate[wrapCmd](r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if x.Limit == nil || x.ID == "" || x.Command == "" {
+			t.Fatalf("Generate left a defaulted flag or an argument unset: %+v", x)
+		}
+		sub := slices.Index(argv, "wrap")
+		sep := slices.Index(argv, "--")
+		if sub == -1 || sep < sub || argv[sep+1] != x.Command {
+			t.Fatalf("argv %q out of order", argv)
+		}
+	}
+}
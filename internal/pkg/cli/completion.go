@@ -0,0 +1,302 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+)
+
+// Shells completion scripts can be written for.
+var Shells = []string{"bash", "zsh", "fish"}
+
+// Tree is a command line tool, or part of one, modeled with the package: a
+// command and the subcommands its arguments are dispatched to, the way
+// ParseAny dispatches them to a union.
+type Tree struct {
+	// Command is the command at the root of the tree. At the root of a
+	// tool, its subcommand is ignored: the tool goes by its name.
+	Command Command
+	// Children are the subcommands of Command.
+	Children []Tree
+}
+
+// Leaves returns trees of cmds without subcommands of their own.
+func Leaves(cmds ...Command) []Tree {
+	trees := make([]Tree, 0, len(cmds))
+	for _, c := range cmds {
+		trees = append(trees, Tree{Command: c})
+	}
+	return trees
+}
+
+// completionFlag is what completion knows of a flag.
+type completionFlag struct {
+	names []string
+	value bool
+	enum  []string
+	file  bool
+	help  string
+}
+
+// completionNode is a command of a tree, by its command line.
+type completionNode struct {
+	path  string
+	desc  string
+	subs  []string
+	flags []completionFlag
+}
+
+// pathWords are the last words of the names of flags taking paths, e.g.
+// --pid-file or --delegate_path.
+var pathWords = map[string]bool{
+	"path": true, "file": true, "dir": true, "root": true, "log": true, "socket": true,
+	"bundle": true, "output": true, "process": true, "criu": true,
+}
+
+func takesPath(flag string) bool {
+	words := strings.FieldsFunc(flag, func(r rune) bool { return r == '-' || r == '_' })
+	return len(words) > 0 && pathWords[words[len(words)-1]]
+}
+
+// completionNodes flattens t, named name, into its commands.
+func completionNodes(name string, t Tree) []completionNode {
+	n := completionNode{path: name}
+	if d, ok := t.Command.(Describer); ok {
+		n.desc = d.Description()
+	}
+	if t.Command != nil {
+		for _, f := range helpFields(t.Command) {
+			if f.flag == "" {
+				continue
+			}
+			cf := completionFlag{
+				names: append([]string{f.flag}, f.alts...),
+				value: valueHint(f) != "",
+				file:  takesPath(f.flag),
+				help:  f.help,
+			}
+			if f.enum != "" {
+				cf.enum = strings.Split(f.enum, "|")
+				cf.file = false
+			}
+			n.flags = append(n.flags, cf)
+		}
+	}
+	nodes := []completionNode{n}
+	for _, c := range t.Children {
+		sub := SubcommandOf(c.Command)
+		if sub == "" {
+			continue
+		}
+		nodes[0].subs = append(nodes[0].subs, sub)
+		nodes = append(nodes, completionNodes(name+" "+sub, c)...)
+	}
+	return nodes
+}
+
+// WriteCompletion writes the script completing the command line of the tool
+// name, modeled by t, in shell to w: subcommands, the flags of each command,
+// the values of enum flags, and files for the flags taking paths and for
+// the arguments of commands without subcommands.
+func WriteCompletion(w io.Writer, shell, name string, t Tree) error {
+	nodes := completionNodes(name, t)
+	fn := "_" + regexp.MustCompile(`[^A-Za-z0-9_]`).ReplaceAllString(name, "_")
+	switch shell {
+	case "bash":
+		writeBash(w, fn, name, nodes)
+	case "zsh":
+		writeZsh(w, fn, name, nodes)
+	case "fish":
+		writeFish(w, fn, name, nodes)
+	default:
+		return fmt.Errorf("unsupported shell %q, want one of %s", shell, strings.Join(Shells, ", "))
+	}
+	return nil
+}
+
+// subPaths returns the command lines of the subcommands of nodes, quoted for
+// a shell case pattern. Without any, the pattern matches no command line.
+func subPaths(nodes []completionNode) []string {
+	paths := []string{"''"}
+	if len(nodes) > 1 {
+		paths = nil
+	}
+	for _, n := range nodes[1:] {
+		paths = append(paths, shellQuote(n.path))
+	}
+	return paths
+}
+
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+func flagNames(flags []completionFlag) []string {
+	var names []string
+	for _, f := range flags {
+		names = append(names, f.names...)
+	}
+	return names
+}
+
+// valueCases writes the case branches completing the value of the flags
+// taking one, keyed by "command line|flag". complete renders the
+// completion of a flag's value: its enum values, files, or nothing.
+func valueCases(w io.Writer, nodes []completionNode, indent string, complete func(completionFlag) string) {
+	for _, n := range nodes {
+		for _, f := range n.flags {
+			if !f.value {
+				continue
+			}
+			var keys []string
+			for _, name := range f.names {
+				keys = append(keys, shellQuote(n.path+"|"+name))
+			}
+			fmt.Fprintf(w, "%s%s) %s; return;;\n", indent, strings.Join(keys, "|"), complete(f))
+		}
+	}
+}
+
+func writeBash(w io.Writer, fn, name string, nodes []completionNode) {
+	fmt.Fprintf(w, `# bash completion for %[2]s
+%[1]s() {
+    local cur prev cmdpath word i subs flags
+    cur="${COMP_WORDS[COMP_CWORD]}"
+    prev="${COMP_WORDS[COMP_CWORD-1]}"
+    # --flag=value is split at the "=".
+    if [[ $cur == "=" ]]; then
+        cur=""
+    elif [[ $prev == "=" ]]; then
+        prev="${COMP_WORDS[COMP_CWORD-2]}"
+    fi
+    cmdpath=%[3]s
+    for ((i = 1; i < COMP_CWORD; i++)); do
+        word="${COMP_WORDS[i]}"
+        case "$cmdpath $word" in
+            %[4]s) cmdpath="$cmdpath $word";;
+        esac
+    done
+    case "$cmdpath|$prev" in
+`, fn, name, shellQuote(name), strings.Join(subPaths(nodes), "|"))
+	valueCases(w, nodes, "        ", func(f completionFlag) string {
+		switch {
+		case len(f.enum) > 0:
+			return fmt.Sprintf(`COMPREPLY=($(compgen -W %s -- "$cur"))`, shellQuote(strings.Join(f.enum, " ")))
+		case f.file:
+			return `COMPREPLY=($(compgen -f -- "$cur"))`
+		}
+		return "COMPREPLY=()"
+	})
+	fmt.Fprintf(w, "    esac\n    case \"$cmdpath\" in\n")
+	for _, n := range nodes {
+		fmt.Fprintf(w, "        %s) subs=%s; flags=%s;;\n", shellQuote(n.path), shellQuote(strings.Join(n.subs, " ")), shellQuote(strings.Join(flagNames(n.flags), " ")))
+	}
+	fmt.Fprintf(w, `    esac
+    if [[ $cur == -* ]]; then
+        COMPREPLY=($(compgen -W "$flags" -- "$cur"))
+    elif [[ -n $subs ]]; then
+        COMPREPLY=($(compgen -W "$subs" -- "$cur"))
+    else
+        COMPREPLY=($(compgen -f -- "$cur"))
+    fi
+}
+complete -F %s %s
+`, fn, name)
+}
+
+func writeZsh(w io.Writer, fn, name string, nodes []completionNode) {
+	fmt.Fprintf(w, `#compdef %[2]s
+%[1]s() {
+    local cmdpath=%[3]s cur="${words[CURRENT]}" prev="${words[CURRENT-1]}" i
+    local -a subs flags
+    for ((i = 2; i < CURRENT; i++)); do
+        case "$cmdpath ${words[i]}" in
+            (%[4]s) cmdpath="$cmdpath ${words[i]}";;
+        esac
+    done
+    case "$cmdpath|$prev" in
+`, fn, name, shellQuote(name), strings.Join(subPaths(nodes), "|"))
+	valueCases(w, nodes, "        (", func(f completionFlag) string {
+		switch {
+		case len(f.enum) > 0:
+			return "compadd -- " + strings.Join(f.enum, " ")
+		case f.file:
+			return "_files"
+		}
+		return ":"
+	})
+	fmt.Fprintf(w, "    esac\n    case \"$cmdpath\" in\n")
+	for _, n := range nodes {
+		fmt.Fprintf(w, "        (%s) subs=(%s); flags=(%s);;\n", shellQuote(n.path), strings.Join(n.subs, " "), strings.Join(flagNames(n.flags), " "))
+	}
+	fmt.Fprintf(w, `    esac
+    if [[ $cur == -* ]]; then
+        compadd -- $flags
+    elif (( $#subs )); then
+        compadd -- $subs
+    else
+        _files
+    fi
+}
+compdef %s %s
+`, fn, name)
+}
+
+func writeFish(w io.Writer, fn, name string, nodes []completionNode) {
+	fmt.Fprintf(w, `# fish completion for %[2]s
+function %[1]s_path
+    set -l cmdpath %[3]s
+    for word in (commandline -opc)[2..-1]
+        switch "$cmdpath $word"
+            case %[4]s
+                set cmdpath "$cmdpath $word"
+        end
+    end
+    echo $cmdpath
+end
+complete -c %[2]s -f
+`, fn, name, shellQuote(name), strings.Join(subPaths(nodes), " "))
+	desc := map[string]string{}
+	for _, n := range nodes {
+		desc[n.path] = n.desc
+	}
+	for _, n := range nodes {
+		cond := fmt.Sprintf(`-n 'test (%s_path) = "%s"'`, fn, n.path)
+		if len(n.subs) == 0 {
+			fmt.Fprintf(w, "complete -c %s %s -F\n", name, cond)
+		}
+		for _, sub := range n.subs {
+			line := fmt.Sprintf("complete -c %s %s -a %s", name, cond, shellQuote(sub))
+			if d := desc[n.path+" "+sub]; d != "" {
+				line += " -d " + shellQuote(d)
+			}
+			fmt.Fprintln(w, line)
+		}
+		for _, f := range n.flags {
+			line := fmt.Sprintf("complete -c %s %s", name, cond)
+			for _, flag := range f.names {
+				switch {
+				case strings.HasPrefix(flag, "--"):
+					line += " -l " + flag[2:]
+				case len(flag) == 2:
+					line += " -s " + flag[1:]
+				default:
+					line += " -o " + flag[1:]
+				}
+			}
+			switch {
+			case len(f.enum) > 0:
+				line += " -x -a " + shellQuote(strings.Join(f.enum, " "))
+			case f.file:
+				line += " -r -F"
+			case f.value:
+				line += " -x"
+			}
+			if f.help != "" {
+				line += " -d " + shellQuote(f.help)
+			}
+			fmt.Fprintln(w, line)
+		}
+	}
+}
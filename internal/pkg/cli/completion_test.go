@@ -0,0 +1,104 @@
+package cli
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+type rootCmd struct {
+	LogPath string   `cli_flag:"--log_path" cli_group:"common"`
+	Args    []string `cli_argument:"args"`
+}
+
+func (rootCmd) Slots() Slot {
+	return Group{Unordered: []Slot{FlagGroup{Name: "common"}, Arguments{Name: "args"}}}
+}
+
+func completionTree() Tree {
+	return Tree{Command: rootCmd{}, Children: []Tree{
+		{Command: &helpCmd{}},
+		{Command: &passthroughCmd{}, Children: Leaves(&simpleCmd{})},
+	}}
+}
+
+func TestCompletionNodes(t *testing.T) {
+	t.Parallel()
+	nodes := completionNodes("tool", completionTree())
+	var paths []string
+	for _, n := range nodes {
+		paths = append(paths, n.path)
+	}
+	if got, want := strings.Join(paths, ","), "tool,tool show,tool wrap,tool wrap do"; got != want {
+		t.Fatalf("nodes = %s, want %s", got, want)
+	}
+	if got := strings.Join(nodes[0].subs, " "); got != "show wrap" {
+		t.Errorf("root subcommands = %q", got)
+	}
+	if f := nodes[0].flags[0]; !f.value || !f.file {
+		t.Errorf("--log_path = %+v, want a path value", f)
+	}
+	format := nodes[1].flags[0]
+	if strings.Join(format.names, " ") != "--format -f" || strings.Join(format.enum, " ") != "text json" || format.file {
+		t.Errorf("--format = %+v, want the enum values", format)
+	}
+	if quiet := nodes[1].flags[3]; quiet.value {
+		t.Errorf("--quiet takes a value: %+v", quiet)
+	}
+}
+
+func TestWriteCompletion(t *testing.T) {
+	t.Parallel()
+	for shell, want := range map[string][]string{
+		"bash": {"complete -F _tool tool", `'tool show|--format'|'tool show|-f') COMPREPLY=($(compgen -W 'text json' -- "$cur"))`},
+		"zsh":  {"#compdef tool", "compdef _tool tool", "('tool|--log_path') _files"},
+		"fish": {`complete -c tool -n 'test (_tool_path) = "tool show"' -l format -s f -x -a 'text json' -d 'Output format.'`, `-a 'show' -d 'show a container'`},
+	} {
+		var b strings.Builder
+		if err := WriteCompletion(&b, shell, "tool", completionTree()); err != nil {
+			t.Fatalf("WriteCompletion(%s): %v", shell, err)
+		}
+		for _, w := range want {
+			if !strings.Contains(b.String(), w) {
+				t.Errorf("%s script lacks %q:\n%s", shell, w, b.String())
+			}
+		}
+	}
+	if err := WriteCompletion(&strings.Builder{}, "tcsh", "tool", completionTree()); err == nil {
+		t.Errorf("WriteCompletion accepted tcsh")
+	}
+}
+
+func TestBashCompletion(t *testing.T) {
+	t.Parallel()
+	bash, err := exec.LookPath("bash")
+	if err != nil {
+		t.Skip("no bash")
+	}
+	var b strings.Builder
+	if err := WriteCompletion(&b, "bash", "tool", completionTree()); err != nil {
+		t.Fatal(err)
+	}
+	script := filepath.Join(t.TempDir(), "tool.bash")
+	if err := os.WriteFile(script, []byte(b.String()), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	for line, want := range map[string]string{
+		"tool s":              "show",
+		"tool wrap ":          "do",
+		"tool show --f":       "--format",
+		"tool show --format ": "text json",
+		"tool wrap do --":     "--flag",
+	} {
+		args := []string{"-c", `source "$1"; shift; COMP_WORDS=("$@"); COMP_CWORD=$(($#-1)); _tool; echo "${COMPREPLY[*]}"`, "_", script}
+		out, err := exec.Command(bash, append(args, strings.Split(line, " ")...)...).Output()
+		if err != nil {
+			t.Fatalf("bash: %v", err)
+		}
+		if got := strings.TrimSpace(string(out)); got != want {
+			t.Errorf("completing %q = %q, want %q", line, got, want)
+		}
+	}
+}
@@ -3,7 +3,7 @@ package cli
 import (
 	"fmt"
 	"reflect"
-	"strconv"
+	"strings"
 )
 
 // ConvertToCmdline validates command values and renders: <subcommand> [flags/args…]
@@ -19,6 +19,7 @@ func ConvertToCmdline(cmd Command) ([]string, error) {
 		flag string // cli_flag value, if any
 		argG string // cli_argument value, if any (used as group/arg name)
 		grp  string // cli_group for flags
+		sep  string // cli_sep for flags holding lists
 	}
 	var fields []fieldInfo
 
@@ -46,6 +47,7 @@ func ConvertToCmdline(cmd Command) ([]string, error) {
 				return ""
 			}(),
 			grp: grp,
+			sep: sf.Tag.Get("cli_sep"),
 		})
 	})
 
@@ -68,7 +70,7 @@ func ConvertToCmdline(cmd Command) ([]string, error) {
 	emitGroupFlags = func(names []string) error {
 		for _, name := range names {
 			for _, f := range flagsByGroup[name] {
-				if _, err := emitFlag(&argv, f.flag, f.val); err != nil {
+				if _, err := emitFlag(&argv, f.flag, f.val, f.sep); err != nil {
 					return fmt.Errorf("%T.%s: %w", cmd, f.sf.Name, err)
 				}
 			}
@@ -88,297 +90,106 @@ func ConvertToCmdline(cmd Command) ([]string, error) {
 		return nil
 	}
 
+	// The unordered slots of the groups being walked, outermost first. A
+	// group renders its own at its RenderUnordered slot if it has one;
+	// otherwise they go after the first subcommand, or else before the first
+	// literal or positional argument, met from there on, nested groups
+	// included, and at the latest at the end of the group.
+	type pendingUnordered struct {
+		flags, args []string
+		marked      bool
+		done        bool
+	}
+	var pending []*pendingUnordered
+	emitPending := func(p *pendingUnordered) error {
+		if p.done {
+			return nil
+		}
+		p.done = true
+		if err := emitGroupFlags(p.flags); err != nil {
+			return err
+		}
+		return emitGroupArgs(p.args)
+	}
+	flushPending := func() error {
+		for _, p := range pending {
+			if p.marked {
+				continue
+			}
+			if err := emitPending(p); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
 	var walk func(Slot) error
 	walk = func(s Slot) error {
-		switch v := s.(type) {
-		case Group:
-			// Determine preferred placement for unordered flags.
-			// If the sequence of non-FlagGroup ordered items is exactly
-			// [Subcommand, Argument], place unordered AFTER that Argument
-			// (e.g., for "update"). Otherwise, place BEFORE the first
-			// non-FlagGroup item (e.g., for "exec").
-			insertedUnordered := false
-			var placeAfterFirstArg bool
-			{
-				var seq []Slot
-				for _, o := range v.Ordered {
-					if _, ok := o.(FlagGroup); ok {
-						continue
-					}
-					seq = append(seq, o)
+		g, ok := s.(Group)
+		if !ok {
+			return nil
+		}
+		p := &pendingUnordered{}
+		for _, u := range g.Unordered {
+			switch u := u.(type) {
+			case FlagGroup:
+				p.flags = append(p.flags, u.Name)
+			case Argument:
+				p.args = append(p.args, u.Name)
+			case Arguments:
+				p.args = append(p.args, u.Name)
+			}
+		}
+		for _, o := range g.Ordered {
+			if _, ok := o.(RenderUnordered); ok {
+				p.marked = true
+			}
+		}
+		pending = append(pending, p)
+		defer func() { pending = pending[:len(pending)-1] }()
+
+		for _, o := range g.Ordered {
+			switch ov := o.(type) {
+			case FlagGroup:
+				// Position-specific flag window
+				if err := emitGroupFlags([]string{ov.Name}); err != nil {
+					return err
 				}
-				if len(seq) == 2 && SubcommandOf(cmd) == "update" {
-					_, a0 := seq[0].(Subcommand)
-					_, a1 := seq[1].(Argument)
-					if a0 && a1 {
-						placeAfterFirstArg = true
-					}
+			case Subcommand:
+				argv = append(argv, ov.Value)
+				if err := flushPending(); err != nil {
+					return err
 				}
-			}
-			unorderedFlagNames := make([]string, 0, len(v.Unordered))
-			unorderedArgNames := make([]string, 0, len(v.Unordered))
-			for _, u := range v.Unordered {
-				switch u := u.(type) {
-				case FlagGroup:
-					unorderedFlagNames = append(unorderedFlagNames, u.Name)
-				case Argument:
-					unorderedArgNames = append(unorderedArgNames, u.Name)
-				case Arguments:
-					unorderedArgNames = append(unorderedArgNames, u.Name)
+			case Literal:
+				if err := flushPending(); err != nil {
+					return err
 				}
-			}
-			// Helper that walks a nested Slot but injects unordered flag groups
-			// after the first Subcommand (or after the first Argument if
-			// placeAfterFirstArg is true).
-			// Inject both outer (current group's) unordered flags and the
-			// nested group's own unordered flags at appropriate positions.
-			var walkInject func(Slot, bool) (bool, error)
-			walkInject = func(s Slot, outerInjected bool) (bool, error) {
-				switch nv := s.(type) {
-				case Group:
-					// Prepare nested group's unordered flags and arguments and placement.
-					nestedUnorderedFlagNames := make([]string, 0, len(nv.Unordered))
-					nestedUnorderedArgNames := make([]string, 0, len(nv.Unordered))
-					for _, u := range nv.Unordered {
-						switch u := u.(type) {
-						case FlagGroup:
-							nestedUnorderedFlagNames = append(nestedUnorderedFlagNames, u.Name)
-						case Argument:
-							nestedUnorderedArgNames = append(nestedUnorderedArgNames, u.Name)
-						case Arguments:
-							nestedUnorderedArgNames = append(nestedUnorderedArgNames, u.Name)
-						}
-					}
-					// Determine if nested wants flags after first argument (only update).
-					nestedPlaceAfterFirstArg := false
-					var seq []Slot
-					var nestedSubValue string
-					for _, oo := range nv.Ordered {
-						if sc, ok := oo.(Subcommand); ok {
-							nestedSubValue = sc.Value
-						}
-						if _, ok := oo.(FlagGroup); ok {
-							continue
-						}
-						seq = append(seq, oo)
-					}
-					if len(seq) == 2 && nestedSubValue == "update" {
-						if _, a0 := seq[0].(Subcommand); a0 {
-							if _, a1 := seq[1].(Argument); a1 {
-								nestedPlaceAfterFirstArg = true
-							}
-						}
-					}
-					nestedInjected := false
-					// handle ordered items
-					for _, oo := range nv.Ordered {
-						if fg, ok := oo.(FlagGroup); ok {
-							if err := emitGroupFlags([]string{fg.Name}); err != nil {
-								return outerInjected, err
-							}
-							continue
-						}
-						switch oov := oo.(type) {
-						case Subcommand:
-							argv = append(argv, oov.Value)
-							if !outerInjected && (len(unorderedFlagNames) > 0 || len(unorderedArgNames) > 0) && !placeAfterFirstArg {
-								if err := emitGroupFlags(unorderedFlagNames); err != nil {
-									return outerInjected, err
-								}
-								if err := emitGroupArgs(unorderedArgNames); err != nil {
-									return outerInjected, err
-								}
-								outerInjected = true
-							}
-							if !nestedInjected && (len(nestedUnorderedFlagNames) > 0 || len(nestedUnorderedArgNames) > 0) && !nestedPlaceAfterFirstArg {
-								if err := emitGroupFlags(nestedUnorderedFlagNames); err != nil {
-									return outerInjected, err
-								}
-								if err := emitGroupArgs(nestedUnorderedArgNames); err != nil {
-									return outerInjected, err
-								}
-								nestedInjected = true
-							}
-						case Literal:
-							if !outerInjected && (len(unorderedFlagNames) > 0 || len(unorderedArgNames) > 0) && !placeAfterFirstArg {
-								if err := emitGroupFlags(unorderedFlagNames); err != nil {
-									return outerInjected, err
-								}
-								if err := emitGroupArgs(unorderedArgNames); err != nil {
-									return outerInjected, err
-								}
-								outerInjected = true
-							}
-							argv = append(argv, oov.Value)
-						case Argument:
-							if !nestedInjected && (len(nestedUnorderedFlagNames) > 0 || len(nestedUnorderedArgNames) > 0) && nestedPlaceAfterFirstArg {
-								// emit nested flags after first argument
-								for _, f := range argsByName[oov.Name] {
-									if err := emitArg(&argv, f.val); err != nil {
-										return outerInjected, fmt.Errorf("%T.%s: %w", cmd, f.sf.Name, err)
-									}
-								}
-								if err := emitGroupFlags(nestedUnorderedFlagNames); err != nil {
-									return outerInjected, err
-								}
-								if err := emitGroupArgs(nestedUnorderedArgNames); err != nil {
-									return outerInjected, err
-								}
-								nestedInjected = true
-								// continue to next item
-								continue
-							}
-							if !outerInjected && (len(unorderedFlagNames) > 0 || len(unorderedArgNames) > 0) && placeAfterFirstArg {
-								// emit after the first argument
-								// but we need the argument first
-								for _, f := range argsByName[oov.Name] {
-									if err := emitArg(&argv, f.val); err != nil {
-										return outerInjected, fmt.Errorf("%T.%s: %w", cmd, f.sf.Name, err)
-									}
-								}
-								if err := emitGroupFlags(unorderedFlagNames); err != nil {
-									return outerInjected, err
-								}
-								if err := emitGroupArgs(unorderedArgNames); err != nil {
-									return outerInjected, err
-								}
-								outerInjected = true
-								// continue to next item
-								continue
-							}
-							for _, f := range argsByName[oov.Name] {
-								if err := emitArg(&argv, f.val); err != nil {
-									return outerInjected, fmt.Errorf("%T.%s: %w", cmd, f.sf.Name, err)
-								}
-							}
-						case Arguments:
-							if !outerInjected && (len(unorderedFlagNames) > 0 || len(unorderedArgNames) > 0) && !placeAfterFirstArg {
-								if err := emitGroupFlags(unorderedFlagNames); err != nil {
-									return outerInjected, err
-								}
-								if err := emitGroupArgs(unorderedArgNames); err != nil {
-									return outerInjected, err
-								}
-								outerInjected = true
-							}
-							for _, f := range argsByName[oov.Name] {
-								if err := emitArg(&argv, f.val); err != nil {
-									return outerInjected, fmt.Errorf("%T.%s: %w", cmd, f.sf.Name, err)
-								}
-							}
-						}
-						// recurse into deeper nesting with same rules
-						var err error
-						outerInjected, err = walkInject(oo, outerInjected)
-						if err != nil {
-							return outerInjected, err
-						}
-					}
-					if !nestedInjected && (len(nestedUnorderedFlagNames) > 0 || len(nestedUnorderedArgNames) > 0) {
-						if err := emitGroupFlags(nestedUnorderedFlagNames); err != nil {
-							return outerInjected, err
-						}
-						if err := emitGroupArgs(nestedUnorderedArgNames); err != nil {
-							return outerInjected, err
-						}
-					}
+				argv = append(argv, ov.Value)
+			case Argument:
+				if err := flushPending(); err != nil {
+					return err
 				}
-				return outerInjected, nil
-			}
-
-			for _, o := range v.Ordered {
-				if fg, ok := o.(FlagGroup); ok {
-					if err := emitGroupFlags([]string{fg.Name}); err != nil {
-						return err
-					}
-					continue
+				if err := emitGroupArgs([]string{ov.Name}); err != nil {
+					return err
 				}
-				switch ov := o.(type) {
-				case Subcommand:
-					argv = append(argv, ov.Value)
-					if !placeAfterFirstArg && !insertedUnordered && (len(unorderedFlagNames) > 0 || len(unorderedArgNames) > 0) {
-						if err := emitGroupFlags(unorderedFlagNames); err != nil {
-							return err
-						}
-						if err := emitGroupArgs(unorderedArgNames); err != nil {
-							return err
-						}
-						insertedUnordered = true
-					}
-				case Literal:
-					if !placeAfterFirstArg && !insertedUnordered && (len(unorderedFlagNames) > 0 || len(unorderedArgNames) > 0) {
-						if err := emitGroupFlags(unorderedFlagNames); err != nil {
-							return err
-						}
-						if err := emitGroupArgs(unorderedArgNames); err != nil {
-							return err
-						}
-						insertedUnordered = true
-					}
-					argv = append(argv, ov.Value)
-				case Argument:
-					if !placeAfterFirstArg && !insertedUnordered && (len(unorderedFlagNames) > 0 || len(unorderedArgNames) > 0) {
-						if err := emitGroupFlags(unorderedFlagNames); err != nil {
-							return err
-						}
-						if err := emitGroupArgs(unorderedArgNames); err != nil {
-							return err
-						}
-						insertedUnordered = true
-					}
-					for _, f := range argsByName[ov.Name] {
-						if err := emitArg(&argv, f.val); err != nil {
-							return fmt.Errorf("%T.%s: %w", cmd, f.sf.Name, err)
-						}
-					}
-					if placeAfterFirstArg && !insertedUnordered && (len(unorderedFlagNames) > 0 || len(unorderedArgNames) > 0) {
-						if err := emitGroupFlags(unorderedFlagNames); err != nil {
-							return err
-						}
-						if err := emitGroupArgs(unorderedArgNames); err != nil {
-							return err
-						}
-						insertedUnordered = true
-					}
-				case Arguments:
-					if !placeAfterFirstArg && !insertedUnordered && (len(unorderedFlagNames) > 0 || len(unorderedArgNames) > 0) {
-						if err := emitGroupFlags(unorderedFlagNames); err != nil {
-							return err
-						}
-						if err := emitGroupArgs(unorderedArgNames); err != nil {
-							return err
-						}
-						insertedUnordered = true
-					}
-					for _, f := range argsByName[ov.Name] {
-						if err := emitArg(&argv, f.val); err != nil {
-							return fmt.Errorf("%T.%s: %w", cmd, f.sf.Name, err)
-						}
-					}
-				case Group:
-					// Defer emission into the nested group just after its
-					// Subcommand (or after first Argument if required).
-					var err error
-					insertedUnordered, err = walkInject(o, insertedUnordered)
-					if err != nil {
-						return err
-					}
-					continue
+			case Arguments:
+				if err := flushPending(); err != nil {
+					return err
 				}
-				if err := walk(o); err != nil {
+				if err := emitGroupArgs([]string{ov.Name}); err != nil {
 					return err
 				}
-			}
-			if !insertedUnordered && (len(unorderedFlagNames) > 0 || len(unorderedArgNames) > 0) {
-				if err := emitGroupFlags(unorderedFlagNames); err != nil {
+			case RenderUnordered:
+				if err := emitPending(p); err != nil {
 					return err
 				}
-				if err := emitGroupArgs(unorderedArgNames); err != nil {
+			case Group:
+				if err := walk(ov); err != nil {
 					return err
 				}
 			}
 		}
-		return nil
+		return emitPending(p)
 	}
 
 	if err := walk(cmd.Slots()); err != nil {
@@ -388,8 +199,9 @@ func ConvertToCmdline(cmd Command) ([]string, error) {
 }
 
 // emitFlag appends a flag (and maybe its value) to argv if the field is non-zero.
-// Returns whether anything was appended.
-func emitFlag(argv *[]string, flag string, v reflect.Value) (bool, error) {
+// Lists and maps repeat the flag once per value, or join their values with
+// sep if it is set. Returns whether anything was appended.
+func emitFlag(argv *[]string, flag string, v reflect.Value, sep string) (bool, error) {
 	for v.Kind() == reflect.Pointer {
 		if v.IsNil() {
 			return false, nil
@@ -397,64 +209,40 @@ func emitFlag(argv *[]string, flag string, v reflect.Value) (bool, error) {
 		v = v.Elem()
 	}
 
-	switch v.Kind() {
-	case reflect.Bool:
+	switch {
+	case v.Kind() == reflect.Bool && !isText(v.Type()):
 		if v.Bool() {
 			*argv = append(*argv, flag)
 			return true, nil
 		}
 		return false, nil
 
-	case reflect.String:
-		if s := v.String(); s != "" {
-			*argv = append(*argv, flag, s)
-			return true, nil
+	case isList(v.Type()):
+		vals, err := formatElems(v)
+		if err != nil {
+			return false, fmt.Errorf("flag %q: %w", flag, err)
 		}
-		return false, nil
-
-	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
-		n := v.Int()
-		if n == 0 {
+		if len(vals) == 0 {
 			return false, nil
 		}
-		*argv = append(*argv, flag, strconv.FormatInt(n, 10))
-		return true, nil
-
-	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
-		n := v.Uint()
-		if n == 0 {
-			return false, nil
+		if sep != "" {
+			vals = []string{strings.Join(vals, sep)}
+		}
+		for _, s := range vals {
+			*argv = append(*argv, flag, s)
 		}
-		*argv = append(*argv, flag, strconv.FormatUint(n, 10))
 		return true, nil
 
-	case reflect.Slice:
-		l := v.Len()
-		if l == 0 {
+	default:
+		if v.IsZero() {
 			return false, nil
 		}
-		switch v.Type().Elem().Kind() {
-		case reflect.String:
-			for i := 0; i < l; i++ {
-				*argv = append(*argv, flag, v.Index(i).String())
-			}
-			return true, nil
-		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
-			for i := 0; i < l; i++ {
-				*argv = append(*argv, flag, strconv.FormatInt(v.Index(i).Int(), 10))
-			}
-			return true, nil
-		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
-			for i := 0; i < l; i++ {
-				*argv = append(*argv, flag, strconv.FormatUint(v.Index(i).Uint(), 10))
-			}
-			return true, nil
-		default:
-			return false, fmt.Errorf("unsupported slice element type %s for flag %q", v.Type().Elem(), flag)
+		s, err := formatScalar(v)
+		if err != nil {
+			return false, fmt.Errorf("flag %q: %w", flag, err)
 		}
-
-	default:
-		return false, fmt.Errorf("unsupported flag field kind %s for %q", v.Kind(), flag)
+		*argv = append(*argv, flag, s)
+		return true, nil
 	}
 }
 
@@ -467,46 +255,22 @@ func emitArg(argv *[]string, v reflect.Value) error {
 		v = v.Elem()
 	}
 
-	switch v.Kind() {
-	case reflect.String:
-		if s := v.String(); s != "" {
-			*argv = append(*argv, s)
+	if isList(v.Type()) {
+		vals, err := formatElems(v)
+		if err != nil {
+			return fmt.Errorf("argument: %w", err)
 		}
+		*argv = append(*argv, vals...)
 		return nil
-
-	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
-		*argv = append(*argv, strconv.FormatInt(v.Int(), 10))
-		return nil
-
-	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
-		*argv = append(*argv, strconv.FormatUint(v.Uint(), 10))
-		return nil
-
-	case reflect.Slice:
-		l := v.Len()
-		switch v.Type().Elem().Kind() {
-		case reflect.String:
-			for i := 0; i < l; i++ {
-				*argv = append(*argv, v.Index(i).String())
-			}
-			return nil
-		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
-			for i := 0; i < l; i++ {
-				*argv = append(*argv, strconv.FormatInt(v.Index(i).Int(), 10))
-			}
-			return nil
-		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
-			for i := 0; i < l; i++ {
-				*argv = append(*argv, strconv.FormatUint(v.Index(i).Uint(), 10))
-			}
-			return nil
-		default:
-			return fmt.Errorf("unsupported slice element type %s for argument", v.Type().Elem())
-		}
-
-	default:
-		return fmt.Errorf("unsupported argument field kind %s", v.Kind())
 	}
+	s, err := formatScalar(v)
+	if err != nil {
+		return fmt.Errorf("argument: %w", err)
+	}
+	if s != "" {
+		*argv = append(*argv, s)
+	}
+	return nil
 }
 
 // walkStruct recursively visits exported fields, following anonymous embedded structs.
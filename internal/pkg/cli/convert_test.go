@@ -34,3 +34,38 @@ func TestConvertMixedArgumentsAndFlags(t *testing.T) {
 		t.Fatalf("got %#v want %#v", args, want)
 	}
 }
+
+type flagsAfterFirstArgCmd struct {
+	Force bool   `cli_flag:"--force" cli_group:"opts"`
+	ID    string `cli_argument:"id"`
+	Sig   string `cli_argument:"signal"`
+}
+
+func (flagsAfterFirstArgCmd) Slots() Slot {
+	return Group{
+		Unordered: []Slot{FlagGroup{Name: "opts"}},
+		Ordered: []Slot{
+			Subcommand{Value: "kill"},
+			Argument{Name: "id"},
+			RenderUnordered{},
+			Argument{Name: "signal"},
+		},
+	}
+}
+
+func TestConvertRenderUnordered(t *testing.T) {
+	cmd := flagsAfterFirstArgCmd{Force: true, ID: "c1", Sig: "KILL"}
+	args, err := ConvertToCmdline(cmd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"kill", "c1", "--force", "KILL"}
+	if !reflect.DeepEqual(args, want) {
+		t.Fatalf("got %#v want %#v", args, want)
+	}
+
+	var parsed flagsAfterFirstArgCmd
+	if err := Parse(&parsed, args[1:]); err != nil || parsed != cmd {
+		t.Fatalf("Parse(%q) = %v, %+v", args[1:], err, parsed)
+	}
+}
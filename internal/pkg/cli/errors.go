@@ -0,0 +1,149 @@
+package cli
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Position locates a parse error on the command line.
+type Position struct {
+	// Index is the index of the offending token in the arguments given to
+	// Parse or ParseAny, or their number when they ran out.
+	Index int
+	// Subcommand is the subcommand of the command being parsed, empty for
+	// commands without one and for unions.
+	Subcommand string
+}
+
+func (p *Position) position() *Position { return p }
+
+// positioned is implemented by the parse errors, so that positions can be
+// mapped back to the arguments the caller gave.
+type positioned interface {
+	position() *Position
+}
+
+func (p Position) prefix() string {
+	if p.Subcommand == "" {
+		return ""
+	}
+	return p.Subcommand + ": "
+}
+
+// UnknownFlagError is returned for a token that looks like a flag but isn't
+// one the command takes where it appears.
+type UnknownFlagError struct {
+	Position
+	Flag string
+	// Suggestions are the flags of the command closest to Flag.
+	Suggestions []string
+}
+
+func (e *UnknownFlagError) Error() string {
+	return fmt.Sprintf("%sunknown flag %q (at index %d)%s", e.prefix(), e.Flag, e.Index, didYouMean(e.Suggestions))
+}
+
+// MissingArgumentError is returned when the arguments run out before a
+// required slot.
+type MissingArgumentError struct {
+	Position
+	// Expected is the slot missing: an argument as <name>, a literal, the
+	// value of a flag, or "subcommand".
+	Expected string
+}
+
+func (e *MissingArgumentError) Error() string {
+	return fmt.Sprintf("%smissing %s (at index %d)", e.prefix(), e.Expected, e.Index)
+}
+
+// EnumError is returned for the value of a flag that isn't one of its
+// cli_enum values.
+type EnumError struct {
+	Position
+	Flag    string
+	Value   string
+	Allowed []string
+	// Suggestions are the allowed values closest to Value.
+	Suggestions []string
+}
+
+func (e *EnumError) Error() string {
+	return fmt.Sprintf("%s%s: %q is not one of %s (at index %d)%s", e.prefix(), e.Flag, e.Value, strings.Join(e.Allowed, "|"), e.Index, didYouMean(e.Suggestions))
+}
+
+// UnexpectedTokenError is returned for a token no slot takes where it
+// appears.
+type UnexpectedTokenError struct {
+	Position
+	Token string
+	// Expected is the slot expected instead, e.g. a literal or "subcommand",
+	// empty when the arguments should have ended.
+	Expected string
+	// Suggestions are the expected values closest to Token, e.g. the
+	// subcommands of a union.
+	Suggestions []string
+}
+
+func (e *UnexpectedTokenError) Error() string {
+	msg := fmt.Sprintf("%sunexpected %q (at index %d)", e.prefix(), e.Token, e.Index)
+	if e.Expected != "" {
+		msg += ", expected " + e.Expected
+	}
+	return msg + didYouMean(e.Suggestions)
+}
+
+func didYouMean(suggestions []string) string {
+	if len(suggestions) == 0 {
+		return ""
+	}
+	return fmt.Sprintf(", did you mean %s?", strings.Join(suggestions, " or "))
+}
+
+// looksLikeFlagToken reports whether tok is written like a flag, rather
+// than like a negative number or the "-" standing for stdin.
+func looksLikeFlagToken(tok string) bool {
+	return len(tok) > 1 && tok[0] == '-' && (tok[1] < '0' || tok[1] > '9')
+}
+
+// suggest returns the candidates closest to tok by edit distance, if any is
+// close enough to be a likely typo.
+func suggest(tok string, candidates []string) []string {
+	best := -1
+	var out []string
+	for _, c := range candidates {
+		d := editDistance(tok, c)
+		if d > max(2, len(c)/3) {
+			continue
+		}
+		switch {
+		case best == -1 || d < best:
+			best, out = d, []string{c}
+		case d == best && !slices.Contains(out, c):
+			out = append(out, c)
+		}
+	}
+	slices.Sort(out)
+	return out
+}
+
+// editDistance is the Levenshtein distance between a and b.
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
@@ -0,0 +1,85 @@
+package cli
+
+import (
+	"errors"
+	"slices"
+	"testing"
+)
+
+func TestParse_TypedErrors(t *testing.T) {
+	t.Parallel()
+	for _, tc := range []struct {
+		args  []string
+		index int
+		check func(error) bool
+	}{
+		{[]string{"abc", "--fromat=json"}, 1, func(err error) bool {
+			var e *UnknownFlagError
+			return errors.As(err, &e) && e.Flag == "--fromat" && slices.Equal(e.Suggestions, []string{"--format"})
+		}},
+		{[]string{"-q", "--format=jsn", "abc"}, 1, func(err error) bool {
+			var e *EnumError
+			return errors.As(err, &e) && e.Value == "jsn" && slices.Equal(e.Suggestions, []string{"json"})
+		}},
+		{[]string{"abc", "--limit"}, 2, func(err error) bool {
+			var e *MissingArgumentError
+			return errors.As(err, &e) && e.Expected == "value of --limit"
+		}},
+		{nil, 0, func(err error) bool {
+			var e *MissingArgumentError
+			return errors.As(err, &e) && e.Expected == "<id>"
+		}},
+		{[]string{"abc", "extra"}, 1, func(err error) bool {
+			var e *UnexpectedTokenError
+			return errors.As(err, &e) && e.Token == "extra" && e.Expected == `"--"`
+		}},
+	} {
+		var cmd helpCmd
+		err := Parse(&cmd, tc.args)
+		if !tc.check(err) {
+			t.Errorf("Parse(%q) = %#v", tc.args, err)
+			continue
+		}
+		var p positioned
+		if errors.As(err, &p) && (p.position().Index != tc.index || p.position().Subcommand != "show") {
+			t.Errorf("Parse(%q) at %+v, want index %d in show", tc.args, *p.position(), tc.index)
+		}
+	}
+}
+
+func TestParse_DashArguments(t *testing.T) {
+	t.Parallel()
+	// Arguments take values written like flags, as long as they aren't
+	// flags of the command.
+	for _, id := range []string{"-abc", "--abc", "-", "-1"} {
+		var cmd helpCmd
+		if err := Parse(&cmd, []string{id, "--", "-x"}); err != nil || cmd.ID != id || len(cmd.Args) != 1 || cmd.Args[0] != "-x" {
+			t.Errorf("Parse(%q) = %v, id %q, args %q", id, err, cmd.ID, cmd.Args)
+		}
+	}
+}
+
+func TestParseAny_TypedErrors(t *testing.T) {
+	t.Parallel()
+	var u helpUnion
+	err := ParseAny(&u, []string{"-q", "shwo", "abc"})
+	var unexpected *UnexpectedTokenError
+	if !errors.As(err, &unexpected) || unexpected.Index != 1 || !slices.Equal(unexpected.Suggestions, []string{"show"}) {
+		t.Errorf("ParseAny(shwo) = %v, want show suggested at index 1", err)
+	}
+
+	// Indices count the subcommand ParseAny takes out of the arguments.
+	err = ParseAny(&u, []string{"show", "abc", "--limt", "3"})
+	var unknown *UnknownFlagError
+	if !errors.As(err, &unknown) || unknown.Index != 2 || unknown.Subcommand != "show" {
+		t.Errorf("ParseAny(--limt) = %v, want index 2 in show", err)
+	}
+	if want := `show: unknown flag "--limt" (at index 2), did you mean --limit?`; err.Error() != want {
+		t.Errorf("message = %q, want %q", err, want)
+	}
+
+	var missing *MissingArgumentError
+	if err := ParseAny(&u, []string{"-q"}); !errors.As(err, &missing) || missing.Expected != "subcommand" || missing.Index != 1 {
+		t.Errorf("ParseAny(-q) = %v, want a missing subcommand at index 1", err)
+	}
+}
@@ -0,0 +1,347 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+)
+
+// ErrHelp is what a HelpError matches: Parse and ParseAny return one when
+// the arguments ask for help with -h, --help or, before a subcommand, help.
+var ErrHelp = errors.New("help requested")
+
+// Describer is implemented by commands with a one-line description, shown
+// next to their name in usage text.
+type Describer interface {
+	Description() string
+}
+
+// HelpError is returned when the arguments ask for help. It carries the
+// command, or the commands of the union, the usage of which was asked for.
+type HelpError struct {
+	// Path is the command line leading to the command, e.g. "vino" for
+	// "vino exec". Nest fills it in.
+	Path []string
+	// Command is the command help was asked for, nil when it was asked for
+	// a union.
+	Command Command
+	// Commands are the members of the union help was asked for.
+	Commands []Command
+}
+
+func (e *HelpError) Error() string { return ErrHelp.Error() }
+
+func (e *HelpError) Is(target error) bool { return target == ErrHelp }
+
+// Usage renders the usage text of the command help was asked for.
+func (e *HelpError) Usage() string {
+	var b strings.Builder
+	name := strings.Join(e.Path, " ")
+	if e.Command != nil {
+		WriteUsage(&b, name, e.Command)
+	} else {
+		WriteUnionUsage(&b, name, e.Commands)
+	}
+	return b.String()
+}
+
+// Nest prepends name to the path of the help request err carries, if any.
+// Commands dispatching their arguments to a union of subcommands nest the
+// errors of parsing them under their own name, so that usage text shows the
+// whole command line.
+func Nest(name string, err error) error {
+	var he *HelpError
+	if errors.As(err, &he) {
+		he.Path = append([]string{name}, he.Path...)
+	}
+	return err
+}
+
+// isHelp reports whether tok asks for help.
+func isHelp(tok string) bool {
+	return tok == "--help" || tok == "-h"
+}
+
+// askedForHelp reports whether args ask for help with a token that isn't a
+// flag of the command, before the first positional argument or "--": past
+// them, a help flag is an argument or a mistake the parse error tells about.
+// flags tells whether each flag of the command takes a value; when nil, the
+// flags aren't known and any token may be the value of one, so only "--"
+// ends the search.
+func askedForHelp(args []string, flags map[string]bool) bool {
+	for i := 0; i < len(args); i++ {
+		a := args[i]
+		takesValue, known := flags[a]
+		switch {
+		case a == "--":
+			return false
+		case known:
+			if takesValue {
+				i++
+			}
+		case isHelp(a):
+			return true
+		case flags != nil && (a == "-" || !strings.HasPrefix(a, "-")):
+			return false
+		}
+	}
+	return false
+}
+
+// helpField is what usage text shows of a tagged field.
+type helpField struct {
+	flag  string
+	alts  []string
+	group string
+	arg   string
+	enum  string
+	help  string
+	def   string
+	env   string
+	sep   string
+	typ   reflect.Type
+}
+
+func helpFields(cmd Command) []helpField {
+	var fields []helpField
+	walkStruct(reflect.ValueOf(cmd), func(sf reflect.StructField, fv reflect.Value) {
+		f := helpField{
+			flag:  sf.Tag.Get("cli_flag"),
+			group: sf.Tag.Get("cli_group"),
+			arg:   sf.Tag.Get("cli_argument"),
+			enum:  sf.Tag.Get("cli_enum"),
+			help:  sf.Tag.Get("cli_help"),
+			def:   sf.Tag.Get("cli_default"),
+			env:   sf.Tag.Get("cli_env"),
+			sep:   sf.Tag.Get("cli_sep"),
+			typ:   sf.Type,
+		}
+		if f.flag == "" && f.arg == "" {
+			return
+		}
+		for _, a := range strings.Split(sf.Tag.Get("cli_flag_alternatives"), "|") {
+			if a = strings.TrimSpace(a); a != "" {
+				f.alts = append(f.alts, a)
+			}
+		}
+		fields = append(fields, f)
+	})
+	return fields
+}
+
+// WriteUsage writes the usage text of cmd, invoked as name followed by its
+// subcommand, to w: its synopsis, then its options by flag group and its
+// arguments.
+func WriteUsage(w io.Writer, name string, cmd Command) {
+	fields := helpFields(cmd)
+	hasFlags := map[string]bool{}
+	for _, f := range fields {
+		if f.flag != "" {
+			hasFlags[f.group] = true
+		}
+	}
+
+	title := strings.TrimSpace(name + " " + SubcommandOf(cmd))
+	fmt.Fprintf(w, "NAME\n  %s", title)
+	if d, ok := cmd.(Describer); ok && d.Description() != "" {
+		fmt.Fprintf(w, " - %s", d.Description())
+	}
+	fmt.Fprintf(w, "\n\nSYNOPSIS\n  %s\n", strings.TrimSpace(name+" "+strings.Join(synopsis(cmd.Slots(), hasFlags), " ")))
+
+	for _, g := range flagGroups(cmd.Slots()) {
+		if !hasFlags[g] {
+			continue
+		}
+		fmt.Fprintf(w, "\nOPTIONS (%s)\n", g)
+		for _, f := range fields {
+			if f.flag == "" || f.group != g {
+				continue
+			}
+			names := append(append([]string{}, f.alts...), f.flag)
+			fmt.Fprintf(w, "  %s%s\n", strings.Join(names, ", "), valueHint(f))
+			if text := describe(f); text != "" {
+				fmt.Fprintf(w, "      %s\n", text)
+			}
+		}
+	}
+
+	var args []helpField
+	for _, f := range fields {
+		if f.arg != "" && f.help != "" {
+			args = append(args, f)
+		}
+	}
+	if len(args) > 0 {
+		fmt.Fprintf(w, "\nARGUMENTS\n")
+		for _, f := range args {
+			fmt.Fprintf(w, "  <%s>\n      %s\n", f.arg, f.help)
+		}
+	}
+}
+
+// WriteUnionUsage writes the usage text of a union of commands, invoked as
+// name followed by the subcommand of one of them, to w.
+func WriteUnionUsage(w io.Writer, name string, cmds []Command) {
+	fmt.Fprintf(w, "SYNOPSIS\n  %s\n  %s\n\nCOMMANDS\n",
+		strings.TrimSpace(name+" <command> [<args>...]"),
+		strings.TrimSpace(name+" help <command>"))
+	width := 0
+	for _, c := range cmds {
+		width = max(width, len(SubcommandOf(c)))
+	}
+	for _, c := range cmds {
+		line := SubcommandOf(c)
+		if d, ok := c.(Describer); ok && d.Description() != "" {
+			line = fmt.Sprintf("%-*s  %s", width, line, d.Description())
+		}
+		fmt.Fprintf(w, "  %s\n", line)
+	}
+}
+
+// synopsis renders the slots of s: the subcommands leading it, then its
+// unordered slots and the rest of its ordered ones. Flag groups without any
+// flag in the command are left out.
+func synopsis(s Slot, hasFlags map[string]bool) []string {
+	switch v := s.(type) {
+	case Group:
+		var out []string
+		ordered := v.Ordered
+		for len(ordered) > 0 {
+			sub, ok := ordered[0].(Subcommand)
+			if !ok {
+				break
+			}
+			out = append(out, sub.Value)
+			ordered = ordered[1:]
+		}
+		for _, u := range v.Unordered {
+			out = append(out, synopsis(u, hasFlags)...)
+		}
+		for _, o := range ordered {
+			nested, ok := o.(Group)
+			if !ok {
+				out = append(out, synopsis(o, hasFlags)...)
+				continue
+			}
+			inner := synopsis(nested, hasFlags)
+			// A group led by a literal may be left out altogether.
+			if len(nested.Ordered) > 0 {
+				if _, lit := nested.Ordered[0].(Literal); lit && len(inner) > 0 {
+					inner = []string{"[" + strings.Join(inner, " ") + "]"}
+				}
+			}
+			out = append(out, inner...)
+		}
+		return out
+	case FlagGroup:
+		if !hasFlags[v.Name] {
+			return nil
+		}
+		return []string{"[" + v.Name + " options]"}
+	case Argument:
+		return []string{"<" + v.Name + ">"}
+	case Arguments:
+		return []string{"[<" + v.Name + ">...]"}
+	case Literal:
+		return []string{v.Value}
+	case Subcommand:
+		return []string{v.Value}
+	}
+	return nil
+}
+
+// flagGroups returns the names of the flag groups of s, in order.
+func flagGroups(s Slot) []string {
+	var names []string
+	var walk func(Slot)
+	walk = func(s Slot) {
+		switch v := s.(type) {
+		case Group:
+			for _, u := range v.Unordered {
+				walk(u)
+			}
+			for _, o := range v.Ordered {
+				walk(o)
+			}
+		case FlagGroup:
+			for _, n := range names {
+				if n == v.Name {
+					return
+				}
+			}
+			names = append(names, v.Name)
+		}
+	}
+	walk(s)
+	return names
+}
+
+// valueHint renders the value a flag takes: nothing for booleans, the
+// values of an enum, or the kind of value, listed with the separator of
+// flags taking several at once.
+func valueHint(f helpField) string {
+	t := f.typ
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t.Kind() == reflect.Bool && !isText(t) {
+		return ""
+	}
+	if f.enum != "" {
+		return "=" + f.enum
+	}
+	hint := "<" + kindHint(t) + ">"
+	if isList(t) {
+		if t.Kind() == reflect.Map {
+			hint = "<" + kindHint(t.Key()) + ">=<" + kindHint(t.Elem()) + ">"
+		} else {
+			hint = "<" + kindHint(t.Elem()) + ">"
+		}
+		if f.sep != "" {
+			hint += "[" + f.sep + "...]"
+		}
+	}
+	return "=" + hint
+}
+
+// kindHint names the kind of value of type t.
+func kindHint(t reflect.Type) string {
+	if t == durationType {
+		return "duration"
+	}
+	if isText(t) {
+		return "value"
+	}
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return "int"
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return "uint"
+	}
+	return "string"
+}
+
+// describe renders the help text of a flag with its default, the
+// environment variable it is read from and whether it may be repeated.
+func describe(f helpField) string {
+	parts := []string{}
+	if f.help != "" {
+		parts = append(parts, f.help)
+	}
+	if f.def != "" {
+		parts = append(parts, fmt.Sprintf("(default %s)", f.def))
+	}
+	if f.env != "" {
+		parts = append(parts, fmt.Sprintf("(env $%s)", f.env))
+	}
+	t := f.typ
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if isList(t) {
+		parts = append(parts, "(repeatable)")
+	}
+	return strings.Join(parts, " ")
+}
@@ -0,0 +1,186 @@
+package cli
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+type helpCmd struct {
+	Format string   `cli_flag:"--format" cli_flag_alternatives:"-f" cli_group:"out" cli_enum:"text|json" cli_default:"text" cli_help:"Output format."`
+	Limit  int      `cli_flag:"--limit" cli_group:"out" cli_default:"10"`
+	Labels []string `cli_flag:"--label" cli_group:"out"`
+	Quiet  bool     `cli_flag:"--quiet" cli_flag_alternatives:"-q" cli_group:"out"`
+	ID     string   `cli_argument:"id" cli_help:"Container to show."`
+	Args   []string `cli_argument:"args"`
+}
+
+func (helpCmd) Description() string { return "show a container" }
+
+func (helpCmd) Slots() Slot {
+	return Group{
+		Unordered: []Slot{FlagGroup{Name: "out"}},
+		Ordered: []Slot{
+			Subcommand{Value: "show"},
+			Argument{Name: "id"},
+			Group{Ordered: []Slot{Literal{Value: "--"}, Arguments{Name: "args"}}},
+		},
+	}
+}
+
+type passthroughCmd struct {
+	Args []string `cli_argument:"args"`
+}
+
+func (passthroughCmd) Slots() Slot {
+	return Group{Ordered: []Slot{Subcommand{Value: "wrap"}, Arguments{Name: "args"}}}
+}
+
+type helpUnion struct {
+	Show *helpCmd
+	Wrap *passthroughCmd
+}
+
+func TestParse_Help(t *testing.T) {
+	t.Parallel()
+	for _, args := range [][]string{{"--help"}, {"-h", "abc"}, {"-q", "--help", "abc"}, {"--limit", "3", "-h"}, {"--bogus", "-h", "abc"}} {
+		var cmd helpCmd
+		err := Parse(&cmd, args)
+		var he *HelpError
+		if !errors.Is(err, ErrHelp) || !errors.As(err, &he) || he.Command != &cmd {
+			t.Errorf("Parse(%v) = %v, want a help request for the command", args, err)
+		}
+	}
+
+	// Past the first argument, a help flag is a mistake like any other, and
+	// the parse error tells about the first one.
+	for _, tc := range []struct {
+		args []string
+		want string
+	}{
+		{[]string{"abc", "-h"}, `unknown flag "-h"`},
+		{[]string{"abc", "def", "--help"}, `unexpected "def" (at index 1), expected "--"`},
+	} {
+		var cmd helpCmd
+		if err := Parse(&cmd, tc.args); errors.Is(err, ErrHelp) || err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("Parse(%v) = %v, want %s", tc.args, err, tc.want)
+		}
+	}
+
+	// After "--" and in passthrough arguments, help flags are arguments.
+	var cmd helpCmd
+	if err := Parse(&cmd, []string{"abc", "--", "--help"}); err != nil || len(cmd.Args) != 1 {
+		t.Errorf("Parse after -- = %v, args %v", err, cmd.Args)
+	}
+	var wrap passthroughCmd
+	if err := Parse(&wrap, []string{"--help"}); err != nil || len(wrap.Args) != 1 {
+		t.Errorf("Parse passthrough = %v, args %v", err, wrap.Args)
+	}
+}
+
+func TestParse_Defaults(t *testing.T) {
+	t.Parallel()
+	var cmd helpCmd
+	if err := Parse(&cmd, []string{"abc"}); err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	if cmd.Format != "text" || cmd.Limit != 10 {
+		t.Errorf("defaults = %q, %d; want text, 10", cmd.Format, cmd.Limit)
+	}
+	cmd = helpCmd{}
+	if err := Parse(&cmd, []string{"--format=json", "--limit", "3", "abc"}); err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	if cmd.Format != "json" || cmd.Limit != 3 {
+		t.Errorf("flags = %q, %d; want json, 3", cmd.Format, cmd.Limit)
+	}
+}
+
+type badDefaultCmd struct {
+	Limit  int    `cli_flag:"--limit" cli_group:"g" cli_default:"ten"`
+	Format string `cli_flag:"--format" cli_group:"g" cli_enum:"text|json" cli_default:"yaml"`
+	Quiet  bool   `cli_flag:"--quiet" cli_group:"g" cli_default:"yes"`
+	Loose  string `cli_help:"not a flag"`
+}
+
+func (badDefaultCmd) Slots() Slot {
+	return Group{Unordered: []Slot{FlagGroup{Name: "g"}}}
+}
+
+func TestValidateCommandTags_Defaults(t *testing.T) {
+	t.Parallel()
+	err := ValidateCommandTags(&badDefaultCmd{})
+	if err == nil {
+		t.Fatalf("ValidateCommandTags accepted bad defaults")
+	}
+	for _, want := range []string{`"Limit" has invalid cli_default`, `"Format" cli_default "yaml" is not one of`, `"Quiet" has invalid cli_default`, `"Loose" has cli_help or cli_default`} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q lacks %q", err, want)
+		}
+	}
+}
+
+func TestParseAny_Help(t *testing.T) {
+	t.Parallel()
+	var u helpUnion
+	err := ParseAny(&u, []string{"--help"})
+	var he *HelpError
+	if !errors.As(err, &he) || he.Command != nil || len(he.Commands) != 2 {
+		t.Fatalf("ParseAny(--help) = %v, want a help request for the union", err)
+	}
+
+	if err := ParseAny(&u, []string{"help", "show"}); !errors.As(err, &he) || SubcommandOf(he.Command) != "show" {
+		t.Errorf("ParseAny(help show) = %v, want a help request for show", err)
+	}
+
+	// "help wrap more" hands the request to the wrapped command.
+	u = helpUnion{}
+	if err := ParseAny(&u, []string{"help", "wrap", "inner"}); err != nil || u.Wrap == nil || strings.Join(u.Wrap.Args, " ") != "inner --help" {
+		t.Errorf("ParseAny(help wrap inner) = %v, %+v", err, u.Wrap)
+	}
+
+	err = Nest("tool", Nest("outer", ParseAny(&u, []string{"show", "-h"})))
+	if !errors.As(err, &he) || strings.Join(he.Path, " ") != "tool outer" {
+		t.Errorf("nested help = %v, path %v", err, he.Path)
+	}
+}
+
+func TestWriteUsage(t *testing.T) {
+	t.Parallel()
+	he := &HelpError{Path: []string{"tool"}, Command: &helpCmd{}}
+	want := `NAME
+  tool show - show a container
+
+SYNOPSIS
+  tool show [out options] <id> [-- [<args>...]]
+
+OPTIONS (out)
+  -f, --format=text|json
+      Output format. (default text)
+  --limit=<int>
+      (default 10)
+  --label=<string>
+      (repeatable)
+  -q, --quiet
+
+ARGUMENTS
+  <id>
+      Container to show.
+`
+	if got := he.Usage(); got != want {
+		t.Errorf("usage:\n%s\nwant:\n%s", got, want)
+	}
+
+	he = &HelpError{Path: []string{"tool"}, Commands: []Command{&helpCmd{}, &passthroughCmd{}}}
+	want = `SYNOPSIS
+  tool <command> [<args>...]
+  tool help <command>
+
+COMMANDS
+  show  show a container
+  wrap
+`
+	if got := he.Usage(); got != want {
+		t.Errorf("union usage:\n%s\nwant:\n%s", got, want)
+	}
+}
@@ -1,34 +1,59 @@
 package cli
 
 import (
+	"errors"
 	"fmt"
+	"maps"
 	"reflect"
+	"slices"
 	"strconv"
 	"strings"
 )
 
-func ParseAny[T any](cmdUnion *T, args []string) error {
+// ParseAny parses args into the member of the union cmdUnion, a struct of
+// pointers to commands, whose subcommand they hold, as Parse does.
+func ParseAny[T any](cmdUnion *T, args []string, sources ...Source) error {
 	if cmdUnion == nil {
 		return fmt.Errorf("Parse: nil cmdUnion")
 	}
 	if len(args) == 0 {
-		return fmt.Errorf("Parse: missing subcommand")
+		return &MissingArgumentError{Expected: "subcommand"}
 	}
 
 	// Expand equals for flags first.
-	args = expandEquals(args)
+	args, origin := expandEquals(args)
 
 	// Discover subcommand tokens for each union field and find a match in args.
 	v := reflect.ValueOf(cmdUnion).Elem()
+	members, err := unionMembers(v.Type())
+	if err != nil {
+		return err
+	}
+
+	// "help [subcommand...]", or a help flag before any subcommand.
+	if args[0] == "help" || isHelp(args[0]) {
+		if args[0] == "help" && len(args) > 1 {
+			for _, cmd := range members {
+				if SubcommandOf(cmd) != args[1] {
+					continue
+				}
+				if len(args) == 2 {
+					return &HelpError{Command: cmd}
+				}
+				// "help display vnc": ask the subcommand itself, which
+				// hands it to the union it dispatches to.
+				args = append(append([]string{}, args[1:]...), "--help")
+				break
+			}
+		}
+		if args[0] == "help" || isHelp(args[0]) {
+			return &HelpError{Commands: members}
+		}
+	}
+
 	matchIdx := -1
 	fieldIdx := -1
-	for i := 0; i < v.NumField(); i++ {
-		ft := v.Field(i).Type()
-		inst := reflect.New(ft.Elem()).Interface()
-		cmd, ok := inst.(Command)
-		if !ok {
-			return fmt.Errorf("field type '%s' does not implement Command", ft.Name())
-		}
+	for i, cmd := range members {
 		sub := SubcommandOf(cmd)
 		for j, tok := range args {
 			if tok == sub {
@@ -42,7 +67,10 @@ func ParseAny[T any](cmdUnion *T, args []string) error {
 		}
 	}
 	if matchIdx == -1 {
-		return fmt.Errorf("Parse: no valid subcommand found")
+		if askedForHelp(args, nil) {
+			return &HelpError{Commands: members}
+		}
+		return unknownSubcommand(args, origin, members)
 	}
 
 	// Instantiate the chosen command and parse with subcommand removed.
@@ -51,8 +79,13 @@ func ParseAny[T any](cmdUnion *T, args []string) error {
 	cmd := cmdVal.Interface().(Command)
 
 	rest := append(append([]string{}, args[:matchIdx]...), args[matchIdx+1:]...)
-	if err := Parse(cmd, rest); err != nil {
-		return err
+	if err := parse(cmd, rest, matchIdx, sources); err != nil {
+		return reposition(err, func(i int) int {
+			if i >= matchIdx {
+				i++
+			}
+			return origin[i]
+		})
 	}
 	if !field.CanSet() {
 		return fmt.Errorf("field %d not settable", fieldIdx)
@@ -61,11 +94,97 @@ func ParseAny[T any](cmdUnion *T, args []string) error {
 	return nil
 }
 
+// unknownSubcommand reports that args hold none of the subcommands of the
+// union members: the token that is closest to one of them, or else the
+// first that isn't a flag, is taken for a mistyped subcommand.
+func unknownSubcommand(args []string, origin []int, members []Command) error {
+	var subs []string
+	for _, m := range members {
+		if sub := SubcommandOf(m); sub != "" {
+			subs = append(subs, sub)
+		}
+	}
+	at := -1
+	for i, tok := range args {
+		if looksLikeFlagToken(tok) {
+			continue
+		}
+		if at == -1 {
+			at = i
+		}
+		if len(suggest(tok, subs)) > 0 {
+			at = i
+			break
+		}
+	}
+	if at == -1 {
+		return &MissingArgumentError{Position: Position{Index: origin[len(args)]}, Expected: "subcommand"}
+	}
+	return &UnexpectedTokenError{
+		Position:    Position{Index: origin[at]},
+		Token:       args[at],
+		Expected:    "subcommand",
+		Suggestions: suggest(args[at], subs),
+	}
+}
+
+// reposition maps the index of the parse error err, if it is one, with
+// index.
+func reposition(err error, index func(int) int) error {
+	var p positioned
+	if errors.As(err, &p) {
+		p.position().Index = index(p.position().Index)
+	}
+	return err
+}
+
+// Members returns a zero command for each member of the union T, a struct
+// of pointers to commands as ParseAny takes.
+func Members[T any]() []Command {
+	members, _ := unionMembers(reflect.TypeFor[T]())
+	return members
+}
+
+func unionMembers(t reflect.Type) ([]Command, error) {
+	var members []Command
+	for i := 0; i < t.NumField(); i++ {
+		ft := t.Field(i).Type
+		inst := reflect.New(ft.Elem()).Interface()
+		cmd, ok := inst.(Command)
+		if !ok {
+			return members, fmt.Errorf("field type '%s' does not implement Command", ft.Name())
+		}
+		members = append(members, cmd)
+	}
+	return members, nil
+}
+
 // Parse reads args into cmd according to struct tags.
 // Flags from groups within the same contiguous segment may appear in any order.
 // The ordering is enforced by the Slots() structure. Literals (including "--")
 // are matched exactly and do not set any values.
-func Parse(cmd Command, args []string) error {
+//
+// Fields hold strings, integers, booleans, time.Duration or types
+// implementing encoding.TextUnmarshaler, or slices of those, which repeated
+// flags append to, or maps, which take KEY=VALUE. Slices and maps with a
+// cli_sep tag also take several values at once, joined by the separator.
+//
+// Flags left out of args are filled from their cli_env variable, sources
+// and cli_default, in the order Source documents.
+//
+// Errors in the arguments are an *UnknownFlagError, a *MissingArgumentError,
+// an *EnumError or an *UnexpectedTokenError, locating the token at fault.
+func Parse(cmd Command, args []string, sources ...Source) error {
+	// Expand --flag=value
+	args, origin := expandEquals(args)
+	return reposition(parse(cmd, args, -1, sources), func(i int) int { return origin[i] })
+}
+
+// parse is Parse with args expanded already. ParseAny took the subcommand
+// out of args at subAt, if not negative: flags before it are only read up
+// to there, so that a flag of the subcommand isn't taken for a global flag
+// of the same name.
+func parse(cmd Command, args []string, subAt int, sources []Source) error {
 	if cmd == nil {
 		return fmt.Errorf("Parse: nil cmd")
 	}
@@ -73,9 +192,6 @@ func Parse(cmd Command, args []string) error {
 		return err
 	}
 
-	// Expand --flag=value
-	args = expandEquals(args)
-
 	type fieldInfo struct {
 		sf   reflect.StructField
 		val  reflect.Value
@@ -83,6 +199,11 @@ func Parse(cmd Command, args []string) error {
 		alts []string
 		argG string
 		grp  string
+		def  string
+		env  string
+		sep  string
+		enum []string
+		set  bool
 	}
 
 	v := reflect.ValueOf(cmd).Elem()
@@ -92,6 +213,11 @@ func Parse(cmd Command, args []string) error {
 		altSpec, hasAlt := sf.Tag.Lookup("cli_flag_alternatives")
 		argG, hasArg := sf.Tag.Lookup("cli_argument")
 		grp, _ := sf.Tag.Lookup("cli_group")
+		def := sf.Tag.Get("cli_default")
+		var enum []string
+		if e := sf.Tag.Get("cli_enum"); e != "" {
+			enum = strings.Split(e, "|")
+		}
 		if !hasFlag && !hasArg {
 			return
 		}
@@ -114,7 +240,7 @@ func Parse(cmd Command, args []string) error {
 				return argG
 			}
 			return ""
-		}(), grp: grp})
+		}(), grp: grp, def: def, env: sf.Tag.Get("cli_env"), sep: sf.Tag.Get("cli_sep"), enum: enum})
 	})
 
 	// Indexes
@@ -144,8 +270,53 @@ func Parse(cmd Command, args []string) error {
 		return m
 	}
 
+	// Help flags only ask for help where the command doesn't take them
+	// itself, and arguments take anything but the flags of the command.
+	flags := map[string]bool{}
+	for tok, f := range tokensForGroups(slices.Collect(maps.Keys(flagsByGroup))) {
+		flags[tok] = flagTakesValue(f.val)
+	}
+	isFlag := func(tok string) bool {
+		_, ok := flags[tok]
+		return ok
+	}
+
+	set := func(fi *fieldInfo, val string) error {
+		fi.set = true
+		return setValue(fi.val, val, fi.sep)
+	}
+
+	// Errors locate the token at fault, and suggest flags of the command
+	// for unknown ones.
+	sub := SubcommandOf(cmd)
+	at := func(i int) Position { return Position{Index: i, Subcommand: sub} }
+	var flagNames []string
+	for _, f := range fields {
+		if f.flag != "" {
+			flagNames = append(flagNames, f.flag)
+			flagNames = append(flagNames, f.alts...)
+		}
+	}
+	unexpected := func(i int, expected string) error {
+		tok := args[i]
+		if looksLikeFlagToken(tok) {
+			// A flag of the command, out of place, is no typo of itself.
+			suggestions := slices.DeleteFunc(suggest(tok, flagNames), func(s string) bool { return s == tok })
+			return &UnknownFlagError{Position: at(i), Flag: tok, Suggestions: suggestions}
+		}
+		return &UnexpectedTokenError{Position: at(i), Token: tok, Expected: expected}
+	}
+
 	// Recursively parse slots with inherited unordered groups/args
 	idx := 0
+	// limit bounds the flags read until the Subcommand slot is reached.
+	limit := len(args)
+	if subAt >= 0 {
+		limit = subAt
+	}
+	// passthrough is set within groups whose variadic arguments take help
+	// flags along with the rest, to hand them to another command.
+	passthrough := false
 
 	type unorderedArg struct {
 		name     string
@@ -170,29 +341,49 @@ func Parse(cmd Command, args []string) error {
 		return groups, argspecs
 	}
 
+	// takeFlag consumes the flag tok at idx, and its value if it takes one.
+	takeFlag := func(fi *fieldInfo, tok string) error {
+		idx++
+		if !flagTakesValue(fi.val) {
+			if err := set(fi, ""); err != nil {
+				return fmt.Errorf("%s: %w", fi.sf.Name, err)
+			}
+			return nil
+		}
+		if idx >= len(args) {
+			return &MissingArgumentError{Position: at(idx), Expected: "value of " + tok}
+		}
+		val := args[idx]
+		if len(fi.enum) > 0 && !slices.Contains(fi.enum, val) {
+			return &EnumError{Position: at(idx), Flag: tok, Value: val, Allowed: fi.enum, Suggestions: suggest(val, fi.enum)}
+		}
+		idx++
+		if err := set(fi, val); err != nil {
+			return fmt.Errorf("%s: %w", fi.sf.Name, err)
+		}
+		return nil
+	}
+
 	// Consume unordered tokens (flags and arguments) from allowed sets
 	consumeUnordered := func(allowed map[string]*fieldInfo, uargs []*unorderedArg) error {
-		for idx < len(args) {
+		for idx < limit {
 			tok := args[idx]
 			if fi, ok := allowed[tok]; ok {
-				idx++
-				if flagTakesValue(fi.val) {
-					if idx >= len(args) {
-						return fmt.Errorf("flag %s requires value", tok)
-					}
-					val := args[idx]
-					idx++
-					if err := setValue(fi.val, val); err != nil {
-						return fmt.Errorf("%s: %w", fi.sf.Name, err)
-					}
-				} else {
-					if err := setValue(fi.val, ""); err != nil {
-						return fmt.Errorf("%s: %w", fi.sf.Name, err)
-					}
+				if err := takeFlag(fi, tok); err != nil {
+					return err
 				}
 				continue
 			}
 
+			if isHelp(tok) && !passthrough && !slices.ContainsFunc(uargs, func(ua *unorderedArg) bool { return ua.variadic }) {
+				// Nothing takes it as an argument: a request for help,
+				// unless arguments came first.
+				if askedForHelp(args[:idx+1], flags) {
+					return ErrHelp
+				}
+				return unexpected(idx, "")
+			}
+
 			assigned := false
 			remainingSingles := 0
 			for _, ua := range uargs {
@@ -206,7 +397,7 @@ func Parse(cmd Command, args []string) error {
 					if remainingSingles == 0 || remainingTokens-1 > remainingSingles {
 						fs := argsByName[ua.name]
 						for _, fi := range fs {
-							if err := setValue(fi.val, tok); err != nil {
+							if err := set(fi, tok); err != nil {
 								return fmt.Errorf("%s: %w", fi.sf.Name, err)
 							}
 						}
@@ -214,9 +405,12 @@ func Parse(cmd Command, args []string) error {
 						assigned = true
 					}
 				} else if !ua.consumed {
+					if isFlag(tok) {
+						return unexpected(idx, "")
+					}
 					fs := argsByName[ua.name]
 					for _, fi := range fs {
-						if err := setValue(fi.val, tok); err != nil {
+						if err := set(fi, tok); err != nil {
 							return fmt.Errorf("%s: %w", fi.sf.Name, err)
 						}
 					}
@@ -238,27 +432,15 @@ func Parse(cmd Command, args []string) error {
 
 	// Consume flags from allowed set greedily; unknown flag ends window (no error here)
 	consumeFlags := func(allowed map[string]*fieldInfo) error {
-		for idx < len(args) {
+		for idx < limit {
 			tok := args[idx]
 			fi, ok := allowed[tok]
 			if !ok {
 				// not allowed here; leave for later items
 				break
 			}
-			idx++
-			if flagTakesValue(fi.val) {
-				if idx >= len(args) {
-					return fmt.Errorf("flag %s requires value", tok)
-				}
-				val := args[idx]
-				idx++
-				if err := setValue(fi.val, val); err != nil {
-					return fmt.Errorf("%s: %w", fi.sf.Name, err)
-				}
-			} else {
-				if err := setValue(fi.val, ""); err != nil {
-					return fmt.Errorf("%s: %w", fi.sf.Name, err)
-				}
+			if err := takeFlag(fi, tok); err != nil {
+				return err
 			}
 		}
 		return nil
@@ -272,7 +454,7 @@ func Parse(cmd Command, args []string) error {
 				onlyOptional := true
 				for _, o := range v.Ordered {
 					switch o.(type) {
-					case FlagGroup, Subcommand:
+					case FlagGroup, Subcommand, RenderUnordered:
 					case Literal:
 						// handled below
 						onlyOptional = false
@@ -292,8 +474,14 @@ func Parse(cmd Command, args []string) error {
 						return nil
 					}
 				}
-				return fmt.Errorf("missing required arguments")
+				return &MissingArgumentError{Position: at(idx), Expected: firstRequired(v)}
 			}
+			defer func(outer bool) { passthrough = outer }(passthrough)
+			passthrough = passthrough || slices.ContainsFunc(v.Ordered, func(o Slot) bool {
+				_, ok := o.(Arguments)
+				return ok
+			})
+
 			// Active unordered groups/args = inherited + this group's unordered
 			localGroups := append([]string{}, inheritedGroups...)
 			localArgs := append([]*unorderedArg{}, inheritedArgs...)
@@ -301,6 +489,18 @@ func Parse(cmd Command, args []string) error {
 			localGroups = append(localGroups, groups...)
 			localArgs = append(localArgs, ua...)
 			unorderedTokens := tokensForGroups(localGroups)
+			// The group's own unordered slots follow its subcommand, when
+			// ParseAny saw where it was: before it, flags are global ones,
+			// or inherited. After it, the flags of the groups before it are
+			// still taken, unless the subcommand has one of the same name.
+			activeTokens, activeArgs := unorderedTokens, localArgs
+			var outerGroups []string
+			if subAt >= 0 && slices.ContainsFunc(v.Ordered, func(o Slot) bool {
+				_, ok := o.(Subcommand)
+				return ok
+			}) {
+				activeTokens, activeArgs = tokensForGroups(inheritedGroups), inheritedArgs
+			}
 
 			// Walk ordered items in sequence
 			blockUnordered := false
@@ -313,7 +513,7 @@ func Parse(cmd Command, args []string) error {
 
 				// Greedily consume unordered before this item (unless blocked or this item is a Literal)
 				if _, isLit := v.Ordered[i].(Literal); !blockUnordered && !isLit {
-					if err := consumeUnordered(unorderedTokens, localArgs); err != nil {
+					if err := consumeUnordered(activeTokens, activeArgs); err != nil {
 						return err
 					}
 				}
@@ -321,15 +521,22 @@ func Parse(cmd Command, args []string) error {
 				switch ov := v.Ordered[i].(type) {
 				case FlagGroup:
 					// Position-specific flag window
+					outerGroups = append(outerGroups, ov.Name)
 					allowed := tokensForGroups([]string{ov.Name})
 					if err := consumeFlags(allowed); err != nil {
 						return err
 					}
 				case Subcommand:
 					// Subcommand token is removed by ParseAny; act as anchor only
+					limit = len(args)
+					activeTokens, activeArgs = tokensForGroups(outerGroups), localArgs
+					maps.Copy(activeTokens, unorderedTokens)
 				case Literal:
-					if idx >= len(args) || args[idx] != ov.Value {
-						return fmt.Errorf("expected literal %q", ov.Value)
+					if idx >= len(args) {
+						return &MissingArgumentError{Position: at(idx), Expected: strconv.Quote(ov.Value)}
+					}
+					if args[idx] != ov.Value {
+						return unexpected(idx, strconv.Quote(ov.Value))
 					}
 					idx++
 					// After a Literal, disable unordered flags for the rest of this Group
@@ -338,11 +545,14 @@ func Parse(cmd Command, args []string) error {
 					fs := argsByName[ov.Name]
 					for _, fi := range fs {
 						if idx >= len(args) {
-							return fmt.Errorf("missing value for %s", ov.Name)
+							return &MissingArgumentError{Position: at(idx), Expected: "<" + ov.Name + ">"}
+						}
+						if isFlag(args[idx]) {
+							return unexpected(idx, "<"+ov.Name+">")
 						}
 						val := args[idx]
 						idx++
-						if err := setValue(fi.val, val); err != nil {
+						if err := set(fi, val); err != nil {
 							return fmt.Errorf("%s: %w", fi.sf.Name, err)
 						}
 					}
@@ -357,7 +567,7 @@ func Parse(cmd Command, args []string) error {
 						// No special literal handling; literals must be next ordered
 						idx++
 						for _, fi := range fs {
-							if err := setValue(fi.val, val); err != nil {
+							if err := set(fi, val); err != nil {
 								return fmt.Errorf("%s: %w", fi.sf.Name, err)
 							}
 						}
@@ -370,7 +580,7 @@ func Parse(cmd Command, args []string) error {
 				}
 				// Greedily consume unordered after this item unless blocked or next ordered is a Literal
 				if !blockUnordered && !nextIsLiteral {
-					if err := consumeUnordered(unorderedTokens, localArgs); err != nil {
+					if err := consumeUnordered(activeTokens, activeArgs); err != nil {
 						return err
 					}
 				}
@@ -387,11 +597,41 @@ func Parse(cmd Command, args []string) error {
 		}
 	}
 
-	if err := parse(cmd.Slots(), nil, nil); err != nil {
+	err := parse(cmd.Slots(), nil, nil)
+	if err == nil && idx != len(args) {
+		err = unexpected(idx, "")
+	}
+	if errors.Is(err, ErrHelp) || (err != nil && askedForHelp(args, flags)) {
+		return &HelpError{Command: cmd}
+	}
+	if err != nil {
 		return err
 	}
-	if idx != len(args) {
-		return fmt.Errorf("unexpected trailing args: %v", args[idx:])
+
+	for i := range fields {
+		f := &fields[i]
+		if f.set || f.flag == "" {
+			continue
+		}
+		vals, from, ok := lookupSources(f.flag, f.env, sources)
+		if !ok {
+			continue
+		}
+		f.set = true
+		for _, val := range vals {
+			if err := setSourced(f.val, val, f.enum, f.sep); err != nil {
+				return fmt.Errorf("%s from %s: %w", f.flag, from, err)
+			}
+		}
+	}
+	for i := range fields {
+		f := &fields[i]
+		if f.set || f.def == "" || f.def == "false" {
+			continue
+		}
+		if err := setValue(f.val, f.def, f.sep); err != nil {
+			return fmt.Errorf("%s: default: %w", f.sf.Name, err)
+		}
 	}
 	return nil
 }
@@ -407,13 +647,19 @@ func flagTakesValue(v reflect.Value) bool {
 	return true
 }
 
-func setValue(v reflect.Value, val string) error {
+// setValue sets v from val, a value of its flag or argument. Slices append
+// val and maps add it as KEY=VALUE; given the separator sep of their
+// cli_sep tag, val holds several of those.
+func setValue(v reflect.Value, val, sep string) error {
 	for v.Kind() == reflect.Pointer {
 		if v.IsNil() {
 			v.Set(reflect.New(v.Type().Elem()))
 		}
 		v = v.Elem()
 	}
+	if ok, err := unmarshalText(v, val); ok {
+		return err
+	}
 	switch v.Kind() {
 	case reflect.Bool:
 		v.SetBool(true)
@@ -441,43 +687,71 @@ func setValue(v reflect.Value, val string) error {
 		}
 		v.SetUint(n)
 		return nil
-	case reflect.Slice:
-		elem := v.Type().Elem()
-		switch elem.Kind() {
-		case reflect.String:
-			v.Set(reflect.Append(v, reflect.ValueOf(val)))
-			return nil
-		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
-			n, err := strconv.ParseInt(val, 10, 64)
-			if err != nil {
-				return err
+	case reflect.Slice, reflect.Map:
+		vals := []string{val}
+		if sep != "" {
+			vals = strings.Split(val, sep)
+		}
+		for _, val := range vals {
+			if v.Kind() == reflect.Map {
+				if err := setEntry(v, val); err != nil {
+					return err
+				}
+				continue
 			}
-			v.Set(reflect.Append(v, reflect.ValueOf(n).Convert(elem)))
-			return nil
-		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
-			n, err := strconv.ParseUint(val, 10, 64)
-			if err != nil {
+			elem := reflect.New(v.Type().Elem()).Elem()
+			if k := elem.Kind(); !isText(elem.Type()) && (k == reflect.Slice || k == reflect.Map || k == reflect.Bool) {
+				return fmt.Errorf("unsupported slice element type %s", elem.Type())
+			}
+			if err := setValue(elem, val, ""); err != nil {
 				return err
 			}
-			v.Set(reflect.Append(v, reflect.ValueOf(n).Convert(elem)))
-			return nil
+			v.Set(reflect.Append(v, elem))
 		}
+		return nil
 	}
 	return fmt.Errorf("unsupported field kind %s", v.Kind())
 }
 
 // expandEquals splits tokens of the form "--flag=value" or "-f=value" into
 // separate flag and value tokens so that standard flag processing can occur.
-func expandEquals(args []string) []string {
-	var out []string
-	for _, a := range args {
+// origin maps the index of each token out, and their number, back to args.
+func expandEquals(args []string) (out []string, origin []int) {
+	for i, a := range args {
 		if strings.HasPrefix(a, "-") {
 			if eq := strings.Index(a, "="); eq != -1 {
 				out = append(out, a[:eq], a[eq+1:])
+				origin = append(origin, i, i)
 				continue
 			}
 		}
 		out = append(out, a)
+		origin = append(origin, i)
+	}
+	return out, append(origin, len(args))
+}
+
+// firstRequired describes the first slot of g arguments can't be left out
+// of.
+func firstRequired(g Group) string {
+	if r := requiredSlot(g); r != "" {
+		return r
+	}
+	return "arguments"
+}
+
+func requiredSlot(g Group) string {
+	for _, o := range g.Ordered {
+		switch ov := o.(type) {
+		case Argument:
+			return "<" + ov.Name + ">"
+		case Literal:
+			return strconv.Quote(ov.Value)
+		case Group:
+			if r := requiredSlot(ov); r != "" {
+				return r
+			}
+		}
 	}
-	return out
+	return ""
 }
@@ -7,6 +7,7 @@ var (
 	_ Slot = Literal{}
 	_ Slot = Subcommand{}
 	_ Slot = Group{}
+	_ Slot = RenderUnordered{}
 )
 
 type Slot interface{ slot() }
@@ -47,6 +48,19 @@ type Subcommand struct {
 
 func (Subcommand) slot() {}
 
+// RenderUnordered marks where, among the Ordered slots of a Group,
+// ConvertToCmdline renders the Group's Unordered slots. Without one they
+// are rendered right after the Subcommand, or else before the first
+// positional slot. Parsing ignores it: unordered slots are accepted
+// anywhere in their Group either way.
+//
+// For example, runc wants the flags of update after the container ID:
+//
+//	Ordered: []Slot{Subcommand{Value: "update"}, Argument{Name: "container_id"}, RenderUnordered{}}
+type RenderUnordered struct{}
+
+func (RenderUnordered) slot() {}
+
 // Group defines a segment of command-line arguments where flags and
 // positional arguments can be mixed.
 type Group struct {
@@ -56,7 +70,8 @@ type Group struct {
 	Unordered []Slot
 
 	// Ordered contains Argument, Arguments, Literal, and Subcommand
-	// slots. These must appear in the specified sequence.
+	// slots. These must appear in the specified sequence. It may also hold
+	// a RenderUnordered slot.
 	Ordered []Slot
 }
 
@@ -0,0 +1,115 @@
+package cli
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Source supplies values for the flags the command line leaves unset, e.g.
+// the settings of a configuration file.
+//
+// Parse and ParseAny fill a flag, in order of precedence, from:
+//
+//  1. the command line;
+//  2. the environment variable named by its cli_env tag, if set;
+//  3. the first of the sources given that has a value for it;
+//  4. its cli_default.
+type Source interface {
+	// Lookup returns the values of the flag named key, which is its
+	// cli_flag without the leading dashes, e.g. "delegate_path". Each
+	// value is taken as if the flag were given once with it.
+	Lookup(key string) ([]string, bool)
+}
+
+// MapSource is a Source holding the values of flags by key.
+type MapSource map[string][]string
+
+func (m MapSource) Lookup(key string) ([]string, bool) {
+	vals, ok := m[key]
+	return vals, ok
+}
+
+// ReadConfig reads a MapSource from lines of "key = value", the key being
+// a flag without its leading dashes. Blank lines and lines starting with #
+// are skipped; repeating a key gives a repeatable flag more values.
+func ReadConfig(r io.Reader) (MapSource, error) {
+	m := MapSource{}
+	sc := bufio.NewScanner(r)
+	for n := 1; sc.Scan(); n++ {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		key, val, ok := strings.Cut(line, "=")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" {
+			return nil, fmt.Errorf("line %d: want key = value, got %q", n, line)
+		}
+		m[key] = append(m[key], strings.TrimSpace(val))
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// ReadConfigFile reads the MapSource in the file at path, as ReadConfig.
+func ReadConfigFile(path string) (MapSource, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	m, err := ReadConfig(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return m, nil
+}
+
+// sourceKey is the key of flag in a Source.
+func sourceKey(flag string) string {
+	return strings.TrimLeft(flag, "-")
+}
+
+// lookupSources returns the values of the flag bound to the environment
+// variable env, if any, from the environment or else sources, along with
+// where they come from.
+func lookupSources(flag, env string, sources []Source) ([]string, string, bool) {
+	if env != "" {
+		if val, ok := os.LookupEnv(env); ok {
+			return []string{val}, "$" + env, true
+		}
+	}
+	for _, s := range sources {
+		if vals, ok := s.Lookup(sourceKey(flag)); ok && len(vals) > 0 {
+			return vals, sourceKey(flag), true
+		}
+	}
+	return nil, "", false
+}
+
+// setSourced sets v to val as found in a source. Unlike on the command
+// line, booleans take a value there: false leaves v unset.
+func setSourced(v reflect.Value, val string, enum []string, sep string) error {
+	t := v.Type()
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t.Kind() == reflect.Bool {
+		b, err := strconv.ParseBool(val)
+		if err != nil || !b {
+			return err
+		}
+	}
+	if len(enum) > 0 && !slices.Contains(enum, val) {
+		return fmt.Errorf("%q is not one of %s", val, strings.Join(enum, "|"))
+	}
+	return setValue(v, val, sep)
+}
@@ -0,0 +1,105 @@
+package cli
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type envCmd struct {
+	DelegatePath string   `cli_flag:"--delegate_path" cli_group:"g" cli_env:"CLI_TEST_DELEGATE_PATH" cli_default:"runc"`
+	Format       string   `cli_flag:"--format" cli_group:"g" cli_enum:"text|json" cli_env:"CLI_TEST_FORMAT"`
+	Labels       []string `cli_flag:"--label" cli_group:"g"`
+	Quiet        bool     `cli_flag:"--quiet" cli_group:"g" cli_env:"CLI_TEST_QUIET"`
+}
+
+func (envCmd) Slots() Slot {
+	return Group{Unordered: []Slot{FlagGroup{Name: "g"}}}
+}
+
+func TestParse_Precedence(t *testing.T) {
+	config, err := ReadConfig(strings.NewReader(`
+# vino settings
+delegate_path = /usr/bin/crun
+label = a
+label = b=c
+`))
+	if err != nil {
+		t.Fatalf("ReadConfig: %v", err)
+	}
+
+	var cmd envCmd
+	if err := Parse(&cmd, nil); err != nil || cmd.DelegatePath != "runc" {
+		t.Errorf("default: %v, %q", err, cmd.DelegatePath)
+	}
+	cmd = envCmd{}
+	if err := Parse(&cmd, nil, config); err != nil || cmd.DelegatePath != "/usr/bin/crun" || strings.Join(cmd.Labels, ",") != "a,b=c" {
+		t.Errorf("config: %v, %+v", err, cmd)
+	}
+	t.Setenv("CLI_TEST_DELEGATE_PATH", "/opt/runsc")
+	cmd = envCmd{}
+	if err := Parse(&cmd, nil, config); err != nil || cmd.DelegatePath != "/opt/runsc" {
+		t.Errorf("env: %v, %q", err, cmd.DelegatePath)
+	}
+	cmd = envCmd{}
+	if err := Parse(&cmd, []string{"--delegate_path=/bin/runc"}, config); err != nil || cmd.DelegatePath != "/bin/runc" {
+		t.Errorf("command line: %v, %q", err, cmd.DelegatePath)
+	}
+
+	// Values from the environment are rendered back as flags.
+	cmd = envCmd{}
+	if err := Parse(&cmd, []string{"--quiet"}); err != nil {
+		t.Fatal(err)
+	}
+	args, err := ConvertToCmdline(cmd)
+	if want := []string{"--delegate_path", "/opt/runsc", "--quiet"}; err != nil || !reflect.DeepEqual(args, want) {
+		t.Errorf("ConvertToCmdline = %q, %v; want %q", args, err, want)
+	}
+}
+
+func TestParse_EnvValues(t *testing.T) {
+	t.Setenv("CLI_TEST_QUIET", "false")
+	t.Setenv("CLI_TEST_FORMAT", "json")
+	var cmd envCmd
+	if err := Parse(&cmd, nil); err != nil || cmd.Quiet || cmd.Format != "json" {
+		t.Errorf("Parse = %v, %+v", err, cmd)
+	}
+
+	t.Setenv("CLI_TEST_QUIET", "1")
+	cmd = envCmd{}
+	if err := Parse(&cmd, nil); err != nil || !cmd.Quiet {
+		t.Errorf("Parse = %v, %+v; want --quiet set", err, cmd)
+	}
+
+	t.Setenv("CLI_TEST_FORMAT", "yaml")
+	err := Parse(&envCmd{}, nil)
+	if err == nil || !strings.Contains(err.Error(), `--format from $CLI_TEST_FORMAT: "yaml" is not one of text|json`) {
+		t.Errorf("Parse with a bad enum = %v", err)
+	}
+}
+
+type badEnvCmd struct {
+	Path string `cli_flag:"--path" cli_group:"g" cli_env:"NOT-A-NAME"`
+	Arg  string `cli_argument:"arg" cli_env:"ARG"`
+}
+
+func (badEnvCmd) Slots() Slot {
+	return Group{Unordered: []Slot{FlagGroup{Name: "g"}}, Ordered: []Slot{Argument{Name: "arg"}}}
+}
+
+func TestValidateCommandTags_Env(t *testing.T) {
+	t.Parallel()
+	err := ValidateCommandTags(&badEnvCmd{})
+	for _, want := range []string{`"Path" has invalid cli_env "NOT-A-NAME"`, `"Arg" has cli_env but no cli_flag`} {
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("ValidateCommandTags = %v, want %q", err, want)
+		}
+	}
+}
+
+func TestReadConfig_Errors(t *testing.T) {
+	t.Parallel()
+	if _, err := ReadConfig(strings.NewReader("ok = 1\nbroken\n")); err == nil || !strings.Contains(err.Error(), "line 2") {
+		t.Errorf("ReadConfig = %v, want an error on line 2", err)
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"reflect"
 	"regexp"
+	"slices"
 	"strings"
 )
 
@@ -73,12 +74,33 @@ func ValidateCommandTags(cmd Command) error {
 		argGroup, hasArg := sf.Tag.Lookup("cli_argument")
 		group, hasGroup := sf.Tag.Lookup("cli_group")
 		enum, hasEnum := sf.Tag.Lookup("cli_enum")
+		_, hasHelp := sf.Tag.Lookup("cli_help")
+		def, hasDefault := sf.Tag.Lookup("cli_default")
+		env, hasEnv := sf.Tag.Lookup("cli_env")
+		sep, hasSep := sf.Tag.Lookup("cli_sep")
 
 		// skip untagged fields
-		if !hasFlag && !hasArg && !hasAlt {
+		if !hasFlag && !hasArg && !hasAlt && !hasHelp && !hasDefault && !hasEnv && !hasSep {
 			return
 		}
 
+		if (hasHelp || hasDefault) && !hasFlag && !hasArg {
+			errs = append(errs, fmt.Sprintf("%s: field %q has cli_help or cli_default but no cli_flag or cli_argument", typ, sf.Name))
+			return
+		}
+
+		if hasEnv && !hasFlag {
+			errs = append(errs, fmt.Sprintf("%s: field %q has cli_env but no cli_flag", typ, sf.Name))
+		} else if hasEnv && !envNameRe.MatchString(env) {
+			errs = append(errs, fmt.Sprintf("%s: field %q has invalid cli_env %q", typ, sf.Name, env))
+		}
+
+		if hasSep && !hasFlag {
+			errs = append(errs, fmt.Sprintf("%s: field %q has cli_sep but no cli_flag", typ, sf.Name))
+		} else if hasSep && (sep == "" || !isList(sf.Type)) {
+			errs = append(errs, fmt.Sprintf("%s: field %q has cli_sep %q but is not a list or map of values", typ, sf.Name, sep))
+		}
+
 		if hasAlt && !hasFlag {
 			errs = append(errs, fmt.Sprintf("%s: field %q has cli_flag_alternatives but no cli_flag", typ, sf.Name))
 		}
@@ -133,6 +155,14 @@ func ValidateCommandTags(cmd Command) error {
 					errs = append(errs, fmt.Sprintf(`%s: field %q has cli_enum but is not string or *string`, typ, sf.Name))
 				}
 			}
+			// default rules: must be a value of the field, and of its enum
+			if hasDefault {
+				if err := validateDefault(sf.Type, def, sep); err != nil {
+					errs = append(errs, fmt.Sprintf("%s: field %q has invalid cli_default %q: %v", typ, sf.Name, def, err))
+				} else if hasEnum && !slices.Contains(strings.Split(enum, "|"), def) {
+					errs = append(errs, fmt.Sprintf("%s: field %q cli_default %q is not one of cli_enum %q", typ, sf.Name, def, enum))
+				}
+			}
 			return
 		}
 
@@ -151,6 +181,10 @@ func ValidateCommandTags(cmd Command) error {
 			if hasEnum {
 				errs = append(errs, fmt.Sprintf(`%s: field %q (argument %q) must not have cli_enum`, typ, sf.Name, argGroup))
 			}
+			// defaults only make sense for flags, which may be left out
+			if hasDefault {
+				errs = append(errs, fmt.Sprintf(`%s: field %q (argument %q) must not have cli_default`, typ, sf.Name, argGroup))
+			}
 			return
 		}
 	})
@@ -163,6 +197,22 @@ func ValidateCommandTags(cmd Command) error {
 
 // ----------------- helpers (tags-only) -----------------
 
+// validateDefault checks that def can be parsed into a field of type t.
+// Booleans take "true" or "false".
+func validateDefault(t reflect.Type, def, sep string) error {
+	base := t
+	for base.Kind() == reflect.Pointer {
+		base = base.Elem()
+	}
+	if base.Kind() == reflect.Bool {
+		if def != "true" && def != "false" {
+			return errors.New(`must be "true" or "false"`)
+		}
+		return nil
+	}
+	return setValue(reflect.New(t).Elem(), def, sep)
+}
+
 func looksLikeFlag(s string) bool {
 	return strings.HasPrefix(s, "-")
 }
@@ -174,6 +224,15 @@ func isStringish(t reflect.Type) bool {
 	return t.Kind() == reflect.Pointer && t.Elem().Kind() == reflect.String
 }
 
+// isList reports whether fields of type t hold several values: slices and
+// maps, but for types parsing themselves.
+func isList(t reflect.Type) bool {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	return !isText(t) && (t.Kind() == reflect.Slice || t.Kind() == reflect.Map)
+}
+
 func firstDuplicate(ss []string) string {
 	seen := map[string]struct{}{}
 	for _, s := range ss {
@@ -195,5 +254,8 @@ func count(ss []string, needle string) int {
 	return n
 }
 
+// envNameRe matches the names of environment variables cli_env may bind.
+var envNameRe = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
 // (optional) quick sanity check for enum spec format (kept private; used above)
 var enumSpecRe = regexp.MustCompile(`^[^|]+(\|[^|]+)+$`)
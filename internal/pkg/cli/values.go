@@ -0,0 +1,132 @@
+package cli
+
+import (
+	"encoding"
+	"fmt"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Fields of types that parse and render themselves take their values
+// through those: time.Duration, which spells "5s", and any type
+// implementing encoding.TextUnmarshaler to be parsed, and
+// encoding.TextMarshaler to be rendered.
+var (
+	durationType        = reflect.TypeFor[time.Duration]()
+	textUnmarshalerType = reflect.TypeFor[encoding.TextUnmarshaler]()
+	textMarshalerType   = reflect.TypeFor[encoding.TextMarshaler]()
+)
+
+// isText reports whether values of t parse and render themselves.
+func isText(t reflect.Type) bool {
+	return t == durationType || reflect.PointerTo(t).Implements(textUnmarshalerType)
+}
+
+// unmarshalText sets v from val if its type parses itself, and reports
+// whether it does.
+func unmarshalText(v reflect.Value, val string) (bool, error) {
+	if v.Type() == durationType {
+		d, err := time.ParseDuration(val)
+		if err == nil {
+			v.SetInt(int64(d))
+		}
+		return true, err
+	}
+	if v.CanAddr() && v.Addr().Type().Implements(textUnmarshalerType) {
+		return true, v.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(val))
+	}
+	return false, nil
+}
+
+// marshalText renders v if its type renders itself, and reports whether it
+// does.
+func marshalText(v reflect.Value) (string, bool, error) {
+	if v.Type() == durationType {
+		return time.Duration(v.Int()).String(), true, nil
+	}
+	if !reflect.PointerTo(v.Type()).Implements(textMarshalerType) {
+		return "", false, nil
+	}
+	if !v.CanAddr() {
+		c := reflect.New(v.Type()).Elem()
+		c.Set(v)
+		v = c
+	}
+	b, err := v.Addr().Interface().(encoding.TextMarshaler).MarshalText()
+	return string(b), true, err
+}
+
+// formatScalar renders v, of a kind a flag or argument holds once.
+func formatScalar(v reflect.Value) (string, error) {
+	if s, ok, err := marshalText(v); ok {
+		return s, err
+	}
+	switch v.Kind() {
+	case reflect.String:
+		return v.String(), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(v.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return strconv.FormatUint(v.Uint(), 10), nil
+	}
+	return "", fmt.Errorf("unsupported kind %s", v.Kind())
+}
+
+// formatElems renders the elements of v, a slice or a map, one value each:
+// map entries as KEY=VALUE, sorted by key so that rendering is stable.
+func formatElems(v reflect.Value) ([]string, error) {
+	var vals []string
+	switch v.Kind() {
+	case reflect.Slice:
+		for i := 0; i < v.Len(); i++ {
+			s, err := formatScalar(v.Index(i))
+			if err != nil {
+				return nil, fmt.Errorf("element type %s: %w", v.Type().Elem(), err)
+			}
+			vals = append(vals, s)
+		}
+	case reflect.Map:
+		iter := v.MapRange()
+		for iter.Next() {
+			k, err := formatScalar(iter.Key())
+			if err != nil {
+				return nil, fmt.Errorf("key type %s: %w", v.Type().Key(), err)
+			}
+			e, err := formatScalar(iter.Value())
+			if err != nil {
+				return nil, fmt.Errorf("element type %s: %w", v.Type().Elem(), err)
+			}
+			vals = append(vals, k+"="+e)
+		}
+		slices.SortFunc(vals, func(a, b string) int {
+			ka, _, _ := strings.Cut(a, "=")
+			kb, _, _ := strings.Cut(b, "=")
+			return strings.Compare(ka, kb)
+		})
+	}
+	return vals, nil
+}
+
+// setEntry adds val, of the form KEY=VALUE, to the map v.
+func setEntry(v reflect.Value, val string) error {
+	k, e, ok := strings.Cut(val, "=")
+	if !ok {
+		return fmt.Errorf("value %q is not of the form KEY=VALUE", val)
+	}
+	key := reflect.New(v.Type().Key()).Elem()
+	if err := setValue(key, k, ""); err != nil {
+		return err
+	}
+	elem := reflect.New(v.Type().Elem()).Elem()
+	if err := setValue(elem, e, ""); err != nil {
+		return err
+	}
+	if v.IsNil() {
+		v.Set(reflect.MakeMap(v.Type()))
+	}
+	v.SetMapIndex(key, elem)
+	return nil
+}
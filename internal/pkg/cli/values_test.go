@@ -0,0 +1,120 @@
+package cli
+
+import (
+	"net/netip"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type valuesCmd struct {
+	Interval time.Duration     `cli_flag:"--interval" cli_group:"g" cli_default:"5s"`
+	Timeouts []time.Duration   `cli_flag:"--timeout" cli_group:"g"`
+	Env      map[string]string `cli_flag:"--env" cli_flag_alternatives:"-e" cli_group:"g"`
+	Caps     []string          `cli_flag:"--cap" cli_group:"g" cli_sep:","`
+	Gids     []uint            `cli_flag:"--gids" cli_group:"g" cli_sep:":"`
+	Addr     *netip.Addr       `cli_flag:"--addr" cli_group:"g"`
+	Peers    []netip.Addr      `cli_flag:"--peer" cli_group:"g"`
+}
+
+func (valuesCmd) Slots() Slot {
+	return Group{Unordered: []Slot{FlagGroup{Name: "g"}}}
+}
+
+func TestParse_TypedValues(t *testing.T) {
+	t.Parallel()
+	args := []string{"--timeout", "1m30s", "-e", "B=2", "--env=A=1=x", "--cap", "CAP_KILL,CAP_CHOWN", "--cap", "CAP_SYS_ADMIN",
+		"--gids", "1:2", "--addr", "10.0.0.1", "--peer", "::1", "--timeout=10ms", "-e", "B=3"}
+	var cmd valuesCmd
+	if err := Parse(&cmd, args); err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	addr := netip.MustParseAddr("10.0.0.1")
+	want := valuesCmd{
+		Interval: 5 * time.Second,
+		Timeouts: []time.Duration{90 * time.Second, 10 * time.Millisecond},
+		Env:      map[string]string{"A": "1=x", "B": "3"},
+		Caps:     []string{"CAP_KILL", "CAP_CHOWN", "CAP_SYS_ADMIN"},
+		Gids:     []uint{1, 2},
+		Addr:     &addr,
+		Peers:    []netip.Addr{netip.MustParseAddr("::1")},
+	}
+	if !reflect.DeepEqual(cmd, want) {
+		t.Fatalf("Parse(%q) =\n  %+v\nwant\n  %+v", args, cmd, want)
+	}
+
+	got, err := ConvertToCmdline(cmd)
+	if err != nil {
+		t.Fatalf("ConvertToCmdline: %v", err)
+	}
+	rendered := []string{"--interval", "5s", "--timeout", "1m30s", "--timeout", "10ms", "--env", "A=1=x", "--env", "B=3",
+		"--cap", "CAP_KILL,CAP_CHOWN,CAP_SYS_ADMIN", "--gids", "1:2", "--addr", "10.0.0.1", "--peer", "::1"}
+	if !reflect.DeepEqual(got, rendered) {
+		t.Errorf("ConvertToCmdline =\n  %q\nwant\n  %q", got, rendered)
+	}
+}
+
+func TestParse_TypedValueErrors(t *testing.T) {
+	t.Parallel()
+	for _, tc := range []struct {
+		args []string
+		want string
+	}{
+		{[]string{"--timeout", "5"}, `missing unit in duration "5"`},
+		{[]string{"--env", "A"}, `value "A" is not of the form KEY=VALUE`},
+		{[]string{"--gids", "1:x"}, `invalid syntax`},
+		{[]string{"--addr", "10.0.0"}, `ParseAddr("10.0.0")`},
+	} {
+		err := Parse(&valuesCmd{}, tc.args)
+		if err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("Parse(%q) = %v, want an error with %q", tc.args, err, tc.want)
+		}
+	}
+}
+
+type badSepCmd struct {
+	Name  string            `cli_flag:"--name" cli_group:"g" cli_sep:","`
+	Empty []string          `cli_flag:"--empty" cli_group:"g" cli_sep:""`
+	Arg   []string          `cli_argument:"arg" cli_sep:","`
+	Env   map[string]string `cli_flag:"--env" cli_group:"g" cli_default:"A=1"`
+}
+
+func (badSepCmd) Slots() Slot {
+	return Group{Unordered: []Slot{FlagGroup{Name: "g"}}, Ordered: []Slot{Arguments{Name: "arg"}}}
+}
+
+func TestValidateCommandTags_Sep(t *testing.T) {
+	t.Parallel()
+	err := ValidateCommandTags(&badSepCmd{})
+	for _, want := range []string{
+		`"Name" has cli_sep "," but is not a list or map of values`,
+		`"Empty" has cli_sep "" but is not a list or map of values`,
+		`"Arg" has cli_sep but no cli_flag`,
+	} {
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("ValidateCommandTags = %v, want %q", err, want)
+		}
+	}
+	if err != nil && strings.Contains(err.Error(), `"Env"`) {
+		t.Errorf("ValidateCommandTags rejected a map default: %v", err)
+	}
+}
+
+func TestValueHint_TypedValues(t *testing.T) {
+	t.Parallel()
+	want := map[string]string{
+		"--interval": "=<duration>",
+		"--env":      "=<string>=<string>",
+		"--cap":      "=<string>[,...]",
+		"--gids":     "=<uint>[:...]",
+		"--addr":     "=<value>",
+	}
+	for _, f := range helpFields(&valuesCmd{}) {
+		if w, ok := want[f.flag]; ok {
+			if got := valueHint(f); got != w {
+				t.Errorf("valueHint(%s) = %q, want %q", f.flag, got, w)
+			}
+		}
+	}
+}
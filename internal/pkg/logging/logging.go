@@ -0,0 +1,202 @@
+// Package logging builds the structured loggers used by vino's entrypoints.
+//
+// Records are written either as logfmt-style text or as JSON, matching the
+// two formats runc accepts for --log-format, so that vino can share the log
+// file containerd or Docker hand to the runtime.
+package logging
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"slices"
+	"time"
+)
+
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+)
+
+// Attribute keys attached to vino log records.
+const (
+	KeyContainerID = "container_id"
+	KeySubcommand  = "subcommand"
+	KeyBundle      = "bundle"
+	KeyPhase       = "phase"
+)
+
+// Options configures a logger.
+type Options struct {
+	// Format is either FormatText or FormatJSON. Empty means FormatText.
+	Format string
+	// Debug enables debug level records.
+	Debug bool
+}
+
+// New returns a logger writing records to w.
+func New(w io.Writer, opts Options) (*slog.Logger, error) {
+	level := slog.LevelInfo
+	if opts.Debug {
+		level = slog.LevelDebug
+	}
+	hopts := &slog.HandlerOptions{Level: level, ReplaceAttr: runcLevel}
+
+	switch opts.Format {
+	case "", FormatText:
+		return slog.New(slog.NewTextHandler(w, hopts)), nil
+	case FormatJSON:
+		return slog.New(slog.NewJSONHandler(w, hopts)), nil
+	default:
+		return nil, fmt.Errorf("unsupported log format %q", opts.Format)
+	}
+}
+
+// runcLevel writes levels the way runc's own records do: in lowercase, with
+// "warning" for slog.LevelWarn.
+func runcLevel(groups []string, a slog.Attr) slog.Attr {
+	if len(groups) > 0 || a.Key != slog.LevelKey {
+		return a
+	}
+	level, ok := a.Value.Any().(slog.Level)
+	if !ok {
+		return a
+	}
+	switch {
+	case level < slog.LevelInfo:
+		a.Value = slog.StringValue("debug")
+	case level < slog.LevelWarn:
+		a.Value = slog.StringValue("info")
+	case level < slog.LevelError:
+		a.Value = slog.StringValue("warning")
+	default:
+		a.Value = slog.StringValue("error")
+	}
+	return a
+}
+
+// Open returns a logger appending records to the file at path, or writing to
+// stderr if path is empty. The returned closer must be closed once the logger
+// is no longer used.
+func Open(path string, opts Options) (*slog.Logger, io.Closer, error) {
+	if path == "" {
+		logger, err := New(os.Stderr, opts)
+		if err != nil {
+			return nil, nil, err
+		}
+		return logger, nopCloser{}, nil
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, nil, fmt.Errorf("open log %s: %w", path, err)
+	}
+	logger, err := New(f, opts)
+	if err != nil {
+		f.Close()
+		return nil, nil, err
+	}
+	return logger, f, nil
+}
+
+// maxRelayedLine bounds the records Relay reads.
+const maxRelayedLine = 64 << 10
+
+// Relay logs through logger the JSON records read from r, as written by a
+// logger of FormatJSON that can't share vino's log file, like the launchers
+// inside a container. The records keep their time, level, message and
+// attributes, as strings, numbers or booleans. Lines that aren't records,
+// or longer than 64KiB, are skipped.
+func Relay(logger *slog.Logger, r io.Reader) error {
+	br := bufio.NewReader(r)
+	for {
+		line, err := readLine(br)
+		if len(line) > 0 {
+			relay(logger, line)
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// readLine returns the next line of br, or nothing for a line longer than
+// maxRelayedLine.
+func readLine(br *bufio.Reader) ([]byte, error) {
+	var line []byte
+	long := false
+	for {
+		chunk, isPrefix, err := br.ReadLine()
+		if err != nil {
+			return nil, err
+		}
+		if !long {
+			line = append(line, chunk...)
+			long = len(line) > maxRelayedLine
+		}
+		if isPrefix {
+			continue
+		}
+		if long {
+			return nil, nil
+		}
+		return line, nil
+	}
+}
+
+func relay(logger *slog.Logger, line []byte) {
+	var rec map[string]any
+	if json.Unmarshal(line, &rec) != nil {
+		return
+	}
+	msg, ok := rec[slog.MessageKey].(string)
+	if !ok {
+		return
+	}
+	level := slog.LevelInfo
+	switch rec[slog.LevelKey] {
+	case "debug":
+		level = slog.LevelDebug
+	case "warning":
+		level = slog.LevelWarn
+	case "error":
+		level = slog.LevelError
+	}
+	ctx := context.Background()
+	if !logger.Enabled(ctx, level) {
+		return
+	}
+	t := time.Now()
+	if s, ok := rec[slog.TimeKey].(string); ok {
+		if parsed, err := time.Parse(time.RFC3339Nano, s); err == nil {
+			t = parsed
+		}
+	}
+	delete(rec, slog.MessageKey)
+	delete(rec, slog.LevelKey)
+	delete(rec, slog.TimeKey)
+
+	r := slog.NewRecord(t, level, msg, 0)
+	keys := make([]string, 0, len(rec))
+	for k := range rec {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	for _, k := range keys {
+		switch v := rec[k].(type) {
+		case string, float64, bool:
+			r.AddAttrs(slog.Any(k, v))
+		}
+	}
+	logger.Handler().Handle(ctx, r)
+}
+
+type nopCloser struct{}
+
+func (nopCloser) Close() error { return nil }
@@ -0,0 +1,144 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestNewJSON(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := New(&buf, Options{Format: FormatJSON})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	logger.With(KeyContainerID, "abc", KeySubcommand, "create").Info("hello", KeyPhase, "bundle")
+
+	var rec map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &rec); err != nil {
+		t.Fatalf("decode record %q: %v", buf.String(), err)
+	}
+	want := map[string]string{
+		"msg":          "hello",
+		"level":        "info",
+		KeyContainerID: "abc",
+		KeySubcommand:  "create",
+		KeyPhase:       "bundle",
+	}
+	for k, v := range want {
+		if rec[k] != v {
+			t.Fatalf("record[%q] = %v, want %q", k, rec[k], v)
+		}
+	}
+}
+
+func TestNewText(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := New(&buf, Options{})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	logger.Debug("hidden")
+	logger.Info("shown", KeyBundle, "/bundle")
+	out := buf.String()
+	if strings.Contains(out, "hidden") {
+		t.Fatalf("debug record logged without Debug: %q", out)
+	}
+	if !strings.Contains(out, "msg=shown") || !strings.Contains(out, "bundle=/bundle") {
+		t.Fatalf("unexpected text record %q", out)
+	}
+}
+
+func TestNewLevels(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := New(&buf, Options{Debug: true})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	logger.Debug("d")
+	logger.Info("i")
+	logger.Warn("w")
+	logger.Error("e")
+	logger.WithGroup("g").Info("grouped", "level", "custom")
+	out := buf.String()
+	for _, want := range []string{"level=debug msg=d", "level=info msg=i", "level=warning msg=w", "level=error msg=e", "g.level=custom"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("records %q lack %q", out, want)
+		}
+	}
+}
+
+func TestNewDebug(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := New(&buf, Options{Debug: true})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	logger.Debug("shown")
+	if !strings.Contains(buf.String(), "msg=shown") {
+		t.Fatalf("debug record missing: %q", buf.String())
+	}
+}
+
+func TestNewUnsupportedFormat(t *testing.T) {
+	if _, err := New(&bytes.Buffer{}, Options{Format: "xml"}); err == nil {
+		t.Fatalf("expected error, got nil")
+	}
+}
+
+func TestOpenAppends(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "vino.log")
+	for _, msg := range []string{"first", "second"} {
+		logger, closer, err := Open(path, Options{Format: FormatJSON})
+		if err != nil {
+			t.Fatalf("Open: %v", err)
+		}
+		logger.Info(msg)
+		closer.Close()
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read log: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("got %d lines, want 2: %q", len(lines), data)
+	}
+}
+
+func TestRelay(t *testing.T) {
+	var in bytes.Buffer
+	inner, err := New(&in, Options{Format: FormatJSON, Debug: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	inner.Warn("wineserver gone", "prefix", "/opt/wine/prefix", "restarts", 2)
+	in.WriteString("not a record\n")
+	in.WriteString(`{"msg":"` + strings.Repeat("x", maxRelayedLine) + `"}` + "\n")
+	inner.Debug("dropped below info")
+	inner.Error("crashed", "ok", false)
+
+	var out bytes.Buffer
+	logger, err := New(&out, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Relay(logger.With("source", "launcher"), &in); err != nil {
+		t.Fatalf("Relay: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("relayed %q, want 2 records", lines)
+	}
+	for i, want := range []string{
+		`level=warning msg="wineserver gone" source=launcher prefix=/opt/wine/prefix restarts=2`,
+		`level=error msg=crashed source=launcher ok=false`,
+	} {
+		if _, rest, _ := strings.Cut(lines[i], " "); rest != want {
+			t.Errorf("record %d = %q, want %q", i, rest, want)
+		}
+	}
+}
@@ -0,0 +1,188 @@
+// Package metrics collects vino specific counters and histograms.
+//
+// vino processes are short lived and run on both sides of pivot_root, so
+// they don't expose metrics themselves. Instead every sample is sent as a
+// datagram to a unix socket owned by `vino metrics serve`, which aggregates
+// them and exposes the result in the Prometheus text format. Samples sent
+// while nothing listens on the socket are dropped.
+package metrics
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net"
+	"sync"
+)
+
+type Kind string
+
+const (
+	Counter   Kind = "counter"
+	Histogram Kind = "histogram"
+)
+
+// Desc describes a metric known to the registry.
+type Desc struct {
+	Name   string
+	Help   string
+	Kind   Kind
+	Labels []string
+	// Values lists, for the labels that have one, the only values they
+	// take. The other labels take any value, within MaxSeries.
+	Values map[string][]string
+	// Buckets are the upper bounds of a histogram's buckets.
+	Buckets []float64
+}
+
+// MaxSeries caps the series of a metric: anyone in a container can send
+// samples, the values of their labels can't grow the registry unbounded.
+const MaxSeries = 256
+
+// DurationBuckets are the histogram buckets, in seconds, used for hook
+// phases: from a symlink farm to a cold wineboot.
+var DurationBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60}
+
+var (
+	ContainerCreations = Desc{
+		Name:   "vino_container_creations_total",
+		Help:   "Containers created through vino, by result.",
+		Kind:   Counter,
+		Labels: []string{"result"},
+		Values: map[string][]string{"result": {"ok", "error"}},
+	}
+	RewriteFailures = Desc{
+		Name:   "vino_rewrite_failures_total",
+		Help:   "Bundle and process rewrites that failed, by reason.",
+		Kind:   Counter,
+		Labels: []string{"reason"},
+		// The reasons of runc.RewriteError, from runc and vino.
+		Values: map[string][]string{"reason": {
+			"read_bundle", "rewrite_bundle", "rewrite_process", "write_bundle", "process_file",
+			"annotations", "device", "mount",
+		}},
+	}
+	HookDuration = Desc{
+		Name:   "vino_hook_duration_seconds",
+		Help:   "Time spent in OCI hooks, by hook and phase.",
+		Kind:   Histogram,
+		Labels: []string{"hook", "phase"},
+		Values: map[string][]string{
+			"hook":  {"create", "start"},
+			"phase": {"total", "devices", "prefix", "wineserver", "wineboot"},
+		},
+		Buckets: DurationBuckets,
+	}
+	WineserverRestarts = Desc{
+		Name: "vino_wineserver_restarts_total",
+		Help: "Launches that found the wineserver of an already booted prefix gone.",
+		Kind: Counter,
+	}
+	WindowsProcessExits = Desc{
+		Name:   "vino_windows_process_exits_total",
+		Help:   "Windows processes that ended, by exit code or crash NTSTATUS.",
+		Kind:   Counter,
+		Labels: []string{"code"},
+	}
+)
+
+// Descs lists every metric in the order it is exposed.
+var Descs = []Desc{
+	ContainerCreations,
+	RewriteFailures,
+	HookDuration,
+	WineserverRestarts,
+	WindowsProcessExits,
+}
+
+// Sample is a single measurement as sent over the socket.
+type Sample struct {
+	Name   string            `json:"name"`
+	Labels map[string]string `json:"labels,omitempty"`
+	Value  float64           `json:"value"`
+}
+
+func newSample(d Desc, v float64, labels []string) (Sample, error) {
+	if len(labels) != len(d.Labels) {
+		return Sample{}, fmt.Errorf("metric %s: got %d label values, want %d", d.Name, len(labels), len(d.Labels))
+	}
+	s := Sample{Name: d.Name, Value: v}
+	if len(labels) > 0 {
+		s.Labels = make(map[string]string, len(labels))
+		for i, l := range d.Labels {
+			s.Labels[l] = labels[i]
+		}
+	}
+	return s, nil
+}
+
+// Client sends samples to the socket of a `vino metrics serve` process.
+type Client struct {
+	Socket string
+}
+
+// Add increments the counter d by v. labels are the values of d.Labels, in
+// order.
+func (c *Client) Add(d Desc, v float64, labels ...string) {
+	c.send(d, v, labels)
+}
+
+// Observe records v in the histogram d.
+func (c *Client) Observe(d Desc, v float64, labels ...string) {
+	c.send(d, v, labels)
+}
+
+func (c *Client) send(d Desc, v float64, labels []string) {
+	if c == nil || c.Socket == "" {
+		return
+	}
+	s, err := newSample(d, v, labels)
+	if err != nil {
+		slog.Debug("drop metric", "error", err)
+		return
+	}
+	data, err := json.Marshal(s)
+	if err != nil {
+		slog.Debug("drop metric", "metric", d.Name, "error", err)
+		return
+	}
+	conn, err := net.Dial("unixgram", c.Socket)
+	if err != nil {
+		slog.Debug("drop metric", "metric", d.Name, "error", err)
+		return
+	}
+	defer conn.Close()
+	if _, err := conn.Write(data); err != nil {
+		slog.Debug("drop metric", "metric", d.Name, "error", err)
+	}
+}
+
+var (
+	defaultMu     sync.Mutex
+	defaultClient *Client
+)
+
+// SetDefault makes c the client used by the package level functions.
+func SetDefault(c *Client) {
+	defaultMu.Lock()
+	defer defaultMu.Unlock()
+	defaultClient = c
+}
+
+// Default returns the client used by the package level functions, which may
+// be nil when metrics are disabled.
+func Default() *Client {
+	defaultMu.Lock()
+	defer defaultMu.Unlock()
+	return defaultClient
+}
+
+// Inc increments the counter d by one on the default client.
+func Inc(d Desc, labels ...string) {
+	Default().Add(d, 1, labels...)
+}
+
+// Observe records v in the histogram d on the default client.
+func Observe(d Desc, v float64, labels ...string) {
+	Default().Observe(d, v, labels...)
+}
@@ -0,0 +1,159 @@
+package metrics
+
+import (
+	"bytes"
+	"context"
+	"net/http/httptest"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/TheGrizzlyDev/vino/internal/pkg/runc"
+	"github.com/TheGrizzlyDev/vino/internal/pkg/vino"
+)
+
+func TestRegistryExposition(t *testing.T) {
+	r := NewRegistry()
+	samples := []Sample{
+		{Name: ContainerCreations.Name, Labels: map[string]string{"result": "ok"}, Value: 1},
+		{Name: ContainerCreations.Name, Labels: map[string]string{"result": "ok"}, Value: 1},
+		{Name: ContainerCreations.Name, Labels: map[string]string{"result": "error"}, Value: 1},
+		{Name: HookDuration.Name, Labels: map[string]string{"hook": "start", "phase": "wineboot"}, Value: 0.3},
+		{Name: HookDuration.Name, Labels: map[string]string{"hook": "start", "phase": "wineboot"}, Value: 20},
+		{Name: WineserverRestarts.Name, Value: 1},
+		{Name: WindowsProcessExits.Name, Labels: map[string]string{"code": `3"\`}, Value: 1},
+	}
+	for _, s := range samples {
+		if err := r.Record(s); err != nil {
+			t.Fatalf("Record(%v): %v", s, err)
+		}
+	}
+
+	var buf bytes.Buffer
+	if _, err := r.WriteTo(&buf); err != nil {
+		t.Fatalf("WriteTo: %v", err)
+	}
+	out := buf.String()
+	for _, want := range []string{
+		"# TYPE vino_container_creations_total counter\n",
+		`vino_container_creations_total{result="ok"} 2` + "\n",
+		`vino_container_creations_total{result="error"} 1` + "\n",
+		"# TYPE vino_hook_duration_seconds histogram\n",
+		`vino_hook_duration_seconds_bucket{hook="start",phase="wineboot",le="0.25"} 0` + "\n",
+		`vino_hook_duration_seconds_bucket{hook="start",phase="wineboot",le="0.5"} 1` + "\n",
+		`vino_hook_duration_seconds_bucket{hook="start",phase="wineboot",le="30"} 2` + "\n",
+		`vino_hook_duration_seconds_bucket{hook="start",phase="wineboot",le="+Inf"} 2` + "\n",
+		`vino_hook_duration_seconds_sum{hook="start",phase="wineboot"} 20.3` + "\n",
+		`vino_hook_duration_seconds_count{hook="start",phase="wineboot"} 2` + "\n",
+		"vino_wineserver_restarts_total 1\n",
+		`vino_windows_process_exits_total{code="3\"\\"} 1` + "\n",
+		"# HELP vino_rewrite_failures_total ",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("exposition missing %q:\n%s", want, out)
+		}
+	}
+}
+
+func TestRegistryRejectsInvalidSamples(t *testing.T) {
+	r := NewRegistry()
+	for _, s := range []Sample{
+		{Name: "vino_unknown_total", Value: 1},
+		{Name: ContainerCreations.Name, Value: 1},
+		{Name: ContainerCreations.Name, Labels: map[string]string{"reason": "x"}, Value: 1},
+		{Name: ContainerCreations.Name, Labels: map[string]string{"result": "ok"}, Value: -1},
+		{Name: ContainerCreations.Name, Labels: map[string]string{"result": "maybe"}, Value: 1},
+		{Name: RewriteFailures.Name, Labels: map[string]string{"reason": "x"}, Value: 1},
+		{Name: HookDuration.Name, Labels: map[string]string{"hook": "start", "phase": "x"}, Value: 1},
+	} {
+		if err := r.Record(s); err == nil {
+			t.Errorf("Record(%v): expected error, got nil", s)
+		}
+	}
+}
+
+func TestRegistryCapsSeries(t *testing.T) {
+	r := NewRegistry()
+	for i := range MaxSeries {
+		if err := r.Record(Sample{Name: WindowsProcessExits.Name, Labels: map[string]string{"code": strconv.Itoa(i)}, Value: 1}); err != nil {
+			t.Fatalf("Record(%d): %v", i, err)
+		}
+	}
+	if err := r.Record(Sample{Name: WindowsProcessExits.Name, Labels: map[string]string{"code": "-1"}, Value: 1}); err == nil {
+		t.Errorf("Record accepted a series past the cap")
+	}
+	// The series there already keep counting.
+	if err := r.Record(Sample{Name: WindowsProcessExits.Name, Labels: map[string]string{"code": "0"}, Value: 1}); err != nil {
+		t.Errorf("Record(0): %v", err)
+	}
+}
+
+// TestRewriteReasonsKnown checks that every reason a rewrite fails with is
+// one RewriteFailures takes.
+func TestRewriteReasonsKnown(t *testing.T) {
+	for _, reason := range []string{
+		runc.RewriteReasonReadBundle, runc.RewriteReasonBundle, runc.RewriteReasonProcess,
+		runc.RewriteReasonWriteBundle, runc.RewriteReasonProcessFile,
+		vino.RewriteReasonAnnotations, vino.RewriteReasonDevice, vino.RewriteReasonMount,
+	} {
+		if !slices.Contains(RewriteFailures.Values["reason"], reason) {
+			t.Errorf("RewriteFailures doesn't take reason %q", reason)
+		}
+	}
+}
+
+func TestClientToServer(t *testing.T) {
+	sock := filepath.Join(t.TempDir(), "metrics.sock")
+	conn, err := Listen(sock)
+	if err != nil {
+		t.Fatalf("Listen: %v", err)
+	}
+	r := NewRegistry()
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- r.Consume(ctx, conn) }()
+
+	c := &Client{Socket: sock}
+	c.Add(RewriteFailures, 1, "annotations")
+	c.Add(RewriteFailures, 1) // wrong label count, dropped
+	c.Observe(HookDuration, 0.01, "create", "total")
+
+	srv := httptest.NewServer(r)
+	defer srv.Close()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		resp, err := srv.Client().Get(srv.URL)
+		if err != nil {
+			t.Fatalf("scrape: %v", err)
+		}
+		var buf bytes.Buffer
+		buf.ReadFrom(resp.Body)
+		resp.Body.Close()
+		out := buf.String()
+		if strings.Contains(out, `vino_rewrite_failures_total{reason="annotations"} 1`) &&
+			strings.Contains(out, `vino_hook_duration_seconds_count{hook="create",phase="total"} 1`) {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("samples not exposed:\n%s", out)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatalf("Consume: %v", err)
+	}
+}
+
+func TestClientWithoutServer(t *testing.T) {
+	// Nothing listens: samples are dropped without failing the caller.
+	c := &Client{Socket: filepath.Join(t.TempDir(), "missing.sock")}
+	c.Add(ContainerCreations, 1, "ok")
+
+	var nilClient *Client
+	nilClient.Add(ContainerCreations, 1, "ok")
+}
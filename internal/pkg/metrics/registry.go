@@ -0,0 +1,241 @@
+package metrics
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Registry aggregates samples received from vino processes.
+type Registry struct {
+	descs map[string]Desc
+
+	mu     sync.Mutex
+	series map[string]map[string]*series
+}
+
+type series struct {
+	labels map[string]string
+	// value is the counter value, or the histogram sum.
+	value   float64
+	count   uint64
+	buckets []uint64
+}
+
+// NewRegistry returns a registry accepting samples for descs, or for Descs
+// when none are given.
+func NewRegistry(descs ...Desc) *Registry {
+	if len(descs) == 0 {
+		descs = Descs
+	}
+	r := &Registry{descs: map[string]Desc{}, series: map[string]map[string]*series{}}
+	for _, d := range descs {
+		r.descs[d.Name] = d
+		r.series[d.Name] = map[string]*series{}
+	}
+	return r
+}
+
+// Record adds s to the metric it names.
+func (r *Registry) Record(s Sample) error {
+	d, ok := r.descs[s.Name]
+	if !ok {
+		return fmt.Errorf("unknown metric %q", s.Name)
+	}
+	if len(s.Labels) != len(d.Labels) {
+		return fmt.Errorf("metric %s: got labels %v, want %v", s.Name, s.Labels, d.Labels)
+	}
+	for _, l := range d.Labels {
+		v, ok := s.Labels[l]
+		if !ok {
+			return fmt.Errorf("metric %s: missing label %q", s.Name, l)
+		}
+		if values, ok := d.Values[l]; ok && !slices.Contains(values, v) {
+			return fmt.Errorf("metric %s: unknown %s %q", s.Name, l, v)
+		}
+	}
+	if d.Kind == Counter && s.Value < 0 {
+		return fmt.Errorf("metric %s: counters can't decrease", s.Name)
+	}
+
+	key := seriesKey(d, s.Labels)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	ser := r.series[d.Name][key]
+	if ser == nil && len(r.series[d.Name]) >= MaxSeries {
+		return fmt.Errorf("metric %s: more than %d series, dropping %v", s.Name, MaxSeries, s.Labels)
+	}
+	if ser == nil {
+		ser = &series{labels: s.Labels, buckets: make([]uint64, len(d.Buckets))}
+		r.series[d.Name][key] = ser
+	}
+	ser.value += s.Value
+	if d.Kind == Histogram {
+		ser.count++
+		for i, ub := range d.Buckets {
+			if s.Value <= ub {
+				ser.buckets[i]++
+			}
+		}
+	}
+	return nil
+}
+
+// WriteTo writes every metric in the Prometheus text exposition format.
+func (r *Registry) WriteTo(w io.Writer) (int64, error) {
+	bw := bufio.NewWriter(w)
+	cw := &countingWriter{w: bw}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	names := make([]string, 0, len(r.descs))
+	for name := range r.descs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		d := r.descs[name]
+		fmt.Fprintf(cw, "# HELP %s %s\n", d.Name, escapeHelp(d.Help))
+		fmt.Fprintf(cw, "# TYPE %s %s\n", d.Name, d.Kind)
+
+		keys := make([]string, 0, len(r.series[name]))
+		for k := range r.series[name] {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			ser := r.series[name][k]
+			switch d.Kind {
+			case Counter:
+				fmt.Fprintf(cw, "%s%s %s\n", d.Name, formatLabels(d.Labels, ser.labels, ""), formatFloat(ser.value))
+			case Histogram:
+				for i, ub := range d.Buckets {
+					fmt.Fprintf(cw, "%s_bucket%s %d\n", d.Name, formatLabels(d.Labels, ser.labels, formatFloat(ub)), ser.buckets[i])
+				}
+				fmt.Fprintf(cw, "%s_bucket%s %d\n", d.Name, formatLabels(d.Labels, ser.labels, "+Inf"), ser.count)
+				fmt.Fprintf(cw, "%s_sum%s %s\n", d.Name, formatLabels(d.Labels, ser.labels, ""), formatFloat(ser.value))
+				fmt.Fprintf(cw, "%s_count%s %d\n", d.Name, formatLabels(d.Labels, ser.labels, ""), ser.count)
+			}
+		}
+	}
+	if cw.err != nil {
+		return cw.n, cw.err
+	}
+	return cw.n, bw.Flush()
+}
+
+// ServeHTTP exposes the registry to Prometheus scrapes.
+func (r *Registry) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	if _, err := r.WriteTo(w); err != nil {
+		slog.Warn("write metrics", "error", err)
+	}
+}
+
+// Listen binds the datagram socket vino processes send samples to, replacing
+// a stale socket left by a previous server. The socket is world writable so
+// that processes running as any user in a container can report.
+func Listen(path string) (*net.UnixConn, error) {
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("remove stale socket: %w", err)
+	}
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: path, Net: "unixgram"})
+	if err != nil {
+		return nil, fmt.Errorf("listen %s: %w", path, err)
+	}
+	if err := os.Chmod(path, 0o666); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("chmod %s: %w", path, err)
+	}
+	return conn, nil
+}
+
+// Consume records samples read from conn until ctx is done or conn is
+// closed. Malformed samples are logged and skipped.
+func (r *Registry) Consume(ctx context.Context, conn net.PacketConn) error {
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	buf := make([]byte, 64*1024)
+	for {
+		n, _, err := conn.ReadFrom(buf)
+		if err != nil {
+			if ctx.Err() != nil || errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return err
+		}
+		var s Sample
+		if err := json.Unmarshal(buf[:n], &s); err != nil {
+			slog.Warn("decode metric sample", "error", err)
+			continue
+		}
+		if err := r.Record(s); err != nil {
+			slog.Warn("record metric sample", "error", err)
+		}
+	}
+}
+
+func seriesKey(d Desc, labels map[string]string) string {
+	var b strings.Builder
+	for _, l := range d.Labels {
+		b.WriteString(labels[l])
+		b.WriteByte(0)
+	}
+	return b.String()
+}
+
+func formatLabels(names []string, labels map[string]string, le string) string {
+	var parts []string
+	for _, l := range names {
+		parts = append(parts, l+`="`+labelEscaper.Replace(labels[l])+`"`)
+	}
+	if le != "" {
+		parts = append(parts, `le="`+le+`"`)
+	}
+	if len(parts) == 0 {
+		return ""
+	}
+	return "{" + strings.Join(parts, ",") + "}"
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+var (
+	helpEscaper  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+	labelEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+)
+
+func escapeHelp(s string) string {
+	return helpEscaper.Replace(s)
+}
+
+type countingWriter struct {
+	w   io.Writer
+	n   int64
+	err error
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	if c.err != nil {
+		return 0, c.err
+	}
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	c.err = err
+	return n, err
+}
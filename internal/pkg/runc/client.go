@@ -18,6 +18,8 @@ type Forward func(ctx context.Context, cmd cli.Command) (*exec.Cmd, error)
 // inspect or modify the exec.Cmd before it's returned to the caller.
 type Middleware func(next Forward) Forward
 
+// RunResult is what a command run to completion by Client.Run printed, and
+// how it exited.
 type RunResult struct {
 	Stdout   []byte
 	Stderr   []byte
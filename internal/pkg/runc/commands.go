@@ -1,6 +1,51 @@
 package runc
 
-import cli "github.com/TheGrizzlyDev/vino/internal/pkg/cli"
+//go:generate go run github.com/TheGrizzlyDev/vino/internal/cmd/cligen --spec runc.json --package runcgen -o runcgen/commands_gen.go
+
+import (
+	"time"
+
+	cli "github.com/TheGrizzlyDev/vino/internal/pkg/cli"
+)
+
+// ------------------------------------------------------------
+// Enumerated flag values
+// ------------------------------------------------------------
+
+// OutputFormat is the output format of list and ps.
+type OutputFormat string
+
+const (
+	FormatTable OutputFormat = "table"
+	FormatJSON  OutputFormat = "json"
+)
+
+// LogFormat is the format of runc's log.
+type LogFormat string
+
+const (
+	LogFormatText LogFormat = "text"
+	LogFormatJSON LogFormat = "json"
+)
+
+// RootlessMode tells runc whether it runs rootless.
+type RootlessMode string
+
+const (
+	RootlessTrue  RootlessMode = "true"
+	RootlessFalse RootlessMode = "false"
+	RootlessAuto  RootlessMode = "auto"
+)
+
+// CgroupsMode is how criu handles the cgroups of a container.
+type CgroupsMode string
+
+const (
+	CgroupsSoft   CgroupsMode = "soft"
+	CgroupsFull   CgroupsMode = "full"
+	CgroupsStrict CgroupsMode = "strict"
+	CgroupsIgnore CgroupsMode = "ignore"
+)
 
 // ------------------------------------------------------------
 // Common, embeddable option groups (no cli.Subcommand() here)
@@ -35,7 +80,7 @@ type DetachOpt struct {
 
 // FormatOpt standard output-format selector (table/json).
 type FormatOpt struct {
-	Format string `cli_flag:"--format" cli_flag_alternatives:"-f" cli_group:"output" cli_enum:"table|json"`
+	Format OutputFormat `cli_flag:"--format" cli_flag_alternatives:"-f" cli_group:"output" cli_enum:"table|json"`
 }
 
 // ------------------------------------------------------------
@@ -44,13 +89,13 @@ type FormatOpt struct {
 // ------------------------------------------------------------
 
 type Global struct {
-	Root          string `cli_flag:"--root"            cli_group:"global"`
-	Debug         bool   `cli_flag:"--debug"           cli_group:"global"`
-	Log           string `cli_flag:"--log"             cli_group:"global"`
-	LogFormat     string `cli_flag:"--log-format"      cli_group:"global"`
-	SystemdCgroup bool   `cli_flag:"--systemd-cgroup"  cli_group:"global"`
-	Criu          string `cli_flag:"--criu"            cli_group:"global"`
-	Rootless      string `cli_flag:"--rootless"        cli_group:"global" cli_enum:"true|false|auto"`
+	Root          string       `cli_flag:"--root"            cli_group:"global"`
+	Debug         bool         `cli_flag:"--debug"           cli_group:"global"`
+	Log           string       `cli_flag:"--log"             cli_group:"global"`
+	LogFormat     LogFormat    `cli_flag:"--log-format"      cli_group:"global" cli_enum:"text|json"`
+	SystemdCgroup bool         `cli_flag:"--systemd-cgroup"  cli_group:"global"`
+	Criu          string       `cli_flag:"--criu"            cli_group:"global"`
+	Rootless      RootlessMode `cli_flag:"--rootless"        cli_group:"global" cli_enum:"true|false|auto"`
 }
 
 // ------------------------------------------------------------
@@ -61,23 +106,23 @@ type Global struct {
 type Checkpoint struct {
 	Global
 	// flags
-	ImagePath           string `cli_flag:"--image-path"         cli_group:"images"`
-	WorkPath            string `cli_flag:"--work-path"          cli_group:"images"`
-	ParentPath          string `cli_flag:"--parent-path"        cli_group:"images"`
-	LeaveRunning        bool   `cli_flag:"--leave-running"      cli_group:"lifecycle"`
-	TcpEstablished      bool   `cli_flag:"--tcp-established"    cli_group:"criu"`
-	TcpSkipInFlight     bool   `cli_flag:"--tcp-skip-in-flight" cli_group:"criu"`
-	LinkRemap           bool   `cli_flag:"--link-remap"         cli_group:"criu"`
-	ExternalUnixSockets bool   `cli_flag:"--ext-unix-sk"        cli_group:"criu"`
-	ShellJob            bool   `cli_flag:"--shell-job"          cli_group:"criu"`
-	LazyPages           bool   `cli_flag:"--lazy-pages"         cli_group:"criu"`
-	StatusFD            *uint  `cli_flag:"--status-fd"          cli_group:"criu"`
-	PageServer          string `cli_flag:"--page-server"        cli_group:"criu"` // "IP:port"
-	FileLocks           bool   `cli_flag:"--file-locks"         cli_group:"criu"`
-	PreDump             bool   `cli_flag:"--pre-dump"           cli_group:"criu"`
-	ManageCgroupsMode   string `cli_flag:"--manage-cgroups-mode" cli_group:"cgroups" cli_enum:"soft|full|strict|ignore"`
-	EmptyNameSpace      string `cli_flag:"--empty-ns"           cli_group:"namespaces"`
-	AutoDedup           bool   `cli_flag:"--auto-dedup"         cli_group:"criu"`
+	ImagePath           string      `cli_flag:"--image-path"         cli_group:"images"`
+	WorkPath            string      `cli_flag:"--work-path"          cli_group:"images"`
+	ParentPath          string      `cli_flag:"--parent-path"        cli_group:"images"`
+	LeaveRunning        bool        `cli_flag:"--leave-running"      cli_group:"lifecycle"`
+	TcpEstablished      bool        `cli_flag:"--tcp-established"    cli_group:"criu"`
+	TcpSkipInFlight     bool        `cli_flag:"--tcp-skip-in-flight" cli_group:"criu"`
+	LinkRemap           bool        `cli_flag:"--link-remap"         cli_group:"criu"`
+	ExternalUnixSockets bool        `cli_flag:"--ext-unix-sk"        cli_group:"criu"`
+	ShellJob            bool        `cli_flag:"--shell-job"          cli_group:"criu"`
+	LazyPages           bool        `cli_flag:"--lazy-pages"         cli_group:"criu"`
+	StatusFD            *uint       `cli_flag:"--status-fd"          cli_group:"criu"`
+	PageServer          string      `cli_flag:"--page-server"        cli_group:"criu"` // "IP:port"
+	FileLocks           bool        `cli_flag:"--file-locks"         cli_group:"criu"`
+	PreDump             bool        `cli_flag:"--pre-dump"           cli_group:"criu"`
+	ManageCgroupsMode   CgroupsMode `cli_flag:"--manage-cgroups-mode" cli_group:"cgroups" cli_enum:"soft|full|strict|ignore"`
+	EmptyNameSpace      string      `cli_flag:"--empty-ns"           cli_group:"namespaces"`
+	AutoDedup           bool        `cli_flag:"--auto-dedup"         cli_group:"criu"`
 
 	// args
 	ContainerID string `cli_argument:"container_id"`
@@ -114,19 +159,19 @@ type Restore struct {
 	PidFileOpt
 
 	// flags
-	ImagePath         string `cli_flag:"--image-path"         cli_group:"images"`
-	WorkPath          string `cli_flag:"--work-path"          cli_group:"images"`
-	TcpEstablished    bool   `cli_flag:"--tcp-established"    cli_group:"criu"`
-	ExternalUnixSk    bool   `cli_flag:"--ext-unix-sk"        cli_group:"criu"`
-	ShellJob          bool   `cli_flag:"--shell-job"          cli_group:"criu"`
-	FileLocks         bool   `cli_flag:"--file-locks"         cli_group:"criu"`
-	ManageCgroupsMode string `cli_flag:"--manage-cgroups-mode" cli_group:"cgroups" cli_enum:"soft|full|strict|ignore"`
-	NoSubreaper       bool   `cli_flag:"--no-subreaper"       cli_group:"lifecycle"`
-	EmptyNS           string `cli_flag:"--empty-ns"           cli_group:"namespaces"`
-	AutoDedup         bool   `cli_flag:"--auto-dedup"         cli_group:"criu"`
-	LazyPages         bool   `cli_flag:"--lazy-pages"         cli_group:"criu"`
-	LSMProfile        string `cli_flag:"--lsm-profile"        cli_group:"security"` // "type:label"
-	LSMMountContext   string `cli_flag:"--lsm-mount-context"  cli_group:"security"` // SELinux mount context
+	ImagePath         string      `cli_flag:"--image-path"         cli_group:"images"`
+	WorkPath          string      `cli_flag:"--work-path"          cli_group:"images"`
+	TcpEstablished    bool        `cli_flag:"--tcp-established"    cli_group:"criu"`
+	ExternalUnixSk    bool        `cli_flag:"--ext-unix-sk"        cli_group:"criu"`
+	ShellJob          bool        `cli_flag:"--shell-job"          cli_group:"criu"`
+	FileLocks         bool        `cli_flag:"--file-locks"         cli_group:"criu"`
+	ManageCgroupsMode CgroupsMode `cli_flag:"--manage-cgroups-mode" cli_group:"cgroups" cli_enum:"soft|full|strict|ignore"`
+	NoSubreaper       bool        `cli_flag:"--no-subreaper"       cli_group:"lifecycle"`
+	EmptyNS           string      `cli_flag:"--empty-ns"           cli_group:"namespaces"`
+	AutoDedup         bool        `cli_flag:"--auto-dedup"         cli_group:"criu"`
+	LazyPages         bool        `cli_flag:"--lazy-pages"         cli_group:"criu"`
+	LSMProfile        string      `cli_flag:"--lsm-profile"        cli_group:"security"` // "type:label"
+	LSMMountContext   string      `cli_flag:"--lsm-mount-context"  cli_group:"security"` // SELinux mount context
 
 	// args
 	ContainerID string `cli_argument:"container_id"`
@@ -406,9 +451,9 @@ func (State) Slots() cli.Slot {
 
 type Events struct {
 	Global
-	Interval    string `cli_flag:"--interval" cli_group:"events"` // e.g. "5s"
-	Stats       bool   `cli_flag:"--stats"    cli_group:"events"`
-	ContainerID string `cli_argument:"container_id"`
+	Interval    time.Duration `cli_flag:"--interval" cli_group:"events"`
+	Stats       bool          `cli_flag:"--stats"    cli_group:"events"`
+	ContainerID string        `cli_argument:"container_id"`
 }
 
 func (Events) Slots() cli.Slot {
@@ -446,7 +491,7 @@ type Exec struct {
 	ProcessLabel   string   `cli_flag:"--process-label"  cli_group:"security"`
 	AppArmor       string   `cli_flag:"--apparmor"       cli_group:"security"`
 	NoNewPrivs     bool     `cli_flag:"--no-new-privs"   cli_group:"security"`
-	Cap            []string `cli_flag:"--cap"            cli_flag_alternatives:"-c" cli_group:"security"`
+	Cap            []string `cli_flag:"--cap"            cli_flag_alternatives:"-c" cli_group:"security"` // repeated: runc doesn't split on commas
 	IgnorePaused   bool     `cli_flag:"--ignore-paused"  cli_group:"lifecycle"`
 	Cgroup         string   `cli_flag:"--cgroup"         cli_group:"cgroups"` // v1 semantics
 
@@ -545,6 +590,8 @@ func (Update) Slots() cli.Slot {
 			cli.FlagGroup{Name: "global"},
 			cli.Subcommand{Value: "update"},
 			cli.Argument{Name: "container_id"},
+			// runc update takes its flags after the container ID.
+			cli.RenderUnordered{},
 		},
 	}
 }
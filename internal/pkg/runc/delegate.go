@@ -0,0 +1,310 @@
+package runc
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"iter"
+	"strconv"
+	"strings"
+	"time"
+
+	cli "github.com/TheGrizzlyDev/vino/internal/pkg/cli"
+	"github.com/opencontainers/runtime-spec/specs-go"
+	"github.com/opencontainers/runtime-spec/specs-go/features"
+)
+
+// Errors a CommandError matches, with errors.Is, when the delegate's
+// message tells why it failed.
+var (
+	ErrNotExist   = errors.New("container does not exist")
+	ErrExist      = errors.New("container already exists")
+	ErrNotRunning = errors.New("container is not running")
+	ErrNotStopped = errors.New("container is not stopped")
+)
+
+// CommandError reports a delegate command that failed.
+type CommandError struct {
+	// Subcommand is the failed subcommand, such as "state".
+	Subcommand string
+	// ID is the container the command was about, if any.
+	ID string
+	// ExitCode is the exit code of the delegate, or -1 if it didn't exit.
+	ExitCode int
+	// Message is the error the delegate printed last on its standard
+	// error, without the decoration of its logger.
+	Message string
+	// Err is the error running the command.
+	Err error
+}
+
+func (e *CommandError) Error() string {
+	op := e.Subcommand
+	if e.ID != "" {
+		op += " " + e.ID
+	}
+	if e.Message != "" {
+		return op + ": " + e.Message
+	}
+	return op + ": " + e.Err.Error()
+}
+
+// Unwrap returns Err and, if Message tells it, one of ErrNotExist, ErrExist,
+// ErrNotRunning or ErrNotStopped.
+func (e *CommandError) Unwrap() []error {
+	if kind := errorKind(e.Message); kind != nil {
+		return []error{kind, e.Err}
+	}
+	return []error{e.Err}
+}
+
+// errorKind returns the error that the message of runc, crun or youki
+// tells, or nil.
+func errorKind(msg string) error {
+	msg = strings.ToLower(msg)
+	switch {
+	case strings.Contains(msg, "does not exist"):
+		return ErrNotExist
+	case strings.Contains(msg, "already exists"), strings.Contains(msg, "with id exists"):
+		return ErrExist
+	case strings.Contains(msg, "not stopped"):
+		return ErrNotStopped
+	case strings.Contains(msg, "not running"), strings.Contains(msg, "stopped container"):
+		return ErrNotRunning
+	}
+	return nil
+}
+
+// errorMessage returns the last message of stderr, decoded from the text
+// or JSON records of the delegate's logger when it's one of them.
+func errorMessage(stderr []byte) string {
+	lines := strings.Split(strings.TrimSpace(string(stderr)), "\n")
+	line := strings.TrimSpace(lines[len(lines)-1])
+	var record struct {
+		Msg string `json:"msg"`
+	}
+	if json.Unmarshal([]byte(line), &record) == nil && record.Msg != "" {
+		return record.Msg
+	}
+	if _, msg, ok := strings.Cut(line, " msg="); ok {
+		if s, err := strconv.QuotedPrefix(msg); err == nil {
+			msg, _ = strconv.Unquote(s)
+		} else {
+			msg, _, _ = strings.Cut(msg, " ")
+		}
+		return msg
+	}
+	return line
+}
+
+// Client queries the delegate runtime through a Cli, and decodes what it
+// prints.
+type Client struct {
+	Cli Cli
+	// Global is given to every command.
+	Global Global
+	// Stderr, if set, receives the standard error of the delegate too.
+	Stderr io.Writer
+}
+
+// NewClient returns a client running commands through c with the global
+// flags global.
+func NewClient(c Cli, global Global) *Client {
+	return &Client{Cli: c, Global: global}
+}
+
+// Run runs cmd to completion and returns its output. The error is a
+// *CommandError if the delegate failed.
+func (c *Client) Run(ctx context.Context, cmd cli.Command) (RunResult, error) {
+	return c.run(ctx, cmd, "")
+}
+
+// run runs cmd, about the container id if not empty.
+func (c *Client) run(ctx context.Context, cmd cli.Command, id string) (RunResult, error) {
+	execCmd, err := c.Cli.Command(ctx, cmd)
+	if err != nil {
+		return RunResult{}, err
+	}
+	var stdout, stderr bytes.Buffer
+	execCmd.Stdout, execCmd.Stderr = &stdout, c.stderr(&stderr)
+	err = execCmd.Run()
+	res := RunResult{Stdout: stdout.Bytes(), Stderr: stderr.Bytes(), ExitCode: -1}
+	if execCmd.ProcessState != nil {
+		res.ExitCode = execCmd.ProcessState.ExitCode()
+	}
+	if err != nil {
+		return res, commandError(cmd, id, res, err)
+	}
+	return res, nil
+}
+
+// stderr returns the standard error to give the delegate, writing to b.
+func (c *Client) stderr(b *bytes.Buffer) io.Writer {
+	if c.Stderr == nil {
+		return b
+	}
+	return io.MultiWriter(b, c.Stderr)
+}
+
+func commandError(cmd cli.Command, id string, res RunResult, err error) *CommandError {
+	return &CommandError{
+		Subcommand: cli.SubcommandOf(cmd),
+		ID:         id,
+		ExitCode:   res.ExitCode,
+		Message:    errorMessage(res.Stderr),
+		Err:        err,
+	}
+}
+
+// decode runs cmd, about the container id if not empty, and decodes its
+// JSON output into v.
+func (c *Client) decode(ctx context.Context, cmd cli.Command, id string, v any) error {
+	res, err := c.run(ctx, cmd, id)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(res.Stdout, v); err != nil {
+		return fmt.Errorf("decode %s: %w", cli.SubcommandOf(cmd), err)
+	}
+	return nil
+}
+
+// State returns the state of the container id.
+func (c *Client) State(ctx context.Context, id string) (*specs.State, error) {
+	var state specs.State
+	if err := c.decode(ctx, State{Global: c.Global, ContainerID: id}, id, &state); err != nil {
+		return nil, err
+	}
+	return &state, nil
+}
+
+// List returns the containers of the delegate.
+func (c *Client) List(ctx context.Context) ([]ContainerState, error) {
+	var list []ContainerState
+	cmd := List{Global: c.Global, FormatOpt: FormatOpt{Format: FormatJSON}}
+	if err := c.decode(ctx, cmd, "", &list); err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+// Ps returns the pids of the processes of the container id.
+func (c *Client) Ps(ctx context.Context, id string) ([]int, error) {
+	var pids []int
+	cmd := Ps{Global: c.Global, FormatOpt: FormatOpt{Format: FormatJSON}, ContainerID: id}
+	if err := c.decode(ctx, cmd, id, &pids); err != nil {
+		return nil, err
+	}
+	return pids, nil
+}
+
+// Features returns the features of the delegate.
+func (c *Client) Features(ctx context.Context) (*features.Features, error) {
+	var f features.Features
+	if err := c.decode(ctx, Features{Global: c.Global}, "", &f); err != nil {
+		return nil, err
+	}
+	return &f, nil
+}
+
+// Kill sends signal, SIGTERM if empty, to the init process of the
+// container id, or to all of its processes.
+func (c *Client) Kill(ctx context.Context, id, signal string, all bool) error {
+	_, err := c.run(ctx, Kill{Global: c.Global, ContainerID: id, Signal: signal, All: all}, id)
+	return err
+}
+
+// Delete deletes the container id, killing it first if force is set.
+func (c *Client) Delete(ctx context.Context, id string, force bool) error {
+	_, err := c.run(ctx, Delete{Global: c.Global, ContainerID: id, Force: force}, id)
+	return err
+}
+
+// Event is one of the events printed by `runc events`, one JSON object per
+// line. Data is the payload of the event, such as the statistics of a
+// "stats" event.
+type Event struct {
+	Type string          `json:"type"`
+	ID   string          `json:"id"`
+	Data json.RawMessage `json:"data,omitempty"`
+}
+
+// Stats returns the "stats" event of the container id.
+func (c *Client) Stats(ctx context.Context, id string) (*Event, error) {
+	var ev Event
+	if err := c.decode(ctx, Events{Global: c.Global, Stats: true, ContainerID: id}, id, &ev); err != nil {
+		return nil, err
+	}
+	return &ev, nil
+}
+
+// Events streams the events of the container id, with statistics every
+// interval, or the delegate's default if zero. The stream ends with an
+// error if the delegate fails or prints something other than events; the
+// delegate is stopped when the loop over the stream breaks.
+func (c *Client) Events(ctx context.Context, id string, interval time.Duration) iter.Seq2[Event, error] {
+	return func(yield func(Event, error) bool) {
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		cmd := Events{Global: c.Global, Interval: interval, ContainerID: id}
+		execCmd, err := c.Cli.Command(ctx, cmd)
+		if err != nil {
+			yield(Event{}, err)
+			return
+		}
+		var stderr bytes.Buffer
+		execCmd.Stderr = c.stderr(&stderr)
+		stdout, err := execCmd.StdoutPipe()
+		if err != nil {
+			yield(Event{}, err)
+			return
+		}
+		if err := execCmd.Start(); err != nil {
+			yield(Event{}, commandError(cmd, id, RunResult{ExitCode: -1}, err))
+			return
+		}
+		// stop kills the delegate and reaps it, when the loop ends before
+		// it does.
+		stop := func() {
+			cancel()
+			_ = execCmd.Wait()
+		}
+
+		dec := json.NewDecoder(stdout)
+		for {
+			var ev Event
+			err := dec.Decode(&ev)
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				stop()
+				if ctx.Err() == nil {
+					err = fmt.Errorf("decode events: %w", err)
+				} else {
+					err = ctx.Err()
+				}
+				yield(Event{}, err)
+				return
+			}
+			if !yield(ev, nil) {
+				stop()
+				return
+			}
+		}
+		if err := execCmd.Wait(); err != nil {
+			if ctx.Err() != nil {
+				yield(Event{}, ctx.Err())
+				return
+			}
+			res := RunResult{Stderr: stderr.Bytes(), ExitCode: -1}
+			if execCmd.ProcessState != nil {
+				res.ExitCode = execCmd.ProcessState.ExitCode()
+			}
+			yield(Event{}, commandError(cmd, id, res, err))
+		}
+	}
+}
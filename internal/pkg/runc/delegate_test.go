@@ -0,0 +1,213 @@
+package runc
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/opencontainers/runtime-spec/specs-go"
+)
+
+// fakeDelegate returns a client of a delegate answering like runc would
+// about the running container "c1", and failing for any other.
+func fakeDelegate(t *testing.T) *Client {
+	t.Helper()
+	script := `#!/bin/sh
+case "$*" in
+"--root /run/test state c1")
+	echo '{"ociVersion":"1.2.1","id":"c1","status":"running","pid":42,"bundle":"/b"}' ;;
+"--root /run/test list --format json")
+	echo '[{"ociVersion":"1.2.1","id":"c1","pid":42,"status":"running","bundle":"/b","rootfs":"/b/rootfs","created":"2024-01-02T03:04:05Z","owner":"root"}]' ;;
+"--root /run/test ps --format json c1")
+	echo '[42,43]' ;;
+"--root /run/test features")
+	echo '{"ociVersionMin":"1.0.0","ociVersionMax":"1.2.1","hooks":["prestart"]}' ;;
+"--root /run/test kill c1 KILL"|"--root /run/test delete c1")
+	;;
+"--root /run/test delete --force c1")
+	echo 'time="2024-01-02T03:04:05Z" level=error msg="cannot delete container c1 that is not stopped: running"' >&2
+	exit 1 ;;
+"--root /run/test kill --all c1")
+	echo '{"level":"error","msg":"container not running","time":"2024-01-02T03:04:05Z"}' >&2
+	exit 1 ;;
+"--root /run/test events --interval 1s c1")
+	echo '{"type":"stats","id":"c1","data":{"pids":{"current":2}}}'
+	echo '{"type":"oom","id":"c1"}'
+	echo 'container exited' >&2
+	exit 3 ;;
+"--root /run/test events c1")
+	while :; do echo '{"type":"stats","id":"c1","data":{}}'; sleep 0.1; done ;;
+"--root /run/test events --stats c1")
+	echo 'not json' ;;
+*)
+	echo "time=\"2024-01-02T03:04:05Z\" level=error msg=\"container does not exist\"" >&2
+	exit 1 ;;
+esac
+`
+	bin := filepath.Join(t.TempDir(), "runc")
+	if err := os.WriteFile(bin, []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	c, err := NewDelegatingCliClient(bin)
+	if err != nil {
+		t.Fatalf("NewDelegatingCliClient: %v", err)
+	}
+	return NewClient(c, Global{Root: "/run/test"})
+}
+
+func TestClient_Queries(t *testing.T) {
+	t.Parallel()
+	c := fakeDelegate(t)
+	ctx := context.Background()
+
+	state, err := c.State(ctx, "c1")
+	if err != nil {
+		t.Fatalf("State: %v", err)
+	}
+	if want := (specs.State{Version: "1.2.1", ID: "c1", Status: specs.StateRunning, Pid: 42, Bundle: "/b"}); !reflect.DeepEqual(*state, want) {
+		t.Errorf("State = %+v, want %+v", *state, want)
+	}
+
+	list, err := c.List(ctx)
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if len(list) != 1 || list[0].ID != "c1" || list[0].Rootfs != "/b/rootfs" || !list[0].Created.Equal(time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)) {
+		t.Errorf("List = %+v", list)
+	}
+
+	pids, err := c.Ps(ctx, "c1")
+	if err != nil {
+		t.Fatalf("Ps: %v", err)
+	}
+	if !reflect.DeepEqual(pids, []int{42, 43}) {
+		t.Errorf("Ps = %v, want [42 43]", pids)
+	}
+
+	f, err := c.Features(ctx)
+	if err != nil {
+		t.Fatalf("Features: %v", err)
+	}
+	if f.OCIVersionMax != "1.2.1" || !reflect.DeepEqual(f.Hooks, []string{"prestart"}) {
+		t.Errorf("Features = %+v", f)
+	}
+
+	if err := c.Kill(ctx, "c1", "KILL", false); err != nil {
+		t.Errorf("Kill: %v", err)
+	}
+	if err := c.Delete(ctx, "c1", false); err != nil {
+		t.Errorf("Delete: %v", err)
+	}
+}
+
+func TestClient_Errors(t *testing.T) {
+	t.Parallel()
+	c := fakeDelegate(t)
+	ctx := context.Background()
+
+	_, err := c.State(ctx, "c2")
+	var ce *CommandError
+	if !errors.As(err, &ce) {
+		t.Fatalf("State = %v, want a CommandError", err)
+	}
+	if ce.Subcommand != "state" || ce.ID != "c2" || ce.ExitCode != 1 || ce.Message != "container does not exist" {
+		t.Errorf("CommandError = %+v", ce)
+	}
+	if got, want := err.Error(), "state c2: container does not exist"; got != want {
+		t.Errorf("Error() = %q, want %q", got, want)
+	}
+	if !errors.Is(err, ErrNotExist) {
+		t.Errorf("State = %v, want ErrNotExist", err)
+	}
+
+	for _, tc := range []struct {
+		name string
+		err  error
+		want error
+	}{
+		{"kill --all", c.Kill(ctx, "c1", "", true), ErrNotRunning},
+		{"delete --force", c.Delete(ctx, "c1", true), ErrNotStopped},
+		{"delete", c.Delete(ctx, "c2", false), ErrNotExist},
+	} {
+		if !errors.Is(tc.err, tc.want) {
+			t.Errorf("%s = %v, want %v", tc.name, tc.err, tc.want)
+		}
+	}
+
+	if _, err := c.Stats(ctx, "c1"); err == nil || errors.As(err, &ce) {
+		t.Errorf("Stats = %v, want a decoding error", err)
+	}
+}
+
+func TestErrorMessage(t *testing.T) {
+	t.Parallel()
+	for stderr, want := range map[string]string{
+		"":                           "",
+		"container does not exist\n": "container does not exist",
+		"warning\nlast error\n":      "last error",
+		`time="2024" level=error msg="a \"b\" c"`: `a "b" c`,
+		`time=2024 level=error msg=plain`:         "plain",
+		`{"level":"error","msg":"from json"}`:     "from json",
+		"crun: container `c1` already exists":     "crun: container `c1` already exists",
+	} {
+		if got := errorMessage([]byte(stderr)); got != want {
+			t.Errorf("errorMessage(%q) = %q, want %q", stderr, got, want)
+		}
+	}
+	if !errors.Is(errorKind("crun: container `c1` already exists"), ErrExist) {
+		t.Errorf("errorKind doesn't tell ErrExist")
+	}
+}
+
+func TestClient_Events(t *testing.T) {
+	t.Parallel()
+	c := fakeDelegate(t)
+	ctx := context.Background()
+
+	var got []Event
+	var err error
+	for ev, e := range c.Events(ctx, "c1", time.Second) {
+		if e != nil {
+			err = e
+			break
+		}
+		got = append(got, ev)
+	}
+	want := []Event{
+		{Type: "stats", ID: "c1", Data: []byte(`{"pids":{"current":2}}`)},
+		{Type: "oom", ID: "c1"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("events = %+v, want %+v", got, want)
+	}
+	var ce *CommandError
+	if !errors.As(err, &ce) || ce.ExitCode != 3 || ce.Message != "container exited" {
+		t.Errorf("end of events = %v, want the delegate's failure", err)
+	}
+
+	// Breaking out of the loop stops an endless stream.
+	n := 0
+	for _, err := range c.Events(ctx, "c1", 0) {
+		if err != nil {
+			t.Fatalf("events: %v", err)
+		}
+		if n++; n == 2 {
+			break
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 200*time.Millisecond)
+	defer cancel()
+	for _, err = range c.Events(ctx, "c1", 0) {
+		if err != nil {
+			break
+		}
+	}
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("events after the deadline = %v, want %v", err, context.DeadlineExceeded)
+	}
+}
@@ -0,0 +1,46 @@
+package runc
+
+import (
+	"testing"
+
+	"github.com/TheGrizzlyDev/vino/internal/pkg/cli/clitest"
+)
+
+// Each member of RuncCommands must survive Parse and ConvertToCmdline in
+// both directions, for any values and any spelling of its flags:
+//
+//	go test ./internal/pkg/runc -fuzz FuzzExec
+
+func FuzzCheckpoint(f *testing.F) { clitest.Fuzz[Checkpoint](f) }
+
+func FuzzRestore(f *testing.F) { clitest.Fuzz[Restore](f) }
+
+func FuzzCreate(f *testing.F) { clitest.Fuzz[Create](f) }
+
+func FuzzRun(f *testing.F) { clitest.Fuzz[Run](f) }
+
+func FuzzStart(f *testing.F) { clitest.Fuzz[Start](f) }
+
+func FuzzDelete(f *testing.F) { clitest.Fuzz[Delete](f) }
+
+func FuzzPause(f *testing.F) { clitest.Fuzz[Pause](f) }
+
+func FuzzResume(f *testing.F) { clitest.Fuzz[Resume](f) }
+
+func FuzzKill(f *testing.F) { clitest.Fuzz[Kill](f) }
+
+func FuzzList(f *testing.F) { clitest.Fuzz[List](f) }
+
+func FuzzPs(f *testing.F) { clitest.Fuzz[Ps](f) }
+
+func FuzzState(f *testing.F) { clitest.Fuzz[State](f) }
+
+func FuzzEvents(f *testing.F) { clitest.Fuzz[Events](f) }
+
+func FuzzExec(f *testing.F) { clitest.Fuzz[Exec](f) }
+
+func FuzzSpec(f *testing.F) { clitest.Fuzz[Spec](f) }
+
+func FuzzUpdate(f *testing.F) { clitest.Fuzz[Update](f) }
+
+func FuzzFeatures(f *testing.F) { clitest.Fuzz[Features](f) }
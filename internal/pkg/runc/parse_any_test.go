@@ -177,3 +177,32 @@ func TestParseAny_Features(t *testing.T) {
 		t.Fatalf("Features command not populated")
 	}
 }
+
+// TestParseAny_SubcommandSeparatesGlobalFlags verifies that a global flag and
+// a flag of the subcommand sharing its name are told apart by which side of
+// the subcommand they are on.
+func TestParseAny_SubcommandSeparatesGlobalFlags(t *testing.T) {
+	t.Parallel()
+
+	var u RuncCommands
+	if err := cli.ParseAny(&u, []string{"--rootless", "auto", "spec", "--rootless"}); err != nil {
+		t.Fatalf("ParseAny: %v", err)
+	}
+	if u.Spec == nil || u.Spec.Global.Rootless != "auto" || !u.Spec.Rootless {
+		t.Fatalf("got %+v, want global --rootless auto and spec --rootless", u.Spec)
+	}
+}
+
+// TestParseAny_GlobalFlagsAfterSubcommand verifies that global flags are
+// still taken after the subcommand.
+func TestParseAny_GlobalFlagsAfterSubcommand(t *testing.T) {
+	t.Parallel()
+
+	var u RuncCommands
+	if err := cli.ParseAny(&u, []string{"create", "--bundle", "/b", "--root", "/r", "ctr"}); err != nil {
+		t.Fatalf("ParseAny: %v", err)
+	}
+	if u.Create == nil || u.Create.Global.Root != "/r" || u.Create.Bundle != "/b" || u.Create.ContainerID != "ctr" {
+		t.Fatalf("got %+v, want --root /r, --bundle /b and container ctr", u.Create)
+	}
+}
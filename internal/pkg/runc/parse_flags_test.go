@@ -4,6 +4,7 @@ import (
 	cli "github.com/TheGrizzlyDev/vino/internal/pkg/cli"
 	"reflect"
 	"testing"
+	"time"
 )
 
 func TestParseFlags_Exec_ProcessFlag(t *testing.T) {
@@ -20,6 +21,30 @@ func TestParseFlags_Exec_ProcessFlag(t *testing.T) {
 	}
 }
 
+// TestParseFlags_Exec_EnvOrder ensures that --env keeps the order and the
+// repeats it was given in, along with bare names runc takes from its own
+// environment.
+func TestParseFlags_Exec_EnvOrder(t *testing.T) {
+	t.Parallel()
+
+	args := []string{"--env", "B", "-e", "A=1", "--env=A=2", "cid", "--", "sh"}
+	var cmd Exec
+	if err := cli.Parse(&cmd, args); err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	expected := Exec{Env: []string{"B", "A=1", "A=2"}, ContainerID: "cid", Command: "sh"}
+	if !reflect.DeepEqual(cmd, expected) {
+		t.Fatalf("got %#v want %#v", cmd, expected)
+	}
+	out, err := cli.ConvertToCmdline(&cmd)
+	if err != nil {
+		t.Fatalf("ConvertToCmdline: %v", err)
+	}
+	if want := []string{"exec", "--env", "B", "--env", "A=1", "--env", "A=2", "cid", "--", "sh"}; !reflect.DeepEqual(out, want) {
+		t.Fatalf("got %q want %q", out, want)
+	}
+}
+
 // TestParseFlags_Docs_Run ensures that the example from the runc README
 // `runc run mycontainerid` parses correctly.
 func TestParseFlags_Docs_Run(t *testing.T) {
@@ -250,7 +275,7 @@ func TestParseFlags_Events_Permutations(t *testing.T) {
 	t.Parallel()
 	flags := [][]string{{"--interval", "5s"}, {"--stats"}}
 	after := []string{"cid"}
-	expected := Events{Interval: "5s", Stats: true, ContainerID: "cid"}
+	expected := Events{Interval: 5 * time.Second, Stats: true, ContainerID: "cid"}
 	testPermutations(t, nil, flags, after, expected)
 }
 
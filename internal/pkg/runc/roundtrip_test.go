@@ -0,0 +1,80 @@
+package runc
+
+import (
+	"reflect"
+	"testing"
+
+	cli "github.com/TheGrizzlyDev/vino/internal/pkg/cli"
+)
+
+// roundTripArgs are command lines setting most flags of each member of
+// RuncCommands, by field name.
+var roundTripArgs = map[string][]string{
+	"Checkpoint": {"--root", "/run/runc", "--debug", "checkpoint", "--image-path", "/img", "--work-path", "/work",
+		"--parent-path", "../pre", "--leave-running", "--tcp-established", "--tcp-skip-in-flight", "--link-remap",
+		"--ext-unix-sk", "--shell-job", "--lazy-pages", "--status-fd", "3", "--page-server", "10.0.0.1:27",
+		"--file-locks", "--pre-dump", "--manage-cgroups-mode", "soft", "--empty-ns", "network", "--auto-dedup", "cid"},
+	"Restore": {"--log", "/log.json", "--log-format", "json", "restore", "-b", "/bundle", "--console-socket", socketPath,
+		"--no-pivot", "--no-new-keyring", "--preserve-fds", "2", "-d", "--pid-file", pidFilePath, "--image-path", "/img",
+		"--work-path", "/work", "--tcp-established", "--ext-unix-sk", "--shell-job", "--file-locks",
+		"--manage-cgroups-mode=full", "--no-subreaper", "--empty-ns", "network", "--auto-dedup", "--lazy-pages",
+		"--lsm-profile", "apparmor:" + apparmor, "--lsm-mount-context", selinuxLbl, "cid"},
+	"Create": {"--systemd-cgroup", "create", "--bundle", "/bundle", "--console-socket", socketPath, "--no-pivot",
+		"--preserve-fds", "1", "--pid-file", pidFilePath, "cid"},
+	"Run": {"--rootless", "auto", "run", "-b", "/bundle", "--no-new-keyring", "-d", "--pid-file", pidFilePath,
+		"--no-subreaper", "--keep", "cid"},
+	"Start":  {"--criu", "/usr/sbin/criu", "start", "cid"},
+	"Delete": {"delete", "-f", "cid"},
+	"Pause":  {"--root", "/run/runc", "pause", "cid"},
+	"Resume": {"resume", "cid"},
+	"Kill":   {"kill", "--all", "cid", "SIGKILL"},
+	"List":   {"list", "--format", "json", "-q"},
+	"Ps":     {"ps", "-f", "table", "cid", "-o", "pid,comm", "-A"},
+	"State":  {"--debug", "state", "cid"},
+	"Events": {"events", "--interval", "5s", "--stats", "cid"},
+	"Exec": {"exec", "--console-socket", socketPath, "-d", "--pid-file", pidFilePath, "--no-pivot", "--preserve-fds", "3",
+		"--cwd", workDir, "-e", "FOO=1", "--env", "BAR=2", "-t", "-u", userSpec, "-g", "10", "--additional-gids", "20",
+		"-p", processJSON, "--process-label", selinuxLbl, "--apparmor", apparmor, "--no-new-privs", "--cap", "CAP_KILL",
+		"-c", "CAP_CHOWN", "--ignore-paused", "--cgroup", cgroupPath, "cid", "--", shellPath, "-lc", "echo ok"},
+	"Spec": {"spec", "--bundle", "/bundle", "--rootless"},
+	"Update": {"update", "cid", "--resources", "-", "--cpu-quota", "50000", "--cpu-period", "100000",
+		"--cpu-shares", "512", "--cpuset-cpus", "0-3", "--cpuset-mems", "0", "--memory", "1073741824",
+		"--memory-swap", "2147483648", "--memory-reservation", "536870912", "--kernel-memory", "1024",
+		"--pids-limit", "100", "--blkio-weight", "500"},
+	"Features": {"--debug", "features"},
+}
+
+// TestRoundTrip_RuncCommands parses a command line of each member of
+// RuncCommands, renders it back and parses the rendering again: both parses
+// must agree, and rendering must be stable.
+func TestRoundTrip_RuncCommands(t *testing.T) {
+	t.Parallel()
+	members := reflect.TypeFor[RuncCommands]()
+	for i := 0; i < members.NumField(); i++ {
+		name := members.Field(i).Name
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			args, ok := roundTripArgs[name]
+			if !ok {
+				t.Fatalf("no round trip command line for %s", name)
+			}
+			var first RuncCommands
+			if err := cli.ParseAny(&first, args); err != nil {
+				t.Fatalf("ParseAny(%q): %v", args, err)
+			}
+			if got := reflect.ValueOf(first).Field(i); got.IsNil() {
+				t.Fatalf("ParseAny(%q) did not select %s", args, name)
+			}
+
+			rendered := mustConvert(t, first.Command())
+			var second RuncCommands
+			if err := cli.ParseAny(&second, rendered); err != nil {
+				t.Fatalf("ParseAny(%q), rendered from %q: %v", rendered, args, err)
+			}
+			if !reflect.DeepEqual(first, second) {
+				t.Fatalf("round trip through %q:\n  got:  %#v\n  want: %#v", rendered, second.Command(), first.Command())
+			}
+			eq(t, mustConvert(t, second.Command()), rendered)
+		})
+	}
+}
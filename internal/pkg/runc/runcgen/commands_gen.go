@@ -0,0 +1,501 @@
+// Code generated by cligen from runc.json; DO NOT EDIT.
+
+package runcgen
+
+import (
+	"time"
+
+	"github.com/TheGrizzlyDev/vino/internal/pkg/cli"
+)
+
+// Global holds the global flags of runc, given before the subcommand.
+type Global struct {
+	Root          string `cli_flag:"--root" cli_group:"global"`
+	Debug         bool   `cli_flag:"--debug" cli_group:"global"`
+	Log           string `cli_flag:"--log" cli_group:"global"`
+	LogFormat     string `cli_flag:"--log-format" cli_group:"global" cli_enum:"text|json"`
+	SystemdCgroup bool   `cli_flag:"--systemd-cgroup" cli_group:"global"`
+	Criu          string `cli_flag:"--criu" cli_group:"global"`
+	Rootless      string `cli_flag:"--rootless" cli_group:"global" cli_enum:"true|false|auto"`
+}
+
+// Checkpoint is "runc checkpoint": checkpoint a running container.
+type Checkpoint struct {
+	Global
+
+	ImagePath         string `cli_flag:"--image-path" cli_group:"images"`
+	WorkPath          string `cli_flag:"--work-path" cli_group:"images"`
+	ParentPath        string `cli_flag:"--parent-path" cli_group:"images"`
+	LeaveRunning      bool   `cli_flag:"--leave-running" cli_group:"lifecycle"`
+	TCPEstablished    bool   `cli_flag:"--tcp-established" cli_group:"criu"`
+	TCPSkipInFlight   bool   `cli_flag:"--tcp-skip-in-flight" cli_group:"criu"`
+	LinkRemap         bool   `cli_flag:"--link-remap" cli_group:"criu"`
+	ExtUnixSk         bool   `cli_flag:"--ext-unix-sk" cli_group:"criu"`
+	ShellJob          bool   `cli_flag:"--shell-job" cli_group:"criu"`
+	LazyPages         bool   `cli_flag:"--lazy-pages" cli_group:"criu"`
+	StatusFD          uint   `cli_flag:"--status-fd" cli_group:"criu"`
+	PageServer        string `cli_flag:"--page-server" cli_group:"criu"`
+	FileLocks         bool   `cli_flag:"--file-locks" cli_group:"criu"`
+	PreDump           bool   `cli_flag:"--pre-dump" cli_group:"criu"`
+	ManageCgroupsMode string `cli_flag:"--manage-cgroups-mode" cli_group:"cgroups" cli_enum:"soft|full|strict|ignore"`
+	EmptyNs           string `cli_flag:"--empty-ns" cli_group:"namespaces"`
+	AutoDedup         bool   `cli_flag:"--auto-dedup" cli_group:"criu"`
+
+	ContainerID string `cli_argument:"container_id"`
+}
+
+func (Checkpoint) Slots() cli.Slot {
+	return cli.Group{
+		Unordered: []cli.Slot{
+			cli.FlagGroup{Name: "images"},
+			cli.FlagGroup{Name: "lifecycle"},
+			cli.FlagGroup{Name: "criu"},
+			cli.FlagGroup{Name: "cgroups"},
+			cli.FlagGroup{Name: "namespaces"},
+		},
+		Ordered: []cli.Slot{
+			cli.FlagGroup{Name: "global"},
+			cli.Subcommand{Value: "checkpoint"},
+			cli.Argument{Name: "container_id"},
+		},
+	}
+}
+
+// Restore is "runc restore": restore a container from a previous checkpoint.
+type Restore struct {
+	Global
+
+	Bundle            string `cli_flag:"--bundle" cli_flag_alternatives:"-b" cli_group:"bundle"`
+	ConsoleSocket     string `cli_flag:"--console-socket" cli_group:"console"`
+	NoPivot           bool   `cli_flag:"--no-pivot" cli_group:"runtime"`
+	NoNewKeyring      bool   `cli_flag:"--no-new-keyring" cli_group:"runtime"`
+	PreserveFDs       uint   `cli_flag:"--preserve-fds" cli_group:"runtime"`
+	Detach            bool   `cli_flag:"--detach" cli_flag_alternatives:"-d" cli_group:"lifecycle"`
+	PIDFile           string `cli_flag:"--pid-file" cli_group:"lifecycle"`
+	ImagePath         string `cli_flag:"--image-path" cli_group:"images"`
+	WorkPath          string `cli_flag:"--work-path" cli_group:"images"`
+	TCPEstablished    bool   `cli_flag:"--tcp-established" cli_group:"criu"`
+	ExtUnixSk         bool   `cli_flag:"--ext-unix-sk" cli_group:"criu"`
+	ShellJob          bool   `cli_flag:"--shell-job" cli_group:"criu"`
+	FileLocks         bool   `cli_flag:"--file-locks" cli_group:"criu"`
+	ManageCgroupsMode string `cli_flag:"--manage-cgroups-mode" cli_group:"cgroups" cli_enum:"soft|full|strict|ignore"`
+	NoSubreaper       bool   `cli_flag:"--no-subreaper" cli_group:"lifecycle"`
+	EmptyNs           string `cli_flag:"--empty-ns" cli_group:"namespaces"`
+	AutoDedup         bool   `cli_flag:"--auto-dedup" cli_group:"criu"`
+	LazyPages         bool   `cli_flag:"--lazy-pages" cli_group:"criu"`
+	LSMProfile        string `cli_flag:"--lsm-profile" cli_group:"security"`
+	LSMMountContext   string `cli_flag:"--lsm-mount-context" cli_group:"security"`
+
+	ContainerID string `cli_argument:"container_id"`
+}
+
+func (Restore) Slots() cli.Slot {
+	return cli.Group{
+		Unordered: []cli.Slot{
+			cli.FlagGroup{Name: "bundle"},
+			cli.FlagGroup{Name: "console"},
+			cli.FlagGroup{Name: "runtime"},
+			cli.FlagGroup{Name: "lifecycle"},
+			cli.FlagGroup{Name: "images"},
+			cli.FlagGroup{Name: "criu"},
+			cli.FlagGroup{Name: "cgroups"},
+			cli.FlagGroup{Name: "namespaces"},
+			cli.FlagGroup{Name: "security"},
+		},
+		Ordered: []cli.Slot{
+			cli.FlagGroup{Name: "global"},
+			cli.Subcommand{Value: "restore"},
+			cli.Argument{Name: "container_id"},
+		},
+	}
+}
+
+// Create is "runc create": create a container.
+type Create struct {
+	Global
+
+	Bundle        string `cli_flag:"--bundle" cli_flag_alternatives:"-b" cli_group:"bundle"`
+	ConsoleSocket string `cli_flag:"--console-socket" cli_group:"console"`
+	NoPivot       bool   `cli_flag:"--no-pivot" cli_group:"runtime"`
+	NoNewKeyring  bool   `cli_flag:"--no-new-keyring" cli_group:"runtime"`
+	PreserveFDs   uint   `cli_flag:"--preserve-fds" cli_group:"runtime"`
+	PIDFile       string `cli_flag:"--pid-file" cli_group:"lifecycle"`
+
+	ContainerID string `cli_argument:"container_id"`
+}
+
+func (Create) Slots() cli.Slot {
+	return cli.Group{
+		Unordered: []cli.Slot{
+			cli.FlagGroup{Name: "bundle"},
+			cli.FlagGroup{Name: "console"},
+			cli.FlagGroup{Name: "runtime"},
+			cli.FlagGroup{Name: "lifecycle"},
+		},
+		Ordered: []cli.Slot{
+			cli.FlagGroup{Name: "global"},
+			cli.Subcommand{Value: "create"},
+			cli.Argument{Name: "container_id"},
+		},
+	}
+}
+
+// Run is "runc run": create and run a container.
+type Run struct {
+	Global
+
+	Bundle        string `cli_flag:"--bundle" cli_flag_alternatives:"-b" cli_group:"bundle"`
+	ConsoleSocket string `cli_flag:"--console-socket" cli_group:"console"`
+	NoPivot       bool   `cli_flag:"--no-pivot" cli_group:"runtime"`
+	NoNewKeyring  bool   `cli_flag:"--no-new-keyring" cli_group:"runtime"`
+	PreserveFDs   uint   `cli_flag:"--preserve-fds" cli_group:"runtime"`
+	Detach        bool   `cli_flag:"--detach" cli_flag_alternatives:"-d" cli_group:"lifecycle"`
+	PIDFile       string `cli_flag:"--pid-file" cli_group:"lifecycle"`
+	NoSubreaper   bool   `cli_flag:"--no-subreaper" cli_group:"lifecycle"`
+	Keep          bool   `cli_flag:"--keep" cli_group:"lifecycle"`
+
+	ContainerID string `cli_argument:"container_id"`
+}
+
+func (Run) Slots() cli.Slot {
+	return cli.Group{
+		Unordered: []cli.Slot{
+			cli.FlagGroup{Name: "bundle"},
+			cli.FlagGroup{Name: "console"},
+			cli.FlagGroup{Name: "runtime"},
+			cli.FlagGroup{Name: "lifecycle"},
+		},
+		Ordered: []cli.Slot{
+			cli.FlagGroup{Name: "global"},
+			cli.Subcommand{Value: "run"},
+			cli.Argument{Name: "container_id"},
+		},
+	}
+}
+
+// Start is "runc start": executes the user defined process in a created container.
+type Start struct {
+	Global
+
+	ContainerID string `cli_argument:"container_id"`
+}
+
+func (Start) Slots() cli.Slot {
+	return cli.Group{
+		Ordered: []cli.Slot{
+			cli.FlagGroup{Name: "global"},
+			cli.Subcommand{Value: "start"},
+			cli.Argument{Name: "container_id"},
+		},
+	}
+}
+
+// Delete is "runc delete": delete any resources held by the container often used with detached container.
+type Delete struct {
+	Global
+
+	Force bool `cli_flag:"--force" cli_flag_alternatives:"-f" cli_group:"common"`
+
+	ContainerID string `cli_argument:"container_id"`
+}
+
+func (Delete) Slots() cli.Slot {
+	return cli.Group{
+		Unordered: []cli.Slot{
+			cli.FlagGroup{Name: "common"},
+		},
+		Ordered: []cli.Slot{
+			cli.FlagGroup{Name: "global"},
+			cli.Subcommand{Value: "delete"},
+			cli.Argument{Name: "container_id"},
+		},
+	}
+}
+
+// Pause is "runc pause": pause suspends all processes inside the container.
+type Pause struct {
+	Global
+
+	ContainerID string `cli_argument:"container_id"`
+}
+
+func (Pause) Slots() cli.Slot {
+	return cli.Group{
+		Ordered: []cli.Slot{
+			cli.FlagGroup{Name: "global"},
+			cli.Subcommand{Value: "pause"},
+			cli.Argument{Name: "container_id"},
+		},
+	}
+}
+
+// Resume is "runc resume": resumes all processes that have been previously paused.
+type Resume struct {
+	Global
+
+	ContainerID string `cli_argument:"container_id"`
+}
+
+func (Resume) Slots() cli.Slot {
+	return cli.Group{
+		Ordered: []cli.Slot{
+			cli.FlagGroup{Name: "global"},
+			cli.Subcommand{Value: "resume"},
+			cli.Argument{Name: "container_id"},
+		},
+	}
+}
+
+// Kill is "runc kill": kill sends the specified signal (default: SIGTERM) to the container's init process.
+type Kill struct {
+	Global
+
+	All bool `cli_flag:"--all" cli_group:"common"`
+
+	ContainerID string `cli_argument:"container_id"`
+	Signal      string `cli_argument:"signal"`
+}
+
+func (Kill) Slots() cli.Slot {
+	return cli.Group{
+		Unordered: []cli.Slot{
+			cli.FlagGroup{Name: "common"},
+		},
+		Ordered: []cli.Slot{
+			cli.FlagGroup{Name: "global"},
+			cli.Subcommand{Value: "kill"},
+			cli.Argument{Name: "container_id"},
+			cli.Argument{Name: "signal"},
+		},
+	}
+}
+
+// List is "runc list": lists containers started by runc with the given root.
+type List struct {
+	Global
+
+	Format string `cli_flag:"--format" cli_flag_alternatives:"-f" cli_group:"output" cli_enum:"table|json"`
+	Quiet  bool   `cli_flag:"--quiet" cli_flag_alternatives:"-q" cli_group:"output"`
+}
+
+func (List) Slots() cli.Slot {
+	return cli.Group{
+		Unordered: []cli.Slot{
+			cli.FlagGroup{Name: "output"},
+		},
+		Ordered: []cli.Slot{
+			cli.FlagGroup{Name: "global"},
+			cli.Subcommand{Value: "list"},
+		},
+	}
+}
+
+// Ps is "runc ps": ps displays the processes running inside a container.
+type Ps struct {
+	Global
+
+	Format string `cli_flag:"--format" cli_flag_alternatives:"-f" cli_group:"output" cli_enum:"table|json"`
+
+	ContainerID string   `cli_argument:"container_id"`
+	PsArgs      []string `cli_argument:"ps_args"`
+}
+
+func (Ps) Slots() cli.Slot {
+	return cli.Group{
+		Unordered: []cli.Slot{
+			cli.FlagGroup{Name: "output"},
+		},
+		Ordered: []cli.Slot{
+			cli.FlagGroup{Name: "global"},
+			cli.Subcommand{Value: "ps"},
+			cli.Argument{Name: "container_id"},
+			cli.Arguments{Name: "ps_args"},
+		},
+	}
+}
+
+// State is "runc state": output the state of a container.
+type State struct {
+	Global
+
+	ContainerID string `cli_argument:"container_id"`
+}
+
+func (State) Slots() cli.Slot {
+	return cli.Group{
+		Ordered: []cli.Slot{
+			cli.FlagGroup{Name: "global"},
+			cli.Subcommand{Value: "state"},
+			cli.Argument{Name: "container_id"},
+		},
+	}
+}
+
+// Events is "runc events": display container events such as OOM notifications, cpu, memory, and IO usage statistics.
+type Events struct {
+	Global
+
+	Interval time.Duration `cli_flag:"--interval" cli_group:"events"`
+	Stats    bool          `cli_flag:"--stats" cli_group:"events"`
+
+	ContainerID string `cli_argument:"container_id"`
+}
+
+func (Events) Slots() cli.Slot {
+	return cli.Group{
+		Unordered: []cli.Slot{
+			cli.FlagGroup{Name: "events"},
+		},
+		Ordered: []cli.Slot{
+			cli.FlagGroup{Name: "global"},
+			cli.Subcommand{Value: "events"},
+			cli.Argument{Name: "container_id"},
+		},
+	}
+}
+
+// Exec is "runc exec": execute new process inside the container.
+type Exec struct {
+	Global
+
+	ConsoleSocket  string   `cli_flag:"--console-socket" cli_group:"console"`
+	Detach         bool     `cli_flag:"--detach" cli_flag_alternatives:"-d" cli_group:"lifecycle"`
+	PIDFile        string   `cli_flag:"--pid-file" cli_group:"lifecycle"`
+	NoPivot        bool     `cli_flag:"--no-pivot" cli_group:"runtime"`
+	NoNewKeyring   bool     `cli_flag:"--no-new-keyring" cli_group:"runtime"`
+	PreserveFDs    uint     `cli_flag:"--preserve-fds" cli_group:"runtime"`
+	Cwd            string   `cli_flag:"--cwd" cli_group:"process"`
+	Env            []string `cli_flag:"--env" cli_flag_alternatives:"-e" cli_group:"process"`
+	TTY            bool     `cli_flag:"--tty" cli_flag_alternatives:"-t" cli_group:"process"`
+	User           string   `cli_flag:"--user" cli_flag_alternatives:"-u" cli_group:"process"`
+	AdditionalGids []uint   `cli_flag:"--additional-gids" cli_flag_alternatives:"-g" cli_group:"process"`
+	Process        string   `cli_flag:"--process" cli_flag_alternatives:"-p" cli_group:"process"`
+	ProcessLabel   string   `cli_flag:"--process-label" cli_group:"security"`
+	Apparmor       string   `cli_flag:"--apparmor" cli_group:"security"`
+	NoNewPrivs     bool     `cli_flag:"--no-new-privs" cli_group:"security"`
+	Cap            []string `cli_flag:"--cap" cli_flag_alternatives:"-c" cli_group:"security"`
+	IgnorePaused   bool     `cli_flag:"--ignore-paused" cli_group:"lifecycle"`
+	Cgroup         string   `cli_flag:"--cgroup" cli_group:"cgroups"`
+
+	ContainerID string   `cli_argument:"container_id"`
+	Command     string   `cli_argument:"command"`
+	Args        []string `cli_argument:"args"`
+}
+
+func (Exec) Slots() cli.Slot {
+	return cli.Group{
+		Unordered: []cli.Slot{
+			cli.FlagGroup{Name: "console"},
+			cli.FlagGroup{Name: "lifecycle"},
+			cli.FlagGroup{Name: "runtime"},
+			cli.FlagGroup{Name: "process"},
+			cli.FlagGroup{Name: "security"},
+			cli.FlagGroup{Name: "cgroups"},
+		},
+		Ordered: []cli.Slot{
+			cli.FlagGroup{Name: "global"},
+			cli.Subcommand{Value: "exec"},
+			cli.Argument{Name: "container_id"},
+			cli.Group{
+				Ordered: []cli.Slot{
+					cli.Literal{Value: "--"},
+					cli.Argument{Name: "command"},
+					cli.Arguments{Name: "args"},
+				},
+			},
+		},
+	}
+}
+
+// Spec is "runc spec": create a new specification file.
+type Spec struct {
+	Global
+
+	Bundle   string `cli_flag:"--bundle" cli_flag_alternatives:"-b" cli_group:"bundle"`
+	Rootless bool   `cli_flag:"--rootless" cli_group:"spec"`
+}
+
+func (Spec) Slots() cli.Slot {
+	return cli.Group{
+		Unordered: []cli.Slot{
+			cli.FlagGroup{Name: "bundle"},
+			cli.FlagGroup{Name: "spec"},
+		},
+		Ordered: []cli.Slot{
+			cli.FlagGroup{Name: "global"},
+			cli.Subcommand{Value: "spec"},
+		},
+	}
+}
+
+// Update is "runc update": update container resource constraints.
+type Update struct {
+	Global
+
+	R                 string `cli_flag:"-r" cli_flag_alternatives:"--resources" cli_group:"mode"`
+	CPUQuota          int    `cli_flag:"--cpu-quota" cli_group:"cpu"`
+	CPUPeriod         uint   `cli_flag:"--cpu-period" cli_group:"cpu"`
+	CPUShares         uint   `cli_flag:"--cpu-shares" cli_group:"cpu"`
+	CpusetCpus        string `cli_flag:"--cpuset-cpus" cli_group:"cpu"`
+	CpusetMems        string `cli_flag:"--cpuset-mems" cli_group:"cpu"`
+	Memory            int    `cli_flag:"--memory" cli_group:"memory"`
+	MemorySwap        int    `cli_flag:"--memory-swap" cli_group:"memory"`
+	MemoryReservation int    `cli_flag:"--memory-reservation" cli_group:"memory"`
+	KernelMemory      int    `cli_flag:"--kernel-memory" cli_group:"memory"`
+	PidsLimit         int    `cli_flag:"--pids-limit" cli_group:"pids"`
+	BlkioWeight       uint   `cli_flag:"--blkio-weight" cli_group:"io"`
+
+	ContainerID string `cli_argument:"container_id"`
+}
+
+func (Update) Slots() cli.Slot {
+	return cli.Group{
+		Unordered: []cli.Slot{
+			cli.FlagGroup{Name: "mode"},
+			cli.FlagGroup{Name: "cpu"},
+			cli.FlagGroup{Name: "memory"},
+			cli.FlagGroup{Name: "pids"},
+			cli.FlagGroup{Name: "io"},
+		},
+		Ordered: []cli.Slot{
+			cli.FlagGroup{Name: "global"},
+			cli.Subcommand{Value: "update"},
+			cli.Argument{Name: "container_id"},
+		},
+	}
+}
+
+// Features is "runc features": show the enabled features.
+type Features struct {
+	Global
+}
+
+func (Features) Slots() cli.Slot {
+	return cli.Group{
+		Ordered: []cli.Slot{
+			cli.FlagGroup{Name: "global"},
+			cli.Subcommand{Value: "features"},
+		},
+	}
+}
+
+// Commands holds one of the commands of runc, for cli.ParseAny.
+type Commands struct {
+	Checkpoint *Checkpoint
+	Restore    *Restore
+	Create     *Create
+	Run        *Run
+	Start      *Start
+	Delete     *Delete
+	Pause      *Pause
+	Resume     *Resume
+	Kill       *Kill
+	List       *List
+	Ps         *Ps
+	State      *State
+	Events     *Events
+	Exec       *Exec
+	Spec       *Spec
+	Update     *Update
+	Features   *Features
+}
@@ -0,0 +1,5 @@
+// Package runcgen holds the commands of runc as generated by cligen from
+// the reviewed description in ../runc.json. The tests of package runc hold
+// its hand-written commands to them, so that a new version of runc is a
+// diff of runc.json, of the generated code and of commands.go.
+package runcgen
@@ -0,0 +1,181 @@
+package runc
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	cli "github.com/TheGrizzlyDev/vino/internal/pkg/cli"
+	"github.com/TheGrizzlyDev/vino/internal/pkg/cli/cligen"
+	"github.com/TheGrizzlyDev/vino/internal/pkg/runc/runcgen"
+)
+
+// TestGeneratedIsUpToDate verifies that runcgen holds the code generated
+// from runc.json: run go generate after amending it.
+func TestGeneratedIsUpToDate(t *testing.T) {
+	t.Parallel()
+
+	f, err := os.Open("runc.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	rt, err := cligen.ReadJSON(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := cligen.Generate(rt, cligen.Options{Package: "runcgen", Source: "runc.json"})
+	if err != nil {
+		t.Fatalf("Generate: %v", err)
+	}
+	got, err := os.ReadFile("runcgen/commands_gen.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Fatalf("runcgen/commands_gen.go is stale, run go generate ./internal/pkg/runc")
+	}
+}
+
+// TestCommandsMatchSpec verifies that the hand-written commands take the
+// command line generated from runc.json: the same subcommands, flags,
+// aliases, groups, value types, enums and arguments.
+func TestCommandsMatchSpec(t *testing.T) {
+	t.Parallel()
+
+	got, err := describe(cli.Members[RuncCommands]())
+	if err != nil {
+		t.Fatalf("commands.go: %v", err)
+	}
+	want, err := describe(cli.Members[runcgen.Commands]())
+	if err != nil {
+		t.Fatalf("runcgen: %v", err)
+	}
+	if !reflect.DeepEqual(got.Global, want.Global) {
+		t.Errorf("global flags differ:\n got %+v\nwant %+v", got.Global, want.Global)
+	}
+	for i := range max(len(got.Commands), len(want.Commands)) {
+		switch {
+		case i >= len(got.Commands):
+			t.Errorf("commands.go lacks %q", want.Commands[i].Name)
+		case i >= len(want.Commands):
+			t.Errorf("runc.json lacks %q", got.Commands[i].Name)
+		case !reflect.DeepEqual(got.Commands[i], want.Commands[i]):
+			t.Errorf("%s differs:\n got %+v\nwant %+v", got.Commands[i].Name, got.Commands[i], want.Commands[i])
+		}
+	}
+}
+
+// describe returns the command line taken by the members of a union, as
+// far as parsing it goes: field names, documentation and the width of
+// integers are left out.
+func describe(members []cli.Command) (cligen.Runtime, error) {
+	var rt cligen.Runtime
+	for _, m := range members {
+		c := cligen.Command{Name: cli.SubcommandOf(m)}
+		global, unordered, args := slotsOf(m.Slots())
+		c.Arguments = args
+		var groups []string
+		for _, f := range flagsOf(reflect.TypeOf(m).Elem()) {
+			if slices.Contains(global, f.Group) {
+				if !slices.ContainsFunc(rt.Global, func(g cligen.Flag) bool { return g.Name == f.Name }) {
+					rt.Global = append(rt.Global, f)
+				}
+				continue
+			}
+			if !slices.Contains(groups, f.Group) {
+				groups = append(groups, f.Group)
+			}
+			c.Flags = append(c.Flags, f)
+		}
+		slices.Sort(groups)
+		slices.Sort(unordered)
+		if !slices.Equal(groups, unordered) {
+			return rt, fmt.Errorf("%s: takes the groups %q, has flags in %q", c.Name, unordered, groups)
+		}
+		rt.Commands = append(rt.Commands, c)
+	}
+	slices.SortFunc(rt.Commands, func(a, b cligen.Command) int { return strings.Compare(a.Name, b.Name) })
+	return rt, nil
+}
+
+// slotsOf returns the flag groups given before the subcommand, the
+// unordered ones and the arguments of slots.
+func slotsOf(slots cli.Slot) (global, unordered []string, args []cligen.Argument) {
+	var walk func(s cli.Slot)
+	walk = func(s cli.Slot) {
+		switch v := s.(type) {
+		case cli.Group:
+			for _, u := range v.Unordered {
+				if fg, ok := u.(cli.FlagGroup); ok {
+					unordered = append(unordered, fg.Name)
+				}
+			}
+			for _, o := range v.Ordered {
+				walk(o)
+			}
+		case cli.FlagGroup:
+			global = append(global, v.Name)
+		case cli.Argument:
+			args = append(args, cligen.Argument{Name: v.Name})
+		case cli.Arguments:
+			args = append(args, cligen.Argument{Name: v.Name, Variadic: true})
+		case cli.Literal:
+			args = append(args, cligen.Argument{Literal: v.Value})
+		}
+	}
+	walk(slots)
+	return global, unordered, args
+}
+
+// flagsOf returns the flags of the struct t, embedded ones included.
+func flagsOf(t reflect.Type) []cligen.Flag {
+	var flags []cligen.Flag
+	for i := range t.NumField() {
+		sf := t.Field(i)
+		if sf.Anonymous && sf.Type.Kind() == reflect.Struct {
+			flags = append(flags, flagsOf(sf.Type)...)
+			continue
+		}
+		name, ok := sf.Tag.Lookup("cli_flag")
+		if !ok {
+			continue
+		}
+		f := cligen.Flag{Name: name, Group: sf.Tag.Get("cli_group"), Type: typeOf(sf.Type)}
+		if alts := sf.Tag.Get("cli_flag_alternatives"); alts != "" {
+			f.Aliases = strings.Split(alts, "|")
+		}
+		if enum := sf.Tag.Get("cli_enum"); enum != "" {
+			f.Enum = strings.Split(enum, "|")
+		}
+		flags = append(flags, f)
+	}
+	return flags
+}
+
+// typeOf returns the type of a flag of type t, as cligen names them.
+func typeOf(t reflect.Type) string {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch {
+	case t == reflect.TypeFor[time.Duration]():
+		return "time.Duration"
+	case t.Kind() == reflect.Slice:
+		return "[]" + typeOf(t.Elem())
+	case t.Kind() == reflect.Map:
+		return "map[" + typeOf(t.Key()) + "]" + typeOf(t.Elem())
+	}
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return "int"
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "uint"
+	}
+	return t.Kind().String()
+}
@@ -1,9 +1,13 @@
 package runc
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"iter"
+	"log/slog"
 	"os"
 	"os/exec"
 	"os/signal"
@@ -11,12 +15,20 @@ import (
 	"reflect"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/TheGrizzlyDev/vino/internal/pkg/cli"
+	"github.com/TheGrizzlyDev/vino/internal/pkg/tracing"
 	specs "github.com/opencontainers/runtime-spec/specs-go"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 	"golang.org/x/sys/unix"
 )
 
+var tracer = otel.Tracer("github.com/TheGrizzlyDev/vino/internal/pkg/runc")
+
 type BundleRewriter interface {
 	RewriteBundle(*specs.Spec) error
 }
@@ -25,10 +37,86 @@ type ProcessRewriter interface {
 	RewriteProcess(*specs.Process) error
 }
 
+// StateRewriter adjusts the container state printed by `runc state`.
+type StateRewriter interface {
+	RewriteState(*ContainerState) error
+}
+
+// EventRewriter adjusts the events printed by `runc events`.
+type EventRewriter interface {
+	// RewriteEvents prints the events of s through emit: those of the
+	// delegate, as they come, and its own. It returns once the delegate's
+	// end. emit may be called from several goroutines.
+	RewriteEvents(ctx context.Context, s *EventStream, emit func(Event) error) error
+}
+
+// EventStream is what an EventRewriter is given of a container's events.
+type EventStream struct {
+	// ID is the container whose events are streamed.
+	ID string
+	// Client queries the delegate about the container.
+	Client *Client
+	// Delegate yields the events printed by the delegate, ending with its
+	// error if it fails.
+	Delegate iter.Seq2[Event, error]
+}
+
+// ContainerState is the state printed by `runc state`.
+type ContainerState struct {
+	OCIVersion  string            `json:"ociVersion"`
+	ID          string            `json:"id"`
+	Pid         int               `json:"pid"`
+	Status      string            `json:"status"`
+	Bundle      string            `json:"bundle"`
+	Rootfs      string            `json:"rootfs"`
+	Created     time.Time         `json:"created"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+	Owner       string            `json:"owner"`
+}
+
+// Reasons reported by RewriteError.
+const (
+	RewriteReasonReadBundle  = "read_bundle"
+	RewriteReasonBundle      = "rewrite_bundle"
+	RewriteReasonProcess     = "rewrite_process"
+	RewriteReasonWriteBundle = "write_bundle"
+	RewriteReasonProcessFile = "process_file"
+)
+
+// RewriteError reports a bundle or process that could not be rewritten before
+// being handed to the delegate. Reason is a short, stable identifier; a
+// BundleRewriter or ProcessRewriter may return a RewriteError itself to
+// report a more specific one.
+type RewriteError struct {
+	Reason string
+	Err    error
+}
+
+func (e *RewriteError) Error() string { return e.Err.Error() }
+
+func (e *RewriteError) Unwrap() error { return e.Err }
+
+func rewriteError(reason string, err error) error {
+	var re *RewriteError
+	if errors.As(err, &re) {
+		return err
+	}
+	return &RewriteError{Reason: reason, Err: err}
+}
+
 type Wrapper struct {
 	BundleRewriter  BundleRewriter
 	ProcessRewriter ProcessRewriter
-	Delegate        Cli
+	// ExecProcessRewriter rewrites processes started by exec. Defaults to
+	// ProcessRewriter.
+	ExecProcessRewriter ProcessRewriter
+	StateRewriter       StateRewriter
+	// EventRewriter rewrites the events streamed by `runc events`. The
+	// statistics printed once with --stats are left alone.
+	EventRewriter EventRewriter
+	Delegate      Cli
+	// Logger receives the wrapper's records. Defaults to slog.Default().
+	Logger *slog.Logger
 }
 
 type RuncCommands struct {
@@ -51,6 +139,59 @@ type RuncCommands struct {
 	Features   *Features
 }
 
+// Command returns the command selected in the union, or nil if none is.
+func (c RuncCommands) Command() cli.Command {
+	v := reflect.ValueOf(c)
+	for i := 0; i < v.NumField(); i++ {
+		f := v.Field(i)
+		if f.IsNil() {
+			continue
+		}
+		return f.Interface().(cli.Command)
+	}
+	return nil
+}
+
+// Bundle returns the bundle path of the selected command, if it references
+// one.
+func (c RuncCommands) Bundle() string {
+	switch {
+	case c.Create != nil:
+		return c.Create.Bundle
+	case c.Run != nil:
+		return c.Run.Bundle
+	case c.Restore != nil:
+		return c.Restore.Bundle
+	}
+	return ""
+}
+
+// GlobalOf returns the global options embedded in cmd.
+func GlobalOf(cmd cli.Command) Global {
+	v := reflect.Indirect(reflect.ValueOf(cmd))
+	if v.Kind() != reflect.Struct {
+		return Global{}
+	}
+	if g, ok := v.FieldByName("Global").Interface().(Global); ok {
+		return g
+	}
+	return Global{}
+}
+
+// ContainerIDOf returns the container id argument of cmd, or an empty string
+// for commands that don't target a container.
+func ContainerIDOf(cmd cli.Command) string {
+	v := reflect.Indirect(reflect.ValueOf(cmd))
+	if v.Kind() != reflect.Struct {
+		return ""
+	}
+	f := v.FieldByName("ContainerID")
+	if !f.IsValid() || f.Kind() != reflect.String {
+		return ""
+	}
+	return f.String()
+}
+
 func RunWithArgs(w *Wrapper, args []string) error {
 	var cmds RuncCommands
 	if err := cli.ParseAny(&cmds, args); err != nil {
@@ -60,59 +201,38 @@ func RunWithArgs(w *Wrapper, args []string) error {
 }
 
 func (w *Wrapper) Run(cmds RuncCommands) error {
+	return w.RunContext(context.Background(), cmds)
+}
+
+// RunContext is like Run but records its spans under the span found in ctx.
+func (w *Wrapper) RunContext(ctx context.Context, cmds RuncCommands) error {
 	if w.Delegate == nil {
 		return fmt.Errorf("wrapper: nil delegate")
 	}
+	logger := w.Logger
+	if logger == nil {
+		logger = slog.Default()
+	}
 
 	// Bundle rewriting for commands that reference a bundle.
 	if w.BundleRewriter != nil || w.ProcessRewriter != nil {
-		var bundlePath string
-		switch {
-		case cmds.Create != nil:
-			bundlePath = cmds.Create.Bundle
-		case cmds.Run != nil:
-			bundlePath = cmds.Run.Bundle
 		// TODO: check if we actually want to modify a restored bundle
 		//		 or if it is aleady restored with the modifications
-		case cmds.Restore != nil:
-			bundlePath = cmds.Restore.Bundle
-		}
+		bundlePath := cmds.Bundle()
 		if bundlePath != "" {
-			cfg := filepath.Join(bundlePath, "config.json")
-			data, err := os.ReadFile(cfg)
-			if err != nil {
-				return fmt.Errorf("read bundle: %w", err)
-			}
-			var spec specs.Spec
-			if err := json.Unmarshal(data, &spec); err != nil {
-				return fmt.Errorf("unmarshal bundle: %w", err)
-			}
-			if w.BundleRewriter != nil {
-				if err := w.BundleRewriter.RewriteBundle(&spec); err != nil {
-					return err
-				}
-			}
-			if w.ProcessRewriter != nil && spec.Process != nil {
-				if err := w.ProcessRewriter.RewriteProcess(spec.Process); err != nil {
-					return err
-				}
-			}
-			out, err := json.MarshalIndent(&spec, "", "  ")
-			if err != nil {
-				return fmt.Errorf("marshal bundle: %w", err)
-			}
-			if err := os.WriteFile(cfg, out, 0o644); err != nil {
-				return fmt.Errorf("write bundle: %w", err)
+			if err := w.rewriteBundle(ctx, bundlePath); err != nil {
+				return err
 			}
+			logger.Debug("rewrote bundle", "bundle", bundlePath)
 		}
 	}
 
 	// Process rewriting for exec commands.
 	var tmpProc string
-	if w.ProcessRewriter != nil {
+	if rewriter := w.execProcessRewriter(); rewriter != nil {
 		switch {
 		case cmds.Exec != nil:
-			if err := w.rewriteExec(cmds.Exec, &tmpProc); err != nil {
+			if err := w.rewriteExec(ctx, rewriter, cmds.Exec, &tmpProc); err != nil {
 				return err
 			}
 		}
@@ -123,26 +243,33 @@ func (w *Wrapper) Run(cmds RuncCommands) error {
 		defer os.Remove(tmpProc)
 	}
 
-	v := reflect.ValueOf(cmds)
+	cmd := cmds.Command()
 
-	var cmd cli.Command
-	for i := 0; i < v.NumField(); i++ {
-		f := v.Field(i)
-		if f.IsNil() {
-			continue
-		}
-		cmd = f.Interface().(cli.Command)
-		break
+	ctx, span := tracer.Start(ctx, "delegate", trace.WithAttributes(
+		attribute.String("runc.subcommand", cli.SubcommandOf(cmd)),
+	))
+	var err error
+	defer tracing.End(span, &err)
+
+	if cmds.Events != nil && !cmds.Events.Stats && w.EventRewriter != nil {
+		err = w.rewriteEvents(ctx, cmds.Events)
+		return err
 	}
 
-	ctx := context.Background()
 	execCmd, err := w.Delegate.Command(ctx, cmd)
 	if err != nil {
 		return err
 	}
+	logger.Debug("delegating", "path", execCmd.Path, "args", execCmd.Args[1:])
+	span.SetAttributes(attribute.StringSlice("runc.args", execCmd.Args[1:]))
 	if execCmd.Stdin == nil {
 		execCmd.Stdin = os.Stdin
 	}
+	var stateOut *bytes.Buffer
+	if cmds.State != nil && w.StateRewriter != nil && execCmd.Stdout == nil {
+		stateOut = &bytes.Buffer{}
+		execCmd.Stdout = stateOut
+	}
 	if execCmd.Stdout == nil {
 		execCmd.Stdout = os.Stdout
 	}
@@ -167,13 +294,14 @@ func (w *Wrapper) Run(cmds RuncCommands) error {
 	if len(extra) > 0 {
 		execCmd.ExtraFiles = extra
 	}
-	if err := execCmd.Start(); err != nil {
+	if err = execCmd.Start(); err != nil {
 		for _, f := range extra {
 			if f != nil {
 				f.Close()
 			}
 		}
-		return fmt.Errorf("start process: %w", err)
+		err = fmt.Errorf("start process: %w", err)
+		return err
 	}
 	sigCh := make(chan os.Signal, 1)
 	signal.Notify(sigCh)
@@ -194,16 +322,133 @@ func (w *Wrapper) Run(cmds RuncCommands) error {
 			f.Close()
 		}
 	}
-	if err := execCmd.Wait(); err != nil {
+	if err = execCmd.Wait(); err != nil {
 		if exitErr, ok := err.(*exec.ExitError); ok {
+			span.SetAttributes(attribute.Int("runc.exit_code", exitErr.ExitCode()))
 			return exitErr
 		}
-		return fmt.Errorf("wait process: %w", err)
+		err = fmt.Errorf("wait process: %w", err)
+		return err
+	}
+	if stateOut != nil {
+		w.writeState(logger, stateOut.Bytes())
 	}
 	return nil
 }
 
-func (w *Wrapper) rewriteExec(c *Exec, tmpPath *string) error {
+// rewriteEvents streams the events of the delegate through the
+// EventRewriter, printing them one JSON object per line as `runc events`
+// does, until the delegate stops or the wrapper is told to.
+func (w *Wrapper) rewriteEvents(ctx context.Context, c *Events) error {
+	ctx, stop := signal.NotifyContext(ctx, unix.SIGINT, unix.SIGTERM)
+	defer stop()
+
+	client := &Client{Cli: w.Delegate, Global: c.Global, Stderr: os.Stderr}
+	s := &EventStream{
+		ID:       c.ContainerID,
+		Client:   client,
+		Delegate: client.Events(ctx, c.ContainerID, c.Interval),
+	}
+	var mu sync.Mutex
+	enc := json.NewEncoder(os.Stdout)
+	emit := func(ev Event) error {
+		mu.Lock()
+		defer mu.Unlock()
+		return enc.Encode(ev)
+	}
+	err := w.EventRewriter.RewriteEvents(ctx, s, emit)
+	// Being interrupted is how event streams end.
+	if ctx.Err() != nil && errors.Is(err, context.Canceled) {
+		return nil
+	}
+	return err
+}
+
+// writeState prints the state output by the delegate after passing it
+// through the StateRewriter. The delegate's output is printed unchanged if
+// it can't be rewritten.
+func (w *Wrapper) writeState(logger *slog.Logger, out []byte) {
+	var state ContainerState
+	if err := json.Unmarshal(out, &state); err != nil {
+		logger.Warn("decode state", "error", err)
+		os.Stdout.Write(out)
+		return
+	}
+	if err := w.StateRewriter.RewriteState(&state); err != nil {
+		logger.Warn("rewrite state", "error", err)
+		os.Stdout.Write(out)
+		return
+	}
+	data, err := json.MarshalIndent(&state, "", "  ")
+	if err != nil {
+		logger.Warn("encode state", "error", err)
+		os.Stdout.Write(out)
+		return
+	}
+	os.Stdout.Write(data)
+}
+
+func (w *Wrapper) rewriteBundle(ctx context.Context, bundlePath string) (err error) {
+	cfg := filepath.Join(bundlePath, "config.json")
+
+	_, span := tracer.Start(ctx, "bundle.read", trace.WithAttributes(attribute.String("bundle", bundlePath)))
+	data, err := os.ReadFile(cfg)
+	if err != nil {
+		err = rewriteError(RewriteReasonReadBundle, fmt.Errorf("read bundle: %w", err))
+		tracing.End(span, &err)
+		return err
+	}
+	var spec specs.Spec
+	if err = json.Unmarshal(data, &spec); err != nil {
+		err = rewriteError(RewriteReasonReadBundle, fmt.Errorf("unmarshal bundle: %w", err))
+		tracing.End(span, &err)
+		return err
+	}
+	span.End()
+
+	if w.BundleRewriter != nil {
+		_, span := tracer.Start(ctx, "bundle.rewrite")
+		err = w.BundleRewriter.RewriteBundle(&spec)
+		tracing.End(span, &err)
+		if err != nil {
+			return rewriteError(RewriteReasonBundle, err)
+		}
+	}
+	if w.ProcessRewriter != nil && spec.Process != nil {
+		_, span := tracer.Start(ctx, "process.rewrite")
+		err = w.ProcessRewriter.RewriteProcess(spec.Process)
+		tracing.End(span, &err)
+		if err != nil {
+			return rewriteError(RewriteReasonProcess, err)
+		}
+	}
+
+	out, err := json.MarshalIndent(&spec, "", "  ")
+	if err != nil {
+		return rewriteError(RewriteReasonWriteBundle, fmt.Errorf("marshal bundle: %w", err))
+	}
+	if err := os.WriteFile(cfg, out, 0o644); err != nil {
+		return rewriteError(RewriteReasonWriteBundle, fmt.Errorf("write bundle: %w", err))
+	}
+	return nil
+}
+
+func (w *Wrapper) execProcessRewriter() ProcessRewriter {
+	if w.ExecProcessRewriter != nil {
+		return w.ExecProcessRewriter
+	}
+	return w.ProcessRewriter
+}
+
+func (w *Wrapper) rewriteExec(ctx context.Context, rewriter ProcessRewriter, c *Exec, tmpPath *string) (err error) {
+	_, span := tracer.Start(ctx, "process.rewrite", trace.WithAttributes(attribute.String("container_id", c.ContainerID)))
+	defer tracing.End(span, &err)
+	defer func() {
+		if err != nil {
+			err = rewriteError(RewriteReasonProcessFile, err)
+		}
+	}()
+
 	if c.Process != "" {
 		data, err := os.ReadFile(c.Process)
 		if err != nil {
@@ -213,8 +458,8 @@ func (w *Wrapper) rewriteExec(c *Exec, tmpPath *string) error {
 		if err := json.Unmarshal(data, &p); err != nil {
 			return fmt.Errorf("unmarshal process: %w", err)
 		}
-		if err := w.ProcessRewriter.RewriteProcess(&p); err != nil {
-			return err
+		if err := rewriter.RewriteProcess(&p); err != nil {
+			return rewriteError(RewriteReasonProcess, err)
 		}
 		out, err := json.MarshalIndent(&p, "", "  ")
 		if err != nil {
@@ -249,8 +494,8 @@ func (w *Wrapper) rewriteExec(c *Exec, tmpPath *string) error {
 			p.User.AdditionalGids[i] = uint32(g)
 		}
 	}
-	if err := w.ProcessRewriter.RewriteProcess(&p); err != nil {
-		return err
+	if err := rewriter.RewriteProcess(&p); err != nil {
+		return rewriteError(RewriteReasonProcess, err)
 	}
 	f, err := os.CreateTemp("", "process-*.json")
 	if err != nil {
@@ -0,0 +1,110 @@
+package runc
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	cli "github.com/TheGrizzlyDev/vino/internal/pkg/cli"
+	specs "github.com/opencontainers/runtime-spec/specs-go"
+)
+
+func TestRuncCommandsAccessors(t *testing.T) {
+	var cmds RuncCommands
+	args := []string{"--log", "/run/runc.log", "--log-format", "json", "create", "--bundle", "/bundle", "ctr"}
+	if err := cli.ParseAny(&cmds, args); err != nil {
+		t.Fatalf("ParseAny: %v", err)
+	}
+
+	active := cmds.Command()
+	if _, ok := active.(*Create); !ok {
+		t.Fatalf("Command() = %T, want *Create", active)
+	}
+	if got := cmds.Bundle(); got != "/bundle" {
+		t.Fatalf("Bundle() = %q, want %q", got, "/bundle")
+	}
+	if got := ContainerIDOf(active); got != "ctr" {
+		t.Fatalf("ContainerIDOf() = %q, want %q", got, "ctr")
+	}
+	global := GlobalOf(active)
+	if global.Log != "/run/runc.log" || global.LogFormat != "json" {
+		t.Fatalf("GlobalOf() = %#v", global)
+	}
+}
+
+func TestRuncCommandsAccessorsWithoutContainer(t *testing.T) {
+	var cmds RuncCommands
+	if err := cli.ParseAny(&cmds, []string{"--debug", "list"}); err != nil {
+		t.Fatalf("ParseAny: %v", err)
+	}
+	active := cmds.Command()
+	if got := ContainerIDOf(active); got != "" {
+		t.Fatalf("ContainerIDOf() = %q, want empty", got)
+	}
+	if got := cmds.Bundle(); got != "" {
+		t.Fatalf("Bundle() = %q, want empty", got)
+	}
+	if !GlobalOf(active).Debug {
+		t.Fatalf("GlobalOf().Debug = false, want true")
+	}
+	if got := (RuncCommands{}).Command(); got != nil {
+		t.Fatalf("empty Command() = %T, want nil", got)
+	}
+}
+
+type bundleRewriterFunc func(*specs.Spec) error
+
+func (f bundleRewriterFunc) RewriteBundle(s *specs.Spec) error { return f(s) }
+
+func TestWrapperRewriteErrors(t *testing.T) {
+	delegate, err := NewDelegatingCliClient("/bin/true")
+	if err != nil {
+		t.Fatalf("NewDelegatingCliClient: %v", err)
+	}
+	bundle := t.TempDir()
+	if err := os.WriteFile(filepath.Join(bundle, "config.json"), []byte(`{"ociVersion":"1.0.2"}`), 0o644); err != nil {
+		t.Fatalf("write bundle: %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		bundle     string
+		rewriter   BundleRewriter
+		wantReason string
+	}{
+		{
+			name:       "missing bundle",
+			bundle:     filepath.Join(bundle, "missing"),
+			rewriter:   bundleRewriterFunc(func(*specs.Spec) error { return nil }),
+			wantReason: RewriteReasonReadBundle,
+		},
+		{
+			name:       "rewriter failure",
+			bundle:     bundle,
+			rewriter:   bundleRewriterFunc(func(*specs.Spec) error { return errors.New("boom") }),
+			wantReason: RewriteReasonBundle,
+		},
+		{
+			name:   "rewriter reason preserved",
+			bundle: bundle,
+			rewriter: bundleRewriterFunc(func(*specs.Spec) error {
+				return &RewriteError{Reason: "annotations", Err: errors.New("bad label")}
+			}),
+			wantReason: "annotations",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := &Wrapper{BundleRewriter: tt.rewriter, Delegate: delegate}
+			err := w.Run(RuncCommands{Create: &Create{BundleOpt: BundleOpt{Bundle: tt.bundle}, ContainerID: "ctr"}})
+			var re *RewriteError
+			if !errors.As(err, &re) {
+				t.Fatalf("Run() = %v, want *RewriteError", err)
+			}
+			if re.Reason != tt.wantReason {
+				t.Fatalf("reason = %q, want %q", re.Reason, tt.wantReason)
+			}
+		})
+	}
+}
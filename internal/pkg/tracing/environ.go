@@ -0,0 +1,49 @@
+package tracing
+
+import (
+	"context"
+	"strings"
+
+	"go.opentelemetry.io/otel/propagation"
+)
+
+// Environment variables carrying a W3C trace context across process
+// boundaries, e.g. from vino into the OCI hooks it installs.
+const (
+	TraceParentEnv = "TRACEPARENT"
+	TraceStateEnv  = "TRACESTATE"
+)
+
+var envKeys = map[string]string{
+	"traceparent": TraceParentEnv,
+	"tracestate":  TraceStateEnv,
+}
+
+// Environ returns the KEY=VALUE assignments propagating the span in ctx, or
+// nil if ctx carries no valid span.
+func Environ(ctx context.Context) []string {
+	carrier := propagation.MapCarrier{}
+	propagation.TraceContext{}.Inject(ctx, carrier)
+	var env []string
+	for _, k := range []string{"traceparent", "tracestate"} {
+		if v := carrier.Get(k); v != "" {
+			env = append(env, envKeys[k]+"="+v)
+		}
+	}
+	return env
+}
+
+// FromEnviron returns ctx extended with the remote span context found in
+// environ, typically os.Environ().
+func FromEnviron(ctx context.Context, environ []string) context.Context {
+	carrier := propagation.MapCarrier{}
+	for _, kv := range environ {
+		k, v, _ := strings.Cut(kv, "=")
+		for header, env := range envKeys {
+			if k == env {
+				carrier.Set(header, v)
+			}
+		}
+	}
+	return propagation.TraceContext{}.Extract(ctx, carrier)
+}
@@ -0,0 +1,94 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace"
+	coltracepb "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	tracepb "go.opentelemetry.io/proto/otlp/trace/v1"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+var (
+	_ otlptrace.Client = &fileClient{}
+)
+
+// fileClient appends every upload as a single OTLP/JSON line to path, the
+// layout produced by the collector's file exporter.
+type fileClient struct {
+	path string
+}
+
+func (c *fileClient) Start(context.Context) error { return nil }
+
+func (c *fileClient) Stop(context.Context) error { return nil }
+
+func (c *fileClient) UploadTraces(_ context.Context, spans []*tracepb.ResourceSpans) error {
+	data, err := marshalOTLP(spans)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(c.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("open trace file: %w", err)
+	}
+	defer f.Close()
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("write trace file: %w", err)
+	}
+	return nil
+}
+
+// idFields are the fields OTLP/JSON writes in hexadecimal rather than in the
+// base64 of the protobuf JSON mapping.
+var idFields = map[string]bool{"traceId": true, "spanId": true, "parentSpanId": true}
+
+// marshalOTLP encodes spans as an OTLP/JSON ExportTraceServiceRequest.
+func marshalOTLP(spans []*tracepb.ResourceSpans) ([]byte, error) {
+	data, err := protojson.Marshal(&coltracepb.ExportTraceServiceRequest{ResourceSpans: spans})
+	if err != nil {
+		return nil, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var req any
+	if err := dec.Decode(&req); err != nil {
+		return nil, err
+	}
+	if err := hexIDs(req); err != nil {
+		return nil, err
+	}
+	return json.Marshal(req)
+}
+
+func hexIDs(v any) error {
+	switch v := v.(type) {
+	case map[string]any:
+		for k, e := range v {
+			if s, ok := e.(string); ok && idFields[k] {
+				id, err := base64.StdEncoding.DecodeString(s)
+				if err != nil {
+					return fmt.Errorf("decode %s: %w", k, err)
+				}
+				v[k] = hex.EncodeToString(id)
+				continue
+			}
+			if err := hexIDs(e); err != nil {
+				return err
+			}
+		}
+	case []any:
+		for _, e := range v {
+			if err := hexIDs(e); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,78 @@
+// Package tracing records spans of the container lifecycle handled by vino
+// with the OpenTelemetry SDK and exports them over OTLP, either posted to a
+// collector or appended to a file as OTLP/JSON.
+//
+// vino processes are short lived: spans are batched in memory and flushed
+// when the provider is shut down right before the process exits.
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Setup installs the W3C trace context propagator and a global
+// TracerProvider exporting to endpoint, and returns a function flushing the
+// recorded spans. The endpoint is either a file path (optionally as a
+// file:// URL) or an http(s):// collector URL; an empty endpoint leaves
+// tracing disabled.
+func Setup(endpoint, serviceName string) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	if endpoint == "" {
+		return func(context.Context) error { return nil }, nil
+	}
+	exp, err := newExporter(endpoint)
+	if err != nil {
+		return nil, err
+	}
+	p := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exp),
+		sdktrace.WithResource(resource.NewSchemaless(attribute.String("service.name", serviceName))),
+	)
+	otel.SetTracerProvider(p)
+	return p.Shutdown, nil
+}
+
+// End ends span, recording err as its failure cause when non-nil. It is meant
+// to be deferred with a pointer to a named error result.
+func End(span trace.Span, err *error) {
+	if err != nil && *err != nil {
+		span.RecordError(*err)
+		span.SetStatus(codes.Error, (*err).Error())
+	}
+	span.End()
+}
+
+func newExporter(endpoint string) (*otlptrace.Exporter, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("parse trace endpoint %q: %w", endpoint, err)
+	}
+	var client otlptrace.Client
+	switch strings.ToLower(u.Scheme) {
+	case "":
+		client = &fileClient{path: endpoint}
+	case "file":
+		client = &fileClient{path: u.Path}
+	case "http", "https":
+		if u.Path == "" || u.Path == "/" {
+			u.Path = "/v1/traces"
+		}
+		client = otlptracehttp.NewClient(otlptracehttp.WithEndpointURL(u.String()))
+	default:
+		return nil, fmt.Errorf("unsupported trace endpoint %q", endpoint)
+	}
+	return otlptrace.New(context.Background(), client)
+}
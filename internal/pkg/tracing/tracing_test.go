@@ -0,0 +1,178 @@
+package tracing
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	coltracepb "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestEndRecordsError(t *testing.T) {
+	exp := tracetest.NewInMemoryExporter()
+	p := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exp))
+	tr := p.Tracer("test")
+
+	ctx, parent := tr.Start(context.Background(), "vino create")
+	_, child := tr.Start(ctx, "bundle.rewrite")
+	err := errors.New("boom")
+	End(child, &err)
+	var ok error
+	End(parent, &ok)
+
+	spans := exp.GetSpans()
+	if len(spans) != 2 {
+		t.Fatalf("got %d spans, want 2", len(spans))
+	}
+	c, pa := spans[0], spans[1]
+	if c.Parent.SpanID() != pa.SpanContext.SpanID() {
+		t.Fatalf("child parent %s, want %s", c.Parent.SpanID(), pa.SpanContext.SpanID())
+	}
+	if c.Status.Code != codes.Error || c.Status.Description != "boom" {
+		t.Fatalf("child status = %v, want error boom", c.Status)
+	}
+	if len(c.Events) != 1 || c.Events[0].Name != "exception" {
+		t.Fatalf("child events = %#v, want one exception", c.Events)
+	}
+	if pa.Status.Code != codes.Unset || len(pa.Events) != 0 {
+		t.Fatalf("parent status = %v, events %#v; want none", pa.Status, pa.Events)
+	}
+}
+
+func TestEnvironRoundTrip(t *testing.T) {
+	p := sdktrace.NewTracerProvider()
+	ctx, span := p.Tracer("test").Start(context.Background(), "vino create")
+	defer span.End()
+
+	env := Environ(ctx)
+	if len(env) != 1 || !strings.HasPrefix(env[0], TraceParentEnv+"=") {
+		t.Fatalf("Environ = %q", env)
+	}
+
+	remote := trace.SpanContextFromContext(FromEnviron(context.Background(), append([]string{"PATH=/bin"}, env...)))
+	if remote.TraceID() != span.SpanContext().TraceID() || remote.SpanID() != span.SpanContext().SpanID() {
+		t.Fatalf("remote span %v, want %v", remote, span.SpanContext())
+	}
+	if !remote.IsRemote() {
+		t.Fatalf("span context extracted from environ is not remote")
+	}
+}
+
+func TestEnvironWithoutSpan(t *testing.T) {
+	if env := Environ(context.Background()); env != nil {
+		t.Fatalf("Environ = %q, want nil", env)
+	}
+}
+
+func TestFileExporter(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "traces.json")
+	exp, err := newExporter(path)
+	if err != nil {
+		t.Fatalf("newExporter: %v", err)
+	}
+	p := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exp))
+	ctx, parent := p.Tracer("test").Start(context.Background(), "vino create")
+	_, span := p.Tracer("test").Start(ctx, "delegate", trace.WithAttributes(attribute.Int("runc.exit_code", 1)))
+	span.End()
+	parent.End()
+	if err := p.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Shutdown: %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read traces: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("got %d lines, want one per export: %q", len(lines), data)
+	}
+	var req struct {
+		ResourceSpans []struct {
+			ScopeSpans []struct {
+				Spans []struct {
+					TraceID      string `json:"traceId"`
+					SpanID       string `json:"spanId"`
+					ParentSpanID string `json:"parentSpanId"`
+					Name         string `json:"name"`
+					Attributes   []struct {
+						Key   string `json:"key"`
+						Value struct {
+							IntValue string `json:"intValue"`
+						} `json:"value"`
+					} `json:"attributes"`
+				} `json:"spans"`
+			} `json:"scopeSpans"`
+		} `json:"resourceSpans"`
+	}
+	if err := json.Unmarshal([]byte(lines[0]), &req); err != nil {
+		t.Fatalf("decode %q: %v", lines[0], err)
+	}
+	s := req.ResourceSpans[0].ScopeSpans[0].Spans[0]
+	sc := span.SpanContext()
+	if s.Name != "delegate" || s.TraceID != sc.TraceID().String() || s.SpanID != sc.SpanID().String() || s.ParentSpanID != parent.SpanContext().SpanID().String() {
+		t.Fatalf("span = %+v, want delegate %s %s in hexadecimal", s, sc.TraceID(), sc.SpanID())
+	}
+	if len(s.Attributes) != 1 || s.Attributes[0].Key != "runc.exit_code" || s.Attributes[0].Value.IntValue != "1" {
+		t.Fatalf("attributes = %+v", s.Attributes)
+	}
+}
+
+func TestHTTPExporter(t *testing.T) {
+	var body []byte
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/traces" || r.Header.Get("Content-Type") != "application/x-protobuf" {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		body, _ = io.ReadAll(r.Body)
+	}))
+	defer srv.Close()
+
+	exp, err := newExporter(srv.URL)
+	if err != nil {
+		t.Fatalf("newExporter: %v", err)
+	}
+	p := sdktrace.NewTracerProvider(sdktrace.WithBatcher(exp))
+	_, span := p.Tracer("test").Start(context.Background(), "vino start")
+	span.End()
+	if err := p.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Shutdown: %v", err)
+	}
+	var req coltracepb.ExportTraceServiceRequest
+	if err := proto.Unmarshal(body, &req); err != nil {
+		t.Fatalf("decode body: %v", err)
+	}
+	if len(req.ResourceSpans) != 1 || req.ResourceSpans[0].ScopeSpans[0].Spans[0].Name != "vino start" {
+		t.Fatalf("unexpected request %v", &req)
+	}
+}
+
+func TestSetupDisabled(t *testing.T) {
+	shutdown, err := Setup("", "vino")
+	if err != nil {
+		t.Fatalf("Setup: %v", err)
+	}
+	if err := shutdown(context.Background()); err != nil {
+		t.Fatalf("shutdown: %v", err)
+	}
+}
+
+func TestSetupUnsupportedEndpoint(t *testing.T) {
+	if _, err := Setup("grpc://localhost:4317", "vino"); err == nil {
+		t.Fatalf("expected error, got nil")
+	}
+}
@@ -0,0 +1,51 @@
+package vino
+
+import (
+	"os"
+
+	"github.com/TheGrizzlyDev/vino/internal/pkg/vino/labels"
+)
+
+// Access is what the mode of a label grants the container on a host path.
+//
+//	mode        cgroup  family cgroup  mount options      node mode
+//	ro (or "")  r       r              rbind,ro,nosuid    0444
+//	rw          rw      rwm            rbind,rw,nosuid    0666
+//
+// The nodes of a device family are allowed by major, so that the container
+// can also create the ones of minors appearing after it started, e.g. a
+// second render node: rw families are granted mknod on top.
+type Access struct {
+	// Cgroup is the device cgroup access of a single node: r or rw.
+	Cgroup string
+	// FamilyCgroup is the device cgroup access of a whole major: r or rwm.
+	FamilyCgroup string
+	// MountOptions are the options of the path's bind mount.
+	MountOptions []string
+	// FileMode is the permissions of the device nodes created in the
+	// container.
+	FileMode os.FileMode
+}
+
+// DeviceAccess returns the access of device d. Devices are read-only unless
+// asked otherwise, but USB ones, which programs talk to both ways, default
+// to read-write.
+func DeviceAccess(d labels.Device) Access {
+	mode := d.Mode
+	if mode == "" && d.Class == labels.ClassUSB {
+		mode = labels.ModeRW
+	}
+	if mode == labels.ModeRW {
+		return Access{Cgroup: "rw", FamilyCgroup: "rwm", MountOptions: []string{"rbind", "rw", "nosuid"}, FileMode: 0o666}
+	}
+	return Access{Cgroup: "r", FamilyCgroup: "r", MountOptions: []string{"rbind", "ro", "nosuid"}, FileMode: 0o444}
+}
+
+// MountAccess returns the access of mount m, read-only unless asked
+// otherwise.
+func MountAccess(m labels.Mount) Access {
+	if m.Mode == labels.ModeRW {
+		return Access{MountOptions: []string{"rbind", "rw"}}
+	}
+	return Access{MountOptions: []string{"rbind", "ro"}}
+}
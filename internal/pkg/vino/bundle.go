@@ -3,9 +3,13 @@ package vino
 import (
 	"fmt"
 	"os"
+	"path/filepath"
+	"slices"
 
 	"github.com/TheGrizzlyDev/vino/internal/pkg/runc"
 	"github.com/TheGrizzlyDev/vino/internal/pkg/vino/labels"
+	"github.com/TheGrizzlyDev/vino/internal/pkg/vino/launcher"
+	"github.com/TheGrizzlyDev/vino/internal/pkg/vino/usb"
 	specs "github.com/opencontainers/runtime-spec/specs-go"
 	"golang.org/x/sys/unix"
 )
@@ -18,12 +22,30 @@ const (
 	VINO_HOOK_PATH_IN_CONTAINER = "/run/vino-hook"
 )
 
+// Rewrite failure reasons reported by BundleRewriter.
+const (
+	RewriteReasonAnnotations = "annotations"
+	RewriteReasonDevice      = "device"
+	RewriteReasonMount       = "mount"
+)
+
 type BundleRewriter struct {
 	HookPathBeforePivot     string
 	HookPathAfterPivot      string
 	CreateContainerHookArgs []string
 	StartContainerHookArgs  []string
 	RebindPaths             map[string]string
+	// SysRoot is where USB devices are looked up. Defaults to /sys.
+	SysRoot string
+	// ProcDevices lists the majors of device drivers. Defaults to
+	// /proc/devices.
+	ProcDevices string
+	// CgroupRoot is where the cgroup filesystem is mounted, to tell
+	// cgroup v2 apart. Defaults to /sys/fs/cgroup.
+	CgroupRoot string
+	// MainProcess rewrites the main process of the bundles rewritten: it is
+	// told the Windows service their annotations run it as.
+	MainProcess *ProcessRewriter
 }
 
 func (b *BundleRewriter) RewriteBundle(bundle *specs.Spec) error {
@@ -32,7 +54,14 @@ func (b *BundleRewriter) RewriteBundle(bundle *specs.Spec) error {
 	}
 	devices, mounts, err := labels.Parse(bundle.Annotations)
 	if err != nil {
-		return fmt.Errorf("parse annotations: %w", err)
+		return &runc.RewriteError{Reason: RewriteReasonAnnotations, Err: fmt.Errorf("parse annotations: %w", err)}
+	}
+	cfg, err := labels.ParseConfig(bundle.Annotations)
+	if err != nil {
+		return &runc.RewriteError{Reason: RewriteReasonAnnotations, Err: fmt.Errorf("parse annotations: %w", err)}
+	}
+	if b.MainProcess != nil {
+		b.MainProcess.service = cfg.Process.Service
 	}
 	if bundle.Linux == nil {
 		bundle.Linux = &specs.Linux{}
@@ -40,50 +69,50 @@ func (b *BundleRewriter) RewriteBundle(bundle *specs.Spec) error {
 	if bundle.Linux.Resources == nil {
 		bundle.Linux.Resources = &specs.LinuxResources{}
 	}
+	printerEnv, err := bindPrinters(bundle, mounts)
+	if err != nil {
+		return &runc.RewriteError{Reason: RewriteReasonMount, Err: err}
+	}
+	var audioEnv []string
+	if d, ok := audioDevice(devices); ok {
+		host, err := bindAudio(bundle, d)
+		if err != nil {
+			return &runc.RewriteError{Reason: RewriteReasonDevice, Err: err}
+		}
+		audioEnv = launcher.AudioEnviron(host)
+	}
+	if bundle.Process != nil {
+		env := append(launcher.Environ(cfg), audioEnv...)
+		env = append(env, printerEnv...)
+		// Without a display of their own, the container's processes share a
+		// virtual one started by the launcher of the main process.
+		_, display := lookupEnv(bundle.Process.Env, "DISPLAY")
+		_, xdg := lookupEnv(bundle.Process.Env, "XDG_RUNTIME_DIR")
+		if !display && !xdg {
+			env = append(env, launcher.DisplayEnviron(cfg.Display)...)
+		}
+		bundle.Process.Env = mergeEnv(bundle.Process.Env, env)
+	}
 
 	for _, d := range devices {
-		var st unix.Stat_t
-		if err := unix.Stat(d.Path, &st); err != nil {
-			if os.IsNotExist(err) && d.Optional {
-				continue
-			}
-			return fmt.Errorf("stat %s: %w", d.Path, err)
+		if d.Class == labels.ClassAudio {
+			continue
 		}
-
-		devType := "c"
-		if (st.Mode & unix.S_IFMT) == unix.S_IFBLK {
-			devType = "b"
+		if d.Class == labels.ClassUSB {
+			if err := b.addUSB(bundle, d); err != nil {
+				return &runc.RewriteError{Reason: RewriteReasonDevice, Err: err}
+			}
+			continue
 		}
-		major := int64(unix.Major(uint64(st.Rdev)))
-		minor := int64(unix.Minor(uint64(st.Rdev)))
-
-		bundle.Linux.Devices = append(bundle.Linux.Devices, specs.LinuxDevice{
-			Path:  d.Path,
-			Type:  devType,
-			Major: major,
-			Minor: minor,
-		})
-
-		access := "r"
-		if d.Mode != "r" {
-			access = d.Mode
+		if err := addDevice(bundle, d); err != nil {
+			return &runc.RewriteError{Reason: RewriteReasonDevice, Err: err}
 		}
-		bundle.Linux.Resources.Devices = append(bundle.Linux.Resources.Devices, specs.LinuxDeviceCgroup{
-			Allow:  true,
-			Type:   devType,
-			Major:  &major,
-			Minor:  &minor,
-			Access: access,
-		})
-		bundle.Mounts = append(bundle.Mounts, specs.Mount{
-			Destination: d.Path,
-			Type:        "bind",
-			Source:      d.Path,
-			Options:     []string{"rbind", access},
-		})
 	}
 
 	for _, m := range mounts {
+		if m.Type == labels.MountPrinter {
+			continue
+		}
 		src := m.SourcePath
 		if src == "" {
 			src = m.Volume
@@ -92,23 +121,19 @@ func (b *BundleRewriter) RewriteBundle(bundle *specs.Spec) error {
 			if m.Optional {
 				continue
 			}
-			return fmt.Errorf("mount %q missing source path and volume", m.DestinationLabel)
+			return &runc.RewriteError{Reason: RewriteReasonMount, Err: fmt.Errorf("mount %q missing source path and volume", m.DestinationLabel)}
 		}
 		if _, err := os.Stat(src); err != nil {
 			if os.IsNotExist(err) && m.Optional {
 				continue
 			}
-			return fmt.Errorf("stat %s: %w", src, err)
-		}
-		access := "ro"
-		if m.Mode != "ro" {
-			access = m.Mode
+			return &runc.RewriteError{Reason: RewriteReasonMount, Err: fmt.Errorf("stat %s: %w", src, err)}
 		}
-		bundle.Mounts = append(bundle.Mounts, specs.Mount{
+		addMount(bundle, specs.Mount{
 			Destination: src,
 			Type:        "bind",
 			Source:      src,
-			Options:     []string{"rbind", access},
+			Options:     MountAccess(m).MountOptions,
 		})
 	}
 	if bundle.Hooks == nil {
@@ -116,7 +141,7 @@ func (b *BundleRewriter) RewriteBundle(bundle *specs.Spec) error {
 	}
 
 	for rebindPathSrc, rebindPathDest := range b.RebindPaths {
-		bundle.Mounts = append(bundle.Mounts, specs.Mount{
+		addMount(bundle, specs.Mount{
 			Destination: rebindPathDest,
 			Type:        "bind",
 			Source:      rebindPathSrc,
@@ -124,10 +149,12 @@ func (b *BundleRewriter) RewriteBundle(bundle *specs.Spec) error {
 		})
 	}
 
-	bundle.Hooks.CreateContainer = append(bundle.Hooks.CreateContainer, specs.Hook{
-		Path: b.HookPathBeforePivot,
-		Args: append([]string{b.HookPathBeforePivot}, b.CreateContainerHookArgs...),
-	})
+	if !slices.ContainsFunc(bundle.Hooks.CreateContainer, func(h specs.Hook) bool { return h.Path == b.HookPathBeforePivot }) {
+		bundle.Hooks.CreateContainer = append(bundle.Hooks.CreateContainer, specs.Hook{
+			Path: b.HookPathBeforePivot,
+			Args: append([]string{b.HookPathBeforePivot}, b.CreateContainerHookArgs...),
+		})
+	}
 
 	// TODO: for some reason this doesn't work despite the bind to VINO_HOOK_PATH_IN_CONTAINER being present
 	// bundle.Hooks.StartContainer = append(bundle.Hooks.StartContainer, specs.Hook{
@@ -137,3 +164,260 @@ func (b *BundleRewriter) RewriteBundle(bundle *specs.Spec) error {
 
 	return nil
 }
+
+// audioDevice returns the container's audio device, if it asks for one.
+func audioDevice(devices []labels.Device) (labels.Device, bool) {
+	for _, d := range devices {
+		if d.Class == labels.ClassAudio {
+			return d, true
+		}
+	}
+	return labels.Device{}, false
+}
+
+// bindAudio binds the PulseAudio socket d points at into the container and
+// reports whether it did. Without a path, or without the socket when it is
+// optional, the container gets a null sink instead.
+func bindAudio(bundle *specs.Spec, d labels.Device) (bool, error) {
+	if d.Path == "" {
+		return false, nil
+	}
+	var st unix.Stat_t
+	if err := unix.Stat(d.Path, &st); err != nil {
+		if os.IsNotExist(err) && d.Optional {
+			return false, nil
+		}
+		return false, fmt.Errorf("stat %s: %w", d.Path, err)
+	}
+	if st.Mode&unix.S_IFMT != unix.S_IFSOCK {
+		return false, fmt.Errorf("audio device %s is not a socket", d.Path)
+	}
+	addMount(bundle, specs.Mount{
+		Destination: launcher.AudioSocket,
+		Type:        "bind",
+		Source:      d.Path,
+		Options:     []string{"rbind", "rw"},
+	})
+	return true, nil
+}
+
+// addDevice gives the container the node, or the family of nodes, of
+// device d at the same path.
+func addDevice(bundle *specs.Spec, d labels.Device) error {
+	access := DeviceAccess(d)
+	if !d.Family() {
+		var st unix.Stat_t
+		if err := unix.Stat(d.Path, &st); err != nil {
+			if os.IsNotExist(err) && d.Optional {
+				return nil
+			}
+			return fmt.Errorf("stat %s: %w", d.Path, err)
+		}
+		addNode(bundle, d.Path, &st, access)
+		return nil
+	}
+
+	paths, err := filepath.Glob(d.Path)
+	if err != nil {
+		return fmt.Errorf("device family %s: %w", d.Path, err)
+	}
+	type family struct {
+		devType string
+		major   int64
+	}
+	var families []family
+	for _, path := range paths {
+		var st unix.Stat_t
+		if err := unix.Stat(path, &st); err != nil {
+			continue
+		}
+		devType, major, minor, ok := deviceNumbers(&st)
+		if !ok {
+			// Not a node, e.g. /dev/dri/by-path.
+			continue
+		}
+		appendDevice(bundle, specs.LinuxDevice{Path: path, Type: devType, Major: major, Minor: minor, FileMode: &access.FileMode})
+		addMount(bundle, bindMount(path, access))
+		if f := (family{devType, major}); !slices.Contains(families, f) {
+			families = append(families, f)
+		}
+	}
+	if len(families) == 0 {
+		if d.Optional {
+			return nil
+		}
+		return fmt.Errorf("device family %s matches no device node", d.Path)
+	}
+	for _, f := range families {
+		allowDevice(bundle, f.devType, f.major, nil, access.FamilyCgroup)
+	}
+	return nil
+}
+
+// addNode binds path into the container and, when st is a device node,
+// gives the container access to it. FIFOs and files, like the sinks of LPT
+// ports, are only bound.
+func addNode(bundle *specs.Spec, path string, st *unix.Stat_t, access Access) {
+	if devType, major, minor, ok := deviceNumbers(st); ok {
+		appendDevice(bundle, specs.LinuxDevice{Path: path, Type: devType, Major: major, Minor: minor, FileMode: &access.FileMode})
+		allowDevice(bundle, devType, major, &minor, access.Cgroup)
+	}
+	addMount(bundle, bindMount(path, access))
+}
+
+// deviceNumbers returns the type and numbers of st, or false when it isn't
+// a character or block device.
+func deviceNumbers(st *unix.Stat_t) (devType string, major, minor int64, ok bool) {
+	switch st.Mode & unix.S_IFMT {
+	case unix.S_IFCHR:
+		devType = "c"
+	case unix.S_IFBLK:
+		devType = "b"
+	default:
+		return "", 0, 0, false
+	}
+	return devType, int64(unix.Major(uint64(st.Rdev))), int64(unix.Minor(uint64(st.Rdev))), true
+}
+
+func bindMount(path string, access Access) specs.Mount {
+	return specs.Mount{Destination: path, Type: "bind", Source: path, Options: access.MountOptions}
+}
+
+// appendDevice adds d to the nodes runc creates, unless a bundle rewritten
+// already has a node at its path.
+func appendDevice(bundle *specs.Spec, d specs.LinuxDevice) {
+	for _, e := range bundle.Linux.Devices {
+		if e.Path == d.Path {
+			return
+		}
+	}
+	bundle.Linux.Devices = append(bundle.Linux.Devices, d)
+}
+
+// allowDevice adds a device cgroup rule allowing access to the node
+// devType major:minor, or to all the nodes of major when minor is nil,
+// unless the same rule is there already.
+func allowDevice(bundle *specs.Spec, devType string, major int64, minor *int64, access string) {
+	for _, r := range bundle.Linux.Resources.Devices {
+		if r.Allow && r.Type == devType && r.Access == access &&
+			r.Major != nil && *r.Major == major &&
+			(r.Minor == nil) == (minor == nil) && (minor == nil || *r.Minor == *minor) {
+			return
+		}
+	}
+	bundle.Linux.Resources.Devices = append(bundle.Linux.Resources.Devices, specs.LinuxDeviceCgroup{
+		Allow:  true,
+		Type:   devType,
+		Major:  &major,
+		Minor:  minor,
+		Access: access,
+	})
+}
+
+// addMount adds m, unless a bundle rewritten already mounts something at
+// its destination.
+func addMount(bundle *specs.Spec, m specs.Mount) {
+	for _, e := range bundle.Mounts {
+		if e.Destination == m.Destination {
+			return
+		}
+	}
+	bundle.Mounts = append(bundle.Mounts, m)
+}
+
+// bindPrinters binds the sinks of printer mounts into the container and
+// returns the environment spooling the jobs of the LPT ports backed by a
+// CUPS socket.
+func bindPrinters(bundle *specs.Spec, mounts []labels.Mount) ([]string, error) {
+	var server string
+	var ports []string
+	for _, m := range mounts {
+		if m.Type != labels.MountPrinter {
+			continue
+		}
+		var st unix.Stat_t
+		if err := unix.Stat(m.SourcePath, &st); err != nil {
+			if os.IsNotExist(err) && m.Optional {
+				continue
+			}
+			return nil, fmt.Errorf("stat %s: %w", m.SourcePath, err)
+		}
+		if st.Mode&unix.S_IFMT == unix.S_IFSOCK {
+			if server != "" && server != m.SourcePath {
+				return nil, fmt.Errorf("printer %s: only one CUPS socket is supported, %s is already used", m.DestinationLabel, server)
+			}
+			ports = append(ports, m.DestinationLabel)
+			if server != "" {
+				continue
+			}
+			server = m.SourcePath
+		}
+		addNode(bundle, m.SourcePath, &st, DeviceAccess(labels.Device{Mode: labels.ModeRW}))
+	}
+	if server == "" {
+		return nil, nil
+	}
+	slices.Sort(ports)
+	return launcher.PrinterEnviron(server, ports), nil
+}
+
+// addUSB gives the container the nodes of the USB device d plugged in now.
+// `vino hotplug` allows the ones it adds later one by one on cgroup v1; on
+// cgroup v2, where a running container can't be allowed a single node, the
+// majors they come from are allowed instead, with the same access.
+func (b *BundleRewriter) addUSB(bundle *specs.Spec, d labels.Device) error {
+	id, err := usb.ParseID(d.ID)
+	if err != nil {
+		return err
+	}
+	sysRoot := b.SysRoot
+	if sysRoot == "" {
+		sysRoot = "/sys"
+	}
+	nodes, err := usb.Resolve(sysRoot, id)
+	if err != nil {
+		return fmt.Errorf("resolve usb device %s: %w", id, err)
+	}
+	if len(nodes) == 0 && !d.Optional {
+		return fmt.Errorf("usb device %s is not plugged in", id)
+	}
+
+	access := DeviceAccess(d)
+	var majors []int64
+	for _, n := range nodes {
+		minor := n.Minor
+		appendDevice(bundle, specs.LinuxDevice{Path: n.Path, Type: "c", Major: n.Major, Minor: minor, FileMode: &access.FileMode})
+		allowDevice(bundle, "c", n.Major, &minor, access.Cgroup)
+		if !slices.Contains(majors, n.Major) {
+			majors = append(majors, n.Major)
+		}
+	}
+	if !b.cgroupV2() {
+		return nil
+	}
+
+	// A device plugged back in comes with the same kinds of nodes; one not
+	// plugged in yet may come with any.
+	if len(majors) == 0 {
+		procDevices := b.ProcDevices
+		if procDevices == "" {
+			procDevices = "/proc/devices"
+		}
+		majors = usb.ProcMajors(procDevices, usb.HotplugMajors)
+	}
+	for _, major := range majors {
+		allowDevice(bundle, "c", major, nil, access.Cgroup)
+	}
+	return nil
+}
+
+// cgroupV2 tells whether the containers' cgroups are of the unified
+// hierarchy.
+func (b *BundleRewriter) cgroupV2() bool {
+	root := b.CgroupRoot
+	if root == "" {
+		root = "/sys/fs/cgroup"
+	}
+	_, err := os.Stat(filepath.Join(root, "cgroup.controllers"))
+	return err == nil
+}
@@ -1,13 +1,19 @@
 package vino
 
 import (
+	"encoding/json"
 	"fmt"
+	"net"
 	"os"
 	"path/filepath"
+	"slices"
 	"testing"
 
 	specs "github.com/opencontainers/runtime-spec/specs-go"
 	"golang.org/x/sys/unix"
+
+	"github.com/TheGrizzlyDev/vino/internal/pkg/vino/hook"
+	"github.com/TheGrizzlyDev/vino/internal/pkg/vino/labels"
 )
 
 func contains(opts []string, target string) bool {
@@ -105,3 +111,408 @@ func TestBundleRewriterAddsDevicesAndMounts(t *testing.T) {
 		t.Fatalf("mount duplicated: %d", countMount)
 	}
 }
+
+func TestBundleRewriterMapsDeviceAccess(t *testing.T) {
+	for _, tc := range []struct {
+		mode     string
+		cgroup   string
+		options  []string
+		fileMode os.FileMode
+	}{
+		{"", "r", []string{"rbind", "ro", "nosuid"}, 0o444},
+		{"ro", "r", []string{"rbind", "ro", "nosuid"}, 0o444},
+		{"rw", "rw", []string{"rbind", "rw", "nosuid"}, 0o666},
+	} {
+		t.Run("mode="+tc.mode, func(t *testing.T) {
+			device := `{"class":"com","path":"/dev/null","label":"COM1"}`
+			if tc.mode != "" {
+				device = `{"class":"com","path":"/dev/null","label":"COM1","mode":"` + tc.mode + `"}`
+			}
+			spec := &specs.Spec{Annotations: map[string]string{
+				"dev.vinoc.devices.dev0": device,
+			}}
+			br := &BundleRewriter{HookPathBeforePivot: "/hook"}
+			if err := br.RewriteBundle(spec); err != nil {
+				t.Fatalf("rewrite bundle: %v", err)
+			}
+			if len(spec.Linux.Devices) != 1 || spec.Linux.Devices[0].FileMode == nil || *spec.Linux.Devices[0].FileMode != tc.fileMode {
+				t.Errorf("devices = %+v, want /dev/null with mode %v", spec.Linux.Devices, tc.fileMode)
+			}
+			if rules := spec.Linux.Resources.Devices; len(rules) != 1 || rules[0].Access != tc.cgroup || rules[0].Minor == nil {
+				t.Errorf("cgroup rules = %+v, want /dev/null allowed %q", rules, tc.cgroup)
+			}
+			if len(spec.Mounts) != 1 || fmt.Sprint(spec.Mounts[0].Options) != fmt.Sprint(tc.options) {
+				t.Errorf("mounts = %+v, want /dev/null bound %v", spec.Mounts, tc.options)
+			}
+		})
+	}
+}
+
+func TestBundleRewriterAddsDeviceFamilies(t *testing.T) {
+	newSpec := func(device string) *specs.Spec {
+		return &specs.Spec{Annotations: map[string]string{
+			"dev.vinoc.devices.mem": device,
+		}}
+	}
+	br := &BundleRewriter{HookPathBeforePivot: "/hook"}
+
+	// /dev/null and /dev/zero, both of the mem driver.
+	spec := newSpec(`{"class":"gpu","path":"/dev/[nz][ue][lr][lo]","label":"GPU0","mode":"rw"}`)
+	if err := br.RewriteBundle(spec); err != nil {
+		t.Fatalf("rewrite bundle: %v", err)
+	}
+	var paths []string
+	for _, d := range spec.Linux.Devices {
+		paths = append(paths, d.Path)
+	}
+	if want := []string{"/dev/null", "/dev/zero"}; fmt.Sprint(paths) != fmt.Sprint(want) {
+		t.Errorf("devices = %v, want %v", paths, want)
+	}
+	var rules []string
+	for _, r := range spec.Linux.Resources.Devices {
+		minor := "*"
+		if r.Minor != nil {
+			minor = fmt.Sprint(*r.Minor)
+		}
+		rules = append(rules, fmt.Sprintf("%s %d:%s %s", r.Type, *r.Major, minor, r.Access))
+	}
+	if want := []string{"c 1:* rwm"}; fmt.Sprint(rules) != fmt.Sprint(want) {
+		t.Errorf("cgroup rules = %v, want %v", rules, want)
+	}
+	if len(spec.Mounts) != 2 {
+		t.Errorf("mounts = %+v, want both nodes bound", spec.Mounts)
+	}
+
+	if err := br.RewriteBundle(spec); err != nil {
+		t.Fatalf("second rewrite: %v", err)
+	}
+	if len(spec.Linux.Devices) != 2 || len(spec.Linux.Resources.Devices) != 1 || len(spec.Mounts) != 2 || len(spec.Hooks.CreateContainer) != 1 {
+		t.Errorf("second rewrite duplicated the spec: %+v", spec.Linux)
+	}
+
+	spec = newSpec(`{"class":"gpu","path":"/nonexistent/*","label":"GPU0","optional":true}`)
+	if err := br.RewriteBundle(spec); err != nil {
+		t.Fatalf("rewrite bundle: %v", err)
+	}
+	if len(spec.Linux.Devices) != 0 || len(spec.Linux.Resources.Devices) != 0 {
+		t.Errorf("empty optional family added %+v", spec.Linux)
+	}
+	if err := br.RewriteBundle(newSpec(`{"class":"gpu","path":"/nonexistent/*","label":"GPU0"}`)); err == nil {
+		t.Errorf("rewrite bundle accepted an empty family")
+	}
+}
+
+func TestBundleRewriterPassesProcessConfig(t *testing.T) {
+	// A bundle needs neither devices nor mounts for its process settings.
+	spec := &specs.Spec{
+		Annotations: map[string]string{
+			"dev.vinoc.process.kind":         "gui",
+			"dev.vinoc.process.stop_timeout": "30s",
+		},
+		Process: &specs.Process{Env: []string{"PATH=/usr/bin", "VINO_PROCESS_KIND=console"}},
+	}
+	br := &BundleRewriter{HookPathBeforePivot: "/hook"}
+	if err := br.RewriteBundle(spec); err != nil {
+		t.Fatalf("rewrite bundle: %v", err)
+	}
+	want := []string{"PATH=/usr/bin", "VINO_PROCESS_KIND=gui", "VINO_STOP_TIMEOUT=30s", "DISPLAY=:99", "VINO_DISPLAY_SCREEN=1280x1024x24"}
+	if fmt.Sprint(spec.Process.Env) != fmt.Sprint(want) {
+		t.Errorf("env = %v, want %v", spec.Process.Env, want)
+	}
+}
+
+func TestBundleRewriterKeepsImageDisplay(t *testing.T) {
+	spec := &specs.Spec{
+		Annotations: map[string]string{
+			"dev.vinoc.display.resolution": "1920x1080",
+		},
+		Process: &specs.Process{Env: []string{"DISPLAY=host:0"}},
+	}
+	br := &BundleRewriter{HookPathBeforePivot: "/hook"}
+	if err := br.RewriteBundle(spec); err != nil {
+		t.Fatalf("rewrite bundle: %v", err)
+	}
+	if want := []string{"DISPLAY=host:0"}; fmt.Sprint(spec.Process.Env) != fmt.Sprint(want) {
+		t.Errorf("env = %v, want %v", spec.Process.Env, want)
+	}
+}
+
+func TestBundleRewriterAddsAudio(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "native")
+	l, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer l.Close()
+
+	tests := []struct {
+		name      string
+		device    string
+		wantEnv   []string
+		wantMount bool
+		wantErr   bool
+	}{
+		{
+			name:      "host socket",
+			device:    `{"class":"audio","path":"` + socket + `"}`,
+			wantEnv:   []string{"VINO_AUDIO=host", "PULSE_SERVER=unix:/run/vino-audio/native"},
+			wantMount: true,
+		},
+		{
+			name:    "null sink",
+			device:  `{"class":"audio"}`,
+			wantEnv: []string{"VINO_AUDIO=null", "PULSE_SERVER=unix:/tmp/.vino-audio/native"},
+		},
+		{
+			name:    "optional socket missing",
+			device:  `{"class":"audio","path":"/nonexistent/native","optional":true}`,
+			wantEnv: []string{"VINO_AUDIO=null", "PULSE_SERVER=unix:/tmp/.vino-audio/native"},
+		},
+		{
+			name:    "not a socket",
+			device:  `{"class":"audio","path":"/dev/null"}`,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			spec := &specs.Spec{
+				Annotations: map[string]string{
+					"dev.vinoc.devices.snd": tt.device,
+				},
+				Process: &specs.Process{Env: []string{"DISPLAY=:0"}},
+			}
+			br := &BundleRewriter{HookPathBeforePivot: "/hook"}
+			err := br.RewriteBundle(spec)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("rewrite bundle succeeded, want error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("rewrite bundle: %v", err)
+			}
+			want := append([]string{"DISPLAY=:0"}, tt.wantEnv...)
+			if fmt.Sprint(spec.Process.Env) != fmt.Sprint(want) {
+				t.Errorf("env = %v, want %v", spec.Process.Env, want)
+			}
+			mounted := false
+			for _, m := range spec.Mounts {
+				if m.Destination == "/run/vino-audio/native" && m.Source == socket {
+					mounted = true
+				}
+			}
+			if mounted != tt.wantMount {
+				t.Errorf("socket mounted = %v, want %v", mounted, tt.wantMount)
+			}
+			if len(spec.Linux.Devices) != 0 {
+				t.Errorf("audio device added as a device node: %v", spec.Linux.Devices)
+			}
+		})
+	}
+}
+
+func TestBundleRewriterAddsPrinters(t *testing.T) {
+	dir := t.TempDir()
+	socket := filepath.Join(dir, "cups.sock")
+	l, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer l.Close()
+	fifo := filepath.Join(dir, "receipts")
+	if err := unix.Mkfifo(fifo, 0o666); err != nil {
+		t.Fatalf("mkfifo: %v", err)
+	}
+
+	spec := &specs.Spec{
+		Annotations: map[string]string{
+			"dev.vinoc.devices.lp":     `{"class":"lpt","path":"` + fifo + `","label":"LPT1","mode":"rw"}`,
+			"dev.vinoc.mounts.office":  `{"type":"printer","source_path":"` + socket + `","destination_label":"LPT3"}`,
+			"dev.vinoc.mounts.labels":  `{"type":"printer","source_path":"` + socket + `","destination_label":"LPT2"}`,
+			"dev.vinoc.mounts.missing": `{"type":"printer","source_path":"/nonexistent","destination_label":"LPT4","optional":true}`,
+		},
+		Process: &specs.Process{Env: []string{"DISPLAY=:0"}},
+	}
+	br := &BundleRewriter{HookPathBeforePivot: "/hook"}
+	if err := br.RewriteBundle(spec); err != nil {
+		t.Fatalf("rewrite bundle: %v", err)
+	}
+
+	want := []string{"DISPLAY=:0", "CUPS_SERVER=" + socket, "VINO_PRINTER_SPOOL=lpt2,lpt3"}
+	if fmt.Sprint(spec.Process.Env) != fmt.Sprint(want) {
+		t.Errorf("env = %v, want %v", spec.Process.Env, want)
+	}
+	if len(spec.Linux.Devices) != 0 {
+		t.Errorf("sinks added as device nodes: %v", spec.Linux.Devices)
+	}
+	counts := map[string]int{}
+	for _, m := range spec.Mounts {
+		counts[m.Destination]++
+		if !contains(m.Options, "rw") {
+			t.Errorf("mount %s options = %v, want rw", m.Destination, m.Options)
+		}
+	}
+	if counts[socket] != 1 || counts[fifo] != 1 || len(counts) != 2 {
+		t.Errorf("mounts = %v, want the socket and the FIFO once each", counts)
+	}
+}
+
+// newSysfs returns a sysfs with the USB device 096e:0006 plugged in, and
+// its /proc/devices as proc-devices.
+func newSysfs(t *testing.T) string {
+	t.Helper()
+	sys := t.TempDir()
+	dev := filepath.Join(sys, "devices", "usb1", "1-1")
+	hidraw := filepath.Join(dev, "1-1:1.0", "hidraw", "hidraw0")
+	for path, data := range map[string]string{
+		filepath.Join(dev, "idVendor"):     "096e\n",
+		filepath.Join(dev, "idProduct"):    "0006\n",
+		filepath.Join(dev, "uevent"):       "MAJOR=189\nMINOR=1\nDEVNAME=bus/usb/001/002\n",
+		filepath.Join(hidraw, "uevent"):    "MAJOR=245\nMINOR=0\nDEVNAME=hidraw0\n",
+		filepath.Join(sys, "proc-devices"): "Character devices:\n189 usb_device\n245 hidraw\n",
+	} {
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.MkdirAll(filepath.Join(sys, "bus", "usb", "devices"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(dev, filepath.Join(sys, "bus", "usb", "devices", "1-1")); err != nil {
+		t.Fatal(err)
+	}
+	return sys
+}
+
+func TestBundleRewriterAddsUSB(t *testing.T) {
+	sys := newSysfs(t)
+
+	newSpec := func(device string) *specs.Spec {
+		return &specs.Spec{Annotations: map[string]string{
+			"dev.vinoc.devices.dongle": device,
+		}}
+	}
+	cgroup := t.TempDir()
+	br := &BundleRewriter{HookPathBeforePivot: "/hook", SysRoot: sys, ProcDevices: filepath.Join(sys, "proc-devices"), CgroupRoot: cgroup}
+	rulesOf := func(spec *specs.Spec) []string {
+		var rules []string
+		for _, r := range spec.Linux.Resources.Devices {
+			minor := "*"
+			if r.Minor != nil {
+				minor = fmt.Sprint(*r.Minor)
+			}
+			rules = append(rules, fmt.Sprintf("%s %d:%s %s", r.Type, *r.Major, minor, r.Access))
+		}
+		return rules
+	}
+
+	spec := newSpec(`{"class":"usb","id":"096e:0006","label":"COM3"}`)
+	if err := br.RewriteBundle(spec); err != nil {
+		t.Fatalf("rewrite bundle: %v", err)
+	}
+	var paths []string
+	for _, d := range spec.Linux.Devices {
+		paths = append(paths, fmt.Sprintf("%s %d:%d", d.Path, d.Major, d.Minor))
+	}
+	if want := []string{"/dev/hidraw0 245:0", "/dev/bus/usb/001/002 189:1"}; fmt.Sprint(paths) != fmt.Sprint(want) {
+		t.Errorf("devices = %v, want %v", paths, want)
+	}
+	// On cgroup v1, hotplug allows the nodes it adds one by one.
+	if rules, want := rulesOf(spec), []string{"c 245:0 rw", "c 189:1 rw"}; fmt.Sprint(rules) != fmt.Sprint(want) {
+		t.Errorf("cgroup rules = %v, want %v", rules, want)
+	}
+	spec = newSpec(`{"class":"usb","id":"096e:0006","mode":"ro"}`)
+	if err := br.RewriteBundle(spec); err != nil {
+		t.Fatalf("rewrite bundle: %v", err)
+	}
+	if rules, want := rulesOf(spec), []string{"c 245:0 r", "c 189:1 r"}; fmt.Sprint(rules) != fmt.Sprint(want) {
+		t.Errorf("read-only cgroup rules = %v, want %v", rules, want)
+	}
+
+	// Unplugged at create time: fine when optional, hotplug adds it later.
+	spec = newSpec(`{"class":"usb","id":"1234:5678","optional":true}`)
+	if err := br.RewriteBundle(spec); err != nil {
+		t.Fatalf("rewrite bundle: %v", err)
+	}
+	if len(spec.Linux.Devices) != 0 || len(spec.Linux.Resources.Devices) != 0 {
+		t.Errorf("devices = %v, rules = %v; want none", spec.Linux.Devices, spec.Linux.Resources.Devices)
+	}
+
+	// On cgroup v2, the majors of its nodes are allowed too, with the same
+	// access; all the USB ones when it is unplugged.
+	if err := os.WriteFile(filepath.Join(cgroup, "cgroup.controllers"), []byte("devices\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	spec = newSpec(`{"class":"usb","id":"096e:0006","label":"COM3"}`)
+	if err := br.RewriteBundle(spec); err != nil {
+		t.Fatalf("rewrite bundle: %v", err)
+	}
+	if rules, want := rulesOf(spec), []string{"c 245:0 rw", "c 189:1 rw", "c 245:* rw", "c 189:* rw"}; fmt.Sprint(rules) != fmt.Sprint(want) {
+		t.Errorf("cgroup v2 rules = %v, want %v", rules, want)
+	}
+	spec = newSpec(`{"class":"usb","id":"1234:5678","optional":true}`)
+	if err := br.RewriteBundle(spec); err != nil {
+		t.Fatalf("rewrite bundle: %v", err)
+	}
+	if rules, want := rulesOf(spec), []string{"c 189:* rw", "c 245:* rw"}; fmt.Sprint(rules) != fmt.Sprint(want) {
+		t.Errorf("cgroup v2 rules of an unplugged device = %v, want %v", rules, want)
+	}
+	if err := br.RewriteBundle(newSpec(`{"class":"usb","id":"1234:5678"}`)); err == nil {
+		t.Errorf("rewrite bundle accepted a missing device")
+	}
+}
+
+// TestBundleRewriterLinksUSB rewrites a bundle asking for a USB device
+// plugged in already, and runs the create hook's part: the label points at
+// a node the bundle gives the container.
+func TestBundleRewriterLinksUSB(t *testing.T) {
+	sys := newSysfs(t)
+	spec := &specs.Spec{
+		Annotations: map[string]string{"dev.vinoc.devices.dongle": `{"class":"usb","id":"096e:0006","label":"COM3"}`},
+		Process:     &specs.Process{Env: []string{"WINEPREFIX=/prefix"}},
+		Root:        &specs.Root{Path: "rootfs"},
+	}
+	br := &BundleRewriter{HookPathBeforePivot: "/hook", SysRoot: sys, CgroupRoot: t.TempDir()}
+	if err := br.RewriteBundle(spec); err != nil {
+		t.Fatalf("rewrite bundle: %v", err)
+	}
+	if len(spec.Hooks.CreateContainer) != 1 {
+		t.Fatalf("create hooks = %v, want vino's", spec.Hooks.CreateContainer)
+	}
+
+	bundle := t.TempDir()
+	data, err := json.Marshal(spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(bundle, "config.json"), data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(bundle, "rootfs", "prefix"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	c, err := hook.FromBundle(bundle)
+	if err != nil {
+		t.Fatalf("FromBundle: %v", err)
+	}
+	c.SysRoot = sys
+	devs, _, err := labels.Parse(spec.Annotations)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.LinkUSB(devs); err != nil {
+		t.Fatalf("LinkUSB: %v", err)
+	}
+	target, err := os.Readlink(filepath.Join(bundle, "rootfs", "prefix", "dosdevices", "com3"))
+	if err != nil {
+		t.Fatalf("com3 not linked: %v", err)
+	}
+	if !slices.ContainsFunc(spec.Linux.Devices, func(d specs.LinuxDevice) bool { return d.Path == target }) {
+		t.Errorf("com3 -> %s, want one of the nodes %v", target, spec.Linux.Devices)
+	}
+}
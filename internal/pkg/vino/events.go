@@ -0,0 +1,129 @@
+package vino
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/TheGrizzlyDev/vino/internal/pkg/runc"
+	"github.com/TheGrizzlyDev/vino/internal/pkg/vino/events"
+	"github.com/TheGrizzlyDev/vino/internal/pkg/vino/launcher"
+)
+
+var (
+	_ runc.EventRewriter = &EventRewriter{}
+)
+
+// EventRewriter interleaves the events recorded in a container's journal
+// with the delegate's, and tells which Windows processes the delegate's
+// "oom" events killed: those gone since the previous statistics.
+type EventRewriter struct {
+	// ProcRoot is where the container's processes are looked up. Defaults
+	// to /proc.
+	ProcRoot string
+	// Poll is how often the journal is read. Defaults to a second.
+	Poll time.Duration
+}
+
+func (r EventRewriter) RewriteEvents(ctx context.Context, s *runc.EventStream, emit func(runc.Event) error) error {
+	ctx, cancel := context.WithCancel(ctx)
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	defer cancel()
+
+	// A container that can't be found is for the delegate to report.
+	if state, err := s.Client.State(ctx, s.ID); err == nil && state.Pid > 0 {
+		root := filepath.Join(r.procRoot(), strconv.Itoa(state.Pid), "root")
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_ = events.Follow(ctx, root, events.JournalPath, r.poll(), func(rec events.Record) error {
+				// The container writes the journal: it doesn't get to
+				// forge the delegate's events.
+				if !strings.HasPrefix(rec.Type, events.TypePrefix) {
+					return nil
+				}
+				return emit(runc.Event{Type: rec.Type, ID: s.ID, Data: rec.Data})
+			})
+		}()
+	}
+
+	procs := r.windowsProcesses(ctx, s)
+	for ev, err := range s.Delegate {
+		if err != nil {
+			return err
+		}
+		if err := emit(ev); err != nil {
+			return err
+		}
+		switch ev.Type {
+		case "stats":
+			procs = r.windowsProcesses(ctx, s)
+		case "oom":
+			now := r.windowsProcesses(ctx, s)
+			var killed []events.Process
+			for pid, image := range procs {
+				if _, ok := now[pid]; !ok {
+					killed = append(killed, events.Process{Pid: pid, Image: image})
+				}
+			}
+			procs = now
+			if len(killed) == 0 {
+				continue
+			}
+			slices.SortFunc(killed, func(a, b events.Process) int { return a.Pid - b.Pid })
+			data, err := json.Marshal(events.OOM{Processes: killed})
+			if err != nil {
+				return err
+			}
+			if err := emit(runc.Event{Type: events.TypeOOM, ID: s.ID, Data: data}); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// windowsProcesses returns the image of each Windows process of the
+// container, by pid, or nothing if the delegate can't list them.
+func (r EventRewriter) windowsProcesses(ctx context.Context, s *runc.EventStream) map[int]string {
+	pids, err := s.Client.Ps(ctx, s.ID)
+	if err != nil {
+		return nil
+	}
+	procs := map[int]string{}
+	for _, pid := range pids {
+		cmdline, err := os.ReadFile(filepath.Join(r.procRoot(), strconv.Itoa(pid), "cmdline"))
+		if err != nil {
+			continue
+		}
+		// wine rewrites the command line of Windows processes to their
+		// Windows one, starting with a drive.
+		arg0, _, _ := bytes.Cut(cmdline, []byte{0})
+		if strings.Contains(string(arg0), `:\`) {
+			procs[pid] = launcher.ImageName(string(arg0))
+		}
+	}
+	return procs
+}
+
+func (r EventRewriter) procRoot() string {
+	if r.ProcRoot != "" {
+		return r.ProcRoot
+	}
+	return "/proc"
+}
+
+func (r EventRewriter) poll() time.Duration {
+	if r.Poll > 0 {
+		return r.Poll
+	}
+	return time.Second
+}
@@ -0,0 +1,231 @@
+// Package events records what happens to a container at the Windows level,
+// for `runc events` to report next to the delegate's events.
+//
+// The processes that see it happen run on both sides of pivot_root: the
+// launchers inside the container, `vino hotplug` outside of it. They append
+// records, one JSON object per line, to the container's journal at
+// JournalPath, and the wrapper follows the journal while it streams the
+// delegate's events, through /proc/<pid>/root. The journal is resolved
+// inside the container's root, where the container controls it.
+package events
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"golang.org/x/sys/unix"
+
+	"github.com/TheGrizzlyDev/vino/internal/pkg/vino/rootfs"
+	"github.com/TheGrizzlyDev/vino/internal/pkg/vino/winexit"
+)
+
+// JournalPath is where the records of a container are appended, inside the
+// container.
+const JournalPath = "/var/lib/vino/events.jsonl"
+
+// TypePrefix prefixes the types of the records, so that they aren't taken
+// for the delegate's own events.
+const TypePrefix = "vino."
+
+// Types of the records, and of the events they are reported as.
+const (
+	TypeWineserverStarted = TypePrefix + "wineserver_started"
+	TypeWineserverCrashed = TypePrefix + "wineserver_crashed"
+	TypeWindowsExit       = TypePrefix + "windows_exit"
+	TypeDevice            = TypePrefix + "device"
+	TypeOOM               = TypePrefix + "oom"
+)
+
+// Record is one line of the journal.
+type Record struct {
+	Type string          `json:"type"`
+	Data json.RawMessage `json:"data,omitempty"`
+}
+
+// Wineserver is the data of TypeWineserverStarted and TypeWineserverCrashed.
+type Wineserver struct {
+	Prefix string `json:"prefix"`
+}
+
+// WindowsExit is the data of TypeWindowsExit: how a Windows process ended.
+type WindowsExit struct {
+	// Image is the executable's name, such as "notepad.exe".
+	Image string       `json:"image"`
+	Kind  winexit.Kind `json:"kind"`
+	// Code is the exit code, or the NTSTATUS of a crash in hexadecimal.
+	Code     string `json:"code"`
+	Name     string `json:"name,omitempty"`
+	Reason   string `json:"reason,omitempty"`
+	ExitCode int    `json:"exit_code"`
+}
+
+// ExitOf returns the WindowsExit of the process running image that ended
+// with s.
+func ExitOf(image string, s winexit.Status) WindowsExit {
+	return WindowsExit{
+		Image:    image,
+		Kind:     s.Kind,
+		Code:     s.CodeString(),
+		Name:     s.Name,
+		Reason:   s.Reason,
+		ExitCode: s.ExitCode,
+	}
+}
+
+// Actions of a Device.
+const (
+	DeviceAdded   = "add"
+	DeviceRemoved = "remove"
+)
+
+// Device is the data of TypeDevice: a device node added to or removed from
+// a running container.
+type Device struct {
+	Action string `json:"action"`
+	Class  string `json:"class"`
+	// ID identifies the device within its class, such as the
+	// vendor:product of a USB device.
+	ID    string `json:"id"`
+	Node  string `json:"node"`
+	Label string `json:"label,omitempty"`
+}
+
+// Process is a Windows process of a container.
+type Process struct {
+	Pid   int    `json:"pid"`
+	Image string `json:"image"`
+}
+
+// OOM is the data of TypeOOM: the Windows processes the delegate's "oom"
+// event killed.
+type OOM struct {
+	Processes []Process `json:"processes"`
+}
+
+// Append records an event of type typ with data at the end of the journal
+// path, resolved inside root, creating it if needed. Processes inside the
+// container give "/" for root, those outside of it the container's root.
+func Append(root, path, typ string, data any) error {
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	line, err := json.Marshal(Record{Type: typ, Data: raw})
+	if err != nil {
+		return err
+	}
+	if err := rootfs.MkdirAll(root, filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("create journal directory: %w", err)
+	}
+	// Opening a FIFO mustn't block until a reader shows up.
+	f, err := rootfs.Open(root, path, unix.O_WRONLY|unix.O_APPEND|unix.O_CREAT|unix.O_NONBLOCK|unix.O_NOCTTY, 0o666)
+	if err != nil {
+		return fmt.Errorf("open journal: %w", err)
+	}
+	defer f.Close()
+	if err := regular(f); err != nil {
+		return err
+	}
+	// A single write keeps the lines of concurrent writers whole.
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("append to journal: %w", err)
+	}
+	return f.Close()
+}
+
+// Follow calls fn with each record appended to the journal path, resolved
+// inside root, from now on, reading it every poll, until ctx is done or fn
+// fails. The journal doesn't have to exist yet. Lines that aren't records,
+// or longer than maxLine, are skipped.
+func Follow(ctx context.Context, root, path string, poll time.Duration, fn func(Record) error) error {
+	var offset int64
+	if f, err := openJournal(root, path); err == nil {
+		if fi, err := f.Stat(); err == nil {
+			offset = fi.Size()
+		}
+		f.Close()
+	}
+	var partial []byte
+	t := time.NewTicker(poll)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-t.C:
+		}
+		data, err := readFrom(root, path, offset)
+		if err != nil {
+			continue
+		}
+		offset += int64(len(data))
+		data = append(partial, data...)
+		// The last line may be being written still.
+		i := bytes.LastIndexByte(data, '\n')
+		partial = append([]byte(nil), data[i+1:]...)
+		if len(partial) > maxLine {
+			partial = nil
+		}
+		for _, line := range bytes.Split(data[:i+1], []byte{'\n'}) {
+			var r Record
+			if len(line) == 0 || len(line) > maxLine || json.Unmarshal(line, &r) != nil || r.Type == "" {
+				continue
+			}
+			if err := fn(r); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+const (
+	// maxLine bounds the records followed.
+	maxLine = 64 << 10
+	// maxRead bounds what is read of the journal every poll.
+	maxRead = 1 << 20
+)
+
+// readFrom returns the content of path, resolved inside root, past offset,
+// up to maxRead bytes of it.
+func readFrom(root, path string, offset int64) ([]byte, error) {
+	f, err := openJournal(root, path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		return nil, err
+	}
+	return io.ReadAll(io.LimitReader(f, maxRead))
+}
+
+// openJournal opens the journal path, resolved inside root, for reading.
+func openJournal(root, path string) (*os.File, error) {
+	f, err := rootfs.Open(root, path, unix.O_RDONLY|unix.O_NONBLOCK|unix.O_NOCTTY, 0)
+	if err != nil {
+		return nil, err
+	}
+	if err := regular(f); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return f, nil
+}
+
+// regular fails unless f is a regular file.
+func regular(f *os.File) error {
+	fi, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	if !fi.Mode().IsRegular() {
+		return fmt.Errorf("%s: not a regular file", f.Name())
+	}
+	return nil
+}
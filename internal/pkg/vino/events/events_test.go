@@ -0,0 +1,129 @@
+package events
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"golang.org/x/sys/unix"
+
+	"github.com/TheGrizzlyDev/vino/internal/pkg/vino/winexit"
+)
+
+func TestAppendFollow(t *testing.T) {
+	t.Parallel()
+	root := t.TempDir()
+	journal := filepath.Join(root, JournalPath)
+	// Recorded before following: not reported.
+	if err := Append(root, JournalPath, TypeWineserverStarted, Wineserver{Prefix: "/wine"}); err != nil {
+		t.Fatalf("Append: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	got := make(chan Record)
+	followed := make(chan error, 1)
+	go func() {
+		followed <- Follow(ctx, root, JournalPath, 10*time.Millisecond, func(r Record) error {
+			got <- r
+			return nil
+		})
+	}()
+	next := func() Record {
+		t.Helper()
+		select {
+		case r := <-got:
+			return r
+		case <-time.After(5 * time.Second):
+			t.Fatal("no record followed")
+			return Record{}
+		}
+	}
+	time.Sleep(20 * time.Millisecond)
+
+	crash := winexit.Status{Kind: winexit.KindCrashed, Code: 0xC0000005, Name: "STATUS_ACCESS_VIOLATION", ExitCode: 139}
+	if err := Append(root, JournalPath, TypeWindowsExit, ExitOf("app.exe", crash)); err != nil {
+		t.Fatalf("Append: %v", err)
+	}
+	want := Record{Type: TypeWindowsExit, Data: []byte(`{"image":"app.exe","kind":"crashed","code":"0xC0000005","name":"STATUS_ACCESS_VIOLATION","exit_code":139}`)}
+	if r := next(); !reflect.DeepEqual(r, want) {
+		t.Errorf("record = %s %s, want %s %s", r.Type, r.Data, want.Type, want.Data)
+	}
+
+	// Lines are reported once whole; others are skipped.
+	f, err := os.OpenFile(journal, os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	f.WriteString("garbage\n{\"type\":\"vino.device\",")
+	time.Sleep(30 * time.Millisecond)
+	f.WriteString("\"data\":{\"action\":\"add\"}}\n")
+	if r := next(); r.Type != TypeDevice || string(r.Data) != `{"action":"add"}` {
+		t.Errorf("record = %s %s, want the device event", r.Type, r.Data)
+	}
+
+	cancel()
+	if err := <-followed; err != context.Canceled {
+		t.Errorf("Follow = %v, want %v", err, context.Canceled)
+	}
+}
+
+func TestFollowMissingJournal(t *testing.T) {
+	t.Parallel()
+	root := t.TempDir()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		Append(root, JournalPath, TypeOOM, OOM{Processes: []Process{{Pid: 7, Image: "app.exe"}}})
+	}()
+	var got Record
+	err := Follow(ctx, root, JournalPath, 10*time.Millisecond, func(r Record) error {
+		got = r
+		return context.Canceled
+	})
+	if err != context.Canceled || got.Type != TypeOOM || string(got.Data) != `{"processes":[{"pid":7,"image":"app.exe"}]}` {
+		t.Errorf("Follow = %v, record %s %s", err, got.Type, got.Data)
+	}
+}
+
+func TestJournalStaysInRoot(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	root := filepath.Join(dir, "root")
+	// Out of the root as the host sees it, at its top as the container does.
+	if err := os.MkdirAll(filepath.Join(root, "var", "lib"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("../../..", filepath.Join(root, "var", "lib", "vino")); err != nil {
+		t.Fatal(err)
+	}
+	if err := Append(root, JournalPath, TypeOOM, OOM{}); err != nil {
+		t.Fatalf("Append: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(root, "events.jsonl")); err != nil {
+		t.Errorf("journal not at the top of the root: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "events.jsonl")); !os.IsNotExist(err) {
+		t.Errorf("journal written out of the root: %v", err)
+	}
+
+	// Nor is anything but a regular file written to, or read.
+	fifo := filepath.Join(t.TempDir(), "root")
+	if err := os.MkdirAll(filepath.Join(fifo, "var", "lib", "vino"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := unix.Mkfifo(filepath.Join(fifo, JournalPath), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := Append(fifo, JournalPath, TypeOOM, OOM{}); err == nil {
+		t.Errorf("Append to a FIFO succeeded")
+	}
+	if _, err := readFrom(fifo, JournalPath, 0); err == nil {
+		t.Errorf("read from a FIFO succeeded")
+	}
+}
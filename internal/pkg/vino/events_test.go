@@ -0,0 +1,118 @@
+package vino
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/TheGrizzlyDev/vino/internal/pkg/runc"
+	"github.com/TheGrizzlyDev/vino/internal/pkg/vino/events"
+)
+
+func TestEventRewriter(t *testing.T) {
+	dir := t.TempDir()
+	proc := filepath.Join(dir, "proc")
+	for pid, cmdline := range map[string]string{
+		"100": "/init\x00",
+		"101": `C:\app\app.exe` + "\x00--flag\x00",
+		"102": `Z:\tools\helper.exe` + "\x00",
+		"103": "/usr/bin/wineserver\x00",
+	} {
+		if err := os.MkdirAll(filepath.Join(proc, pid), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(proc, pid, "cmdline"), []byte(cmdline), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	pids := filepath.Join(dir, "pids")
+	if err := os.WriteFile(pids, []byte("[100,101,102,103]"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	script := "#!/bin/sh\ncase \"$1\" in\n" +
+		"state) echo '{\"ociVersion\":\"1.2.1\",\"id\":\"c1\",\"status\":\"running\",\"pid\":100,\"bundle\":\"/b\"}' ;;\n" +
+		"ps) cat " + pids + " ;;\n" +
+		"*) exit 1 ;;\nesac\n"
+	bin := filepath.Join(dir, "runc")
+	if err := os.WriteFile(bin, []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	delegate, err := runc.NewDelegatingCliClient(bin)
+	if err != nil {
+		t.Fatalf("NewDelegatingCliClient: %v", err)
+	}
+
+	in := make(chan runc.Event)
+	s := &runc.EventStream{
+		ID:     "c1",
+		Client: runc.NewClient(delegate, runc.Global{}),
+		Delegate: func(yield func(runc.Event, error) bool) {
+			for ev := range in {
+				if !yield(ev, nil) {
+					return
+				}
+			}
+		},
+	}
+	out := make(chan runc.Event, 10)
+	done := make(chan error, 1)
+	go func() {
+		r := EventRewriter{ProcRoot: proc, Poll: 10 * time.Millisecond}
+		done <- r.RewriteEvents(context.Background(), s, func(ev runc.Event) error {
+			out <- ev
+			return nil
+		})
+	}()
+	next := func(typ, data string) {
+		t.Helper()
+		select {
+		case ev := <-out:
+			if ev.Type != typ || ev.ID != "c1" || string(ev.Data) != data {
+				t.Fatalf("event = %s %s %s, want %s c1 %s", ev.Type, ev.ID, ev.Data, typ, data)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("no %s event", typ)
+		}
+	}
+
+	in <- runc.Event{Type: "stats", ID: "c1", Data: json.RawMessage(`{}`)}
+	next("stats", `{}`)
+
+	// Events recorded in the container are interleaved, but for those
+	// passing for the delegate's.
+	time.Sleep(50 * time.Millisecond)
+	root := filepath.Join(proc, "100", "root")
+	if err := os.MkdirAll(root, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := events.Append(root, events.JournalPath, "oom", struct{}{}); err != nil {
+		t.Fatalf("Append: %v", err)
+	}
+	if err := events.Append(root, events.JournalPath, events.TypeDevice, events.Device{Action: events.DeviceAdded, Class: "usb", ID: "096e:0006", Node: "/dev/hidraw0"}); err != nil {
+		t.Fatalf("Append: %v", err)
+	}
+	next(events.TypeDevice, `{"action":"add","class":"usb","id":"096e:0006","node":"/dev/hidraw0"}`)
+
+	// The Windows processes gone by the time of an OOM are its victims.
+	if err := os.WriteFile(pids, []byte("[100,103]"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	in <- runc.Event{Type: "oom", ID: "c1"}
+	next("oom", "")
+	next(events.TypeOOM, `{"processes":[{"pid":101,"image":"app.exe"},{"pid":102,"image":"helper.exe"}]}`)
+
+	// Another OOM with no Windows process gone isn't attributed.
+	in <- runc.Event{Type: "oom", ID: "c1"}
+	next("oom", "")
+
+	close(in)
+	if err := <-done; err != nil {
+		t.Fatalf("RewriteEvents: %v", err)
+	}
+	if len(out) != 0 {
+		t.Errorf("unexpected event: %+v", <-out)
+	}
+}
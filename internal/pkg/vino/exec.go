@@ -0,0 +1,97 @@
+package vino
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/TheGrizzlyDev/vino/internal/pkg/path"
+	specs "github.com/opencontainers/runtime-spec/specs-go"
+)
+
+// ExecOptions describes a Windows command to run inside an existing
+// container.
+type ExecOptions struct {
+	Args []string
+	// Cwd is either a Windows path (C:\users) which gets translated under the
+	// container's WINEPREFIX, or a Linux path which is used as is. When empty
+	// the container's own working directory is used.
+	Cwd string
+	// Env holds KEY=VALUE pairs that override the container's environment.
+	Env []string
+	Tty bool
+}
+
+// ExecProcess builds the process to exec into a container described by spec.
+// The returned process inherits the container's environment, user and
+// security settings and still needs to go through a ProcessRewriter to be
+// launched through wine.
+func ExecProcess(spec *specs.Spec, opts ExecOptions) (*specs.Process, error) {
+	if spec == nil || spec.Process == nil {
+		return nil, fmt.Errorf("vinoc: bundle has no process")
+	}
+	if len(opts.Args) == 0 {
+		return nil, fmt.Errorf("vinoc: empty process args")
+	}
+	base := spec.Process
+
+	env := mergeEnv(base.Env, opts.Env)
+
+	cwd := base.Cwd
+	if opts.Cwd != "" {
+		cwd = opts.Cwd
+		if !strings.HasPrefix(cwd, "/") {
+			prefix, ok := lookupEnv(env, "WINEPREFIX")
+			if !ok || prefix == "" {
+				return nil, fmt.Errorf("vinoc: cannot translate %q: WINEPREFIX not set", opts.Cwd)
+			}
+			translated, err := path.TranslatePathToWine(prefix, cwd)
+			if err != nil {
+				return nil, err
+			}
+			cwd = translated
+		}
+	}
+	if cwd == "" {
+		cwd = "/"
+	}
+
+	return &specs.Process{
+		Terminal:        opts.Tty,
+		User:            base.User,
+		Args:            append([]string{}, opts.Args...),
+		Env:             env,
+		Cwd:             cwd,
+		Capabilities:    base.Capabilities,
+		Rlimits:         base.Rlimits,
+		NoNewPrivileges: base.NoNewPrivileges,
+		ApparmorProfile: base.ApparmorProfile,
+		SelinuxLabel:    base.SelinuxLabel,
+	}, nil
+}
+
+// mergeEnv returns base with every KEY=VALUE of overrides applied, preserving
+// the order in which keys first appear.
+func mergeEnv(base, overrides []string) []string {
+	out := make([]string, 0, len(base)+len(overrides))
+	index := map[string]int{}
+	for _, kv := range append(append([]string{}, base...), overrides...) {
+		key, _, _ := strings.Cut(kv, "=")
+		if i, ok := index[key]; ok {
+			out[i] = kv
+			continue
+		}
+		index[key] = len(out)
+		out = append(out, kv)
+	}
+	return out
+}
+
+func lookupEnv(env []string, key string) (string, bool) {
+	for i := len(env) - 1; i >= 0; i-- {
+		k, v, _ := strings.Cut(env[i], "=")
+		if k == key {
+			return v, true
+		}
+	}
+	return "", false
+}
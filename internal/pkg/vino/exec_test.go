@@ -0,0 +1,102 @@
+package vino
+
+import (
+	"reflect"
+	"testing"
+
+	specs "github.com/opencontainers/runtime-spec/specs-go"
+)
+
+func TestExecProcess(t *testing.T) {
+	spec := &specs.Spec{
+		Process: &specs.Process{
+			User: specs.User{UID: 1000, GID: 1000},
+			Args: []string{"/run/vino", "wine-launcher", "app.exe"},
+			Env:  []string{"PATH=/usr/bin", "WINEPREFIX=/opt/wine/prefix", "FOO=bar"},
+			Cwd:  "/work",
+		},
+	}
+
+	tests := []struct {
+		name    string
+		opts    ExecOptions
+		wantCwd string
+		wantEnv []string
+		wantErr bool
+	}{
+		{
+			name:    "inherits container cwd and env",
+			opts:    ExecOptions{Args: []string{"cmd", "/c", "dir"}},
+			wantCwd: "/work",
+			wantEnv: []string{"PATH=/usr/bin", "WINEPREFIX=/opt/wine/prefix", "FOO=bar"},
+		},
+		{
+			name:    "translates windows cwd",
+			opts:    ExecOptions{Args: []string{"cmd"}, Cwd: `C:\users\public`},
+			wantCwd: "/opt/wine/prefix/drive_c/users/public",
+			wantEnv: []string{"PATH=/usr/bin", "WINEPREFIX=/opt/wine/prefix", "FOO=bar"},
+		},
+		{
+			name:    "keeps linux cwd",
+			opts:    ExecOptions{Args: []string{"cmd"}, Cwd: "/tmp"},
+			wantCwd: "/tmp",
+			wantEnv: []string{"PATH=/usr/bin", "WINEPREFIX=/opt/wine/prefix", "FOO=bar"},
+		},
+		{
+			name:    "overrides env",
+			opts:    ExecOptions{Args: []string{"cmd"}, Env: []string{"FOO=baz", "NEW=1"}},
+			wantCwd: "/work",
+			wantEnv: []string{"PATH=/usr/bin", "WINEPREFIX=/opt/wine/prefix", "FOO=baz", "NEW=1"},
+		},
+		{
+			name:    "translates with overridden prefix",
+			opts:    ExecOptions{Args: []string{"cmd"}, Cwd: `D:\`, Env: []string{"WINEPREFIX=/prefix"}},
+			wantCwd: "/prefix/drive_d",
+			wantEnv: []string{"PATH=/usr/bin", "WINEPREFIX=/prefix", "FOO=bar"},
+		},
+		{
+			name:    "empty args",
+			opts:    ExecOptions{},
+			wantErr: true,
+		},
+		{
+			name:    "invalid windows cwd",
+			opts:    ExecOptions{Args: []string{"cmd"}, Cwd: `\\server`},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := ExecProcess(spec, tt.opts)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if p.Cwd != tt.wantCwd {
+				t.Fatalf("cwd = %q, want %q", p.Cwd, tt.wantCwd)
+			}
+			if !reflect.DeepEqual(p.Env, tt.wantEnv) {
+				t.Fatalf("env = %#v, want %#v", p.Env, tt.wantEnv)
+			}
+			if !reflect.DeepEqual(p.Args, tt.opts.Args) {
+				t.Fatalf("args = %#v, want %#v", p.Args, tt.opts.Args)
+			}
+			if !reflect.DeepEqual(p.User, spec.Process.User) {
+				t.Fatalf("user = %#v, want %#v", p.User, spec.Process.User)
+			}
+		})
+	}
+}
+
+func TestExecProcessWithoutPrefix(t *testing.T) {
+	spec := &specs.Spec{Process: &specs.Process{Cwd: "/"}}
+	if _, err := ExecProcess(spec, ExecOptions{Args: []string{"cmd"}, Cwd: `C:\`}); err == nil {
+		t.Fatalf("expected error, got nil")
+	}
+}
@@ -1,17 +1,32 @@
 package hook
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
 	"strings"
 
 	"github.com/TheGrizzlyDev/vino/internal/pkg/vino/labels"
+	"github.com/TheGrizzlyDev/vino/internal/pkg/vino/launcher"
+	"github.com/TheGrizzlyDev/vino/internal/pkg/vino/rootfs"
+	"github.com/TheGrizzlyDev/vino/internal/pkg/vino/usb"
+	specs "github.com/opencontainers/runtime-spec/specs-go"
 	"golang.org/x/sys/unix"
 )
 
 type VinoContainer struct {
 	WinePrefix string
+	// Root is the container's root filesystem, as the create hook sees it
+	// before pivot_root. AttachPrinters and LinkUSB resolve the paths of
+	// the container inside it. Defaults to /.
+	Root string
+	// PrinterSpoolDir holds the FIFOs of LPT ports backed by CUPS. Defaults
+	// to launcher.PrinterSpoolDir.
+	PrinterSpoolDir string
+	// SysRoot is where USB devices are looked up. Defaults to /sys.
+	SysRoot string
 }
 
 func FromEnvironment() (*VinoContainer, error) {
@@ -25,6 +40,103 @@ func FromEnvironment() (*VinoContainer, error) {
 	}, nil
 }
 
+// FromBundle returns the container of the bundle in bundleDir, as its
+// createContainer hook sees it: with its root filesystem not pivoted into
+// yet, and with the environment of the runtime rather than its own. The
+// prefix is left empty when the container's environment doesn't tell it.
+func FromBundle(bundleDir string) (*VinoContainer, error) {
+	data, err := os.ReadFile(filepath.Join(bundleDir, "config.json"))
+	if err != nil {
+		return nil, fmt.Errorf("read bundle: %w", err)
+	}
+	var spec specs.Spec
+	if err := json.Unmarshal(data, &spec); err != nil {
+		return nil, fmt.Errorf("unmarshal bundle: %w", err)
+	}
+	v := &VinoContainer{Root: bundleDir}
+	if spec.Root != nil {
+		v.Root = spec.Root.Path
+		if !filepath.IsAbs(v.Root) {
+			v.Root = filepath.Join(bundleDir, v.Root)
+		}
+	}
+	if spec.Process != nil {
+		if prefix, ok := envValue(spec.Process.Env, "WINEPREFIX"); ok && prefix != "" {
+			v.WinePrefix = prefix
+		} else if home, ok := envValue(spec.Process.Env, "HOME"); ok && home != "" {
+			v.WinePrefix = filepath.Join(home, ".wine")
+		}
+	}
+	return v, nil
+}
+
+func envValue(env []string, key string) (string, bool) {
+	for i := len(env) - 1; i >= 0; i-- {
+		if v, ok := strings.CutPrefix(env[i], key+"="); ok {
+			return v, true
+		}
+	}
+	return "", false
+}
+
+func (v *VinoContainer) root() string {
+	if v.Root == "" {
+		return "/"
+	}
+	return v.Root
+}
+
+// dosDevicesInRoot returns the dosdevices directory of the prefix,
+// creating it inside Root. The hook runs as root and wine as whoever owns
+// the prefix: the directory is given to the owner of the prefix.
+func (v *VinoContainer) dosDevicesInRoot() (string, error) {
+	if v.WinePrefix == "" {
+		return "", errors.New("WINEPREFIX not set")
+	}
+	prefix, err := rootfs.Open(v.root(), v.WinePrefix, unix.O_PATH|unix.O_DIRECTORY, 0)
+	if err != nil {
+		return "", fmt.Errorf("open wine prefix: %w", err)
+	}
+	defer prefix.Close()
+	fd := int(prefix.Fd())
+	var st unix.Stat_t
+	if err := unix.Fstat(fd, &st); err != nil {
+		return "", err
+	}
+	dosDir := filepath.Join(v.WinePrefix, "dosdevices")
+	if err := unix.Mkdirat(fd, "dosdevices", 0o755); errors.Is(err, unix.EEXIST) {
+		return dosDir, nil
+	} else if err != nil {
+		return "", fmt.Errorf("create dosdevices dir: %w", err)
+	}
+	if err := unix.Fchownat(fd, "dosdevices", int(st.Uid), int(st.Gid), unix.AT_SYMLINK_NOFOLLOW); err != nil {
+		return "", fmt.Errorf("create dosdevices dir: %w", err)
+	}
+	return dosDir, nil
+}
+
+// CreateLauncherLog creates, inside Root, the file launchers log to when
+// vino relays their records, writable by whoever runs them.
+func (v *VinoContainer) CreateLauncherLog() error {
+	root := v.root()
+	if err := rootfs.MkdirAll(root, filepath.Dir(launcher.LogPath), 0o755); err != nil {
+		return fmt.Errorf("create launcher log directory: %w", err)
+	}
+	f, err := rootfs.Open(root, launcher.LogPath, unix.O_WRONLY|unix.O_CREAT|unix.O_NONBLOCK|unix.O_NOCTTY, 0o666)
+	if err != nil {
+		return fmt.Errorf("create launcher log: %w", err)
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	if !fi.Mode().IsRegular() {
+		return fmt.Errorf("launcher log %s: not a regular file", launcher.LogPath)
+	}
+	return f.Chmod(0o666)
+}
+
 func (v *VinoContainer) getOrCreateDosDevices() (string, error) {
 	dosDir := filepath.Join(v.WinePrefix, "dosdevices")
 	if err := os.MkdirAll(dosDir, 0o755); err != nil {
@@ -44,6 +156,15 @@ func (v *VinoContainer) ApplyDevices(devs []labels.Device) error {
 	}
 
 	for _, d := range devs {
+		// Audio reaches wine through PulseAudio, not through a DOS device.
+		if d.Class == labels.ClassAudio {
+			continue
+		}
+		// USB devices are linked by LinkUSB, at create time, and by `vino
+		// hotplug`.
+		if d.Class == labels.ClassUSB {
+			continue
+		}
 		if d.Path == "" {
 			if d.Optional {
 				continue
@@ -51,25 +172,84 @@ func (v *VinoContainer) ApplyDevices(devs []labels.Device) error {
 			return fmt.Errorf("device %q missing path", d.Label)
 		}
 
-		if _, err := os.Stat(d.Path); err != nil {
+		target := d.Path
+		if d.Family() {
+			// The label names the family's first node, e.g. GPU0 the first
+			// of /dev/dri/*.
+			target = firstNode(d.Path)
+			if target == "" {
+				if d.Optional {
+					continue
+				}
+				return fmt.Errorf("device family %s matches no device node", d.Path)
+			}
+		}
+		if _, err := os.Stat(target); err != nil {
 			if os.IsNotExist(err) && d.Optional {
 				continue
 			}
-			return fmt.Errorf("stat %s: %w", d.Path, err)
+			return fmt.Errorf("stat %s: %w", target, err)
 		}
 
 		linkName := filepath.Join(dosDir, strings.ToLower(d.Label))
 		if err := os.Remove(linkName); err != nil && !os.IsNotExist(err) {
 			return fmt.Errorf("remove existing link %s: %w", linkName, err)
 		}
-		if err := os.Symlink(d.Path, linkName); err != nil {
-			return fmt.Errorf("symlink %s -> %s: %w", linkName, d.Path, err)
+		if err := os.Symlink(target, linkName); err != nil {
+			return fmt.Errorf("symlink %s -> %s: %w", linkName, target, err)
 		}
 	}
 
 	return nil
 }
 
+// firstNode returns the first path matching pattern that isn't a directory,
+// or "" when there are none.
+func firstNode(pattern string) string {
+	matches, _ := filepath.Glob(pattern)
+	for _, m := range matches {
+		if fi, err := os.Stat(m); err == nil && !fi.IsDir() {
+			return m
+		}
+	}
+	return ""
+}
+
+// LinkUSB points the labels of the USB devices in devs at the primary node
+// of those plugged in at create time. A device plugged in later is linked
+// by `vino hotplug`.
+func (v *VinoContainer) LinkUSB(devs []labels.Device) error {
+	sysRoot := v.SysRoot
+	if sysRoot == "" {
+		sysRoot = "/sys"
+	}
+	for _, d := range devs {
+		if d.Class != labels.ClassUSB || d.Label == "" {
+			continue
+		}
+		id, err := usb.ParseID(d.ID)
+		if err != nil {
+			return err
+		}
+		nodes, err := usb.Resolve(sysRoot, id)
+		if err != nil {
+			return fmt.Errorf("resolve usb device %s: %w", id, err)
+		}
+		n, ok := usb.Primary(nodes)
+		if !ok {
+			continue
+		}
+		if _, err := v.dosDevicesInRoot(); err != nil {
+			return err
+		}
+		c := &usb.Container{Root: v.root(), Prefix: v.WinePrefix}
+		if err := c.Link(d.Label, &n); err != nil {
+			return fmt.Errorf("link usb device %s: %w", id, err)
+		}
+	}
+	return nil
+}
+
 func (v *VinoContainer) ApplyMounts(mounts []labels.Mount) error {
 	if len(mounts) == 0 {
 		return nil
@@ -99,6 +279,11 @@ func (v *VinoContainer) ApplyMounts(mounts []labels.Mount) error {
 			return fmt.Errorf("stat %s: %w", src, err)
 		}
 
+		// Printers are attached by AttachPrinters, at create time.
+		if m.Type == labels.MountPrinter {
+			continue
+		}
+
 		drive := strings.ToLower(m.DestinationLabel)
 		dest := filepath.Join(dosDir, drive)
 
@@ -127,6 +312,63 @@ func (v *VinoContainer) ApplyMounts(mounts []labels.Mount) error {
 	return nil
 }
 
+// AttachPrinters points the LPT ports of the printer mounts in mounts at
+// their sinks. Jobs for a CUPS socket go to a FIFO the launcher spools to
+// it, anything else, a device node, a FIFO or a file, receives them
+// directly.
+func (v *VinoContainer) AttachPrinters(mounts []labels.Mount) error {
+	for _, m := range mounts {
+		if m.Type != labels.MountPrinter {
+			continue
+		}
+		if err := v.attachPrinter(m.SourcePath, m.DestinationLabel); err != nil {
+			if m.Optional {
+				continue
+			}
+			return fmt.Errorf("attach printer %s to %s: %w", m.SourcePath, m.DestinationLabel, err)
+		}
+	}
+	return nil
+}
+
+// attachPrinter points the LPT port label at src, a path of the host bound
+// at the same path into the container.
+func (v *VinoContainer) attachPrinter(src, label string) error {
+	fi, err := os.Stat(src)
+	if err != nil {
+		return err
+	}
+	root := v.root()
+	target := src
+	if fi.Mode()&os.ModeSocket != 0 {
+		spoolDir := v.PrinterSpoolDir
+		if spoolDir == "" {
+			spoolDir = launcher.PrinterSpoolDir
+		}
+		if err := rootfs.MkdirAll(root, spoolDir, 0o755); err != nil {
+			return err
+		}
+		target = launcher.SpoolPath(spoolDir, label)
+		if err := rootfs.Remove(root, target); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		// Whoever runs the program has to be able to print.
+		if err := rootfs.Mknod(root, target, unix.S_IFIFO|0o666, 0); err != nil {
+			return fmt.Errorf("create spool %s: %w", target, err)
+		}
+	}
+
+	dosDir, err := v.dosDevicesInRoot()
+	if err != nil {
+		return err
+	}
+	link := filepath.Join(dosDir, strings.ToLower(label))
+	if err := rootfs.Remove(root, link); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("remove existing link %s: %w", link, err)
+	}
+	return rootfs.Symlink(target, root, link)
+}
+
 func bindOrSymlink(src, dest, mode string) error {
 	fi, err := os.Stat(src)
 	if err != nil {
@@ -1,11 +1,16 @@
 package hook
 
 import (
+	"net"
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
+
+	"golang.org/x/sys/unix"
 
 	"github.com/TheGrizzlyDev/vino/internal/pkg/vino/labels"
+	"github.com/TheGrizzlyDev/vino/internal/pkg/vino/rootfs"
 )
 
 func TestApplyDevices(t *testing.T) {
@@ -32,6 +37,29 @@ func TestApplyDevices(t *testing.T) {
 		}
 	})
 
+	t.Run("links first node of family", func(t *testing.T) {
+		prefix := t.TempDir()
+		dri := t.TempDir()
+		for _, name := range []string{"card0", "renderD128"} {
+			if err := os.WriteFile(filepath.Join(dri, name), nil, 0o644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := os.Mkdir(filepath.Join(dri, "by-path"), 0o755); err != nil {
+			t.Fatal(err)
+		}
+
+		vc := &VinoContainer{WinePrefix: prefix}
+		dev := labels.Device{Class: "gpu", Path: filepath.Join(dri, "*"), Label: "GPU0"}
+		if err := vc.ApplyDevices([]labels.Device{dev}); err != nil {
+			t.Fatalf("ApplyDevices: %v", err)
+		}
+		target, err := os.Readlink(filepath.Join(prefix, "dosdevices", "gpu0"))
+		if err != nil || target != filepath.Join(dri, "card0") {
+			t.Fatalf("gpu0 -> %q, %v; want card0", target, err)
+		}
+	})
+
 	t.Run("missing path error", func(t *testing.T) {
 		prefix := t.TempDir()
 		vc := &VinoContainer{WinePrefix: prefix}
@@ -40,6 +68,60 @@ func TestApplyDevices(t *testing.T) {
 			t.Fatalf("expected error, got nil")
 		}
 	})
+
+	t.Run("skips audio", func(t *testing.T) {
+		prefix := t.TempDir()
+		vc := &VinoContainer{WinePrefix: prefix}
+		dev := labels.Device{Class: labels.ClassAudio, Path: "/nonexistent/native"}
+		if err := vc.ApplyDevices([]labels.Device{dev}); err != nil {
+			t.Fatalf("ApplyDevices: %v", err)
+		}
+		entries, _ := os.ReadDir(filepath.Join(prefix, "dosdevices"))
+		if len(entries) != 0 {
+			t.Fatalf("dosdevices = %v, want empty", entries)
+		}
+	})
+}
+
+func TestLinkUSB(t *testing.T) {
+	sys := t.TempDir()
+	dev := filepath.Join(sys, "devices", "usb1", "1-1")
+	for path, data := range map[string]string{
+		filepath.Join(dev, "idVendor"):                               "096e\n",
+		filepath.Join(dev, "idProduct"):                              "0006\n",
+		filepath.Join(dev, "1-1:1.0", "hidraw", "hidraw3", "uevent"): "MAJOR=245\nMINOR=3\nDEVNAME=hidraw3\n",
+	} {
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.MkdirAll(filepath.Join(sys, "bus", "usb", "devices"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(dev, filepath.Join(sys, "bus", "usb", "devices", "1-1")); err != nil {
+		t.Fatal(err)
+	}
+
+	root := newRoot(t)
+	vc := &VinoContainer{Root: root, WinePrefix: "/prefix", SysRoot: sys}
+	devs := []labels.Device{
+		{Class: labels.ClassUSB, ID: "096e:0006", Label: "COM3"},
+		// Not plugged in: linked once hotplugged.
+		{Class: labels.ClassUSB, ID: "1234:5678", Label: "COM4", Optional: true},
+	}
+	if err := vc.LinkUSB(devs); err != nil {
+		t.Fatalf("LinkUSB: %v", err)
+	}
+	target, err := os.Readlink(filepath.Join(root, "prefix", "dosdevices", "com3"))
+	if err != nil || target != "/dev/hidraw3" {
+		t.Fatalf("com3 -> %q, %v; want /dev/hidraw3", target, err)
+	}
+	if _, err := os.Lstat(filepath.Join(root, "prefix", "dosdevices", "com4")); !os.IsNotExist(err) {
+		t.Fatalf("com4 linked without a device: %v", err)
+	}
 }
 
 func TestApplyMounts(t *testing.T) {
@@ -79,3 +161,154 @@ func TestApplyMounts(t *testing.T) {
 		}
 	})
 }
+
+// consumeFIFO reads the first job written to fifo.
+func consumeFIFO(t *testing.T, fifo string) <-chan string {
+	t.Helper()
+	jobs := make(chan string, 1)
+	go func() {
+		data, err := os.ReadFile(fifo)
+		if err != nil {
+			t.Errorf("read %s: %v", fifo, err)
+		}
+		jobs <- string(data)
+	}()
+	return jobs
+}
+
+// newRoot returns the root filesystem of a container whose prefix is
+// /prefix.
+func newRoot(t *testing.T) string {
+	t.Helper()
+	root := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(root, "prefix"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	return root
+}
+
+func TestApplyPrinters(t *testing.T) {
+	t.Run("fifo sink", func(t *testing.T) {
+		root := newRoot(t)
+		fifo := filepath.Join(t.TempDir(), "receipts")
+		if err := unix.Mkfifo(fifo, 0o666); err != nil {
+			t.Fatalf("mkfifo: %v", err)
+		}
+		vc := &VinoContainer{Root: root, WinePrefix: "/prefix"}
+		m := labels.Mount{Type: labels.MountPrinter, SourcePath: fifo, DestinationLabel: "LPT1"}
+		if err := vc.AttachPrinters([]labels.Mount{m}); err != nil {
+			t.Fatalf("AttachPrinters: %v", err)
+		}
+
+		// The sink is bound at the same path into the container.
+		link := filepath.Join(root, "prefix", "dosdevices", "lpt1")
+		if target, err := os.Readlink(link); err != nil || target != fifo {
+			t.Fatalf("lpt1 -> %q, %v; want %q", target, err, fifo)
+		}
+		jobs := consumeFIFO(t, fifo)
+		if err := os.WriteFile(link, []byte("RECEIPT\n"), 0); err != nil {
+			t.Fatalf("print: %v", err)
+		}
+		select {
+		case job := <-jobs:
+			if job != "RECEIPT\n" {
+				t.Fatalf("job = %q, want %q", job, "RECEIPT\n")
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("consumer got no job")
+		}
+	})
+
+	t.Run("cups socket", func(t *testing.T) {
+		root := newRoot(t)
+		socket := filepath.Join(t.TempDir(), "cups.sock")
+		l, err := net.Listen("unix", socket)
+		if err != nil {
+			t.Fatalf("listen: %v", err)
+		}
+		defer l.Close()
+		vc := &VinoContainer{Root: root, WinePrefix: "/prefix", PrinterSpoolDir: "/spool"}
+		m := labels.Mount{Type: labels.MountPrinter, SourcePath: socket, DestinationLabel: "LPT2"}
+		if err := vc.AttachPrinters([]labels.Mount{m}); err != nil {
+			t.Fatalf("AttachPrinters: %v", err)
+		}
+
+		link := filepath.Join(root, "prefix", "dosdevices", "lpt2")
+		if target, err := os.Readlink(link); err != nil || target != "/spool/lpt2" {
+			t.Fatalf("lpt2 -> %q, %v; want /spool/lpt2", target, err)
+		}
+		fifo := filepath.Join(root, "spool", "lpt2")
+		if fi, err := os.Stat(fifo); err != nil || fi.Mode().Type() != os.ModeNamedPipe || fi.Mode().Perm() != 0o666 {
+			t.Fatalf("spool = %v, %v; want a FIFO anyone can write", fi, err)
+		}
+		jobs := consumeFIFO(t, fifo)
+		f, err := rootfs.Open(root, "/prefix/dosdevices/lpt2", unix.O_WRONLY, 0)
+		if err != nil {
+			t.Fatalf("open lpt2: %v", err)
+		}
+		f.WriteString("LABEL\n")
+		f.Close()
+		select {
+		case job := <-jobs:
+			if job != "LABEL\n" {
+				t.Fatalf("job = %q, want %q", job, "LABEL\n")
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("spooler got no job")
+		}
+	})
+
+	t.Run("gives dosdevices to the prefix owner", func(t *testing.T) {
+		if os.Geteuid() != 0 {
+			t.Skip("needs root")
+		}
+		root := newRoot(t)
+		if err := os.Chown(filepath.Join(root, "prefix"), 1000, 1000); err != nil {
+			t.Fatal(err)
+		}
+		sink := filepath.Join(t.TempDir(), "lp0")
+		if err := os.WriteFile(sink, nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		vc := &VinoContainer{Root: root, WinePrefix: "/prefix"}
+		m := labels.Mount{Type: labels.MountPrinter, SourcePath: sink, DestinationLabel: "LPT1"}
+		if err := vc.AttachPrinters([]labels.Mount{m}); err != nil {
+			t.Fatalf("AttachPrinters: %v", err)
+		}
+		var st unix.Stat_t
+		if err := unix.Stat(filepath.Join(root, "prefix", "dosdevices"), &st); err != nil || st.Uid != 1000 || st.Gid != 1000 {
+			t.Fatalf("dosdevices owned by %d:%d, %v; want 1000:1000", st.Uid, st.Gid, err)
+		}
+	})
+
+	t.Run("missing optional", func(t *testing.T) {
+		vc := &VinoContainer{Root: newRoot(t), WinePrefix: "/prefix"}
+		m := labels.Mount{Type: labels.MountPrinter, SourcePath: "/nonexistent", DestinationLabel: "LPT4", Optional: true}
+		if err := vc.AttachPrinters([]labels.Mount{m}); err != nil {
+			t.Fatalf("AttachPrinters: %v", err)
+		}
+		m.Optional = false
+		if err := vc.AttachPrinters([]labels.Mount{m}); err == nil {
+			t.Fatalf("AttachPrinters accepted a missing printer")
+		}
+	})
+
+	t.Run("lpt device", func(t *testing.T) {
+		prefix := t.TempDir()
+		sink := filepath.Join(t.TempDir(), "lp0")
+		if err := os.WriteFile(sink, nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		vc := &VinoContainer{WinePrefix: prefix}
+		dev := labels.Device{Class: labels.ClassLPT, Path: sink, Label: "LPT1"}
+		if err := vc.ApplyDevices([]labels.Device{dev}); err != nil {
+			t.Fatalf("ApplyDevices: %v", err)
+		}
+		if err := os.WriteFile(filepath.Join(prefix, "dosdevices", "lpt1"), []byte("JOB"), 0); err != nil {
+			t.Fatalf("print: %v", err)
+		}
+		if data, _ := os.ReadFile(sink); string(data) != "JOB" {
+			t.Fatalf("sink = %q, want %q", data, "JOB")
+		}
+	})
+}
@@ -36,6 +36,121 @@ func TestParse(t *testing.T) {
 			},
 			wantErr: true,
 		},
+		{
+			name: "audio",
+			annotations: map[string]string{
+				"dev.vinoc.devices.snd.class":             "audio",
+				"dev.vinoc.devices.snd.path":              "/run/user/1000/pulse/native",
+				"dev.vinoc.mounts.data.source_path":       "/data",
+				"dev.vinoc.mounts.data.destination_label": "D:",
+			},
+			wantDevs:   []Device{{Class: "audio", Path: "/run/user/1000/pulse/native"}},
+			wantMounts: []Mount{{SourcePath: "/data", DestinationLabel: "D:"}},
+		},
+		{
+			name: "audio null sink",
+			annotations: map[string]string{
+				"dev.vinoc.devices.snd.class":             "audio",
+				"dev.vinoc.mounts.data.source_path":       "/data",
+				"dev.vinoc.mounts.data.destination_label": "D:",
+			},
+			wantDevs:   []Device{{Class: "audio"}},
+			wantMounts: []Mount{{SourcePath: "/data", DestinationLabel: "D:"}},
+		},
+		{
+			name: "invalid audio label",
+			annotations: map[string]string{
+				"dev.vinoc.devices.snd.class": "audio",
+				"dev.vinoc.devices.snd.path":  "/run/user/1000/pulse/native",
+				"dev.vinoc.devices.snd.label": "SND",
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid device missing label",
+			annotations: map[string]string{
+				"dev.vinoc.devices.gpu0.class": "gpu",
+				"dev.vinoc.devices.gpu0.path":  "/dev/dri/renderD128",
+			},
+			wantErr: true,
+		},
+		{
+			name: "lpt",
+			annotations: map[string]string{
+				"dev.vinoc.devices.lp.class":                  "lpt",
+				"dev.vinoc.devices.lp.path":                   "/dev/usb/lp0",
+				"dev.vinoc.devices.lp.label":                  "LPT1",
+				"dev.vinoc.mounts.receipts.type":              "printer",
+				"dev.vinoc.mounts.receipts.source_path":       "/run/cups/cups.sock",
+				"dev.vinoc.mounts.receipts.destination_label": "LPT2",
+			},
+			wantDevs:   []Device{{Class: "lpt", Path: "/dev/usb/lp0", Label: "LPT1"}},
+			wantMounts: []Mount{{Type: "printer", SourcePath: "/run/cups/cups.sock", DestinationLabel: "LPT2"}},
+		},
+		{
+			name: "invalid lpt label",
+			annotations: map[string]string{
+				"dev.vinoc.devices.lp.class": "lpt",
+				"dev.vinoc.devices.lp.path":  "/dev/usb/lp0",
+				"dev.vinoc.devices.lp.label": "COM1",
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid printer drive",
+			annotations: map[string]string{
+				"dev.vinoc.mounts.receipts.type":              "printer",
+				"dev.vinoc.mounts.receipts.source_path":       "/tmp/receipts",
+				"dev.vinoc.mounts.receipts.destination_label": "D:",
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid printer volume",
+			annotations: map[string]string{
+				"dev.vinoc.mounts.receipts.type":              "printer",
+				"dev.vinoc.mounts.receipts.volume":            "receipts",
+				"dev.vinoc.mounts.receipts.destination_label": "LPT1",
+			},
+			wantErr: true,
+		},
+		{
+			name: "usb",
+			annotations: map[string]string{
+				"dev.vinoc.devices.dongle.class":          "usb",
+				"dev.vinoc.devices.dongle.id":             "096e:0006",
+				"dev.vinoc.devices.dongle.optional":       "true",
+				"dev.vinoc.mounts.data.source_path":       "/data",
+				"dev.vinoc.mounts.data.destination_label": "D:",
+			},
+			wantDevs:   []Device{{Class: "usb", ID: "096e:0006", Optional: true}},
+			wantMounts: []Mount{{SourcePath: "/data", DestinationLabel: "D:"}},
+		},
+		{
+			name: "invalid usb path",
+			annotations: map[string]string{
+				"dev.vinoc.devices.dongle.class": "usb",
+				"dev.vinoc.devices.dongle.id":    "096e:0006",
+				"dev.vinoc.devices.dongle.path":  "/dev/hidraw0",
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid usb id",
+			annotations: map[string]string{
+				"dev.vinoc.devices.dongle.class": "usb",
+				"dev.vinoc.devices.dongle.id":    "96e:6",
+			},
+			wantErr: true,
+		},
+		{
+			name: "process only",
+			annotations: map[string]string{
+				"dev.vinoc.process.kind": "gui",
+			},
+			wantDevs:   []Device{},
+			wantMounts: []Mount{},
+		},
 		{
 			name: "invalid mount missing source",
 			annotations: map[string]string{
@@ -69,3 +184,107 @@ func TestParse(t *testing.T) {
 		})
 	}
 }
+
+func TestParseConfig(t *testing.T) {
+	with := func(kv ...string) map[string]string {
+		a := map[string]string{}
+		for i := 0; i < len(kv); i += 2 {
+			a[kv[i]] = kv[i+1]
+		}
+		return a
+	}
+
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		want        Config
+		wantErr     bool
+	}{
+		{
+			name:        "defaults",
+			annotations: with(),
+		},
+		{
+			name:        "process",
+			annotations: with("dev.vinoc.process.kind", "gui", "dev.vinoc.process.stop_timeout", "1m30s"),
+			want:        Config{Process: Process{Kind: "gui", StopTimeout: "1m30s"}},
+		},
+		{
+			name:        "service",
+			annotations: with("dev.vinoc.process.service", "Spooler"),
+			want:        Config{Process: Process{Service: "Spooler"}},
+		},
+		{
+			name:        "invalid service",
+			annotations: with("dev.vinoc.process.service", `C:\svc`),
+			wantErr:     true,
+		},
+		{
+			name:        "debug channels",
+			annotations: with("dev.vinoc.debug.channels", `["debugstr","eventlog"]`),
+			want:        Config{Debug: Debug{Channels: []string{"debugstr", "eventlog"}}},
+		},
+		{
+			name:        "invalid debug channel",
+			annotations: with("dev.vinoc.debug.channels", `["+all"]`),
+			wantErr:     true,
+		},
+		{
+			name: "debug profile",
+			annotations: with(
+				"dev.vinoc.debug.profile", "relay",
+				"dev.vinoc.debug.relay", `["ws2_32","kernel32.CreateFileW"]`,
+				"dev.vinoc.debug.log", "fd:3",
+			),
+			want: Config{Debug: Debug{Profile: "relay", Relay: []string{"ws2_32", "kernel32.CreateFileW"}, Log: "fd:3"}},
+		},
+		{
+			name:        "invalid debug profile",
+			annotations: with("dev.vinoc.debug.profile", "loud"),
+			wantErr:     true,
+		},
+		{
+			name:        "relative debug log",
+			annotations: with("dev.vinoc.debug.log", "wine.log"),
+			wantErr:     true,
+		},
+		{
+			name:        "display",
+			annotations: with("dev.vinoc.display.resolution", "1920x1080", "dev.vinoc.display.depth", "16", "dev.vinoc.display.number", "7", "dev.vinoc.display.vnc", "true"),
+			want:        Config{Display: Display{Resolution: "1920x1080", Depth: 16, Number: 7, VNC: true}},
+		},
+		{
+			name:        "invalid display depth",
+			annotations: with("dev.vinoc.display.depth", "12"),
+			wantErr:     true,
+		},
+		{
+			name:        "invalid kind",
+			annotations: with("dev.vinoc.process.kind", "daemon"),
+			wantErr:     true,
+		},
+		{
+			name:        "invalid stop timeout",
+			annotations: with("dev.vinoc.process.stop_timeout", "10"),
+			wantErr:     true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseConfig(tt.annotations)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("config = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
@@ -12,6 +12,51 @@ func Parse(annotations map[string]string) ([]Device, []Mount, error) {
 		return nil, nil, err
 	}
 
+	var root struct {
+		Dev struct {
+			Vinoc struct {
+				Devices map[string]Device `json:"devices"`
+				Mounts  map[string]Mount  `json:"mounts"`
+			} `json:"vinoc"`
+		} `json:"dev"`
+	}
+	if err := decode(nest(annotations), &root); err != nil {
+		return nil, nil, err
+	}
+
+	devices := make([]Device, 0, len(root.Dev.Vinoc.Devices))
+	for _, d := range root.Dev.Vinoc.Devices {
+		devices = append(devices, d)
+	}
+	mounts := make([]Mount, 0, len(root.Dev.Vinoc.Mounts))
+	for _, m := range root.Dev.Vinoc.Mounts {
+		mounts = append(mounts, m)
+	}
+
+	return devices, mounts, nil
+}
+
+// ParseConfig validates annotations and returns the container settings they
+// carry besides devices and mounts.
+func ParseConfig(annotations map[string]string) (Config, error) {
+	if err := Validate(annotations); err != nil {
+		return Config{}, err
+	}
+
+	var root struct {
+		Dev struct {
+			Vinoc Config `json:"vinoc"`
+		} `json:"dev"`
+	}
+	if err := decode(nest(annotations), &root); err != nil {
+		return Config{}, err
+	}
+	return root.Dev.Vinoc, nil
+}
+
+// nest turns dotted annotation keys into nested objects, decoding values
+// that are valid JSON.
+func nest(annotations map[string]string) map[string]interface{} {
 	data := map[string]interface{}{}
 	for k, v := range annotations {
 		parts := strings.Split(k, ".")
@@ -33,32 +78,16 @@ func Parse(annotations map[string]string) ([]Device, []Mount, error) {
 			m = next
 		}
 	}
+	return data
+}
 
-	var root struct {
-		Dev struct {
-			Vinoc struct {
-				Devices map[string]Device `json:"devices"`
-				Mounts  map[string]Mount  `json:"mounts"`
-			} `json:"vinoc"`
-		} `json:"dev"`
-	}
-
+func decode(data map[string]interface{}, v interface{}) error {
 	b, err := json.Marshal(data)
 	if err != nil {
-		return nil, nil, fmt.Errorf("marshal annotations: %w", err)
+		return fmt.Errorf("marshal annotations: %w", err)
 	}
-	if err := json.Unmarshal(b, &root); err != nil {
-		return nil, nil, fmt.Errorf("unmarshal annotations: %w", err)
-	}
-
-	devices := make([]Device, 0, len(root.Dev.Vinoc.Devices))
-	for _, d := range root.Dev.Vinoc.Devices {
-		devices = append(devices, d)
+	if err := json.Unmarshal(b, v); err != nil {
+		return fmt.Errorf("unmarshal annotations: %w", err)
 	}
-	mounts := make([]Mount, 0, len(root.Dev.Vinoc.Mounts))
-	for _, m := range root.Dev.Vinoc.Mounts {
-		mounts = append(mounts, m)
-	}
-
-	return devices, mounts, nil
+	return nil
 }
@@ -1,17 +1,54 @@
 package labels
 
+import "strings"
+
+// ClassAudio is the device class of a PulseAudio socket. Its devices have no
+// label: they are the audio endpoint of every Windows program.
+const ClassAudio = "audio"
+
+// ClassLPT is the device class of a parallel port sink: a device node, a
+// FIFO or a file print jobs sent to its LPT label end up in.
+const ClassLPT = "lpt"
+
+// ClassUSB is the device class of USB devices, HID dongles among them,
+// passed through by vendor:product id rather than by path.
+const ClassUSB = "usb"
+
+// Access modes of devices and mounts.
+const (
+	ModeRO = "ro"
+	ModeRW = "rw"
+)
+
+// Mount types.
+const (
+	MountBind = "bind"
+	// MountPrinter backs an LPT port with a device node, a FIFO, a file or
+	// a CUPS socket.
+	MountPrinter = "printer"
+)
+
 // Device describes a host device exposed to the guest.
 type Device struct {
-	Class    string `json:"class"`
-	Path     string `json:"path"`
-	Label    string `json:"label"`
+	Class string `json:"class"`
+	Path  string `json:"path"`
+	Label string `json:"label"`
+	// ID is the vendor:product id of a USB device.
+	ID       string `json:"id,omitempty"`
 	Mode     string `json:"mode,omitempty"`
 	Optional bool   `json:"optional,omitempty"`
 	Backend  string `json:"backend,omitempty"`
 }
 
+// Family reports whether d is a family of device nodes, a glob pattern
+// such as /dev/dri/* rather than the path of a single node.
+func (d Device) Family() bool {
+	return strings.ContainsAny(d.Path, "*?[")
+}
+
 // Mount describes a host mount exposed to the guest.
 type Mount struct {
+	Type             string `json:"type,omitempty"`
 	SourcePath       string `json:"source_path,omitempty"`
 	Volume           string `json:"volume,omitempty"`
 	DestinationLabel string `json:"destination_label"`
@@ -19,3 +56,35 @@ type Mount struct {
 	Mode             string `json:"mode,omitempty"`
 	Optional         bool   `json:"optional,omitempty"`
 }
+
+// Config holds the container settings carried by annotations besides
+// devices and mounts.
+type Config struct {
+	Process Process `json:"process"`
+	Debug   Debug   `json:"debug"`
+	Display Display `json:"display"`
+}
+
+// Process configures how the container's Windows main process is run and
+// stopped.
+type Process struct {
+	Kind        string `json:"kind,omitempty"`
+	StopTimeout string `json:"stop_timeout,omitempty"`
+	Service     string `json:"service,omitempty"`
+}
+
+// Debug configures which of wine's diagnostics are surfaced.
+type Debug struct {
+	Channels []string `json:"channels,omitempty"`
+	Profile  string   `json:"profile,omitempty"`
+	Relay    []string `json:"relay,omitempty"`
+	Log      string   `json:"log,omitempty"`
+}
+
+// Display configures the container's virtual display.
+type Display struct {
+	Resolution string `json:"resolution,omitempty"`
+	Depth      int    `json:"depth,omitempty"`
+	Number     int    `json:"number,omitempty"`
+	VNC        bool   `json:"vnc,omitempty"`
+}
@@ -0,0 +1,119 @@
+package launcher
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"time"
+)
+
+// EnvAudio tells launchers where the container's audio goes: AudioHost when
+// a host PulseAudio socket is bound in, AudioNull when the launcher of the
+// main process serves a null sink instead.
+const EnvAudio = "VINO_AUDIO"
+
+// Audio backends.
+const (
+	AudioHost = "host"
+	AudioNull = "null"
+)
+
+// AudioSocket is where the host's PulseAudio socket is bound in the
+// container.
+const AudioSocket = "/run/vino-audio/native"
+
+// NullSinkDir holds, inside the container, the runtime files of the null
+// sink server, its socket included.
+const NullSinkDir = "/tmp/.vino-audio"
+
+// AudioEnviron returns the environment pointing PulseAudio clients, wine's
+// audio driver among them, at the host's socket when host is set or at the
+// null sink otherwise.
+func AudioEnviron(host bool) []string {
+	if host {
+		return []string{EnvAudio + "=" + AudioHost, "PULSE_SERVER=unix:" + AudioSocket}
+	}
+	return []string{EnvAudio + "=" + AudioNull, "PULSE_SERVER=unix:" + filepath.Join(NullSinkDir, "native")}
+}
+
+// NullSink is a PulseAudio server playing to a sink that discards
+// everything, so that programs requiring an audio device find one.
+type NullSink struct {
+	// Dir holds the server's socket and runtime files. Defaults to
+	// NullSinkDir.
+	Dir string
+	// PulseAudio is the server binary. Defaults to pulseaudio.
+	PulseAudio string
+	Logger     *slog.Logger
+}
+
+// Start starts the server and waits for its socket. stop terminates it.
+func (n *NullSink) Start(ctx context.Context, timeout time.Duration) (stop func(), err error) {
+	dir := n.Dir
+	if dir == "" {
+		dir = NullSinkDir
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	socket := filepath.Join(dir, "native")
+	os.Remove(socket)
+
+	bin := n.PulseAudio
+	if bin == "" {
+		bin = "pulseaudio"
+	}
+	cmd := exec.Command(bin,
+		"--daemonize=no", "--exit-idle-time=-1", "--use-pid-file=no", "-n",
+		"--load=module-native-protocol-unix socket="+socket+" auth-anonymous=1",
+		"--load=module-null-sink sink_name=vino_null",
+	)
+	cmd.Env = append(os.Environ(), "PULSE_RUNTIME_PATH="+dir, "PULSE_STATE_PATH="+dir)
+	stop, exited, err := startHelper(n.logger(), "pulseaudio", cmd)
+	if err != nil {
+		return nil, fmt.Errorf("start null sink: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	ready := make(chan error, 1)
+	go func() { ready <- waitPath(ctx, socket) }()
+	select {
+	case err := <-ready:
+		if err != nil {
+			stop()
+			return nil, fmt.Errorf("null sink not ready: %w", err)
+		}
+	case err := <-exited:
+		return nil, fmt.Errorf("null sink exited: %v", err)
+	}
+	n.logger().Info("null sink ready", "socket", socket)
+	return stop, nil
+}
+
+func (n *NullSink) logger() *slog.Logger {
+	if n.Logger != nil {
+		return n.Logger
+	}
+	return slog.Default()
+}
+
+// driversKey holds the drivers wine loads.
+const driversKey = `HKCU\Software\Wine\Drivers`
+
+// SetAudioDriver makes wine play audio through PulseAudio, whatever driver
+// the prefix was set up with.
+func SetAudioDriver(ctx context.Context, wine string, env []string, stderr io.Writer) error {
+	cmd := exec.CommandContext(ctx, wine, "reg", "add", driversKey, "/v", "Audio", "/t", "REG_SZ", "/d", "pulse", "/f")
+	cmd.Env = WineDebugEnv(env, ProfileQuiet, nil)
+	cmd.Stdout = stderr
+	cmd.Stderr = stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("set audio driver: %w", err)
+	}
+	return nil
+}
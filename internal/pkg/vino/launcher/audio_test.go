@@ -0,0 +1,92 @@
+package launcher
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAudioEnviron(t *testing.T) {
+	got := AudioEnviron(true)
+	if want := []string{EnvAudio + "=host", "PULSE_SERVER=unix:/run/vino-audio/native"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("AudioEnviron(true) = %v, want %v", got, want)
+	}
+	got = AudioEnviron(false)
+	if want := []string{EnvAudio + "=null", "PULSE_SERVER=unix:/tmp/.vino-audio/native"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("AudioEnviron(false) = %v, want %v", got, want)
+	}
+}
+
+func TestNullSinkStart(t *testing.T) {
+	dir := t.TempDir()
+	state := filepath.Join(dir, "audio")
+	bin := filepath.Join(dir, "pulseaudio")
+	script := `#!/bin/sh
+for a; do echo "$a"; done > ` + filepath.Join(dir, "args") + `
+touch "$PULSE_RUNTIME_PATH/native"
+trap 'rm -f "$PULSE_RUNTIME_PATH/native"; exit 0' TERM
+while :; do sleep 0.05; done
+`
+	if err := os.WriteFile(bin, []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	n := &NullSink{Dir: state, PulseAudio: bin}
+	stop, err := n.Start(context.Background(), 5*time.Second)
+	if err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	args, _ := os.ReadFile(filepath.Join(dir, "args"))
+	for _, want := range []string{
+		"--load=module-native-protocol-unix socket=" + filepath.Join(state, "native") + " auth-anonymous=1",
+		"--load=module-null-sink sink_name=vino_null",
+	} {
+		if !strings.Contains(string(args), want+"\n") {
+			t.Errorf("pulseaudio args %q lack %q", args, want)
+		}
+	}
+
+	stop()
+	if _, err := os.Stat(filepath.Join(state, "native")); !os.IsNotExist(err) {
+		t.Errorf("null sink still running after stop: %v", err)
+	}
+}
+
+func TestNullSinkStartFails(t *testing.T) {
+	dir := t.TempDir()
+	bin := filepath.Join(dir, "pulseaudio")
+	if err := os.WriteFile(bin, []byte("#!/bin/sh\nexit 1\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	n := &NullSink{Dir: dir, PulseAudio: bin}
+	if _, err := n.Start(context.Background(), 5*time.Second); err == nil {
+		t.Errorf("Start succeeded with a failing server")
+	}
+}
+
+func TestSetAudioDriver(t *testing.T) {
+	dir := t.TempDir()
+	calls := filepath.Join(dir, "calls")
+	wine := filepath.Join(dir, "wine")
+	script := "#!/bin/sh\necho \"$WINEDEBUG $*\" >> " + calls + "\n"
+	if err := os.WriteFile(wine, []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	var stderr bytes.Buffer
+	if err := SetAudioDriver(context.Background(), wine, []string{"WINEDEBUG=+relay"}, &stderr); err != nil {
+		t.Fatalf("SetAudioDriver: %v", err)
+	}
+	data, err := os.ReadFile(calls)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := strings.TrimSpace(string(data)), `-all reg add HKCU\Software\Wine\Drivers /v Audio /t REG_SZ /d pulse /f`; got != want {
+		t.Errorf("wine call = %q, want %q", got, want)
+	}
+}
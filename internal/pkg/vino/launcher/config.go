@@ -0,0 +1,113 @@
+// Package launcher holds the pieces of the wine launcher that run a
+// container's Windows processes: its configuration and the translation of
+// POSIX signals into Windows stop requests.
+package launcher
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/TheGrizzlyDev/vino/internal/pkg/vino/labels"
+)
+
+// Environment variables carrying the launcher configuration from the
+// container's annotations to the launcher, which can't read them itself.
+const (
+	EnvProcessKind = "VINO_PROCESS_KIND"
+	EnvStopTimeout = "VINO_STOP_TIMEOUT"
+	// EnvDebugChannels and EnvDebugRelay are comma separated lists.
+	EnvDebugChannels = "VINO_DEBUG_CHANNELS"
+	EnvDebugProfile  = "VINO_DEBUG_PROFILE"
+	EnvDebugRelay    = "VINO_DEBUG_RELAY"
+	EnvDebugLog      = "VINO_DEBUG_LOG"
+)
+
+// LogPath is where launchers log, inside the container, when vino relays
+// their records to the runtime's log: the container doesn't get to write
+// to a file of the host.
+const LogPath = "/var/lib/vino/launcher.jsonl"
+
+// DefaultStopTimeout leaves a stopping process some time to shut down while
+// staying below the 10s Docker waits before killing the container.
+const DefaultStopTimeout = 8 * time.Second
+
+// Options configures a launch.
+type Options struct {
+	Kind        Kind
+	StopTimeout time.Duration
+	// Service, when set, runs the main process as the Windows service of
+	// that name. It comes from the launcher's flags, not the environment
+	// exec'd processes inherit too.
+	Service string
+	// DebugChannels are the WINEDEBUG channels forwarded as structured
+	// lines.
+	DebugChannels []string
+	// DebugProfile names the WINEDEBUG setting to run with, see Profiles.
+	DebugProfile string
+	// RelayDLLs restricts the relay profile to these DLLs.
+	RelayDLLs []string
+	// DebugLog is where wine's diagnostics go instead of stderr, see
+	// OpenDiagnostics.
+	DebugLog string
+}
+
+// Environ returns the environment passing cfg to the launcher.
+func Environ(cfg labels.Config) []string {
+	var env []string
+	if cfg.Process.Kind != "" {
+		env = append(env, EnvProcessKind+"="+cfg.Process.Kind)
+	}
+	if cfg.Process.StopTimeout != "" {
+		env = append(env, EnvStopTimeout+"="+cfg.Process.StopTimeout)
+	}
+	if len(cfg.Debug.Channels) > 0 {
+		env = append(env, EnvDebugChannels+"="+strings.Join(cfg.Debug.Channels, ","))
+	}
+	if cfg.Debug.Profile != "" {
+		env = append(env, EnvDebugProfile+"="+cfg.Debug.Profile)
+	}
+	if len(cfg.Debug.Relay) > 0 {
+		env = append(env, EnvDebugRelay+"="+strings.Join(cfg.Debug.Relay, ","))
+	}
+	if cfg.Debug.Log != "" {
+		env = append(env, EnvDebugLog+"="+cfg.Debug.Log)
+	}
+	return env
+}
+
+// FromEnvironment reads the launcher options from the environment.
+func FromEnvironment() (Options, error) {
+	opts := Options{Kind: KindConsole, StopTimeout: DefaultStopTimeout}
+	if v := os.Getenv(EnvProcessKind); v != "" {
+		switch k := Kind(v); k {
+		case KindConsole, KindGUI, KindService:
+			opts.Kind = k
+		default:
+			return opts, fmt.Errorf("%s: unknown process kind %q", EnvProcessKind, v)
+		}
+	}
+	if v := os.Getenv(EnvStopTimeout); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return opts, fmt.Errorf("%s: %w", EnvStopTimeout, err)
+		}
+		opts.StopTimeout = d
+	}
+	if v := os.Getenv(EnvDebugChannels); v != "" {
+		opts.DebugChannels = strings.Split(v, ",")
+	}
+	opts.DebugProfile = os.Getenv(EnvDebugProfile)
+	if v := os.Getenv(EnvDebugRelay); v != "" {
+		opts.RelayDLLs = strings.Split(v, ",")
+		if opts.DebugProfile == "" {
+			opts.DebugProfile = ProfileRelay
+		}
+	}
+	if _, ok := Profiles[opts.DebugProfile]; opts.DebugProfile != "" && !ok {
+		return opts, fmt.Errorf("%s: unknown profile %q", EnvDebugProfile, opts.DebugProfile)
+	}
+	opts.DebugLog = os.Getenv(EnvDebugLog)
+	return opts, nil
+}
@@ -0,0 +1,244 @@
+package launcher
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log/slog"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DebugLine is a line wine printed through one of its debug channels.
+type DebugLine struct {
+	// Process is the Windows process id, only printed with +pid.
+	Process string
+	Thread  string
+	// Class is one of err, warn, fixme and trace.
+	Class    string
+	Channel  string
+	Function string
+	Message  string
+}
+
+// debugLineRe matches wine's "[[pid:]tid:]class:channel:function message"
+// debug output.
+var debugLineRe = regexp.MustCompile(`^(?:([0-9a-fA-F]+):)?(?:([0-9a-fA-F]+):)?(err|warn|fixme|trace):([\w.-]+):(\S+) ?(.*)$`)
+
+// ParseDebugLine decodes a line of wine's debug output.
+func ParseDebugLine(line string) (DebugLine, bool) {
+	m := debugLineRe.FindStringSubmatch(strings.TrimRight(line, "\r\n"))
+	if m == nil {
+		return DebugLine{}, false
+	}
+	process, thread := m[1], m[2]
+	if thread == "" {
+		process, thread = "", process
+	}
+	return DebugLine{
+		Process:  process,
+		Thread:   thread,
+		Class:    m[3],
+		Channel:  m[4],
+		Function: m[5],
+		Message:  unquoteDebugStr(m[6]),
+	}, true
+}
+
+// unquoteDebugStr turns a message printed with debugstr_a, "...", or
+// debugstr_w, L"...", back into plain text. Anything else is returned as is.
+func unquoteDebugStr(msg string) string {
+	quoted := strings.TrimPrefix(msg, "L")
+	if len(quoted) < 2 || quoted[0] != '"' || quoted[len(quoted)-1] != '"' {
+		return msg
+	}
+	if s, err := strconv.Unquote(quoted); err == nil {
+		return s
+	}
+	return quoted[1 : len(quoted)-1]
+}
+
+// lineWriter hands every complete line written to it to line.
+type lineWriter struct {
+	line func([]byte)
+
+	mu  sync.Mutex
+	buf []byte
+}
+
+func (w *lineWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.buf = append(w.buf, p...)
+	for {
+		i := bytes.IndexByte(w.buf, '\n')
+		if i < 0 {
+			break
+		}
+		w.line(w.buf[:i+1])
+		w.buf = w.buf[i+1:]
+	}
+	return len(p), nil
+}
+
+// Flush hands out a trailing line without newline.
+func (w *lineWriter) Flush() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if len(w.buf) > 0 {
+		w.line(append(w.buf, '\n'))
+		w.buf = nil
+	}
+}
+
+// eventTypes names the event log entry types after the debug class wine
+// reports them with.
+var eventTypes = map[string]string{
+	"err":   "Error",
+	"warn":  "Warning",
+	"trace": "Information",
+}
+
+// EventLogWriter splits wine's stderr: event log entries a program reports
+// go to Out, one "[Type] message" line each, everything else to Err.
+type EventLogWriter struct {
+	Out io.Writer
+	Err io.Writer
+
+	once sync.Once
+	lw   *lineWriter
+}
+
+func (w *EventLogWriter) Write(p []byte) (int, error) {
+	return w.lines().Write(p)
+}
+
+// Flush writes out a trailing line without newline.
+func (w *EventLogWriter) Flush() {
+	w.lines().Flush()
+}
+
+func (w *EventLogWriter) lines() *lineWriter {
+	w.once.Do(func() { w.lw = &lineWriter{line: w.line} })
+	return w.lw
+}
+
+func (w *EventLogWriter) line(line []byte) {
+	// ReportEventW prints every string of an entry on the eventlog channel,
+	// with the class matching the entry type.
+	if d, ok := ParseDebugLine(string(line)); ok && d.Channel == "eventlog" && d.Function == "ReportEventW" {
+		if t, ok := eventTypes[d.Class]; ok {
+			for _, msg := range strings.Split(strings.TrimRight(d.Message, "\r\n"), "\n") {
+				io.WriteString(w.Out, "["+t+"] "+strings.TrimRight(msg, "\r")+"\n")
+			}
+			return
+		}
+	}
+	w.Err.Write(line)
+}
+
+// DebugForwarder sorts wine's stderr. Lines wine prints on Channels are
+// re-emitted as structured records through Handler. Wine's other
+// diagnostics go to Diagnostics when set, and everything else, the
+// program's own output, to Out.
+type DebugForwarder struct {
+	Channels    []string
+	Handler     slog.Handler
+	Diagnostics io.Writer
+	Out         io.Writer
+
+	once sync.Once
+	lw   *lineWriter
+}
+
+func (f *DebugForwarder) Write(p []byte) (int, error) {
+	return f.lines().Write(p)
+}
+
+// Flush forwards a trailing line without newline.
+func (f *DebugForwarder) Flush() {
+	f.lines().Flush()
+}
+
+func (f *DebugForwarder) lines() *lineWriter {
+	f.once.Do(func() { f.lw = &lineWriter{line: f.line} })
+	return f.lw
+}
+
+func (f *DebugForwarder) line(line []byte) {
+	d, ok := ParseDebugLine(string(line))
+	if !ok || f.Handler == nil || !slices.Contains(f.Channels, d.Channel) {
+		if f.Diagnostics != nil && (ok || isWineMessage(line)) {
+			f.Diagnostics.Write(line)
+			return
+		}
+		f.Out.Write(line)
+		return
+	}
+	r := slog.NewRecord(time.Now(), debugLevel(d), strings.TrimRight(d.Message, "\r\n"), 0)
+	r.AddAttrs(
+		slog.String("channel", d.Channel),
+		slog.String("class", d.Class),
+		slog.String("function", d.Function),
+		slog.String("thread", d.Thread),
+	)
+	if d.Process != "" {
+		r.AddAttrs(slog.String("process", d.Process))
+	}
+	f.Handler.Handle(context.Background(), r)
+}
+
+// isWineMessage reports whether line is one of the messages wine and
+// wineserver print outside of debug channels.
+func isWineMessage(line []byte) bool {
+	return bytes.HasPrefix(line, []byte("wine: ")) ||
+		bytes.HasPrefix(line, []byte("wineserver: ")) ||
+		bytes.HasPrefix(line, []byte("wine client error:"))
+}
+
+// debugLevel maps a debug line to a log level. Debug strings are what the
+// program chose to print, whatever class wine prints them with.
+func debugLevel(d DebugLine) slog.Level {
+	switch {
+	case d.Channel == "debugstr":
+		return slog.LevelInfo
+	case d.Class == "err":
+		return slog.LevelError
+	case d.Class == "warn", d.Class == "fixme":
+		return slog.LevelWarn
+	default:
+		return slog.LevelInfo
+	}
+}
+
+// DebugChannels returns the WINEDEBUG spec enabling every class of channels.
+func DebugChannels(channels []string) string {
+	specs := make([]string, len(channels))
+	for i, c := range channels {
+		specs[i] = "+" + c
+	}
+	return strings.Join(specs, ",")
+}
+
+// AppendWineDebug enables the WINEDEBUG channels in spec on top of the ones
+// env already enables.
+func AppendWineDebug(env []string, spec string) []string {
+	out := append([]string{}, env...)
+	if spec == "" {
+		return out
+	}
+	for i := len(out) - 1; i >= 0; i-- {
+		if v, ok := strings.CutPrefix(out[i], "WINEDEBUG="); ok {
+			if v != "" {
+				spec = v + "," + spec
+			}
+			out[i] = "WINEDEBUG=" + spec
+			return out
+		}
+	}
+	return append(out, "WINEDEBUG="+spec)
+}
@@ -0,0 +1,132 @@
+package launcher
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"reflect"
+	"testing"
+)
+
+func TestParseDebugLine(t *testing.T) {
+	tests := []struct {
+		line string
+		want DebugLine
+		ok   bool
+	}{
+		{
+			line: `0124:err:eventlog:ReportEventW L"Service failed:\n disk full"`,
+			want: DebugLine{Thread: "0124", Class: "err", Channel: "eventlog", Function: "ReportEventW", Message: "Service failed:\n disk full"},
+			ok:   true,
+		},
+		{
+			line: `0020:0024:fixme:advapi:RegisterEventSourceW (L"",L"svc"): stub`,
+			want: DebugLine{Process: "0020", Thread: "0024", Class: "fixme", Channel: "advapi", Function: "RegisterEventSourceW", Message: `(L"",L"svc"): stub`},
+			ok:   true,
+		},
+		{
+			line: `0024:warn:debugstr:OutputDebugStringA "connected to db\n"`,
+			want: DebugLine{Thread: "0024", Class: "warn", Channel: "debugstr", Function: "OutputDebugStringA", Message: "connected to db\n"},
+			ok:   true,
+		},
+		{line: "hello from the service"},
+	}
+	for _, tt := range tests {
+		got, ok := ParseDebugLine(tt.line)
+		if ok != tt.ok || got != tt.want {
+			t.Errorf("ParseDebugLine(%q) = %+v, %v; want %+v, %v", tt.line, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestEventLogWriter(t *testing.T) {
+	var out, errOut bytes.Buffer
+	w := &EventLogWriter{Out: &out, Err: &errOut}
+	for _, chunk := range []string{
+		"0124:trace:eventlog:ReportEventW L\"started\"\n0124:warn:event",
+		"log:ReportEventW L\"low on disk\"\n",
+		"0124:fixme:eventlog:RegisterEventSourceW stub\n",
+		"0124:err:eventlog:ReportEventW L\"crashed\"",
+	} {
+		w.Write([]byte(chunk))
+	}
+	w.Flush()
+
+	if want := "[Information] started\n[Warning] low on disk\n[Error] crashed\n"; out.String() != want {
+		t.Errorf("event log = %q, want %q", out.String(), want)
+	}
+	if want := "0124:fixme:eventlog:RegisterEventSourceW stub\n"; errOut.String() != want {
+		t.Errorf("stderr = %q, want %q", errOut.String(), want)
+	}
+}
+
+func TestDebugForwarder(t *testing.T) {
+	var structured, raw bytes.Buffer
+	f := &DebugForwarder{
+		Channels: []string{"debugstr", "eventlog"},
+		Handler:  slog.NewJSONHandler(&structured, nil),
+		Out:      &raw,
+	}
+	f.Write([]byte("0020:0024:warn:debugstr:OutputDebugStringA \"connected\\n\"\n" +
+		"0024:fixme:d3d:wined3d_guess_card No card selector available\n" +
+		"program output\n" +
+		"0024:err:eventlog:ReportEventW L\"disk full\""))
+	f.Flush()
+
+	if want := "0024:fixme:d3d:wined3d_guess_card No card selector available\nprogram output\n"; raw.String() != want {
+		t.Errorf("raw = %q, want %q", raw.String(), want)
+	}
+
+	var got []map[string]any
+	dec := json.NewDecoder(&structured)
+	for dec.More() {
+		var rec map[string]any
+		if err := dec.Decode(&rec); err != nil {
+			t.Fatalf("decode record: %v", err)
+		}
+		delete(rec, "time")
+		got = append(got, rec)
+	}
+	want := []map[string]any{
+		{"level": "INFO", "msg": "connected", "channel": "debugstr", "class": "warn", "function": "OutputDebugStringA", "thread": "0024", "process": "0020"},
+		{"level": "ERROR", "msg": "disk full", "channel": "eventlog", "class": "err", "function": "ReportEventW", "thread": "0024"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("records = %v, want %v", got, want)
+	}
+}
+
+func TestDebugForwarderDiagnostics(t *testing.T) {
+	var out, diagnostics bytes.Buffer
+	f := &DebugForwarder{Diagnostics: &diagnostics, Out: &out}
+	f.Write([]byte("starting\n" +
+		"0024:fixme:ntdll:NtQuerySystemInformation info_class 0x99\n" +
+		"fixme:heap:RtlSetHeapInformation stub\n" +
+		"wine: Unhandled page fault on read access to 00000000\n" +
+		"done\n"))
+
+	if want := "starting\ndone\n"; out.String() != want {
+		t.Errorf("out = %q, want %q", out.String(), want)
+	}
+	want := "0024:fixme:ntdll:NtQuerySystemInformation info_class 0x99\n" +
+		"fixme:heap:RtlSetHeapInformation stub\n" +
+		"wine: Unhandled page fault on read access to 00000000\n"
+	if diagnostics.String() != want {
+		t.Errorf("diagnostics = %q, want %q", diagnostics.String(), want)
+	}
+}
+
+func TestAppendWineDebug(t *testing.T) {
+	got := AppendWineDebug([]string{"A=1", "WINEDEBUG=-all"}, DebugChannels([]string{"debugstr", "eventlog"}))
+	if want := []string{"A=1", "WINEDEBUG=-all,+debugstr,+eventlog"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("AppendWineDebug = %v, want %v", got, want)
+	}
+	got = AppendWineDebug([]string{"A=1"}, "+eventlog")
+	if want := []string{"A=1", "WINEDEBUG=+eventlog"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("AppendWineDebug = %v, want %v", got, want)
+	}
+	got = AppendWineDebug([]string{"A=1"}, "")
+	if want := []string{"A=1"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("AppendWineDebug = %v, want %v", got, want)
+	}
+}
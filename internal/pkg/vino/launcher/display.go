@@ -0,0 +1,265 @@
+package launcher
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/TheGrizzlyDev/vino/internal/pkg/vino/labels"
+	"golang.org/x/sys/unix"
+)
+
+// EnvDisplayScreen carries the WxHxD screen of the container's virtual
+// display. Its presence tells launchers that the display at DISPLAY is
+// managed by vino.
+const EnvDisplayScreen = "VINO_DISPLAY_SCREEN"
+
+// EnvDisplayVNC asks the launcher owning the display to serve it over VNC.
+const EnvDisplayVNC = "VINO_DISPLAY_VNC"
+
+// Display defaults.
+const (
+	DefaultDisplayNumber     = 99
+	DefaultDisplayResolution = "1280x1024"
+	DefaultDisplayDepth      = 24
+)
+
+// X11SocketDir is where X servers create their sockets.
+const X11SocketDir = "/tmp/.X11-unix"
+
+// DisplayStateDir holds, inside the container, the framebuffer of the
+// virtual display and its VNC socket. The host reaches them through
+// /proc/<pid>/root.
+const DisplayStateDir = "/tmp/.vino-display"
+
+// Files in DisplayStateDir.
+const (
+	// FramebufferFile is the XWD image Xvfb maps its screen to.
+	FramebufferFile = "Xvfb_screen0"
+	VNCSocket       = "vnc.sock"
+)
+
+// DisplayEnviron returns the environment making processes use the virtual
+// display cfg describes.
+func DisplayEnviron(cfg labels.Display) []string {
+	number, resolution, depth := cfg.Number, cfg.Resolution, cfg.Depth
+	if number == 0 {
+		number = DefaultDisplayNumber
+	}
+	if resolution == "" {
+		resolution = DefaultDisplayResolution
+	}
+	if depth == 0 {
+		depth = DefaultDisplayDepth
+	}
+	env := []string{
+		"DISPLAY=:" + strconv.Itoa(number),
+		fmt.Sprintf("%s=%sx%d", EnvDisplayScreen, resolution, depth),
+	}
+	if cfg.VNC {
+		env = append(env, EnvDisplayVNC+"=1")
+	}
+	return env
+}
+
+// Display is a virtual X server.
+type Display struct {
+	// Name is the display, e.g. ":99".
+	Name string
+	// Screen is the WxHxD geometry of its screen.
+	Screen string
+	// Xvfb is the X server binary. Defaults to Xvfb.
+	Xvfb string
+	// SocketDir defaults to X11SocketDir.
+	SocketDir string
+	// LockDir is where the server's lock file lives. Defaults to /tmp.
+	LockDir string
+	// StateDir, when set, is where the framebuffer and the VNC socket go.
+	StateDir string
+	// VNC serves the display over VNC on a unix socket in StateDir.
+	VNC bool
+	// X11VNC is the VNC server binary. Defaults to x11vnc.
+	X11VNC string
+	Logger *slog.Logger
+}
+
+// Start starts the X server and waits for it to accept connections. stop
+// terminates it.
+func (d *Display) Start(ctx context.Context, timeout time.Duration) (stop func(), err error) {
+	number, err := displayNumber(d.Name)
+	if err != nil {
+		return nil, err
+	}
+	d.clearStaleLock(number)
+
+	bin := d.Xvfb
+	if bin == "" {
+		bin = "Xvfb"
+	}
+	args := []string{d.Name, "-screen", "0", d.Screen, "-nolisten", "tcp"}
+	if d.StateDir != "" {
+		if err := os.MkdirAll(d.StateDir, 0o755); err != nil {
+			return nil, err
+		}
+		args = append(args, "-fbdir", d.StateDir)
+	}
+	stopX, exited, err := d.run("Xvfb", bin, args...)
+	if err != nil {
+		return nil, fmt.Errorf("start X server: %w", err)
+	}
+	stop = stopX
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	ready := make(chan error, 1)
+	go func() { ready <- WaitDisplay(ctx, d.socketDir(), d.Name) }()
+	select {
+	case err := <-ready:
+		if err != nil {
+			stop()
+			return nil, err
+		}
+	case err := <-exited:
+		return nil, fmt.Errorf("X server %s exited: %v", d.Name, err)
+	}
+	d.logger().Info("display ready", "display", d.Name, "screen", d.Screen)
+
+	if d.VNC && d.StateDir != "" {
+		vnc := d.X11VNC
+		if vnc == "" {
+			vnc = "x11vnc"
+		}
+		socket := filepath.Join(d.StateDir, VNCSocket)
+		os.Remove(socket)
+		stopVNC, _, err := d.run("x11vnc", vnc, "-display", d.Name, "-unixsockonly", socket, "-forever", "-shared", "-nopw", "-quiet")
+		if err != nil {
+			d.logger().Warn("start VNC server", "error", err)
+			return stop, nil
+		}
+		d.logger().Info("VNC server started", "socket", socket)
+		return func() { stopVNC(); stopX() }, nil
+	}
+	return stop, nil
+}
+
+// run starts a helper whose output goes to the debug log.
+func (d *Display) run(name, bin string, args ...string) (stop func(), exited <-chan error, err error) {
+	return startHelper(d.logger(), name, exec.Command(bin, args...))
+}
+
+// startHelper starts cmd, a helper whose output goes to the debug log. stop
+// terminates it; exited receives its exit.
+func startHelper(logger *slog.Logger, name string, cmd *exec.Cmd) (stop func(), exited <-chan error, err error) {
+	out := &lineWriter{line: func(line []byte) {
+		logger.Debug(name, "line", strings.TrimRight(string(line), "\n"))
+	}}
+	cmd.Stdout = out
+	cmd.Stderr = out
+	if err := cmd.Start(); err != nil {
+		return nil, nil, err
+	}
+	done := make(chan error, 1)
+	go func() {
+		done <- cmd.Wait()
+		out.Flush()
+	}()
+	stop = func() {
+		cmd.Process.Signal(unix.SIGTERM)
+		select {
+		case <-done:
+		case <-time.After(2 * time.Second):
+			cmd.Process.Kill()
+			<-done
+		}
+	}
+	return stop, done, nil
+}
+
+// clearStaleLock removes the lock file and socket an X server of a previous
+// run of the container left behind, which would keep a new one from
+// starting.
+func (d *Display) clearStaleLock(number int) {
+	lockDir := d.LockDir
+	if lockDir == "" {
+		lockDir = "/tmp"
+	}
+	lock := filepath.Join(lockDir, fmt.Sprintf(".X%d-lock", number))
+	data, err := os.ReadFile(lock)
+	if err != nil {
+		return
+	}
+	if pid, err := strconv.Atoi(strings.TrimSpace(string(data))); err == nil && pid > 0 {
+		if err := unix.Kill(pid, 0); err == nil || errors.Is(err, unix.EPERM) {
+			return
+		}
+	}
+	d.logger().Info("removing stale X lock", "lock", lock)
+	os.Remove(lock)
+	os.Remove(SocketPath(d.socketDir(), d.Name))
+}
+
+func (d *Display) socketDir() string {
+	if d.SocketDir != "" {
+		return d.SocketDir
+	}
+	return X11SocketDir
+}
+
+func (d *Display) logger() *slog.Logger {
+	if d.Logger != nil {
+		return d.Logger
+	}
+	return slog.Default()
+}
+
+// SocketPath returns the socket the X server of display listens on.
+func SocketPath(socketDir, display string) string {
+	number, _ := displayNumber(display)
+	return filepath.Join(socketDir, "X"+strconv.Itoa(number))
+}
+
+// WaitDisplay waits for the X server of display to create its socket.
+func WaitDisplay(ctx context.Context, socketDir, display string) error {
+	if err := waitPath(ctx, SocketPath(socketDir, display)); err != nil {
+		return fmt.Errorf("display %s not ready: %w", display, err)
+	}
+	return nil
+}
+
+// waitPath waits for path to exist.
+func waitPath(ctx context.Context, path string) error {
+	t := time.NewTicker(50 * time.Millisecond)
+	defer t.Stop()
+	for {
+		if _, err := os.Stat(path); err == nil {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-t.C:
+		}
+	}
+}
+
+// displayNumber returns the number of a local display name like ":99" or
+// ":99.0".
+func displayNumber(display string) (int, error) {
+	v, ok := strings.CutPrefix(display, ":")
+	if !ok {
+		return 0, fmt.Errorf("display %q is not local", display)
+	}
+	v, _, _ = strings.Cut(v, ".")
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		return 0, fmt.Errorf("display %q: %w", display, err)
+	}
+	return n, nil
+}
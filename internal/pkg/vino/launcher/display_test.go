@@ -0,0 +1,140 @@
+package launcher
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/TheGrizzlyDev/vino/internal/pkg/vino/labels"
+)
+
+func TestDisplayEnviron(t *testing.T) {
+	got := DisplayEnviron(labels.Display{})
+	if want := []string{"DISPLAY=:99", EnvDisplayScreen + "=1280x1024x24"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("DisplayEnviron = %v, want %v", got, want)
+	}
+	got = DisplayEnviron(labels.Display{Resolution: "1920x1080", Depth: 16, Number: 7, VNC: true})
+	if want := []string{"DISPLAY=:7", EnvDisplayScreen + "=1920x1080x16", EnvDisplayVNC + "=1"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("DisplayEnviron = %v, want %v", got, want)
+	}
+}
+
+// fakeXvfb writes an X server that records its arguments and creates the
+// socket of the display it is given.
+func fakeXvfb(t *testing.T, dir, socketDir string) string {
+	t.Helper()
+	bin := filepath.Join(dir, "Xvfb")
+	script := `#!/bin/sh
+echo "$*" > ` + filepath.Join(dir, "args") + `
+mkdir -p ` + socketDir + `
+touch ` + socketDir + `/X${1#:}
+trap 'rm -f ` + socketDir + `/X${1#:}; exit 0' TERM
+while :; do sleep 0.05; done
+`
+	if err := os.WriteFile(bin, []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	return bin
+}
+
+func TestDisplayStart(t *testing.T) {
+	dir := t.TempDir()
+	socketDir := filepath.Join(dir, ".X11-unix")
+	// A lock left behind by a server that is gone.
+	if err := os.WriteFile(filepath.Join(dir, ".X42-lock"), []byte("    999999\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	d := &Display{
+		Name:      ":42",
+		Screen:    "800x600x16",
+		Xvfb:      fakeXvfb(t, dir, socketDir),
+		SocketDir: socketDir,
+		LockDir:   dir,
+	}
+	stop, err := d.Start(context.Background(), 5*time.Second)
+	if err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, ".X42-lock")); !os.IsNotExist(err) {
+		t.Errorf("stale lock not removed: %v", err)
+	}
+	args, _ := os.ReadFile(filepath.Join(dir, "args"))
+	if got := strings.TrimSpace(string(args)); got != ":42 -screen 0 800x600x16 -nolisten tcp" {
+		t.Errorf("Xvfb args = %q", got)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := WaitDisplay(ctx, socketDir, ":42.0"); err != nil {
+		t.Errorf("WaitDisplay: %v", err)
+	}
+
+	stop()
+	if _, err := os.Stat(SocketPath(socketDir, ":42")); !os.IsNotExist(err) {
+		t.Errorf("X server still running after stop: %v", err)
+	}
+}
+
+func TestDisplayStartVNC(t *testing.T) {
+	dir := t.TempDir()
+	socketDir := filepath.Join(dir, ".X11-unix")
+	stateDir := filepath.Join(dir, "state")
+	vnc := filepath.Join(dir, "x11vnc")
+	script := "#!/bin/sh\necho \"$*\" > " + filepath.Join(dir, "vnc-args") + "\nexec sleep 60\n"
+	if err := os.WriteFile(vnc, []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	d := &Display{
+		Name:      ":42",
+		Screen:    "800x600x24",
+		Xvfb:      fakeXvfb(t, dir, socketDir),
+		X11VNC:    vnc,
+		SocketDir: socketDir,
+		LockDir:   dir,
+		StateDir:  stateDir,
+		VNC:       true,
+	}
+	stop, err := d.Start(context.Background(), 5*time.Second)
+	if err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	defer stop()
+
+	args, _ := os.ReadFile(filepath.Join(dir, "args"))
+	if want := ":42 -screen 0 800x600x24 -nolisten tcp -fbdir " + stateDir; strings.TrimSpace(string(args)) != want {
+		t.Errorf("Xvfb args = %q, want %q", args, want)
+	}
+	want := "-display :42 -unixsockonly " + filepath.Join(stateDir, VNCSocket) + " -forever -shared -nopw -quiet"
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		args, _ = os.ReadFile(filepath.Join(dir, "vnc-args"))
+		if len(args) > 0 || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if got := strings.TrimSpace(string(args)); got != want {
+		t.Errorf("x11vnc args = %q, want %q", got, want)
+	}
+}
+
+func TestDisplayStartFails(t *testing.T) {
+	dir := t.TempDir()
+	bin := filepath.Join(dir, "Xvfb")
+	if err := os.WriteFile(bin, []byte("#!/bin/sh\necho 'Server is already active' >&2\nexit 1\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	d := &Display{Name: ":42", Screen: "800x600x16", Xvfb: bin, SocketDir: dir, LockDir: dir}
+	if _, err := d.Start(context.Background(), 5*time.Second); err == nil {
+		t.Errorf("Start succeeded with a failing X server")
+	}
+	if _, err := (&Display{Name: "host:0"}).Start(context.Background(), time.Second); err == nil {
+		t.Errorf("Start accepted a remote display")
+	}
+}
@@ -0,0 +1,127 @@
+package launcher
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// EnvPrinterSpool lists, comma separated, the LPT ports whose print jobs the
+// launcher of the main process hands to CUPS.
+const EnvPrinterSpool = "VINO_PRINTER_SPOOL"
+
+// PrinterSpoolDir holds, inside the container, the FIFOs of the LPT ports
+// backed by CUPS.
+const PrinterSpoolDir = "/tmp/.vino-printer"
+
+// PrinterEnviron returns the environment pointing CUPS clients, wine's
+// spooler among them, at the CUPS socket server, and asking for the print
+// jobs of ports to be spooled to it.
+func PrinterEnviron(server string, ports []string) []string {
+	lower := make([]string, len(ports))
+	for i, p := range ports {
+		lower[i] = strings.ToLower(p)
+	}
+	return []string{"CUPS_SERVER=" + server, EnvPrinterSpool + "=" + strings.Join(lower, ",")}
+}
+
+// SpoolPath returns the FIFO in dir collecting the print jobs of port.
+func SpoolPath(dir, port string) string {
+	return filepath.Join(dir, strings.ToLower(port))
+}
+
+// Spooler submits every print job written to FIFO to CUPS. A job is what a
+// program writes between opening the port and closing it.
+type Spooler struct {
+	FIFO string
+	// Lp is the job submission binary. Defaults to lp.
+	Lp     string
+	Env    []string
+	Logger *slog.Logger
+}
+
+// Run spools jobs until ctx is done.
+func (s *Spooler) Run(ctx context.Context) error {
+	stopped := make(chan struct{})
+	defer close(stopped)
+	go func() {
+		select {
+		case <-ctx.Done():
+		case <-stopped:
+			return
+		}
+		// Wake up the reader waiting for the next job. Opening fails until
+		// there is one.
+		t := time.NewTicker(50 * time.Millisecond)
+		defer t.Stop()
+		for {
+			if f, err := os.OpenFile(s.FIFO, os.O_WRONLY|unix.O_NONBLOCK, 0); err == nil {
+				f.Close()
+			}
+			select {
+			case <-stopped:
+				return
+			case <-t.C:
+			}
+		}
+	}()
+	for ctx.Err() == nil {
+		f, err := os.OpenFile(s.FIFO, os.O_RDONLY, 0)
+		if err != nil {
+			return err
+		}
+		job, err := io.ReadAll(f)
+		f.Close()
+		if ctx.Err() != nil {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("read print job: %w", err)
+		}
+		if len(job) == 0 {
+			continue
+		}
+		if err := s.submit(ctx, job); err != nil {
+			s.logger().Warn("submit print job", "port", filepath.Base(s.FIFO), "bytes", len(job), "error", err)
+			continue
+		}
+		s.logger().Info("print job submitted", "port", filepath.Base(s.FIFO), "bytes", len(job))
+	}
+	return nil
+}
+
+func (s *Spooler) submit(ctx context.Context, job []byte) error {
+	bin := s.Lp
+	if bin == "" {
+		bin = "lp"
+	}
+	cmd := exec.CommandContext(ctx, bin, "-o", "raw", "-t", filepath.Base(s.FIFO))
+	cmd.Env = s.Env
+	cmd.Stdin = bytes.NewReader(job)
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		var ee *exec.ExitError
+		if errors.As(err, &ee) && len(bytes.TrimSpace(out)) > 0 {
+			return fmt.Errorf("%s: %s", bin, bytes.TrimSpace(out))
+		}
+		return err
+	}
+	return nil
+}
+
+func (s *Spooler) logger() *slog.Logger {
+	if s.Logger != nil {
+		return s.Logger
+	}
+	return slog.Default()
+}
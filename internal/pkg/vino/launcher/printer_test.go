@@ -0,0 +1,70 @@
+package launcher
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+func TestPrinterEnviron(t *testing.T) {
+	got := PrinterEnviron("/run/cups/cups.sock", []string{"LPT1", "lpt3"})
+	if want := []string{"CUPS_SERVER=/run/cups/cups.sock", EnvPrinterSpool + "=lpt1,lpt3"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("PrinterEnviron = %v, want %v", got, want)
+	}
+}
+
+func TestSpooler(t *testing.T) {
+	dir := t.TempDir()
+	fifo := SpoolPath(dir, "LPT1")
+	if err := unix.Mkfifo(fifo, 0o666); err != nil {
+		t.Fatal(err)
+	}
+	jobs := filepath.Join(dir, "jobs")
+	lp := filepath.Join(dir, "lp")
+	script := "#!/bin/sh\n{ echo \"$*\"; cat; echo; } >> " + jobs + "\n"
+	if err := os.WriteFile(lp, []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	s := &Spooler{FIFO: fifo, Lp: lp}
+	done := make(chan error, 1)
+	go func() { done <- s.Run(ctx) }()
+
+	for _, job := range []string{"RECEIPT 1", "RECEIPT 2"} {
+		if err := os.WriteFile(fifo, []byte(job), 0); err != nil {
+			t.Fatal(err)
+		}
+		// Wait for the job to be submitted before sending the next one.
+		deadline := time.Now().Add(5 * time.Second)
+		for {
+			data, _ := os.ReadFile(jobs)
+			if strings.Contains(string(data), job) || time.Now().After(deadline) {
+				break
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+
+	cancel()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("Run: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run did not return after cancel")
+	}
+
+	data, _ := os.ReadFile(jobs)
+	want := "-o raw -t lpt1\nRECEIPT 1\n-o raw -t lpt1\nRECEIPT 2\n"
+	if string(data) != want {
+		t.Errorf("submitted jobs = %q, want %q", data, want)
+	}
+}
@@ -0,0 +1,108 @@
+package launcher
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"golang.org/x/sys/unix"
+)
+
+// Debug profiles.
+const (
+	ProfileQuiet   = "quiet"
+	ProfileErrors  = "errors"
+	ProfileDefault = "default"
+	ProfileVerbose = "verbose"
+	ProfileRelay   = "relay"
+)
+
+// Profiles maps each debug profile to the WINEDEBUG setting it stands for.
+// default is wine's own: errors and fixmes of every channel.
+var Profiles = map[string]string{
+	ProfileQuiet:   "-all",
+	ProfileErrors:  "fixme-all",
+	ProfileDefault: "",
+	ProfileVerbose: "warn+all",
+	ProfileRelay:   "+relay,+seh,+tid,+loaddll",
+}
+
+// WineDebugEnv returns env with WINEDEBUG set for profile, replacing the
+// image's setting, and channels enabled on top of it.
+func WineDebugEnv(env []string, profile string, channels []string) []string {
+	if profile != "" {
+		out := make([]string, 0, len(env)+1)
+		for _, kv := range env {
+			if !strings.HasPrefix(kv, "WINEDEBUG=") {
+				out = append(out, kv)
+			}
+		}
+		if spec := Profiles[profile]; spec != "" {
+			out = append(out, "WINEDEBUG="+spec)
+		}
+		env = out
+	}
+	return AppendWineDebug(env, DebugChannels(channels))
+}
+
+// relayKey holds the relay settings of the prefix's user.
+const relayKey = `HKCU\Software\Wine\Debug`
+
+// SetRelayDLLs restricts +relay tracing to dlls, each a DLL name or a
+// dll.function, through wine's RelayInclude setting. Without dlls, every
+// DLL is traced again.
+func SetRelayDLLs(ctx context.Context, wine string, env []string, stderr io.Writer, dlls []string) error {
+	args := []string{"reg", "delete", relayKey, "/v", "RelayInclude", "/f"}
+	if len(dlls) > 0 {
+		args = []string{"reg", "add", relayKey, "/v", "RelayInclude", "/t", "REG_SZ", "/d", strings.Join(dlls, ";"), "/f"}
+	}
+	cmd := exec.CommandContext(ctx, wine, args...)
+	// Don't trace reg itself.
+	cmd.Env = WineDebugEnv(env, ProfileQuiet, nil)
+	cmd.Stdout = stderr
+	cmd.Stderr = stderr
+	err := cmd.Run()
+	if err != nil && len(dlls) > 0 {
+		return fmt.Errorf("set RelayInclude: %w", err)
+	}
+	// Deleting a value that was never set fails, which is fine.
+	return nil
+}
+
+// OpenDiagnostics opens dest, an absolute path or fd:N, as the destination
+// of wine's diagnostics. fd:N is written through a duplicate of N, the
+// caller closes what OpenDiagnostics returns. It returns nil when dest is
+// empty.
+func OpenDiagnostics(dest string) (io.WriteCloser, error) {
+	if dest == "" {
+		return nil, nil
+	}
+	if v, ok := strings.CutPrefix(dest, "fd:"); ok {
+		fd, err := strconv.Atoi(v)
+		if err != nil || fd < 0 {
+			return nil, fmt.Errorf("invalid debug log %q", dest)
+		}
+		// Closing the diagnostics must leave fd, e.g. stderr, open.
+		dup, err := unix.FcntlInt(uintptr(fd), unix.F_DUPFD_CLOEXEC, 0)
+		if err != nil {
+			return nil, fmt.Errorf("debug log %s: %w", dest, err)
+		}
+		return os.NewFile(uintptr(dup), dest), nil
+	}
+	if !filepath.IsAbs(dest) {
+		return nil, fmt.Errorf("debug log %q is not an absolute path", dest)
+	}
+	if err := os.MkdirAll(filepath.Dir(dest), 0o755); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(dest, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	return f, nil
+}
@@ -0,0 +1,129 @@
+package launcher
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestWineDebugEnv(t *testing.T) {
+	env := []string{"A=1", "WINEDEBUG=+d3d"}
+	tests := []struct {
+		profile  string
+		channels []string
+		want     []string
+	}{
+		{"", nil, []string{"A=1", "WINEDEBUG=+d3d"}},
+		{"", []string{"debugstr"}, []string{"A=1", "WINEDEBUG=+d3d,+debugstr"}},
+		{ProfileQuiet, nil, []string{"A=1", "WINEDEBUG=-all"}},
+		{ProfileQuiet, []string{"debugstr"}, []string{"A=1", "WINEDEBUG=-all,+debugstr"}},
+		{ProfileDefault, nil, []string{"A=1"}},
+		{ProfileRelay, nil, []string{"A=1", "WINEDEBUG=+relay,+seh,+tid,+loaddll"}},
+	}
+	for _, tt := range tests {
+		if got := WineDebugEnv(env, tt.profile, tt.channels); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("WineDebugEnv(%q, %v) = %v, want %v", tt.profile, tt.channels, got, tt.want)
+		}
+	}
+}
+
+func TestFromEnvironmentDebug(t *testing.T) {
+	t.Setenv(EnvDebugProfile, "")
+	t.Setenv(EnvDebugRelay, "ws2_32,kernel32.CreateFileW")
+	t.Setenv(EnvDebugLog, "fd:3")
+	opts, err := FromEnvironment()
+	if err != nil {
+		t.Fatalf("FromEnvironment: %v", err)
+	}
+	if opts.DebugProfile != ProfileRelay || !reflect.DeepEqual(opts.RelayDLLs, []string{"ws2_32", "kernel32.CreateFileW"}) || opts.DebugLog != "fd:3" {
+		t.Errorf("options = %+v", opts)
+	}
+
+	t.Setenv(EnvDebugProfile, "loud")
+	if _, err := FromEnvironment(); err == nil {
+		t.Errorf("FromEnvironment accepted an unknown profile")
+	}
+}
+
+func TestSetRelayDLLs(t *testing.T) {
+	dir := t.TempDir()
+	calls := filepath.Join(dir, "calls")
+	wine := filepath.Join(dir, "wine")
+	script := "#!/bin/sh\necho \"$WINEDEBUG $*\" >> " + calls + "\n"
+	if err := os.WriteFile(wine, []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	var stderr bytes.Buffer
+	env := []string{"WINEDEBUG=+relay"}
+	if err := SetRelayDLLs(context.Background(), wine, env, &stderr, []string{"ws2_32", "user32.MessageBoxW"}); err != nil {
+		t.Fatalf("SetRelayDLLs: %v", err)
+	}
+	if err := SetRelayDLLs(context.Background(), wine, env, &stderr, nil); err != nil {
+		t.Fatalf("SetRelayDLLs: %v", err)
+	}
+
+	data, err := os.ReadFile(calls)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		`-all reg add HKCU\Software\Wine\Debug /v RelayInclude /t REG_SZ /d ws2_32;user32.MessageBoxW /f`,
+		`-all reg delete HKCU\Software\Wine\Debug /v RelayInclude /f`,
+	}
+	if got := strings.Split(strings.TrimSpace(string(data)), "\n"); !reflect.DeepEqual(got, want) {
+		t.Errorf("wine calls = %q, want %q", got, want)
+	}
+}
+
+func TestOpenDiagnostics(t *testing.T) {
+	if w, err := OpenDiagnostics(""); w != nil || err != nil {
+		t.Errorf("OpenDiagnostics(\"\") = %v, %v; want nil, nil", w, err)
+	}
+
+	path := filepath.Join(t.TempDir(), "log", "wine.log")
+	w, err := OpenDiagnostics(path)
+	if err != nil {
+		t.Fatalf("OpenDiagnostics: %v", err)
+	}
+	w.Write([]byte("0024:fixme:ntdll:stub\n"))
+	w.Close()
+	if data, _ := os.ReadFile(path); string(data) != "0024:fixme:ntdll:stub\n" {
+		t.Errorf("log = %q", data)
+	}
+
+	r, pw, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	defer pw.Close()
+	w, err = OpenDiagnostics("fd:" + strconv.Itoa(int(pw.Fd())))
+	if err != nil {
+		t.Fatalf("OpenDiagnostics(fd): %v", err)
+	}
+	w.Write([]byte("x\n"))
+	buf := make([]byte, 2)
+	if _, err := r.Read(buf); err != nil || string(buf) != "x\n" {
+		t.Errorf("read %q, %v from fd", buf, err)
+	}
+	// Closing the diagnostics leaves the fd they were given open.
+	w.Close()
+	if _, err := pw.Write([]byte("y\n")); err != nil {
+		t.Errorf("write to fd after closing diagnostics: %v", err)
+	}
+	if _, err := r.Read(buf); err != nil || string(buf) != "y\n" {
+		t.Errorf("read %q, %v from fd", buf, err)
+	}
+
+	for _, dest := range []string{"wine.log", "fd:x", "fd:999"} {
+		if _, err := OpenDiagnostics(dest); err == nil {
+			t.Errorf("OpenDiagnostics(%q) succeeded", dest)
+		}
+	}
+}
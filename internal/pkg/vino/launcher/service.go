@@ -0,0 +1,250 @@
+package launcher
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// errorServiceExists is the Win32 error sc.exe exits with when the service
+// is already registered, e.g. in a prefix kept across container restarts.
+// wine only passes on its low byte.
+const errorServiceExists = 1073 & 0xff
+
+// ImageName returns the file name of a Windows or POSIX path.
+func ImageName(path string) string {
+	if i := strings.LastIndexAny(path, `\/`); i >= 0 {
+		return path[i+1:]
+	}
+	return path
+}
+
+// Service runs a Windows service through wine's service control manager.
+//
+// The service process is started by services.exe, which inherits its
+// standard streams from the wine process that booted the prefix. The
+// registration is the first wine command of the container, so both end up
+// writing to Stdout and Stderr.
+type Service struct {
+	Name string
+	Wine string
+	// Args are the service binary and its arguments.
+	Args []string
+	Env  []string
+	// Stdout receives the entries the service reports to the event log,
+	// Stderr the rest of wine's output.
+	Stdout io.Writer
+	Stderr io.Writer
+	Logger *slog.Logger
+	// Prepare, when set, runs before the service is registered, with the
+	// stderr wine commands have to use.
+	Prepare func(ctx context.Context, stderr io.Writer) error
+	// ProcRoot is where the service process is looked up. Defaults to /proc.
+	ProcRoot string
+	// PollInterval is how often the service process is checked on.
+	// Defaults to a second.
+	PollInterval time.Duration
+}
+
+// Start registers the service, starts it and returns its process.
+func (s *Service) Start(ctx context.Context) (*ServiceProcess, error) {
+	if len(s.Args) == 0 {
+		return nil, fmt.Errorf("service %s: no binary", s.Name)
+	}
+	r, w, err := os.Pipe()
+	if err != nil {
+		return nil, err
+	}
+	out := &EventLogWriter{Out: s.Stdout, Err: s.Stderr}
+	go func() {
+		defer r.Close()
+		io.Copy(out, r)
+		out.Flush()
+	}()
+	defer w.Close()
+
+	if s.Prepare != nil {
+		if err := s.Prepare(ctx, w); err != nil {
+			return nil, err
+		}
+	}
+	env := AppendWineDebug(s.Env, "+eventlog")
+	err = s.wine(ctx, env, w, "sc", "create", s.Name, "binPath=", windowsCommandLine(s.Args), "start=", "demand")
+	var ee *exec.ExitError
+	if errors.As(err, &ee) && ee.ExitCode() == errorServiceExists {
+		s.logger().Info("service already registered", "service", s.Name)
+	} else if err != nil {
+		return nil, fmt.Errorf("register service %s: %w", s.Name, err)
+	}
+	if err := s.wine(ctx, env, w, "net", "start", s.Name); err != nil {
+		return nil, fmt.Errorf("start service %s: %w", s.Name, err)
+	}
+
+	image := ImageName(s.Args[0])
+	pid, err := FindProcess(s.procRoot(), image)
+	if err != nil {
+		return nil, fmt.Errorf("service %s: %w", s.Name, err)
+	}
+	s.logger().Info("service running", "service", s.Name, "image", image, "pid", pid)
+	return &ServiceProcess{Pid: pid, procRoot: s.procRoot(), interval: s.pollInterval()}, nil
+}
+
+func (s *Service) wine(ctx context.Context, env []string, stderr io.Writer, args ...string) error {
+	cmd := exec.CommandContext(ctx, s.Wine, args...)
+	cmd.Env = env
+	cmd.Stdout = stderr
+	cmd.Stderr = stderr
+	return cmd.Run()
+}
+
+func (s *Service) procRoot() string {
+	if s.ProcRoot != "" {
+		return s.ProcRoot
+	}
+	return "/proc"
+}
+
+func (s *Service) pollInterval() time.Duration {
+	if s.PollInterval > 0 {
+		return s.PollInterval
+	}
+	return time.Second
+}
+
+func (s *Service) logger() *slog.Logger {
+	if s.Logger != nil {
+		return s.Logger
+	}
+	return slog.Default()
+}
+
+// ServiceProcess is a running service process. It is not a child of the
+// launcher, so it is watched through procfs.
+type ServiceProcess struct {
+	Pid      int
+	procRoot string
+	interval time.Duration
+}
+
+func (p *ServiceProcess) Signal(sig os.Signal) error {
+	s, ok := sig.(syscall.Signal)
+	if !ok {
+		return fmt.Errorf("unsupported signal %v", sig)
+	}
+	return unix.Kill(p.Pid, s)
+}
+
+func (p *ServiceProcess) Kill() error {
+	return unix.Kill(p.Pid, unix.SIGKILL)
+}
+
+// Wait blocks until the process is gone.
+func (p *ServiceProcess) Wait(ctx context.Context) error {
+	t := time.NewTicker(p.interval)
+	defer t.Stop()
+	for {
+		if !processAlive(p.procRoot, p.Pid) {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-t.C:
+		}
+	}
+}
+
+func processAlive(procRoot string, pid int) bool {
+	stat, err := os.ReadFile(filepath.Join(procRoot, strconv.Itoa(pid), "stat"))
+	if err != nil {
+		return false
+	}
+	// The state follows the parenthesised command name; zombies are gone
+	// as far as we're concerned.
+	if i := bytes.LastIndexByte(stat, ')'); i >= 0 && i+2 < len(stat) {
+		return stat[i+2] != 'Z' && stat[i+2] != 'X'
+	}
+	return true
+}
+
+// FindProcess returns the pid of the process running image. wine rewrites
+// the command line of Windows processes to their Windows one, so the first
+// argument is the executable's Windows path.
+func FindProcess(procRoot, image string) (int, error) {
+	entries, err := os.ReadDir(procRoot)
+	if err != nil {
+		return 0, err
+	}
+	for _, e := range entries {
+		pid, err := strconv.Atoi(e.Name())
+		if err != nil {
+			continue
+		}
+		cmdline, err := os.ReadFile(filepath.Join(procRoot, e.Name(), "cmdline"))
+		if err != nil || len(cmdline) == 0 {
+			continue
+		}
+		arg0, _, _ := bytes.Cut(cmdline, []byte{0})
+		if strings.EqualFold(ImageName(string(arg0)), image) && processAlive(procRoot, pid) {
+			return pid, nil
+		}
+	}
+	return 0, fmt.Errorf("no process running %s", image)
+}
+
+// windowsPath maps absolute POSIX paths to wine's Z: drive, which exposes
+// the whole filesystem.
+func windowsPath(path string) string {
+	if strings.HasPrefix(path, "/") {
+		return "Z:" + strings.ReplaceAll(path, "/", `\`)
+	}
+	return path
+}
+
+// windowsCommandLine joins args into a Windows command line, quoting the
+// ones that need it.
+func windowsCommandLine(args []string) string {
+	quoted := make([]string, len(args))
+	for i, a := range args {
+		if i == 0 {
+			a = windowsPath(a)
+		}
+		if a != "" && !strings.ContainsAny(a, " \t\"") {
+			quoted[i] = a
+			continue
+		}
+		var b strings.Builder
+		b.WriteByte('"')
+		slashes := 0
+		for _, c := range a {
+			switch c {
+			case '\\':
+				slashes++
+				continue
+			case '"':
+				// Backslashes are only special before a quote.
+				b.WriteString(strings.Repeat(`\`, 2*slashes+1))
+			default:
+				b.WriteString(strings.Repeat(`\`, slashes))
+			}
+			b.WriteRune(c)
+			slashes = 0
+		}
+		b.WriteString(strings.Repeat(`\`, 2*slashes))
+		b.WriteByte('"')
+		quoted[i] = b.String()
+	}
+	return strings.Join(quoted, " ")
+}
@@ -0,0 +1,140 @@
+package launcher
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestWindowsCommandLine(t *testing.T) {
+	got := windowsCommandLine([]string{"/opt/svc/svc.exe", "-port", "80", `C:\Program Files\`, `say "hi"`, ""})
+	want := `Z:\opt\svc\svc.exe -port 80 "C:\Program Files\\" "say \"hi\"" ""`
+	if got != want {
+		t.Errorf("windowsCommandLine = %s, want %s", got, want)
+	}
+}
+
+// fakeProc creates a procfs entry for pid running arg0 in state.
+func fakeProc(t *testing.T, root string, pid, arg0, state string) {
+	t.Helper()
+	dir := filepath.Join(root, pid)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "cmdline"), []byte(arg0+"\x00-port\x0080\x00"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	stat := pid + " (svc.exe) " + state + " 1 1 1"
+	if err := os.WriteFile(filepath.Join(dir, "stat"), []byte(stat), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestFindProcess(t *testing.T) {
+	root := t.TempDir()
+	fakeProc(t, root, "10", `C:\windows\system32\services.exe`, "S")
+	fakeProc(t, root, "11", `Z:\opt\svc\SVC.EXE`, "Z")
+	fakeProc(t, root, "12", `Z:\opt\svc\svc.exe`, "S")
+	os.MkdirAll(filepath.Join(root, "self"), 0o755)
+
+	pid, err := FindProcess(root, "svc.exe")
+	if err != nil {
+		t.Fatalf("FindProcess: %v", err)
+	}
+	if pid != 12 {
+		t.Errorf("pid = %d, want 12 (11 is a zombie)", pid)
+	}
+	if _, err := FindProcess(root, "other.exe"); err == nil {
+		t.Errorf("FindProcess found a process that doesn't exist")
+	}
+}
+
+// syncBuffer is a bytes.Buffer safe for concurrent use.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func TestServiceStart(t *testing.T) {
+	dir := t.TempDir()
+	root := filepath.Join(dir, "proc")
+	calls := filepath.Join(dir, "calls")
+	wine := filepath.Join(dir, "wine")
+	// sc reports the service as already registered, which is fine.
+	script := `#!/bin/sh
+echo "$WINEDEBUG $*" >> ` + calls + `
+case "$1" in
+sc) exit 1073 ;;
+net) echo '0124:trace:eventlog:ReportEventW L"service started"' >&2 ;;
+esac
+`
+	if err := os.WriteFile(wine, []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	fakeProc(t, root, "42", `Z:\opt\svc\svc.exe`, "S")
+
+	var out, errOut syncBuffer
+	s := &Service{
+		Name:         "MySvc",
+		Wine:         wine,
+		Args:         []string{"/opt/svc/svc.exe", "-port", "80"},
+		Stdout:       &out,
+		Stderr:       &errOut,
+		ProcRoot:     root,
+		PollInterval: 10 * time.Millisecond,
+	}
+	proc, err := s.Start(context.Background())
+	if err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	if proc.Pid != 42 {
+		t.Errorf("pid = %d, want 42", proc.Pid)
+	}
+
+	data, err := os.ReadFile(calls)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		`+eventlog sc create MySvc binPath= Z:\opt\svc\svc.exe -port 80 start= demand`,
+		`+eventlog net start MySvc`,
+	}
+	if got := strings.Split(strings.TrimSpace(string(data)), "\n"); !reflect.DeepEqual(got, want) {
+		t.Errorf("wine calls = %q, want %q", got, want)
+	}
+
+	// The pipe is drained asynchronously.
+	deadline := time.Now().Add(time.Second)
+	for out.String() == "" && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if got := out.String(); got != "[Information] service started\n" {
+		t.Errorf("stdout = %q", got)
+	}
+
+	// The service stops.
+	os.RemoveAll(filepath.Join(root, "42"))
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := proc.Wait(ctx); err != nil {
+		t.Errorf("Wait: %v", err)
+	}
+}
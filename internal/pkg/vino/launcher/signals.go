@@ -0,0 +1,202 @@
+package launcher
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"os/exec"
+	"sync"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// Kind is the kind of Windows process being launched. It decides how stop
+// requests reach it.
+type Kind string
+
+const (
+	KindConsole Kind = "console"
+	KindGUI     Kind = "gui"
+	KindService Kind = "service"
+)
+
+// Event is a Windows-side request to stop.
+type Event string
+
+const (
+	CtrlC       Event = "CTRL_C_EVENT"
+	CtrlBreak   Event = "CTRL_BREAK_EVENT"
+	CtrlClose   Event = "CTRL_CLOSE_EVENT"
+	WMClose     Event = "WM_CLOSE"
+	ServiceStop Event = "SERVICE_CONTROL_STOP"
+)
+
+// StopSignals are the signals the launcher intercepts and translates.
+var StopSignals = []os.Signal{unix.SIGINT, unix.SIGTERM, unix.SIGHUP}
+
+// Translate returns the Windows event sig stands for in a process of the
+// given kind.
+func Translate(kind Kind, sig os.Signal) (Event, bool) {
+	switch kind {
+	case KindGUI:
+		switch sig {
+		case unix.SIGINT, unix.SIGTERM, unix.SIGHUP:
+			return WMClose, true
+		}
+	case KindService:
+		switch sig {
+		case unix.SIGINT, unix.SIGTERM, unix.SIGHUP:
+			return ServiceStop, true
+		}
+	default:
+		switch sig {
+		case unix.SIGINT:
+			return CtrlC, true
+		case unix.SIGTERM:
+			return CtrlBreak, true
+		case unix.SIGHUP:
+			return CtrlClose, true
+		}
+	}
+	return "", false
+}
+
+// Process is the wine process being supervised.
+type Process interface {
+	Signal(os.Signal) error
+	Kill() error
+}
+
+// Stopper delivers stop requests to a Windows process and kills it if it
+// doesn't comply in time.
+type Stopper struct {
+	Kind Kind
+	// Grace is how long a process may take to stop once asked to.
+	Grace time.Duration
+	// Wine is the wine binary running helper programs.
+	Wine string
+	// Image is the executable name of the process, addressed by taskkill.
+	Image string
+	// Service is the service stopped for KindService.
+	Service string
+	// CtrlHelper is the vino-ctrl.exe raising console events other than
+	// CTRL_C_EVENT. Without it, they are delivered as CTRL_C_EVENT.
+	CtrlHelper string
+	Logger     *slog.Logger
+	// Run runs a helper command. Defaults to running it with os/exec.
+	Run func(ctx context.Context, name string, args ...string) error
+}
+
+// Supervise translates the signals received on sigs into stop requests for
+// proc until done is closed. Once a stop was requested, proc is killed when
+// Grace expires or another signal arrives.
+func (s *Stopper) Supervise(proc Process, sigs <-chan os.Signal, done <-chan struct{}) {
+	var (
+		deadline <-chan time.Time
+		stopping bool
+		helpers  sync.WaitGroup
+	)
+	defer helpers.Wait()
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-deadline:
+			s.logger().Warn("process did not stop in time, killing it", "grace", s.Grace)
+			s.kill(proc, &helpers)
+			return
+		case sig := <-sigs:
+			if stopping {
+				s.logger().Warn("stop requested again, killing process", "signal", sig)
+				s.kill(proc, &helpers)
+				return
+			}
+			event, ok := Translate(s.Kind, sig)
+			if !ok {
+				continue
+			}
+			stopping = true
+			deadline = time.After(s.Grace)
+			s.logger().Info("requesting stop", "signal", sig, "event", event, "grace", s.Grace)
+			helpers.Add(1)
+			go func() {
+				defer helpers.Done()
+				s.deliver(proc, event)
+			}()
+		}
+	}
+}
+
+func (s *Stopper) deliver(proc Process, event Event) {
+	ctx, cancel := context.WithTimeout(context.Background(), s.Grace)
+	defer cancel()
+
+	var err error
+	switch event {
+	case WMClose:
+		// Without /f, taskkill posts WM_CLOSE to the process' windows.
+		err = s.run(ctx, s.Wine, "taskkill", "/im", s.Image)
+	case ServiceStop:
+		if s.Service == "" {
+			s.logger().Warn("no service to stop, sending CTRL_C_EVENT instead")
+			err = proc.Signal(unix.SIGINT)
+			break
+		}
+		err = s.run(ctx, s.Wine, "net", "stop", s.Service)
+	case CtrlC:
+		// wine turns SIGINT into a CTRL_C_EVENT for the process' console
+		// control handlers.
+		err = proc.Signal(unix.SIGINT)
+	default:
+		// wine has no way to raise the other console events from outside
+		// the process: vino-ctrl raises them from inside wine.
+		if s.CtrlHelper == "" {
+			s.logger().Warn("no vino-ctrl to raise console event, sending CTRL_C_EVENT instead", "event", event)
+			err = proc.Signal(unix.SIGINT)
+			break
+		}
+		if err = s.run(ctx, s.Wine, s.CtrlHelper, s.Image, string(event)); err != nil {
+			s.logger().Warn("raise console event, sending CTRL_C_EVENT instead", "event", event, "error", err)
+			err = proc.Signal(unix.SIGINT)
+		}
+	}
+	if err != nil {
+		s.logger().Warn("deliver stop request", "event", event, "error", err)
+	}
+}
+
+func (s *Stopper) kill(proc Process, helpers *sync.WaitGroup) {
+	if err := proc.Kill(); err != nil {
+		s.logger().Debug("kill process", "error", err)
+	}
+	// Take down whatever else runs in the prefix, e.g. processes the
+	// application spawned.
+	helpers.Add(1)
+	go func() {
+		defer helpers.Done()
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := s.run(ctx, "wineserver", "-k"); err != nil {
+			s.logger().Debug("kill wineserver", "error", err)
+		}
+	}()
+}
+
+func (s *Stopper) run(ctx context.Context, name string, args ...string) error {
+	if s.Run != nil {
+		return s.Run(ctx, name, args...)
+	}
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Stdout = os.Stderr
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
+
+func (s *Stopper) logger() *slog.Logger {
+	if s.Logger != nil {
+		return s.Logger
+	}
+	return slog.Default()
+}
@@ -0,0 +1,201 @@
+package launcher
+
+import (
+	"context"
+	"os"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/sys/unix"
+
+	"github.com/TheGrizzlyDev/vino/internal/pkg/vino/labels"
+)
+
+func TestTranslate(t *testing.T) {
+	tests := []struct {
+		kind Kind
+		sig  os.Signal
+		want Event
+	}{
+		{KindConsole, unix.SIGINT, CtrlC},
+		{KindConsole, unix.SIGTERM, CtrlBreak},
+		{KindConsole, unix.SIGHUP, CtrlClose},
+		{KindGUI, unix.SIGTERM, WMClose},
+		{KindGUI, unix.SIGINT, WMClose},
+		{KindService, unix.SIGTERM, ServiceStop},
+		{KindService, unix.SIGHUP, ServiceStop},
+		{"", unix.SIGTERM, CtrlBreak},
+	}
+	for _, tt := range tests {
+		got, ok := Translate(tt.kind, tt.sig)
+		if !ok || got != tt.want {
+			t.Errorf("Translate(%q, %v) = %q, %v; want %q", tt.kind, tt.sig, got, ok, tt.want)
+		}
+	}
+	if _, ok := Translate(KindConsole, unix.SIGUSR1); ok {
+		t.Errorf("Translate(SIGUSR1) succeeded, want no event")
+	}
+}
+
+type fakeProcess struct {
+	mu      sync.Mutex
+	signals []os.Signal
+	killed  bool
+}
+
+func (p *fakeProcess) Signal(sig os.Signal) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.signals = append(p.signals, sig)
+	return nil
+}
+
+func (p *fakeProcess) Kill() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.killed = true
+	return nil
+}
+
+type fakeRunner struct {
+	mu   sync.Mutex
+	cmds []string
+}
+
+func (r *fakeRunner) run(_ context.Context, name string, args ...string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.cmds = append(r.cmds, strings.Join(append([]string{name}, args...), " "))
+	return nil
+}
+
+func TestStopperConsole(t *testing.T) {
+	tests := []struct {
+		name        string
+		sig         os.Signal
+		helper      string
+		wantSignals []os.Signal
+		wantCmds    []string
+	}{
+		{name: "ctrl c", sig: unix.SIGINT, helper: "vino-ctrl.exe", wantSignals: []os.Signal{unix.SIGINT}},
+		{name: "ctrl break", sig: unix.SIGTERM, helper: "vino-ctrl.exe", wantCmds: []string{"wine vino-ctrl.exe app.exe CTRL_BREAK_EVENT"}},
+		{name: "ctrl close", sig: unix.SIGHUP, helper: "vino-ctrl.exe", wantCmds: []string{"wine vino-ctrl.exe app.exe CTRL_CLOSE_EVENT"}},
+		{name: "no helper", sig: unix.SIGTERM, wantSignals: []os.Signal{unix.SIGINT}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			proc := &fakeProcess{}
+			runner := &fakeRunner{}
+			s := &Stopper{Kind: KindConsole, Grace: time.Minute, Wine: "wine", Image: "app.exe", CtrlHelper: tt.helper, Run: runner.run}
+
+			sigs := make(chan os.Signal, 1)
+			done := make(chan struct{})
+			finished := make(chan struct{})
+			go func() {
+				s.Supervise(proc, sigs, done)
+				close(finished)
+			}()
+
+			sigs <- tt.sig
+			// The process exits on its own.
+			time.Sleep(50 * time.Millisecond)
+			close(done)
+			<-finished
+
+			if !reflect.DeepEqual(proc.signals, tt.wantSignals) {
+				t.Errorf("signals = %v, want %v", proc.signals, tt.wantSignals)
+			}
+			if proc.killed {
+				t.Errorf("process killed, want it left to exit")
+			}
+			if !reflect.DeepEqual(runner.cmds, tt.wantCmds) {
+				t.Errorf("ran %q, want %q", runner.cmds, tt.wantCmds)
+			}
+		})
+	}
+}
+
+func TestStopperEscalates(t *testing.T) {
+	tests := []struct {
+		name   string
+		stop   Stopper
+		second bool
+		want   []string
+	}{
+		{
+			name: "gui grace expires",
+			stop: Stopper{Kind: KindGUI, Grace: 10 * time.Millisecond, Wine: "wine", Image: "app.exe"},
+			want: []string{"wine taskkill /im app.exe", "wineserver -k"},
+		},
+		{
+			name:   "service second signal",
+			stop:   Stopper{Kind: KindService, Grace: time.Minute, Wine: "wine", Service: "Spooler"},
+			second: true,
+			want:   []string{"wine net stop Spooler", "wineserver -k"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			proc := &fakeProcess{}
+			runner := &fakeRunner{}
+			s := tt.stop
+			s.Run = runner.run
+
+			sigs := make(chan os.Signal, 2)
+			sigs <- unix.SIGTERM
+			if tt.second {
+				// Let the first request be delivered before insisting.
+				go func() {
+					time.Sleep(20 * time.Millisecond)
+					sigs <- unix.SIGTERM
+				}()
+			}
+			s.Supervise(proc, sigs, make(chan struct{}))
+
+			if !proc.killed {
+				t.Errorf("process not killed")
+			}
+			if !reflect.DeepEqual(runner.cmds, tt.want) {
+				t.Errorf("ran %q, want %q", runner.cmds, tt.want)
+			}
+		})
+	}
+}
+
+func TestFromEnvironment(t *testing.T) {
+	t.Setenv(EnvProcessKind, "")
+	t.Setenv(EnvStopTimeout, "")
+	opts, err := FromEnvironment()
+	if err != nil {
+		t.Fatalf("FromEnvironment: %v", err)
+	}
+	if opts.Kind != KindConsole || opts.StopTimeout != DefaultStopTimeout {
+		t.Errorf("defaults = %+v", opts)
+	}
+
+	for _, kv := range Environ(labels.Config{Process: labels.Process{Kind: "gui", StopTimeout: "30s"}}) {
+		k, v, _ := strings.Cut(kv, "=")
+		t.Setenv(k, v)
+	}
+	opts, err = FromEnvironment()
+	if err != nil {
+		t.Fatalf("FromEnvironment: %v", err)
+	}
+	if opts.Kind != KindGUI || opts.StopTimeout != 30*time.Second {
+		t.Errorf("options = %+v, want gui/30s", opts)
+	}
+
+	// Exec'd processes inherit the environment: it doesn't name the
+	// service only the main process runs as.
+	if env := Environ(labels.Config{Process: labels.Process{Service: "Spooler"}}); len(env) != 0 {
+		t.Errorf("Environ = %q, want no service", env)
+	}
+
+	t.Setenv(EnvProcessKind, "daemon")
+	if _, err := FromEnvironment(); err == nil {
+		t.Errorf("FromEnvironment accepted an unknown kind")
+	}
+}
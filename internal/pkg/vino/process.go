@@ -14,6 +14,11 @@ var (
 type ProcessRewriter struct {
 	WineLauncherPath string
 	WineLauncherArgs []string
+	// ServiceFlag is the launcher flag running the process as a Windows
+	// service. Only the rewriter of the main process has it, and passes it
+	// the service set by the annotations of the bundle last rewritten.
+	ServiceFlag string
+	service     string
 }
 
 func (p *ProcessRewriter) RewriteProcess(proc *specs.Process) error {
@@ -25,6 +30,9 @@ func (p *ProcessRewriter) RewriteProcess(proc *specs.Process) error {
 	}
 
 	args := append([]string{p.WineLauncherPath}, p.WineLauncherArgs...)
+	if p.ServiceFlag != "" && p.service != "" {
+		args = append(args, p.ServiceFlag, p.service)
+	}
 	proc.Args = append(args, proc.Args...)
 	return nil
 }
@@ -0,0 +1,175 @@
+// Package rootfs reaches the files of a container's root filesystem from
+// the host. Paths are resolved inside the root, the way the container
+// resolves them, so that the symlinks it controls can't lead vino, running
+// as root on the host, out of it.
+package rootfs
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+
+	"golang.org/x/sys/unix"
+)
+
+// Open opens path, resolved inside root, with flags, creating it with mode
+// if flags hold O_CREAT.
+func Open(root, path string, flags int, mode uint32) (*os.File, error) {
+	dirfd, err := unix.Open(root, unix.O_PATH|unix.O_DIRECTORY|unix.O_CLOEXEC, 0)
+	if err != nil {
+		return nil, &os.PathError{Op: "open", Path: root, Err: err}
+	}
+	defer unix.Close(dirfd)
+	return openat(dirfd, root, path, flags, mode)
+}
+
+func openat(dirfd int, root, path string, flags int, mode uint32) (*os.File, error) {
+	how := &unix.OpenHow{
+		Flags:   uint64(flags | unix.O_CLOEXEC),
+		Mode:    uint64(mode),
+		Resolve: unix.RESOLVE_IN_ROOT | unix.RESOLVE_NO_MAGICLINKS,
+	}
+	name := filepath.Join(root, path)
+	for {
+		fd, err := unix.Openat2(dirfd, path, how)
+		// The kernel gives up on a resolution racing with a rename.
+		if errors.Is(err, unix.EAGAIN) {
+			continue
+		}
+		if err != nil {
+			return nil, &os.PathError{Op: "openat2", Path: name, Err: err}
+		}
+		return os.NewFile(uintptr(fd), name), nil
+	}
+}
+
+// ReadFile reads the regular file path, resolved inside root, failing if it
+// is larger than max bytes.
+func ReadFile(root, path string, max int64) ([]byte, error) {
+	// Opening a FIFO mustn't block until a writer shows up.
+	f, err := Open(root, path, unix.O_RDONLY|unix.O_NONBLOCK|unix.O_NOCTTY, 0)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if !fi.Mode().IsRegular() {
+		return nil, fmt.Errorf("%s: not a regular file", f.Name())
+	}
+	data, err := io.ReadAll(io.LimitReader(f, max+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > max {
+		return nil, fmt.Errorf("%s: larger than %d bytes", f.Name(), max)
+	}
+	return data, nil
+}
+
+// Dial connects to the unix socket path, resolved inside root.
+func Dial(root, path string) (net.Conn, error) {
+	f, err := Open(root, path, unix.O_PATH, 0)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if fi.Mode().Type() != os.ModeSocket {
+		return nil, fmt.Errorf("%s: not a socket", f.Name())
+	}
+	// Connecting through the descriptor reaches the socket resolved, not
+	// whatever path names it by then.
+	return net.Dial("unix", fmt.Sprintf("/proc/self/fd/%d", f.Fd()))
+}
+
+// MkdirAll creates the directory path, resolved inside root, along with any
+// missing parent, like os.MkdirAll.
+func MkdirAll(root, path string, perm os.FileMode) error {
+	path = filepath.Clean("/" + path)
+	if path == "/" {
+		return nil
+	}
+	f, err := Open(root, path, unix.O_PATH|unix.O_DIRECTORY, 0)
+	if err == nil {
+		return f.Close()
+	}
+	if !os.IsNotExist(err) {
+		return err
+	}
+	if err := MkdirAll(root, filepath.Dir(path), perm); err != nil {
+		return err
+	}
+	return atParent(root, path, "mkdirat", func(dirfd int, name string) error {
+		if err := unix.Mkdirat(dirfd, name, uint32(perm.Perm())); err != nil && err != unix.EEXIST {
+			return err
+		}
+		return nil
+	})
+}
+
+// Remove removes the file path, resolved inside root. A symlink is removed
+// rather than what it points at.
+func Remove(root, path string) error {
+	return atParent(root, path, "unlinkat", func(dirfd int, name string) error {
+		return unix.Unlinkat(dirfd, name, 0)
+	})
+}
+
+// Symlink creates newname, resolved inside root, as a symlink to oldname.
+func Symlink(oldname, root, newname string) error {
+	return atParent(root, newname, "symlinkat", func(dirfd int, name string) error {
+		return unix.Symlinkat(oldname, dirfd, name)
+	})
+}
+
+// Mknod creates the node path, resolved inside root, with the type and
+// permissions of mode whatever the umask.
+func Mknod(root, path string, mode uint32, dev int) error {
+	return atParent(root, path, "mknodat", func(dirfd int, name string) error {
+		if err := unix.Mknodat(dirfd, name, mode, dev); err != nil {
+			return err
+		}
+		// Set the permissions the umask left out on the node just made,
+		// not on whatever the container may have put in its place.
+		fd, err := unix.Openat(dirfd, name, unix.O_PATH|unix.O_NOFOLLOW|unix.O_CLOEXEC, 0)
+		if err != nil {
+			return err
+		}
+		defer unix.Close(fd)
+		var st unix.Stat_t
+		if err := unix.Fstat(fd, &st); err != nil {
+			return err
+		}
+		if st.Mode&unix.S_IFMT != mode&unix.S_IFMT || st.Rdev != uint64(dev) {
+			return unix.EEXIST
+		}
+		return unix.Chmod(fmt.Sprintf("/proc/self/fd/%d", fd), mode&0o7777)
+	})
+}
+
+// atParent calls fn with the directory holding path, resolved inside root,
+// and the last element of path.
+func atParent(root, path, op string, fn func(dirfd int, name string) error) error {
+	dir, name := filepath.Split(filepath.Clean("/" + path))
+	if name == "" {
+		return &os.PathError{Op: op, Path: root, Err: unix.EINVAL}
+	}
+	parent, err := Open(root, dir, unix.O_PATH|unix.O_DIRECTORY, 0)
+	if err != nil {
+		return err
+	}
+	defer parent.Close()
+	if err := fn(int(parent.Fd()), name); err != nil {
+		return &os.PathError{Op: op, Path: filepath.Join(root, path), Err: err}
+	}
+	return nil
+}
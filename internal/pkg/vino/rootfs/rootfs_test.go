@@ -0,0 +1,147 @@
+package rootfs
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang.org/x/sys/unix"
+)
+
+func TestReadFile(t *testing.T) {
+	dir := t.TempDir()
+	root := filepath.Join(dir, "root")
+	for path, data := range map[string]string{
+		"root/etc/status": "inside",
+		"etc/status":      "outside",
+		"root/big":        strings.Repeat("x", 17),
+	} {
+		if err := os.MkdirAll(filepath.Dir(filepath.Join(dir, path)), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, path), []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for link, target := range map[string]string{
+		"abs":      "/etc/status",
+		"rel":      "../../../etc/status",
+		"dir/file": "../../etc/status",
+	} {
+		if err := os.MkdirAll(filepath.Dir(filepath.Join(root, link)), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Symlink(target, filepath.Join(root, link)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := unix.Mkfifo(filepath.Join(root, "fifo"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	// Symlinks resolve inside the root, whatever they point at.
+	for _, path := range []string{"/etc/status", "abs", "/rel", "dir/file", "../../etc/status"} {
+		data, err := ReadFile(root, path, 16)
+		if err != nil || string(data) != "inside" {
+			t.Errorf("ReadFile(%q) = %q, %v; want inside", path, data, err)
+		}
+	}
+	if _, err := ReadFile(root, "missing", 16); !os.IsNotExist(err) {
+		t.Errorf("ReadFile(missing) = %v, want not exist", err)
+	}
+	if _, err := ReadFile(root, "fifo", 16); err == nil || !strings.Contains(err.Error(), "not a regular file") {
+		t.Errorf("ReadFile(fifo) = %v, want it refused", err)
+	}
+	if _, err := ReadFile(root, "big", 16); err == nil || !strings.Contains(err.Error(), "larger than 16 bytes") {
+		t.Errorf("ReadFile(big) = %v, want it refused", err)
+	}
+}
+
+func TestCreate(t *testing.T) {
+	dir := t.TempDir()
+	root := filepath.Join(dir, "root")
+	if err := os.MkdirAll(root, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	// Out of the root as the host sees it, at its top as the container does.
+	if err := os.Symlink("../..", filepath.Join(root, "up")); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := MkdirAll(root, "/up/dev/bus", 0o755); err != nil {
+		t.Fatalf("MkdirAll: %v", err)
+	}
+	if fi, err := os.Stat(filepath.Join(root, "dev", "bus")); err != nil || !fi.IsDir() {
+		t.Fatalf("dev/bus = %v, %v; want a directory in the root", fi, err)
+	}
+	old := unix.Umask(0o077)
+	err := Mknod(root, "/up/dev/bus/fifo", unix.S_IFIFO|0o666, 0)
+	unix.Umask(old)
+	if err != nil {
+		t.Fatalf("Mknod: %v", err)
+	}
+	if fi, err := os.Stat(filepath.Join(root, "dev", "bus", "fifo")); err != nil || fi.Mode() != os.ModeNamedPipe|0o666 {
+		t.Fatalf("fifo = %v, %v; want a FIFO with mode 0666", fi, err)
+	}
+	if err := Symlink("/dev/bus/fifo", root, "/up/com1"); err != nil {
+		t.Fatalf("Symlink: %v", err)
+	}
+	if target, err := os.Readlink(filepath.Join(root, "com1")); err != nil || target != "/dev/bus/fifo" {
+		t.Fatalf("com1 -> %q, %v", target, err)
+	}
+	// The link goes, not the FIFO it points at.
+	if err := Remove(root, "/com1"); err != nil {
+		t.Fatalf("Remove: %v", err)
+	}
+	if _, err := os.Lstat(filepath.Join(root, "com1")); !os.IsNotExist(err) {
+		t.Errorf("com1 still there: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(root, "dev", "bus", "fifo")); err != nil {
+		t.Errorf("fifo removed through the link: %v", err)
+	}
+	if err := Remove(root, "/com1"); !os.IsNotExist(err) {
+		t.Errorf("Remove(missing) = %v, want not exist", err)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 1 {
+		t.Errorf("written out of the root: %v", entries)
+	}
+}
+
+func TestDial(t *testing.T) {
+	dir := t.TempDir()
+	root := filepath.Join(dir, "root")
+	if err := os.MkdirAll(filepath.Join(root, "run"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	inside, err := net.Listen("unix", filepath.Join(root, "run", "vnc.sock"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer inside.Close()
+	outside, err := net.Listen("unix", filepath.Join(dir, "host.sock"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer outside.Close()
+	// Pointing at the host's socket as the host sees it.
+	if err := os.Symlink(filepath.Join(dir, "host.sock"), filepath.Join(root, "link.sock")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "file"), nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := Dial(root, "/run/vnc.sock")
+	if err != nil {
+		t.Fatalf("Dial: %v", err)
+	}
+	c.Close()
+	if _, err := Dial(root, "/link.sock"); !os.IsNotExist(err) {
+		t.Errorf("Dial(link) = %v, want it resolved inside the root", err)
+	}
+	if _, err := Dial(root, "/file"); err == nil || !strings.Contains(err.Error(), "not a socket") {
+		t.Errorf("Dial(file) = %v, want it refused", err)
+	}
+}
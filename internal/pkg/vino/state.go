@@ -0,0 +1,48 @@
+package vino
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/TheGrizzlyDev/vino/internal/pkg/runc"
+	"github.com/TheGrizzlyDev/vino/internal/pkg/vino/rootfs"
+	"github.com/TheGrizzlyDev/vino/internal/pkg/vino/winexit"
+)
+
+// maxStatusSize bounds the status read from a container, which writes it.
+const maxStatusSize = 64 << 10
+
+var (
+	_ runc.StateRewriter = &StateRewriter{}
+)
+
+// StateRewriter reports how the Windows main process of a container ended,
+// as recorded by its launcher, through state annotations.
+type StateRewriter struct{}
+
+func (StateRewriter) RewriteState(state *runc.ContainerState) error {
+	if state.Rootfs == "" {
+		return nil
+	}
+	// The container owns the file: it is looked up the way the container
+	// would, not through the host's view of its symlinks.
+	data, err := rootfs.ReadFile(state.Rootfs, winexit.StatusPath, maxStatusSize)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var status winexit.Status
+	if err := json.Unmarshal(data, &status); err != nil {
+		return fmt.Errorf("decode status: %w", err)
+	}
+	if state.Annotations == nil {
+		state.Annotations = map[string]string{}
+	}
+	for k, v := range status.Annotations() {
+		state.Annotations[k] = v
+	}
+	return nil
+}
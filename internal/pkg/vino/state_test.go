@@ -0,0 +1,66 @@
+package vino
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/TheGrizzlyDev/vino/internal/pkg/runc"
+	"github.com/TheGrizzlyDev/vino/internal/pkg/vino/winexit"
+)
+
+func TestStateRewriter(t *testing.T) {
+	rootfs := t.TempDir()
+	status := winexit.Status{Kind: winexit.KindCrashed, Code: 0xC0000005, Name: "STATUS_ACCESS_VIOLATION", ExitCode: 139}
+	if err := winexit.WriteFile(filepath.Join(rootfs, winexit.StatusPath), status); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	state := &runc.ContainerState{ID: "ctr", Rootfs: rootfs, Annotations: map[string]string{"foo": "bar"}}
+	if err := (StateRewriter{}).RewriteState(state); err != nil {
+		t.Fatalf("RewriteState: %v", err)
+	}
+	if state.Annotations["foo"] != "bar" {
+		t.Fatalf("existing annotation lost: %v", state.Annotations)
+	}
+	if got := state.Annotations[winexit.AnnotationCode]; got != "0xC0000005" {
+		t.Fatalf("code annotation = %q, want 0xC0000005", got)
+	}
+	if got := state.Annotations[winexit.AnnotationExitCode]; got != "139" {
+		t.Fatalf("exit code annotation = %q, want 139", got)
+	}
+}
+
+func TestStateRewriterWithoutStatus(t *testing.T) {
+	state := &runc.ContainerState{ID: "ctr", Rootfs: t.TempDir()}
+	if err := (StateRewriter{}).RewriteState(state); err != nil {
+		t.Fatalf("RewriteState: %v", err)
+	}
+	if state.Annotations != nil {
+		t.Fatalf("annotations = %v, want nil", state.Annotations)
+	}
+}
+
+func TestStateRewriterStaysInRootfs(t *testing.T) {
+	dir := t.TempDir()
+	status := winexit.Status{Kind: winexit.KindExited, ExitCode: 3}
+	if err := winexit.WriteFile(filepath.Join(dir, "outside.json"), status); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	rootfs := filepath.Join(dir, "rootfs")
+	link := filepath.Join(rootfs, winexit.StatusPath)
+	if err := os.MkdirAll(filepath.Dir(link), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(filepath.Join(dir, "outside.json"), link); err != nil {
+		t.Fatal(err)
+	}
+
+	state := &runc.ContainerState{ID: "ctr", Rootfs: rootfs}
+	if err := (StateRewriter{}).RewriteState(state); err != nil {
+		t.Fatalf("RewriteState: %v", err)
+	}
+	if state.Annotations != nil {
+		t.Fatalf("annotations = %v, want none from outside the rootfs", state.Annotations)
+	}
+}
@@ -0,0 +1,291 @@
+package usb
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/sys/unix"
+
+	"github.com/TheGrizzlyDev/vino/internal/pkg/vino/rootfs"
+)
+
+// HotplugMajors are the character device drivers, as /proc/devices names
+// them, USB devices get their nodes from.
+var HotplugMajors = []string{"usb_device", "usb", "hidraw", "ttyUSB", "ttyACM"}
+
+// Binding is a USB device passed through to a container and the DOS device
+// its primary node is linked to, if any.
+type Binding struct {
+	ID    ID
+	Label string
+	// Access is the device cgroup access to its nodes: r or rw.
+	Access string
+	// FileMode is the permissions of its nodes.
+	FileMode os.FileMode
+}
+
+// Container adds and removes the nodes of a running container. Its paths are
+// resolved inside Root, so that the symlinks of the container can't lead out
+// of it.
+type Container struct {
+	// Root is the container's root filesystem, as the host sees it, e.g.
+	// /proc/<pid>/root.
+	Root string
+	// Prefix is the container's WINEPREFIX.
+	Prefix string
+	// DevicesCgroup is the cgroup v1 devices directory of the container,
+	// where each node is allowed as it is added. It is empty on cgroup v2,
+	// where the majors allowed at create time cover the nodes.
+	DevicesCgroup string
+
+	// mknod is rootfs.Mknod, replaced by tests.
+	mknod func(root, path string, mode uint32, dev int) error
+}
+
+// Add creates n in the container and allows the access of b to it.
+func (c *Container) Add(b Binding, n Node) error {
+	if c.DevicesCgroup != "" {
+		rule := fmt.Sprintf("c %d:%d %s", n.Major, n.Minor, b.Access)
+		if err := os.WriteFile(filepath.Join(c.DevicesCgroup, "devices.allow"), []byte(rule), 0); err != nil {
+			return fmt.Errorf("allow %s: %w", n.Path, err)
+		}
+	}
+	if err := rootfs.MkdirAll(c.Root, filepath.Dir(n.Path), 0o755); err != nil {
+		return err
+	}
+	if err := rootfs.Remove(c.Root, n.Path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	mknod := c.mknod
+	if mknod == nil {
+		mknod = rootfs.Mknod
+	}
+	dev := int(unix.Mkdev(uint32(n.Major), uint32(n.Minor)))
+	if err := mknod(c.Root, n.Path, unix.S_IFCHR|uint32(b.FileMode.Perm()), dev); err != nil {
+		return fmt.Errorf("create %s: %w", n.Path, err)
+	}
+	return nil
+}
+
+// Remove deletes n from the container and revokes access to it.
+func (c *Container) Remove(n Node) error {
+	if err := rootfs.Remove(c.Root, n.Path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if c.DevicesCgroup != "" {
+		rule := fmt.Sprintf("c %d:%d rwm", n.Major, n.Minor)
+		if err := os.WriteFile(filepath.Join(c.DevicesCgroup, "devices.deny"), []byte(rule), 0); err != nil {
+			return fmt.Errorf("deny %s: %w", n.Path, err)
+		}
+	}
+	return nil
+}
+
+// Link points the DOS device label at n, or removes it when n is nil.
+func (c *Container) Link(label string, n *Node) error {
+	if label == "" || c.Prefix == "" {
+		return nil
+	}
+	dosDir := filepath.Join(c.Prefix, "dosdevices")
+	link := filepath.Join(dosDir, strings.ToLower(label))
+	if err := rootfs.Remove(c.Root, link); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if n == nil {
+		return nil
+	}
+	if err := rootfs.MkdirAll(c.Root, dosDir, 0o755); err != nil {
+		return err
+	}
+	return rootfs.Symlink(n.Path, c.Root, link)
+}
+
+// Source yields uevents, like a Monitor does.
+type Source interface {
+	Next() (Event, error)
+}
+
+// Watcher keeps the nodes of a running container in sync with its USB
+// devices being plugged in and out.
+type Watcher struct {
+	// SysRoot is where sysfs is mounted. Defaults to /sys.
+	SysRoot   string
+	Bindings  []Binding
+	Container *Container
+	Logger    *slog.Logger
+	// Changed, if set, is told of each node added to or removed from the
+	// container, with the uevent action: "add" or "remove".
+	Changed func(action string, b Binding, n Node)
+
+	nodes map[int][]Node
+}
+
+// Sync adds the nodes of the devices plugged in now.
+func (w *Watcher) Sync() error {
+	w.nodes = map[int][]Node{}
+	for i, b := range w.Bindings {
+		nodes, err := Resolve(w.sysRoot(), b.ID)
+		if err != nil {
+			return fmt.Errorf("resolve %s: %w", b.ID, err)
+		}
+		for _, n := range nodes {
+			w.add(i, n)
+		}
+	}
+	return nil
+}
+
+// Run handles the events of src until it fails.
+func (w *Watcher) Run(src Source) error {
+	if w.nodes == nil {
+		w.nodes = map[int][]Node{}
+	}
+	for {
+		e, err := src.Next()
+		if err != nil {
+			return err
+		}
+		w.Handle(e)
+	}
+}
+
+// Handle applies a uevent to the container.
+func (w *Watcher) Handle(e Event) {
+	if e.Node == nil {
+		return
+	}
+	switch e.Action {
+	case "add":
+		for i, b := range w.Bindings {
+			if Matches(w.sysRoot(), e.DevPath, b.ID) {
+				w.add(i, *e.Node)
+			}
+		}
+	case "remove":
+		// The device is gone from sysfs already: go by the nodes added.
+		for i := range w.Bindings {
+			for _, n := range w.nodes[i] {
+				if n.DevPath == e.DevPath || n.Path == e.Node.Path {
+					w.remove(i, n)
+				}
+			}
+		}
+	}
+}
+
+func (w *Watcher) add(i int, n Node) {
+	b := w.Bindings[i]
+	if err := w.Container.Add(b, n); err != nil {
+		w.logger().Warn("add usb node", "id", b.ID.String(), "node", n.Path, "error", err)
+		return
+	}
+	w.logger().Info("usb node added", "id", b.ID.String(), "node", n.Path)
+	if w.Changed != nil {
+		w.Changed("add", b, n)
+	}
+	nodes := w.nodes[i][:0:0]
+	for _, m := range w.nodes[i] {
+		if m.Path != n.Path {
+			nodes = append(nodes, m)
+		}
+	}
+	w.nodes[i] = append(nodes, n)
+	w.link(i)
+}
+
+func (w *Watcher) remove(i int, n Node) {
+	b := w.Bindings[i]
+	if err := w.Container.Remove(n); err != nil {
+		w.logger().Warn("remove usb node", "id", b.ID.String(), "node", n.Path, "error", err)
+	} else {
+		w.logger().Info("usb node removed", "id", b.ID.String(), "node", n.Path)
+		if w.Changed != nil {
+			w.Changed("remove", b, n)
+		}
+	}
+	var nodes []Node
+	for _, m := range w.nodes[i] {
+		if m.Path != n.Path {
+			nodes = append(nodes, m)
+		}
+	}
+	w.nodes[i] = nodes
+	w.link(i)
+}
+
+// link points the binding's DOS device at its primary node.
+func (w *Watcher) link(i int) {
+	b := w.Bindings[i]
+	var target *Node
+	if n, ok := Primary(w.nodes[i]); ok {
+		target = &n
+	}
+	if err := w.Container.Link(b.Label, target); err != nil {
+		w.logger().Warn("link usb device", "id", b.ID.String(), "label", b.Label, "error", err)
+	}
+}
+
+func (w *Watcher) sysRoot() string {
+	if w.SysRoot != "" {
+		return w.SysRoot
+	}
+	return "/sys"
+}
+
+func (w *Watcher) logger() *slog.Logger {
+	if w.Logger != nil {
+		return w.Logger
+	}
+	return slog.Default()
+}
+
+// DevicesCgroup returns the cgroup v1 devices directory of process pid, or
+// "" when its devices aren't controlled by cgroup v1.
+func DevicesCgroup(procRoot, cgroupRoot string, pid int) string {
+	data, err := os.ReadFile(filepath.Join(procRoot, fmt.Sprint(pid), "cgroup"))
+	if err != nil {
+		return ""
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		// hierarchy-ID:controller-list:cgroup-path
+		parts := strings.SplitN(line, ":", 3)
+		if len(parts) != 3 {
+			continue
+		}
+		for _, c := range strings.Split(parts[1], ",") {
+			if c == "devices" {
+				return filepath.Join(cgroupRoot, "devices", parts[2])
+			}
+		}
+	}
+	return ""
+}
+
+// ProcMajors returns the majors /proc/devices lists for the character
+// device drivers names.
+func ProcMajors(procDevices string, names []string) []int64 {
+	data, err := os.ReadFile(procDevices)
+	if err != nil {
+		return nil
+	}
+	var majors []int64
+	for _, line := range strings.Split(string(data), "\n") {
+		if strings.HasPrefix(line, "Block devices:") {
+			break
+		}
+		var major int64
+		var name string
+		if _, err := fmt.Sscanf(line, "%d %s", &major, &name); err != nil {
+			continue
+		}
+		for _, n := range names {
+			if n == name {
+				majors = append(majors, major)
+			}
+		}
+	}
+	return majors
+}
@@ -0,0 +1,94 @@
+package usb
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"golang.org/x/sys/unix"
+)
+
+// Event is a kernel uevent about a device node.
+type Event struct {
+	// Action is add, remove, change, bind, unbind...
+	Action string
+	// DevPath is the sysfs path of the device, relative to the sysfs root.
+	DevPath   string
+	Subsystem string
+	// Node is set for devices with a node, with DevPath filled in.
+	Node *Node
+}
+
+// ParseEvent decodes the message the kernel multicasts for a uevent: an
+// "action@devpath" header followed by NUL separated KEY=VALUE pairs.
+func ParseEvent(msg []byte) (Event, bool) {
+	fields := bytes.Split(msg, []byte{0})
+	if len(fields) == 0 || !bytes.Contains(fields[0], []byte("@")) {
+		// Not from the kernel: udev's messages start with "libudev".
+		return Event{}, false
+	}
+	env := map[string]string{}
+	for _, f := range fields[1:] {
+		if k, v, ok := strings.Cut(string(f), "="); ok {
+			env[k] = v
+		}
+	}
+	e := Event{Action: env["ACTION"], DevPath: env["DEVPATH"], Subsystem: env["SUBSYSTEM"]}
+	if e.Action == "" || e.DevPath == "" {
+		return Event{}, false
+	}
+	if name := env["DEVNAME"]; name != "" {
+		major, err1 := strconv.ParseInt(env["MAJOR"], 10, 64)
+		minor, err2 := strconv.ParseInt(env["MINOR"], 10, 64)
+		if err1 == nil && err2 == nil {
+			path := name
+			if !strings.HasPrefix(path, "/") {
+				path = "/dev/" + path
+			}
+			e.Node = &Node{Path: path, Major: major, Minor: minor, DevPath: e.DevPath}
+		}
+	}
+	return e, true
+}
+
+// Monitor receives the uevents the kernel multicasts over netlink.
+type Monitor struct {
+	f   *os.File
+	buf []byte
+}
+
+// Listen subscribes to kernel uevents.
+func Listen() (*Monitor, error) {
+	fd, err := unix.Socket(unix.AF_NETLINK, unix.SOCK_RAW|unix.SOCK_CLOEXEC|unix.SOCK_NONBLOCK, unix.NETLINK_KOBJECT_UEVENT)
+	if err != nil {
+		return nil, fmt.Errorf("uevent socket: %w", err)
+	}
+	// Group 1 carries the kernel's own events, before udev processed them.
+	if err := unix.Bind(fd, &unix.SockaddrNetlink{Family: unix.AF_NETLINK, Groups: 1}); err != nil {
+		unix.Close(fd)
+		return nil, fmt.Errorf("bind uevent socket: %w", err)
+	}
+	// A non-blocking file goes through the runtime poller, so Close wakes
+	// up a pending Next.
+	return &Monitor{f: os.NewFile(uintptr(fd), "uevent"), buf: make([]byte, 64*1024)}, nil
+}
+
+// Next blocks until the next uevent. It fails once the monitor is closed.
+func (m *Monitor) Next() (Event, error) {
+	for {
+		n, err := m.f.Read(m.buf)
+		if err != nil {
+			return Event{}, err
+		}
+		if e, ok := ParseEvent(m.buf[:n]); ok {
+			return e, nil
+		}
+	}
+}
+
+// Close stops the monitor.
+func (m *Monitor) Close() error {
+	return m.f.Close()
+}
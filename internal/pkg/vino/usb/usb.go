@@ -0,0 +1,176 @@
+// Package usb passes USB devices through to containers by vendor:product
+// id: it resolves the device nodes of a device from sysfs and keeps a
+// running container's nodes in sync with the device being plugged in and
+// out.
+package usb
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// ID is the vendor:product id of a USB device.
+type ID struct {
+	Vendor  uint16
+	Product uint16
+}
+
+// ParseID parses an id written vvvv:pppp in hex.
+func ParseID(s string) (ID, error) {
+	v, p, ok := strings.Cut(s, ":")
+	if !ok {
+		return ID{}, fmt.Errorf("usb id %q is not vendor:product", s)
+	}
+	vendor, err := strconv.ParseUint(v, 16, 16)
+	if err != nil {
+		return ID{}, fmt.Errorf("usb id %q: vendor: %w", s, err)
+	}
+	product, err := strconv.ParseUint(p, 16, 16)
+	if err != nil {
+		return ID{}, fmt.Errorf("usb id %q: product: %w", s, err)
+	}
+	return ID{Vendor: uint16(vendor), Product: uint16(product)}, nil
+}
+
+func (id ID) String() string {
+	return fmt.Sprintf("%04x:%04x", id.Vendor, id.Product)
+}
+
+// Node is a device node of a USB device or of one of its interfaces, e.g.
+// the /dev/bus/usb node of the device and the /dev/hidraw node of its HID
+// interface.
+type Node struct {
+	// Path is the node under /dev.
+	Path  string
+	Major int64
+	Minor int64
+	// DevPath is the sysfs path of the device owning the node, relative to
+	// the sysfs root, as uevents report it.
+	DevPath string
+}
+
+// Primary returns the node programs talk to, the first one that isn't the
+// raw /dev/bus/usb node, or false when there are none.
+func Primary(nodes []Node) (Node, bool) {
+	for _, n := range nodes {
+		if !strings.HasPrefix(n.Path, "/dev/bus/usb/") {
+			return n, true
+		}
+	}
+	if len(nodes) > 0 {
+		return nodes[0], true
+	}
+	return Node{}, false
+}
+
+// Resolve returns the nodes of the devices with id plugged in now, reading
+// the sysfs mounted at sysRoot.
+func Resolve(sysRoot string, id ID) ([]Node, error) {
+	entries, err := os.ReadDir(filepath.Join(sysRoot, "bus", "usb", "devices"))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var nodes []Node
+	for _, e := range entries {
+		dir, err := filepath.EvalSymlinks(filepath.Join(sysRoot, "bus", "usb", "devices", e.Name()))
+		if err != nil {
+			continue
+		}
+		if got, ok := readID(dir); !ok || got != id {
+			continue
+		}
+		found, err := walkNodes(sysRoot, dir)
+		if err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, found...)
+	}
+	return nodes, nil
+}
+
+// Matches reports whether devPath, as a uevent reports it, belongs to a
+// device with id: the device itself or one of its interfaces.
+func Matches(sysRoot, devPath string, id ID) bool {
+	for rel := filepath.Clean("/" + devPath); rel != "/"; rel = filepath.Dir(rel) {
+		if got, ok := readID(filepath.Join(sysRoot, rel)); ok {
+			return got == id
+		}
+	}
+	return false
+}
+
+// readID reads the id of the USB device whose sysfs directory is dir. Only
+// devices, not their interfaces, have one.
+func readID(dir string) (ID, bool) {
+	v, err := os.ReadFile(filepath.Join(dir, "idVendor"))
+	if err != nil {
+		return ID{}, false
+	}
+	p, err := os.ReadFile(filepath.Join(dir, "idProduct"))
+	if err != nil {
+		return ID{}, false
+	}
+	id, err := ParseID(strings.TrimSpace(string(v)) + ":" + strings.TrimSpace(string(p)))
+	return id, err == nil
+}
+
+// walkNodes returns the nodes of dir and of the devices under it.
+func walkNodes(sysRoot, dir string) ([]Node, error) {
+	var nodes []Node
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			// Attributes may vanish while the device is unplugged.
+			return nil
+		}
+		if d.Name() != "uevent" || d.IsDir() {
+			return nil
+		}
+		env, err := readUevent(path)
+		if err != nil || env["DEVNAME"] == "" {
+			return nil
+		}
+		major, err1 := strconv.ParseInt(env["MAJOR"], 10, 64)
+		minor, err2 := strconv.ParseInt(env["MINOR"], 10, 64)
+		if err1 != nil || err2 != nil {
+			return nil
+		}
+		rel, err := filepath.Rel(sysRoot, filepath.Dir(path))
+		if err != nil {
+			return nil
+		}
+		nodes = append(nodes, Node{
+			Path:    filepath.Join("/dev", env["DEVNAME"]),
+			Major:   major,
+			Minor:   minor,
+			DevPath: "/" + rel,
+		})
+		return nil
+	})
+	return nodes, err
+}
+
+// readUevent reads the KEY=VALUE lines of a sysfs uevent file.
+func readUevent(path string) (map[string]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	env := map[string]string{}
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		if k, v, ok := strings.Cut(s.Text(), "="); ok {
+			env[k] = v
+		}
+	}
+	return env, s.Err()
+}
@@ -0,0 +1,293 @@
+package usb
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"golang.org/x/sys/unix"
+
+	"github.com/TheGrizzlyDev/vino/internal/pkg/vino/rootfs"
+)
+
+const (
+	dongleDev = "/devices/pci0000:00/0000:00:14.0/usb1/1-1"
+	hidrawDev = dongleDev + "/1-1:1.0/0003:096E:0006.0001/hidraw/hidraw0"
+)
+
+// fakeSysfs lays out the sysfs of a root hub with a HID dongle plugged in.
+func fakeSysfs(t *testing.T) string {
+	t.Helper()
+	root := t.TempDir()
+	write := func(path, data string) {
+		t.Helper()
+		path = filepath.Join(root, path)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	hub := filepath.Dir(dongleDev)
+	write(hub+"/usb1/idVendor", "1d6b\n")
+	write(hub+"/usb1/idProduct", "0002\n")
+	write(hub+"/usb1/uevent", "MAJOR=189\nMINOR=0\nDEVNAME=bus/usb/001/001\n")
+	write(dongleDev+"/idVendor", "096e\n")
+	write(dongleDev+"/idProduct", "0006\n")
+	write(dongleDev+"/uevent", "MAJOR=189\nMINOR=1\nDEVNAME=bus/usb/001/002\nDEVTYPE=usb_device\n")
+	write(dongleDev+"/1-1:1.0/uevent", "DEVTYPE=usb_interface\n")
+	write(hidrawDev+"/uevent", "MAJOR=245\nMINOR=0\nDEVNAME=hidraw0\n")
+
+	devices := filepath.Join(root, "bus", "usb", "devices")
+	if err := os.MkdirAll(devices, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	for name, dev := range map[string]string{"usb1": hub + "/usb1", "1-1": dongleDev, "1-1:1.0": dongleDev + "/1-1:1.0"} {
+		if err := os.Symlink(filepath.Join(root, dev), filepath.Join(devices, name)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return root
+}
+
+var (
+	dongle     = ID{Vendor: 0x096e, Product: 0x0006}
+	busNode    = Node{Path: "/dev/bus/usb/001/002", Major: 189, Minor: 1, DevPath: dongleDev}
+	hidrawNode = Node{Path: "/dev/hidraw0", Major: 245, Minor: 0, DevPath: hidrawDev}
+)
+
+func TestParseID(t *testing.T) {
+	id, err := ParseID("096E:0006")
+	if err != nil || id != dongle {
+		t.Errorf("ParseID = %v, %v; want %v", id, err, dongle)
+	}
+	if id.String() != "096e:0006" {
+		t.Errorf("String = %q", id.String())
+	}
+	for _, bad := range []string{"096e", "zzzz:0006", "096e:10000"} {
+		if _, err := ParseID(bad); err == nil {
+			t.Errorf("ParseID(%q) succeeded", bad)
+		}
+	}
+}
+
+func TestResolve(t *testing.T) {
+	sys := fakeSysfs(t)
+	nodes, err := Resolve(sys, dongle)
+	if err != nil {
+		t.Fatalf("Resolve: %v", err)
+	}
+	if want := []Node{hidrawNode, busNode}; !reflect.DeepEqual(nodes, want) {
+		t.Errorf("Resolve = %+v, want %+v", nodes, want)
+	}
+	if n, ok := Primary(nodes); !ok || n != hidrawNode {
+		t.Errorf("Primary = %+v, %v; want the hidraw node", n, ok)
+	}
+
+	nodes, err = Resolve(sys, ID{Vendor: 0x1234, Product: 0x5678})
+	if err != nil || len(nodes) != 0 {
+		t.Errorf("Resolve(absent) = %v, %v; want none", nodes, err)
+	}
+	if !Matches(sys, hidrawDev, dongle) {
+		t.Errorf("hidraw node doesn't match its device")
+	}
+	if Matches(sys, filepath.Dir(dongleDev)+"/usb1", dongle) {
+		t.Errorf("root hub matches the dongle")
+	}
+}
+
+func TestParseEvent(t *testing.T) {
+	msg := strings.Join([]string{
+		"add@" + hidrawDev,
+		"ACTION=add",
+		"DEVPATH=" + hidrawDev,
+		"SUBSYSTEM=hidraw",
+		"MAJOR=245",
+		"MINOR=0",
+		"DEVNAME=hidraw0",
+		"SEQNUM=4242",
+	}, "\x00") + "\x00"
+	e, ok := ParseEvent([]byte(msg))
+	if !ok {
+		t.Fatalf("ParseEvent failed")
+	}
+	want := Event{Action: "add", DevPath: hidrawDev, Subsystem: "hidraw", Node: &hidrawNode}
+	if !reflect.DeepEqual(e, want) {
+		t.Errorf("ParseEvent = %+v, want %+v", e, want)
+	}
+
+	if _, ok := ParseEvent([]byte("libudev\x00\xfe\xed")); ok {
+		t.Errorf("ParseEvent accepted a udev message")
+	}
+}
+
+// fakeMknod creates a regular file recording the node's numbers.
+func fakeMknod(root, path string, mode uint32, dev int) error {
+	f, err := rootfs.Open(root, path, unix.O_WRONLY|unix.O_CREAT|unix.O_EXCL, 0o600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = fmt.Fprintf(f, "%d:%d", unix.Major(uint64(dev)), unix.Minor(uint64(dev)))
+	return err
+}
+
+// events replays uevents to a watcher.
+type events []Event
+
+func (e *events) Next() (Event, error) {
+	if len(*e) == 0 {
+		return Event{}, errors.New("done")
+	}
+	next := (*e)[0]
+	*e = (*e)[1:]
+	return next, nil
+}
+
+func TestWatcher(t *testing.T) {
+	sys := fakeSysfs(t)
+	root := t.TempDir()
+	cgroup := t.TempDir()
+	c := &Container{Root: root, Prefix: "/wine", DevicesCgroup: cgroup, mknod: fakeMknod}
+	var changes []string
+	w := &Watcher{SysRoot: sys, Bindings: []Binding{{ID: dongle, Label: "COM3", Access: "rw", FileMode: 0o666}}, Container: c,
+		Changed: func(action string, b Binding, n Node) {
+			changes = append(changes, action+" "+b.ID.String()+" "+n.Path)
+		},
+	}
+
+	if err := w.Sync(); err != nil {
+		t.Fatalf("Sync: %v", err)
+	}
+	node := filepath.Join(root, "dev", "hidraw0")
+	if data, err := os.ReadFile(node); err != nil || string(data) != "245:0" {
+		t.Fatalf("hidraw node = %q, %v; want 245:0", data, err)
+	}
+	link := filepath.Join(root, "wine", "dosdevices", "com3")
+	if target, err := os.Readlink(link); err != nil || target != "/dev/hidraw0" {
+		t.Fatalf("com3 -> %q, %v; want /dev/hidraw0", target, err)
+	}
+	if data, _ := os.ReadFile(filepath.Join(cgroup, "devices.allow")); string(data) != "c 189:1 rw" {
+		t.Errorf("last allowed rule = %q", data)
+	}
+
+	// Unplugged: the device is gone from sysfs before its events arrive.
+	if err := os.RemoveAll(filepath.Join(sys, dongleDev)); err != nil {
+		t.Fatal(err)
+	}
+	src := &events{
+		{Action: "remove", DevPath: hidrawDev, Subsystem: "hidraw", Node: &hidrawNode},
+		{Action: "remove", DevPath: dongleDev, Subsystem: "usb", Node: &busNode},
+	}
+	w.Run(src)
+	if _, err := os.Stat(node); !os.IsNotExist(err) {
+		t.Errorf("hidraw node still there after unplug: %v", err)
+	}
+	if _, err := os.Lstat(link); !os.IsNotExist(err) {
+		t.Errorf("com3 still linked after unplug: %v", err)
+	}
+	if data, _ := os.ReadFile(filepath.Join(cgroup, "devices.deny")); string(data) != "c 189:1 rwm" {
+		t.Errorf("last denied rule = %q", data)
+	}
+
+	// Plugged back in, on another bus number.
+	sys2 := fakeSysfs(t)
+	w.SysRoot = sys2
+	replugged := hidrawNode
+	replugged.Path, replugged.Minor = "/dev/hidraw1", 1
+	src = &events{
+		{Action: "add", DevPath: dongleDev, Subsystem: "usb", Node: &busNode},
+		{Action: "add", DevPath: hidrawDev, Subsystem: "hidraw", Node: &replugged},
+	}
+	w.Run(src)
+	if target, err := os.Readlink(link); err != nil || target != "/dev/hidraw1" {
+		t.Errorf("com3 -> %q, %v; want /dev/hidraw1", target, err)
+	}
+	want := []string{
+		"add 096e:0006 /dev/hidraw0", "add 096e:0006 /dev/bus/usb/001/002",
+		"remove 096e:0006 /dev/hidraw0", "remove 096e:0006 /dev/bus/usb/001/002",
+		"add 096e:0006 /dev/bus/usb/001/002", "add 096e:0006 /dev/hidraw1",
+	}
+	if !reflect.DeepEqual(changes, want) {
+		t.Errorf("changes = %q, want %q", changes, want)
+	}
+}
+
+func TestContainerStaysInRoot(t *testing.T) {
+	dir := t.TempDir()
+	root := filepath.Join(dir, "root")
+	outside := filepath.Join(dir, "outside")
+	for _, d := range []string{filepath.Join(root, "wine"), filepath.Join(root, "outside"), outside} {
+		if err := os.MkdirAll(d, 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// The container points its /dev and dosdevices out of its root.
+	if err := os.Symlink("../outside", filepath.Join(root, "dev")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("../../outside", filepath.Join(root, "wine", "dosdevices")); err != nil {
+		t.Fatal(err)
+	}
+	var made []string
+	c := &Container{Root: root, Prefix: "/wine", mknod: func(root, path string, mode uint32, dev int) error {
+		made = append(made, fmt.Sprintf("%s %o", path, mode&0o777))
+		return fakeMknod(root, path, mode, dev)
+	}}
+	b := Binding{ID: dongle, Label: "COM3", Access: "r", FileMode: 0o444}
+	if err := c.Add(b, hidrawNode); err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+	if err := c.Link(b.Label, &hidrawNode); err != nil {
+		t.Fatalf("Link: %v", err)
+	}
+	if want := []string{"/dev/hidraw0 444"}; !reflect.DeepEqual(made, want) {
+		t.Errorf("nodes made = %q, want %q", made, want)
+	}
+	// Both resolve to root's own outside, not to the host's.
+	for _, p := range []string{filepath.Join(root, "outside", "hidraw0"), filepath.Join(root, "outside", "com3")} {
+		if _, err := os.Lstat(p); err != nil {
+			t.Errorf("%s: %v", p, err)
+		}
+	}
+	if entries, _ := os.ReadDir(outside); len(entries) != 0 {
+		t.Errorf("host directory written to: %v", entries)
+	}
+}
+
+func TestDevicesCgroup(t *testing.T) {
+	proc := t.TempDir()
+	write := func(data string) {
+		t.Helper()
+		if err := os.MkdirAll(filepath.Join(proc, "42"), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(proc, "42", "cgroup"), []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("12:cpu,cpuacct:/ctr\n5:devices:/docker/abc\n0::/\n")
+	if got, want := DevicesCgroup(proc, "/sys/fs/cgroup", 42), "/sys/fs/cgroup/devices/docker/abc"; got != want {
+		t.Errorf("DevicesCgroup = %q, want %q", got, want)
+	}
+	write("0::/system.slice/ctr.scope\n")
+	if got := DevicesCgroup(proc, "/sys/fs/cgroup", 42); got != "" {
+		t.Errorf("DevicesCgroup on cgroup v2 = %q, want none", got)
+	}
+}
+
+func TestProcMajors(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "devices")
+	data := "Character devices:\n  1 mem\n180 usb\n188 ttyUSB\n189 usb_device\n245 hidraw\n\nBlock devices:\n245 hidraw\n"
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := ProcMajors(path, HotplugMajors), []int64{180, 188, 189, 245}; !reflect.DeepEqual(got, want) {
+		t.Errorf("ProcMajors = %v, want %v", got, want)
+	}
+}
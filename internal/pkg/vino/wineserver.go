@@ -0,0 +1,81 @@
+package vino
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"syscall"
+	"time"
+)
+
+// WineserverState describes the wineserver of a prefix as seen by a new
+// client.
+type WineserverState int
+
+const (
+	// WineserverNotStarted means no wineserver ever ran for the prefix.
+	WineserverNotStarted WineserverState = iota
+	// WineserverRunning means a wineserver accepts connections.
+	WineserverRunning
+	// WineserverGone means a wineserver ran for the prefix but exited.
+	WineserverGone
+)
+
+// wineserverTmpDir is where wine keeps its per-user server directories.
+var wineserverTmpDir = "/tmp"
+
+// WineserverSocketPath returns the socket wine clients of prefix connect to:
+// /tmp/.wine-<uid>/server-<dev>-<inode>/socket, derived from the prefix
+// directory the way wine does.
+func WineserverSocketPath(prefix string) (string, error) {
+	fi, err := os.Stat(prefix)
+	if err != nil {
+		return "", err
+	}
+	st, ok := fi.Sys().(*syscall.Stat_t)
+	if !ok {
+		return "", fmt.Errorf("stat %s: no device and inode", prefix)
+	}
+	dir := fmt.Sprintf(".wine-%d/server-%x-%x", os.Getuid(), uint64(st.Dev), uint64(st.Ino))
+	return filepath.Join(wineserverTmpDir, dir, "socket"), nil
+}
+
+// ProbeWineserver reports the state of the wineserver of prefix.
+func ProbeWineserver(prefix string) (WineserverState, error) {
+	sock, err := WineserverSocketPath(prefix)
+	if err != nil {
+		return WineserverNotStarted, err
+	}
+	if _, err := os.Stat(filepath.Dir(sock)); os.IsNotExist(err) {
+		return WineserverNotStarted, nil
+	}
+	conn, err := net.Dial("unix", sock)
+	if err != nil {
+		return WineserverGone, nil
+	}
+	conn.Close()
+	return WineserverRunning, nil
+}
+
+// WatchWineserver probes the wineserver of prefix every interval until ctx
+// is done, calling changed with the state it finds it in each time that
+// state changes, starting with the first.
+func WatchWineserver(ctx context.Context, prefix string, interval time.Duration, changed func(WineserverState)) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	last := WineserverState(-1)
+	for {
+		// The prefix may not be there before wine creates it.
+		if state, err := ProbeWineserver(prefix); err == nil && state != last {
+			last = state
+			changed(state)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+	}
+}
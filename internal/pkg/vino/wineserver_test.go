@@ -0,0 +1,104 @@
+package vino
+
+import (
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestProbeWineserver(t *testing.T) {
+	old := wineserverTmpDir
+	wineserverTmpDir = t.TempDir()
+	defer func() { wineserverTmpDir = old }()
+
+	prefix := t.TempDir()
+	probe := func() WineserverState {
+		t.Helper()
+		state, err := ProbeWineserver(prefix)
+		if err != nil {
+			t.Fatalf("ProbeWineserver: %v", err)
+		}
+		return state
+	}
+
+	if got := probe(); got != WineserverNotStarted {
+		t.Fatalf("state = %v, want WineserverNotStarted", got)
+	}
+
+	sock, err := WineserverSocketPath(prefix)
+	if err != nil {
+		t.Fatalf("WineserverSocketPath: %v", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(sock), 0o700); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	l, err := net.Listen("unix", sock)
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	if got := probe(); got != WineserverRunning {
+		t.Fatalf("state = %v, want WineserverRunning", got)
+	}
+
+	l.Close()
+	if got := probe(); got != WineserverGone {
+		t.Fatalf("state = %v, want WineserverGone", got)
+	}
+}
+
+func TestProbeWineserverMissingPrefix(t *testing.T) {
+	if _, err := ProbeWineserver(filepath.Join(t.TempDir(), "missing")); err == nil {
+		t.Fatalf("expected error, got nil")
+	}
+}
+
+func TestWatchWineserver(t *testing.T) {
+	old := wineserverTmpDir
+	wineserverTmpDir = t.TempDir()
+	defer func() { wineserverTmpDir = old }()
+
+	prefix := t.TempDir()
+	sock, err := WineserverSocketPath(prefix)
+	if err != nil {
+		t.Fatalf("WineserverSocketPath: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	states := make(chan WineserverState, 10)
+	watched := make(chan struct{})
+	go func() {
+		defer close(watched)
+		WatchWineserver(ctx, prefix, 5*time.Millisecond, func(s WineserverState) { states <- s })
+	}()
+	next := func(want WineserverState) {
+		t.Helper()
+		select {
+		case got := <-states:
+			if got != want {
+				t.Fatalf("state = %v, want %v", got, want)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("no change to %v", want)
+		}
+	}
+
+	next(WineserverNotStarted)
+	if err := os.MkdirAll(filepath.Dir(sock), 0o700); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	l, err := net.Listen("unix", sock)
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	next(WineserverRunning)
+	l.Close()
+	next(WineserverGone)
+
+	cancel()
+	<-watched
+	if len(states) != 0 {
+		t.Errorf("unchanged states reported: %d", len(states))
+	}
+}
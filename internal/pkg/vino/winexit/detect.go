@@ -0,0 +1,203 @@
+package winexit
+
+import (
+	"bytes"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// NTStatus describes a well known NTSTATUS value.
+type NTStatus struct {
+	Code   uint32
+	Name   string
+	Reason string
+	// ExitCode mirrors the POSIX signal the status corresponds to, as
+	// 128+signal, so that crashes look like their Linux counterparts.
+	ExitCode int
+}
+
+// Exit codes of crashes, as 128 + the closest POSIX signal.
+const (
+	exitSIGINT  = 130
+	exitSIGILL  = 132
+	exitSIGTRAP = 133
+	exitSIGABRT = 134
+	exitSIGBUS  = 135
+	exitSIGFPE  = 136
+	exitSIGSEGV = 139
+)
+
+// Wine specific exception codes.
+const (
+	exceptionWineStub      = 0x80000100
+	exceptionWineAssertion = 0x80000101
+)
+
+var ntStatuses = map[uint32]NTStatus{}
+
+func init() {
+	for _, s := range []NTStatus{
+		{0x80000002, "STATUS_DATATYPE_MISALIGNMENT", "misaligned data access", exitSIGBUS},
+		{0x80000003, "STATUS_BREAKPOINT", "breakpoint", exitSIGTRAP},
+		{0x80000004, "STATUS_SINGLE_STEP", "single step", exitSIGTRAP},
+		{0xC0000005, "STATUS_ACCESS_VIOLATION", "access violation", exitSIGSEGV},
+		{0xC0000006, "STATUS_IN_PAGE_ERROR", "in-page I/O error", exitSIGBUS},
+		{0xC000001D, "STATUS_ILLEGAL_INSTRUCTION", "illegal instruction", exitSIGILL},
+		{0xC000008C, "STATUS_ARRAY_BOUNDS_EXCEEDED", "array bounds exceeded", exitSIGSEGV},
+		{0xC000008D, "STATUS_FLOAT_DENORMAL_OPERAND", "denormal floating point operand", exitSIGFPE},
+		{0xC000008E, "STATUS_FLOAT_DIVIDE_BY_ZERO", "floating point division by zero", exitSIGFPE},
+		{0xC000008F, "STATUS_FLOAT_INEXACT_RESULT", "inexact floating point result", exitSIGFPE},
+		{0xC0000090, "STATUS_FLOAT_INVALID_OPERATION", "invalid floating point operation", exitSIGFPE},
+		{0xC0000091, "STATUS_FLOAT_OVERFLOW", "floating point overflow", exitSIGFPE},
+		{0xC0000092, "STATUS_FLOAT_STACK_CHECK", "floating point stack check", exitSIGFPE},
+		{0xC0000093, "STATUS_FLOAT_UNDERFLOW", "floating point underflow", exitSIGFPE},
+		{0xC0000094, "STATUS_INTEGER_DIVIDE_BY_ZERO", "integer division by zero", exitSIGFPE},
+		{0xC0000095, "STATUS_INTEGER_OVERFLOW", "integer overflow", exitSIGFPE},
+		{0xC0000096, "STATUS_PRIVILEGED_INSTRUCTION", "privileged instruction", exitSIGILL},
+		{0xC00000FD, "STATUS_STACK_OVERFLOW", "stack overflow", exitSIGSEGV},
+		{0xC0000135, "STATUS_DLL_NOT_FOUND", "dll not found", exitSIGABRT},
+		{0xC0000139, "STATUS_ENTRYPOINT_NOT_FOUND", "entry point not found", exitSIGABRT},
+		{0xC000013A, "STATUS_CONTROL_C_EXIT", "terminated by ctrl-c", exitSIGINT},
+		{0xC0000142, "STATUS_DLL_INIT_FAILED", "dll initialization failed", exitSIGABRT},
+		{0xC0000194, "STATUS_POSSIBLE_DEADLOCK", "possible deadlock", exitSIGABRT},
+		{0xC0000374, "STATUS_HEAP_CORRUPTION", "heap corruption", exitSIGABRT},
+		{0xC0000409, "STATUS_STACK_BUFFER_OVERRUN", "stack buffer overrun (fail fast)", exitSIGABRT},
+		{0xC0000417, "STATUS_INVALID_CRUNTIME_PARAMETER", "invalid C runtime parameter", exitSIGABRT},
+		{0xE06D7363, "EXCEPTION_MSVC_CPP", "unhandled C++ exception", exitSIGABRT},
+		{exceptionWineStub, "EXCEPTION_WINE_STUB", "unimplemented function called", ExitWineCannotRun},
+		{exceptionWineAssertion, "EXCEPTION_WINE_ASSERTION", "wine assertion failed", ExitWineCannotRun},
+	} {
+		ntStatuses[s.Code] = s
+	}
+}
+
+// LookupNTStatus returns the description of code, if it is a known
+// NTSTATUS.
+func LookupNTStatus(code uint32) (NTStatus, bool) {
+	s, ok := ntStatuses[code]
+	return s, ok
+}
+
+// Detection is a crash or wine failure decoded from a diagnostic line.
+type Detection struct {
+	Kind     Kind
+	Code     uint32
+	Name     string
+	Reason   string
+	ExitCode int
+	Line     string
+}
+
+type linePattern struct {
+	re *regexp.Regexp
+	// code is the NTSTATUS the line reports, or 0 when it carries it in its
+	// first submatch.
+	code uint32
+	// wine marks failures of wine itself rather than of the Windows process.
+	wine     bool
+	exitCode int
+	reason   string
+}
+
+// The messages wine prints, prefixed by "wine: ", when a process dies of an
+// unhandled exception or can't be started at all.
+var linePatterns = []linePattern{
+	{re: regexp.MustCompile(`^wine: Unhandled page fault on (?:\w+ )?access to`), code: 0xC0000005},
+	{re: regexp.MustCompile(`^wine: Unhandled illegal instruction`), code: 0xC000001D},
+	{re: regexp.MustCompile(`^wine: Unhandled privileged instruction`), code: 0xC0000096},
+	{re: regexp.MustCompile(`^wine: Unhandled division by zero`), code: 0xC0000094},
+	{re: regexp.MustCompile(`^wine: Unhandled overflow`), code: 0xC0000095},
+	{re: regexp.MustCompile(`^wine: Unhandled array bounds`), code: 0xC000008C},
+	{re: regexp.MustCompile(`^wine: Unhandled stack overflow`), code: 0xC00000FD},
+	{re: regexp.MustCompile(`^wine: Unhandled alignment`), code: 0x80000002},
+	{re: regexp.MustCompile(`^wine: Unhandled breakpoint`), code: 0x80000003},
+	{re: regexp.MustCompile(`^wine: Unhandled \^C`), code: 0xC000013A},
+	{re: regexp.MustCompile(`^wine: Unhandled exception (0x[0-9a-fA-F]{8})`)},
+	{re: regexp.MustCompile(`^wine: Unimplemented function \S+ called`), code: exceptionWineStub, wine: true},
+	{re: regexp.MustCompile(`^wine: Assertion failed`), code: exceptionWineAssertion, wine: true},
+	{re: regexp.MustCompile(`^wine: cannot find `), wine: true, exitCode: ExitWineNotFound, reason: "program not found"},
+	{re: regexp.MustCompile(`^wine: (?:could not load|failed to initialize|could not exec|Bad EXE format)`), wine: true, exitCode: ExitWineCannotRun, reason: "wine could not start the program"},
+}
+
+// Detect decodes a single line of wine's stderr.
+func Detect(line string) (*Detection, bool) {
+	line = strings.TrimSpace(line)
+	for _, p := range linePatterns {
+		m := p.re.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		d := &Detection{Kind: KindCrashed, Code: p.code, ExitCode: p.exitCode, Reason: p.reason, Line: line}
+		if len(m) > 1 {
+			code, err := strconv.ParseUint(m[1], 0, 32)
+			if err != nil {
+				continue
+			}
+			d.Code = uint32(code)
+		}
+		if p.wine {
+			d.Kind = KindWineError
+		}
+		if s, ok := LookupNTStatus(d.Code); ok && d.Code != 0 {
+			d.Name = s.Name
+			d.Reason = s.Reason
+			d.ExitCode = s.ExitCode
+		}
+		if d.ExitCode == 0 {
+			d.ExitCode = exitSIGABRT
+			d.Reason = "unhandled exception"
+		}
+		return d, true
+	}
+	return nil, false
+}
+
+// Detector is an io.Writer scanning wine's stderr for the diagnostics
+// Detect understands. The first detection wins, as later lines are usually
+// fallout of the first failure.
+type Detector struct {
+	mu    sync.Mutex
+	buf   []byte
+	found *Detection
+}
+
+func (d *Detector) Write(p []byte) (int, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.buf = append(d.buf, p...)
+	for {
+		i := bytes.IndexByte(d.buf, '\n')
+		if i < 0 {
+			break
+		}
+		d.scan(string(d.buf[:i]))
+		d.buf = d.buf[i+1:]
+	}
+	// Diagnostics are short; don't let a chatty program without newlines
+	// grow the buffer unbounded.
+	if len(d.buf) > 4096 {
+		d.buf = d.buf[:0]
+	}
+	return len(p), nil
+}
+
+func (d *Detector) scan(line string) {
+	if d.found != nil {
+		return
+	}
+	if found, ok := Detect(line); ok {
+		d.found = found
+	}
+}
+
+// Detection returns the first failure seen so far, or nil.
+func (d *Detector) Detection() *Detection {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.found == nil && len(d.buf) > 0 {
+		d.scan(string(d.buf))
+	}
+	return d.found
+}
@@ -0,0 +1,199 @@
+// Package winexit decodes how a Windows process run under wine ended.
+//
+// wine only hands the low byte of a Windows exit code to its parent, so an
+// access violation (0xC0000005) is indistinguishable from a program that
+// returned 5. The full NTSTATUS of a crash is recovered from the diagnostics
+// wine prints on stderr, and both crashes and wine's own failures are mapped
+// to container exit codes that tell them apart from application exits.
+package winexit
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+// Kind classifies how a process ended.
+type Kind string
+
+const (
+	// KindExited means the Windows process returned an exit code.
+	KindExited Kind = "exited"
+	// KindCrashed means the Windows process died of an unhandled exception.
+	KindCrashed Kind = "crashed"
+	// KindWineError means wine itself could not run the process.
+	KindWineError Kind = "wine_error"
+	// KindSignaled means wine was killed by a signal.
+	KindSignaled Kind = "signaled"
+)
+
+// Container exit codes reported for wine failures, following the shell
+// conventions for commands that can't be run or found.
+const (
+	ExitWineCannotRun = 126
+	ExitWineNotFound  = 127
+)
+
+// StatusPath is where the launcher of a container's main process records its
+// Status, inside the container.
+const StatusPath = "/var/lib/vino/status.json"
+
+// Status describes how a process ended.
+type Status struct {
+	Kind Kind `json:"kind"`
+	// Code is the Windows exit code or the NTSTATUS of a crash. Only its low
+	// byte is known for KindExited.
+	Code uint32 `json:"code"`
+	// Name is the symbolic NTSTATUS name, when known.
+	Name string `json:"name,omitempty"`
+	// Reason is a human readable explanation.
+	Reason string `json:"reason,omitempty"`
+	// Detail is the diagnostic line the status was decoded from.
+	Detail string `json:"detail,omitempty"`
+	// ExitCode is the exit code reported for the container.
+	ExitCode int       `json:"exit_code"`
+	Time     time.Time `json:"time"`
+}
+
+// CodeString formats Code as an NTSTATUS for crashes and wine failures and
+// as a decimal exit code otherwise.
+func (s Status) CodeString() string {
+	if s.Kind == KindCrashed || (s.Kind == KindWineError && s.Code != 0) {
+		return fmt.Sprintf("0x%08X", s.Code)
+	}
+	return strconv.FormatUint(uint64(s.Code), 10)
+}
+
+// Annotation keys under which a Status is reported in `runc state`.
+const (
+	AnnotationKind     = "dev.vinoc.exit.kind"
+	AnnotationCode     = "dev.vinoc.exit.code"
+	AnnotationName     = "dev.vinoc.exit.name"
+	AnnotationReason   = "dev.vinoc.exit.reason"
+	AnnotationExitCode = "dev.vinoc.exit.exit_code"
+)
+
+// Annotations returns s as state annotations.
+func (s Status) Annotations() map[string]string {
+	a := map[string]string{
+		AnnotationKind:     string(s.Kind),
+		AnnotationCode:     s.CodeString(),
+		AnnotationExitCode: strconv.Itoa(s.ExitCode),
+	}
+	if s.Name != "" {
+		a[AnnotationName] = s.Name
+	}
+	if s.Reason != "" {
+		a[AnnotationReason] = s.Reason
+	}
+	return a
+}
+
+// Resolve decodes the end of a wine process from its exit code, as reported
+// by os.ProcessState.ExitCode, the signal that killed it, if any, and what d
+// saw on its stderr. d may be nil.
+func Resolve(exitCode int, signal os.Signal, d *Detector) Status {
+	s := Status{Time: time.Now()}
+	var found *Detection
+	if d != nil {
+		found = d.Detection()
+	}
+
+	switch {
+	case signal != nil:
+		s.Kind = KindSignaled
+		s.Reason = "wine terminated by signal: " + signal.String()
+		s.ExitCode = 128
+		if sig, ok := signal.(syscall.Signal); ok {
+			s.ExitCode += int(sig)
+		}
+	case found != nil && exitCode != 0:
+		s.Kind = found.Kind
+		s.Code = found.Code
+		s.Name = found.Name
+		s.Reason = found.Reason
+		s.Detail = found.Line
+		s.ExitCode = found.ExitCode
+	default:
+		s.Kind = KindExited
+		s.Code = uint32(exitCode)
+		s.ExitCode = exitCode
+	}
+	return s
+}
+
+// LaunchFailed returns the Status of a launch that failed because wine itself
+// could not be executed.
+func LaunchFailed(err error) Status {
+	exitCode := ExitWineCannotRun
+	if errors.Is(err, exec.ErrNotFound) || errors.Is(err, fs.ErrNotExist) {
+		exitCode = ExitWineNotFound
+	}
+	return Status{
+		Kind:     KindWineError,
+		Reason:   err.Error(),
+		ExitCode: exitCode,
+		Time:     time.Now(),
+	}
+}
+
+// ExitError reports a Windows process, or wine, that did not exit cleanly.
+type ExitError struct {
+	Status Status
+}
+
+func (e *ExitError) Error() string {
+	s := e.Status
+	switch s.Kind {
+	case KindExited:
+		return fmt.Sprintf("windows process exited with code %d", s.Code)
+	case KindCrashed:
+		if s.Name != "" {
+			return fmt.Sprintf("windows process crashed: %s %s (%s)", s.CodeString(), s.Name, s.Reason)
+		}
+		return fmt.Sprintf("windows process crashed: %s (%s)", s.CodeString(), s.Reason)
+	default:
+		return fmt.Sprintf("wine failed: %s", s.Reason)
+	}
+}
+
+// ExitCode returns the exit code to report for the container.
+func (e *ExitError) ExitCode() int {
+	return e.Status.ExitCode
+}
+
+// WriteFile records s at path, creating its directory as needed.
+func WriteFile(path string, s Status) error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("create status directory: %w", err)
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return fmt.Errorf("write status: %w", err)
+	}
+	return os.Rename(tmp, path)
+}
+
+// ReadFile reads the Status recorded at path.
+func ReadFile(path string) (Status, error) {
+	var s Status
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return s, err
+	}
+	if err := json.Unmarshal(data, &s); err != nil {
+		return s, fmt.Errorf("decode status %s: %w", path, err)
+	}
+	return s, nil
+}
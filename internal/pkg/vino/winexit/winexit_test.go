@@ -0,0 +1,201 @@
+package winexit
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+)
+
+func TestDetect(t *testing.T) {
+	tests := []struct {
+		line     string
+		kind     Kind
+		code     uint32
+		name     string
+		exitCode int
+	}{
+		{
+			line:     "wine: Unhandled page fault on read access to 0000000000000000 at address 0000000140001010 (thread 0024), starting debugger...",
+			kind:     KindCrashed,
+			code:     0xC0000005,
+			name:     "STATUS_ACCESS_VIOLATION",
+			exitCode: 139,
+		},
+		{
+			line:     "wine: Unhandled illegal instruction at address 0000000140001010 (thread 0024), starting debugger...",
+			kind:     KindCrashed,
+			code:     0xC000001D,
+			name:     "STATUS_ILLEGAL_INSTRUCTION",
+			exitCode: 132,
+		},
+		{
+			line:     "wine: Unhandled division by zero at address 0000000140001010 (thread 0024), starting debugger...",
+			kind:     KindCrashed,
+			code:     0xC0000094,
+			name:     "STATUS_INTEGER_DIVIDE_BY_ZERO",
+			exitCode: 136,
+		},
+		{
+			line:     "wine: Unhandled exception 0xc0000409 in thread 24 at address 0000000140001010 (thread 0024), starting debugger...",
+			kind:     KindCrashed,
+			code:     0xC0000409,
+			name:     "STATUS_STACK_BUFFER_OVERRUN",
+			exitCode: 134,
+		},
+		{
+			line:     "wine: Unhandled exception 0x20474343 in thread 24 at address 0000000140001010 (thread 0024), starting debugger...",
+			kind:     KindCrashed,
+			code:     0x20474343,
+			exitCode: 134,
+		},
+		{
+			line:     "wine: Unimplemented function msvcp140.dll.?_Xbad_function_call@std@@YAXXZ called at address 00006FFFFF4E0C5C (thread 0024), starting debugger...",
+			kind:     KindWineError,
+			code:     0x80000100,
+			name:     "EXCEPTION_WINE_STUB",
+			exitCode: 126,
+		},
+		{
+			line:     `wine: cannot find L"C:\\windows\\system32\\app.exe"`,
+			kind:     KindWineError,
+			exitCode: 127,
+		},
+		{
+			line:     "wine: could not load kernel32.dll, status c0000135",
+			kind:     KindWineError,
+			exitCode: 126,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.line, func(t *testing.T) {
+			d, ok := Detect(tt.line)
+			if !ok {
+				t.Fatalf("Detect() found nothing")
+			}
+			if d.Kind != tt.kind || d.Code != tt.code || d.Name != tt.name || d.ExitCode != tt.exitCode {
+				t.Fatalf("Detect() = %+v, want kind %s code %#x name %q exit %d", d, tt.kind, tt.code, tt.name, tt.exitCode)
+			}
+		})
+	}
+}
+
+func TestDetectIgnoresOtherLines(t *testing.T) {
+	for _, line := range []string{
+		"",
+		"0024:fixme:ntdll:NtQuerySystemInformation info_class SYSTEM_PERFORMANCE_INFORMATION",
+		"hello from the application",
+		"Unhandled exception: page fault on read access to 0x00000000 in 64-bit code",
+	} {
+		if d, ok := Detect(line); ok {
+			t.Fatalf("Detect(%q) = %+v, want nothing", line, d)
+		}
+	}
+}
+
+func TestDetectorKeepsFirstDetection(t *testing.T) {
+	var d Detector
+	fmt.Fprint(&d, "0024:err:module:import_dll Library foo.dll not found\nwine: Unhandled page fault on write ")
+	fmt.Fprint(&d, "access to 0000000000000000 at address 0000000140001010\n")
+	fmt.Fprint(&d, "wine: Unhandled exception 0xc0000409 in thread 24\n")
+
+	found := d.Detection()
+	if found == nil || found.Code != 0xC0000005 {
+		t.Fatalf("Detection() = %+v, want access violation", found)
+	}
+}
+
+func TestResolve(t *testing.T) {
+	crash := &Detector{}
+	fmt.Fprintln(crash, "wine: Unhandled page fault on read access to 0000000000000000 at address 0000000140001010")
+
+	tests := []struct {
+		name     string
+		exitCode int
+		signal   os.Signal
+		detector *Detector
+		want     Status
+	}{
+		{
+			name:     "clean exit",
+			exitCode: 0,
+			detector: &Detector{},
+			want:     Status{Kind: KindExited, Code: 0, ExitCode: 0},
+		},
+		{
+			name:     "application exit code",
+			exitCode: 3,
+			want:     Status{Kind: KindExited, Code: 3, ExitCode: 3},
+		},
+		{
+			name:     "crash",
+			exitCode: 5,
+			detector: crash,
+			want: Status{
+				Kind:     KindCrashed,
+				Code:     0xC0000005,
+				Name:     "STATUS_ACCESS_VIOLATION",
+				Reason:   "access violation",
+				Detail:   "wine: Unhandled page fault on read access to 0000000000000000 at address 0000000140001010",
+				ExitCode: 139,
+			},
+		},
+		{
+			name:     "crash message but clean exit",
+			exitCode: 0,
+			detector: crash,
+			want:     Status{Kind: KindExited, Code: 0, ExitCode: 0},
+		},
+		{
+			name:     "killed",
+			exitCode: -1,
+			signal:   syscall.SIGKILL,
+			want:     Status{Kind: KindSignaled, Reason: "wine terminated by signal: killed", ExitCode: 137},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Resolve(tt.exitCode, tt.signal, tt.detector)
+			got.Time = tt.want.Time
+			if got != tt.want {
+				t.Fatalf("Resolve() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStatusAnnotations(t *testing.T) {
+	s := Status{Kind: KindCrashed, Code: 0xC0000005, Name: "STATUS_ACCESS_VIOLATION", Reason: "access violation", ExitCode: 139}
+	a := s.Annotations()
+	want := map[string]string{
+		AnnotationKind:     "crashed",
+		AnnotationCode:     "0xC0000005",
+		AnnotationName:     "STATUS_ACCESS_VIOLATION",
+		AnnotationReason:   "access violation",
+		AnnotationExitCode: "139",
+	}
+	for k, v := range want {
+		if a[k] != v {
+			t.Fatalf("annotation %s = %q, want %q", k, a[k], v)
+		}
+	}
+	if got := (Status{Kind: KindExited, Code: 3}).CodeString(); got != "3" {
+		t.Fatalf("CodeString() = %q, want 3", got)
+	}
+}
+
+func TestStatusFileRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "var", "lib", "vino", "status.json")
+	s := Resolve(3, nil, nil)
+	if err := WriteFile(path, s); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	got, err := ReadFile(path)
+	if err != nil {
+		t.Fatalf("ReadFile: %v", err)
+	}
+	if got.Kind != s.Kind || got.Code != s.Code || got.ExitCode != s.ExitCode || !got.Time.Equal(s.Time) {
+		t.Fatalf("ReadFile() = %+v, want %+v", got, s)
+	}
+}
@@ -0,0 +1,189 @@
+// Package xwd decodes X Window Dump images, the format Xvfb keeps its
+// framebuffer in when started with -fbdir.
+package xwd
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+	"math/bits"
+)
+
+const (
+	fileVersion = 7
+	headerSize  = 25 * 4
+
+	zPixmap  = 2
+	lsbFirst = 0
+
+	colorSize = 12
+	// maxColors is the size of the largest X colormap, 16 bits of pixel.
+	maxColors = 1 << 16
+	// maxHeaderSize bounds the window name following the header.
+	maxHeaderSize = headerSize + 4096
+)
+
+// MaxDimension is the largest width or height of an image Decode accepts.
+const MaxDimension = 16384
+
+// Header is the XWD file header. All its fields are stored big-endian.
+type Header struct {
+	HeaderSize      uint32
+	FileVersion     uint32
+	PixmapFormat    uint32
+	PixmapDepth     uint32
+	PixmapWidth     uint32
+	PixmapHeight    uint32
+	XOffset         uint32
+	ByteOrder       uint32
+	BitmapUnit      uint32
+	BitmapBitOrder  uint32
+	BitmapPad       uint32
+	BitsPerPixel    uint32
+	BytesPerLine    uint32
+	VisualClass     uint32
+	RedMask         uint32
+	GreenMask       uint32
+	BlueMask        uint32
+	BitsPerRGB      uint32
+	ColormapEntries uint32
+	NColors         uint32
+	WindowWidth     uint32
+	WindowHeight    uint32
+	WindowX         uint32
+	WindowY         uint32
+	WindowBdrWidth  uint32
+}
+
+type xwdColor struct {
+	Pixel            uint32
+	Red, Green, Blue uint16
+	Flags, Pad       uint8
+}
+
+// Decode reads an XWD image in Z pixmap format, either true color with 16,
+// 24 or 32 bits per pixel or indexed through its colormap with 8.
+func Decode(r io.Reader) (image.Image, error) {
+	br := bufio.NewReader(r)
+	var h Header
+	if err := binary.Read(br, binary.BigEndian, &h); err != nil {
+		return nil, fmt.Errorf("read xwd header: %w", err)
+	}
+	if h.FileVersion != fileVersion {
+		return nil, fmt.Errorf("unsupported xwd version %d", h.FileVersion)
+	}
+	if h.HeaderSize < headerSize {
+		return nil, fmt.Errorf("invalid xwd header size %d", h.HeaderSize)
+	}
+	if h.PixmapFormat != zPixmap {
+		return nil, fmt.Errorf("unsupported xwd pixmap format %d", h.PixmapFormat)
+	}
+	if h.HeaderSize > maxHeaderSize {
+		return nil, fmt.Errorf("xwd header size %d larger than %d", h.HeaderSize, maxHeaderSize)
+	}
+	if h.NColors > maxColors {
+		return nil, fmt.Errorf("xwd colormap of %d colors larger than %d", h.NColors, maxColors)
+	}
+	width, height := int(h.PixmapWidth), int(h.PixmapHeight)
+	if width == 0 || height == 0 || width > MaxDimension || height > MaxDimension {
+		return nil, fmt.Errorf("unsupported xwd size %dx%d", h.PixmapWidth, h.PixmapHeight)
+	}
+	bpp := int(h.BitsPerPixel)
+	switch bpp {
+	case 8, 16, 24, 32:
+	default:
+		return nil, fmt.Errorf("unsupported xwd bits per pixel %d", bpp)
+	}
+	// Lines are padded to at most 64 bits.
+	if int(h.BytesPerLine) < width*bpp/8 || int(h.BytesPerLine) > (width*bpp+7)/8+8 {
+		return nil, fmt.Errorf("invalid xwd bytes per line %d", h.BytesPerLine)
+	}
+	// Whatever the header claims, the input has to hold it.
+	if l, ok := r.(interface{ Len() int }); ok {
+		need := int(h.HeaderSize) - headerSize + int(h.NColors)*colorSize + int(h.BytesPerLine)*height
+		if need > l.Len()+br.Buffered() {
+			return nil, errors.New("xwd header larger than its image")
+		}
+	}
+
+	// The window name follows the fixed part of the header.
+	if _, err := br.Discard(int(h.HeaderSize - headerSize)); err != nil {
+		return nil, fmt.Errorf("read xwd window name: %w", err)
+	}
+
+	colors := make([]xwdColor, h.NColors)
+	if err := binary.Read(br, binary.BigEndian, colors); err != nil {
+		return nil, fmt.Errorf("read xwd colormap: %w", err)
+	}
+
+	var order binary.ByteOrder = binary.BigEndian
+	if h.ByteOrder == lsbFirst {
+		order = binary.LittleEndian
+	}
+
+	var palette map[uint32]color.RGBA
+	if bpp == 8 {
+		palette = make(map[uint32]color.RGBA, len(colors))
+		for _, c := range colors {
+			palette[c.Pixel] = color.RGBA{uint8(c.Red >> 8), uint8(c.Green >> 8), uint8(c.Blue >> 8), 0xff}
+		}
+	}
+	red, green, blue := channel(h.RedMask), channel(h.GreenMask), channel(h.BlueMask)
+
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	line := make([]byte, h.BytesPerLine)
+	for y := 0; y < height; y++ {
+		if _, err := io.ReadFull(br, line); err != nil {
+			return nil, fmt.Errorf("read xwd pixels: %w", err)
+		}
+		for x := 0; x < width; x++ {
+			var px uint32
+			switch bpp {
+			case 8:
+				c := palette[uint32(line[x])]
+				img.SetRGBA(x, y, c)
+				continue
+			case 16:
+				px = uint32(order.Uint16(line[x*2:]))
+			case 24:
+				b := line[x*3 : x*3+3]
+				if order == binary.LittleEndian {
+					px = uint32(b[0]) | uint32(b[1])<<8 | uint32(b[2])<<16
+				} else {
+					px = uint32(b[2]) | uint32(b[1])<<8 | uint32(b[0])<<16
+				}
+			case 32:
+				px = order.Uint32(line[x*4:])
+			}
+			img.SetRGBA(x, y, color.RGBA{red.value(px), green.value(px), blue.value(px), 0xff})
+		}
+	}
+	return img, nil
+}
+
+// channelMask extracts a color channel from a pixel value.
+type channelMask struct {
+	mask  uint32
+	shift int
+	max   uint32
+}
+
+func channel(mask uint32) channelMask {
+	if mask == 0 {
+		return channelMask{}
+	}
+	shift := bits.TrailingZeros32(mask)
+	return channelMask{mask: mask, shift: shift, max: mask >> shift}
+}
+
+// value scales the channel to 8 bits.
+func (c channelMask) value(px uint32) uint8 {
+	if c.max == 0 {
+		return 0
+	}
+	return uint8(((px & c.mask) >> c.shift) * 0xff / c.max)
+}
@@ -0,0 +1,156 @@
+package xwd
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image/color"
+	"testing"
+)
+
+// encode builds an XWD file with the given pixel rows, stored in order.
+func encode(t *testing.T, h Header, name string, colors []xwdColor, rows [][]byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	h.FileVersion = fileVersion
+	h.PixmapFormat = zPixmap
+	h.HeaderSize = uint32(headerSize + len(name))
+	h.NColors = uint32(len(colors))
+	h.PixmapHeight = uint32(len(rows))
+	h.BytesPerLine = uint32(len(rows[0]))
+	binary.Write(&buf, binary.BigEndian, h)
+	buf.WriteString(name)
+	binary.Write(&buf, binary.BigEndian, colors)
+	for _, r := range rows {
+		buf.Write(r)
+	}
+	return buf.Bytes()
+}
+
+func TestDecode(t *testing.T) {
+	red := color.RGBA{0xff, 0, 0, 0xff}
+	green := color.RGBA{0, 0xff, 0, 0xff}
+	blue := color.RGBA{0, 0, 0xff, 0xff}
+	white := color.RGBA{0xff, 0xff, 0xff, 0xff}
+
+	tests := []struct {
+		name   string
+		header Header
+		colors []xwdColor
+		rows   [][]byte
+	}{
+		{
+			name:   "32 bits lsb first",
+			header: Header{PixmapWidth: 2, BitsPerPixel: 32, ByteOrder: 0, RedMask: 0xff0000, GreenMask: 0xff00, BlueMask: 0xff},
+			rows: [][]byte{
+				{0, 0, 0xff, 0, 0, 0xff, 0, 0},
+				{0xff, 0, 0, 0, 0xff, 0xff, 0xff, 0},
+			},
+		},
+		{
+			name:   "16 bits msb first",
+			header: Header{PixmapWidth: 2, BitsPerPixel: 16, ByteOrder: 1, RedMask: 0xf800, GreenMask: 0x07e0, BlueMask: 0x001f},
+			rows: [][]byte{
+				{0xf8, 0x00, 0x07, 0xe0, 0, 0}, // padded line
+				{0x00, 0x1f, 0xff, 0xff, 0, 0},
+			},
+		},
+		{
+			name:   "8 bits colormap",
+			header: Header{PixmapWidth: 2, BitsPerPixel: 8},
+			colors: []xwdColor{
+				{Pixel: 1, Red: 0xffff},
+				{Pixel: 2, Green: 0xffff},
+				{Pixel: 3, Blue: 0xffff},
+				{Pixel: 4, Red: 0xffff, Green: 0xffff, Blue: 0xffff},
+			},
+			rows: [][]byte{{1, 2, 0, 0}, {3, 4, 0, 0}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			img, err := Decode(bytes.NewReader(encode(t, tt.header, "Xvfb main window\x00", tt.colors, tt.rows)))
+			if err != nil {
+				t.Fatalf("Decode: %v", err)
+			}
+			if b := img.Bounds(); b.Dx() != 2 || b.Dy() != 2 {
+				t.Fatalf("bounds = %v, want 2x2", b)
+			}
+			want := [][]color.RGBA{{red, green}, {blue, white}}
+			for y, row := range want {
+				for x, c := range row {
+					if got := color.RGBAModel.Convert(img.At(x, y)); got != c {
+						t.Errorf("pixel (%d,%d) = %v, want %v", x, y, got, c)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	if _, err := Decode(bytes.NewReader([]byte("not an xwd"))); err == nil {
+		t.Errorf("Decode accepted a short file")
+	}
+	data := encode(t, Header{PixmapWidth: 1, BitsPerPixel: 12}, "", nil, [][]byte{{0, 0}})
+	if _, err := Decode(bytes.NewReader(data)); err == nil {
+		t.Errorf("Decode accepted 12 bits per pixel")
+	}
+	data = encode(t, Header{PixmapWidth: 1, BitsPerPixel: 32}, "", nil, [][]byte{{0, 0, 0, 0}})
+	if _, err := Decode(bytes.NewReader(data[:len(data)-2])); err == nil {
+		t.Errorf("Decode accepted truncated pixels")
+	}
+}
+
+// raw builds an XWD file from h as is, followed by n bytes.
+func raw(h Header, n int) []byte {
+	h.FileVersion = fileVersion
+	h.PixmapFormat = zPixmap
+	var buf bytes.Buffer
+	binary.Write(&buf, binary.BigEndian, h)
+	buf.Write(make([]byte, n))
+	return buf.Bytes()
+}
+
+func TestDecodeHostileHeaders(t *testing.T) {
+	valid := Header{HeaderSize: headerSize, PixmapWidth: 2, PixmapHeight: 2, BitsPerPixel: 32, BytesPerLine: 8}
+	with := func(fn func(h *Header)) Header {
+		h := valid
+		fn(&h)
+		return h
+	}
+	tests := []struct {
+		name   string
+		header Header
+	}{
+		{"huge colormap", with(func(h *Header) { h.NColors = 0xffffffff })},
+		{"colormap past the end", with(func(h *Header) { h.NColors = 1000 })},
+		{"huge header", with(func(h *Header) { h.HeaderSize = 0xffffffff })},
+		{"huge width", with(func(h *Header) { h.PixmapWidth = 0xffffffff; h.BytesPerLine = 0xffffffff })},
+		{"huge height", with(func(h *Header) { h.PixmapHeight = 0xffffffff })},
+		{"height past the end", with(func(h *Header) { h.PixmapHeight = 1000 })},
+		{"empty", with(func(h *Header) { h.PixmapWidth = 0 })},
+		{"huge bytes per line", with(func(h *Header) { h.BytesPerLine = 0xffffffff })},
+		{"short bytes per line", with(func(h *Header) { h.BytesPerLine = 7 })},
+	}
+	if _, err := Decode(bytes.NewReader(raw(valid, 16))); err != nil {
+		t.Fatalf("Decode(valid): %v", err)
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Decode(bytes.NewReader(raw(tt.header, 16))); err == nil {
+				t.Errorf("Decode accepted %+v", tt.header)
+			}
+		})
+	}
+}
+
+// Decode must fail, not panic or exhaust memory, on any input:
+//
+//	go test ./internal/pkg/xwd -fuzz FuzzDecode
+func FuzzDecode(f *testing.F) {
+	f.Add(raw(Header{HeaderSize: headerSize, PixmapWidth: 2, PixmapHeight: 2, BitsPerPixel: 32, BytesPerLine: 8}, 16))
+	f.Add(raw(Header{HeaderSize: headerSize, PixmapWidth: 2, PixmapHeight: 1, BitsPerPixel: 8, BytesPerLine: 2, NColors: 1}, 14))
+	f.Fuzz(func(t *testing.T, data []byte) {
+		Decode(bytes.NewReader(data))
+	})
+}